@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
@@ -24,7 +26,30 @@ type Config struct {
 	OptSrv struct {
 		Endpoint string `yaml:"endpoint" env:"OPT_SRV_ENDPOINT" env-default:"127.0.0.1"`
 		Port     string `yaml:"port" env:"OPT_SRV_PORT" env-default:"8090"`
+		// Timeout bounds an optimization request, the wait in the queue of the service included
+		Timeout time.Duration `yaml:"timeout" env:"OPT_SRV_TIMEOUT" env-default:"1h"`
 	} `yaml:"opt_srv"`
+	// Retention removes old input and result archives from the bucket, zero MaxAge or MaxCount disables the limit
+	Retention struct {
+		Enabled  bool          `yaml:"enabled" env:"RETENTION_ENABLED" env-default:"false"`
+		DryRun   bool          `yaml:"dryRun" env:"RETENTION_DRY_RUN" env-default:"false"`
+		Interval time.Duration `yaml:"interval" env:"RETENTION_INTERVAL" env-default:"1h"`
+		MaxAge   time.Duration `yaml:"maxAge" env:"RETENTION_MAX_AGE" env-default:"168h"`
+		MaxCount int           `yaml:"maxCount" env:"RETENTION_MAX_COUNT" env-default:"0"`
+		Prefixes []string      `yaml:"prefixes" env:"RETENTION_PREFIXES" env-default:"input_files_,opt_result_,opt_export_"`
+	} `yaml:"retention"`
+	// Jobs limits the finished jobs kept in memory, zero TTL or MaxFinished disables the limit
+	Jobs struct {
+		TTL         time.Duration `yaml:"ttl" env:"JOBS_TTL" env-default:"24h"`
+		MaxFinished int           `yaml:"maxFinished" env:"JOBS_MAX_FINISHED" env-default:"10000"`
+	} `yaml:"jobs"`
+	// Cache keeps the results of the succeeded jobs by the hash of their input set, algorithm and parameters,
+	// zero TTL or MaxEntries disables the limit. The TTL should be shorter than the retention MaxAge of the results.
+	Cache struct {
+		Enabled    bool          `yaml:"enabled" env:"RESULT_CACHE_ENABLED" env-default:"true"`
+		TTL        time.Duration `yaml:"ttl" env:"RESULT_CACHE_TTL" env-default:"1h"`
+		MaxEntries int           `yaml:"maxEntries" env:"RESULT_CACHE_MAX_ENTRIES" env-default:"1000"`
+	} `yaml:"cache"`
 }
 
 // ReadConfig first reads the config file at provided path, then overwrites its values with environment variables of fallbacks to default values.
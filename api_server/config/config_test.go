@@ -4,6 +4,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -27,6 +28,30 @@ func TestConfig_Environment(t *testing.T) {
 	os.Clearenv()
 }
 
+func TestConfig_RetentionDefaults(t *testing.T) {
+	cfg, err := ReadConfig("test_data/test.yml")
+	assert.NoError(t, err)
+	assert.False(t, cfg.Retention.Enabled)
+	assert.Equal(t, time.Hour, cfg.Retention.Interval)
+	assert.Equal(t, 7*24*time.Hour, cfg.Retention.MaxAge)
+	assert.Equal(t, []string{"input_files_", "opt_result_", "opt_export_"}, cfg.Retention.Prefixes)
+}
+
+func TestConfig_CacheDefaults(t *testing.T) {
+	cfg, err := ReadConfig("test_data/test.yml")
+	assert.NoError(t, err)
+	assert.True(t, cfg.Cache.Enabled)
+	assert.Equal(t, time.Hour, cfg.Cache.TTL)
+	assert.Equal(t, 1000, cfg.Cache.MaxEntries)
+}
+
+func TestConfig_JobsDefaults(t *testing.T) {
+	cfg, err := ReadConfig("test_data/test.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, 24*time.Hour, cfg.Jobs.TTL)
+	assert.Equal(t, 10000, cfg.Jobs.MaxFinished)
+}
+
 func TestConfig_NotFound(t *testing.T) {
 	cfg, err := ReadConfig("no_file.yml")
 	assert.Error(t, err)
@@ -0,0 +1,339 @@
+package datasets
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+)
+
+const (
+	// KeyPrefix is the prefix of the dataset objects in the bucket
+	KeyPrefix       = "dataset_"
+	manifestExt     = ".json"
+	jsonContentType = "application/json"
+	// maxManifestSize caps the size of a manifest read from the bucket
+	maxManifestSize = 1 << 20
+	// maxCreateAttempts caps the versions tried by Create when they are taken concurrently
+	maxCreateAttempts = 5
+)
+
+var (
+	// ErrNotFound is returned when no dataset matches the reference
+	ErrNotFound = errors.New("dataset not found")
+	// ErrInvalidName is returned when the name of a new dataset has characters other than letters, digits, '_' and '-'
+	ErrInvalidName = errors.New("invalid dataset name")
+	// ErrVersionConflict is returned when no version could be claimed for a new dataset
+	ErrVersionConflict = errors.New("no free version of dataset")
+)
+
+// namePattern is the name of a dataset, the names are used in the object keys
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// File describes a file of a dataset
+type File struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// Dataset is a named version of an input set stored in the bucket. It is stored as a tar.gz archive of the files
+// and a JSON manifest, the manifest is written last and a dataset without it does not exist.
+type Dataset struct {
+	// ID is name@version
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Version     int       `json:"version"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	// Archive is the key of the archive of the files in the bucket
+	Archive string `json:"archive"`
+	Files   []File `json:"files"`
+}
+
+// Size returns the size of the files of the dataset
+func (d *Dataset) Size() int64 {
+	var size int64
+	for _, f := range d.Files {
+		size += f.Size
+	}
+	return size
+}
+
+// Registry stores the datasets in the bucket. The versions of a name are numbered from 1
+// in the order they are created, the registry holds no state of its own.
+type Registry struct {
+	storage storage.Storage
+	log     *logger.Logger
+	// mu serializes the version claims of the registry, the other replicas are kept out by the conditional put
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+func NewRegistry(storage storage.Storage, log *logger.Logger) *Registry {
+	return &Registry{
+		storage: storage,
+		log:     log,
+		now:     time.Now,
+	}
+}
+
+// ID returns the id of the version of the named dataset
+func ID(name string, version int) string {
+	return name + "@" + strconv.Itoa(version)
+}
+
+// Create stores the entries as the next version of the named dataset
+func (r *Registry) Create(ctx context.Context, name string, description string, entries []compressor.Entry) (*Dataset, error) {
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w '%s', letters, digits, '_' and '-' are allowed", ErrInvalidName, name)
+	}
+	if len(entries) == 0 {
+		return nil, compressor.ErrNoFiles
+	}
+
+	// the archive key is unique to the upload, the version is claimed by the conditional put of the manifest
+	suffix, err := uniqueSuffix()
+	if err != nil {
+		return nil, err
+	}
+	ds := &Dataset{
+		Name:        name,
+		Description: description,
+		Archive:     KeyPrefix + name + "_" + suffix + compressor.TarGz.Ext(),
+		Files:       make([]File, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		ds.Files = append(ds.Files, File{Name: entry.Name, Size: entry.Size})
+	}
+
+	r.log.Debugf("Upload dataset '%s' archive '%s' to the bucket", name, ds.Archive)
+	archive := compressor.Reader(ctx, compressor.TarGz, entries...)
+	_, err = r.storage.Put(ctx, ds.Archive, archive, storage.PutOptions{ContentType: compressor.TarGz.ContentType()})
+	if cerr := archive.Close(); cerr != nil {
+		r.log.Warnf("error closing archive stream: %s", cerr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error uploading dataset archive '%s': %w", ds.Archive, err)
+	}
+
+	if err := r.claimVersion(ctx, ds); err != nil {
+		if derr := r.storage.Delete(context.Background(), ds.Archive); derr != nil {
+			r.log.Warnf("error removing dataset archive '%s': %s", ds.Archive, derr)
+		}
+		return nil, err
+	}
+	return ds, nil
+}
+
+// claimVersion writes the manifest of the dataset as the next version of its name. The manifest is written only
+// if its key doesn't exist, so the registries of the other replicas can't take the same version; a version taken
+// in the meantime is retried with the next one.
+func (r *Registry) claimVersion(ctx context.Context, ds *Dataset) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for attempt := 0; attempt < maxCreateAttempts; attempt++ {
+		version, err := r.nextVersion(ctx, ds.Name)
+		if err != nil {
+			return err
+		}
+		ds.ID = ID(ds.Name, version)
+		ds.Version = version
+		ds.CreatedAt = r.now().UTC()
+
+		manifest, err := json.Marshal(ds)
+		if err != nil {
+			return err
+		}
+		key := objectKey(ds.Name, version) + manifestExt
+		_, err = r.storage.Put(ctx, key, bytes.NewReader(manifest), storage.PutOptions{ContentType: jsonContentType, IfNotExists: true})
+		if errors.Is(err, storage.ErrExists) {
+			r.log.Debugf("dataset version '%s' has been created concurrently, retrying", ds.ID)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error uploading dataset manifest '%s': %w", key, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w '%s' after %d attempts", ErrVersionConflict, ds.Name, maxCreateAttempts)
+}
+
+// nextVersion returns the version after the latest one of the name. The versions are read from the manifest keys,
+// so a manifest which can't be read still holds its version.
+func (r *Registry) nextVersion(ctx context.Context, name string) (int, error) {
+	versions, err := r.versions(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	if len(versions) == 0 {
+		return 1, nil
+	}
+	return versions[len(versions)-1] + 1, nil
+}
+
+// versions lists the versions of the name in ascending order from the keys of its manifests
+func (r *Registry) versions(ctx context.Context, name string) ([]int, error) {
+	prefix := versionPrefix(name)
+	objects, err := r.storage.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int, 0, len(objects))
+	for _, object := range objects {
+		if !strings.HasSuffix(object.Key, manifestExt) {
+			continue
+		}
+		v, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(object.Key, prefix), manifestExt))
+		if err == nil && v > 0 {
+			versions = append(versions, v)
+		}
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+// List returns the datasets ordered by name and version. Manifests which can't be read are skipped.
+func (r *Registry) List(ctx context.Context) ([]Dataset, error) {
+	objects, err := r.storage.List(ctx, KeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Dataset, 0)
+	for _, object := range objects {
+		if !strings.HasSuffix(object.Key, manifestExt) {
+			continue
+		}
+		ds, err := r.manifest(ctx, object.Key)
+		if err != nil {
+			r.log.Warnf("error reading dataset manifest '%s': %s", object.Key, err)
+			continue
+		}
+		list = append(list, *ds)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Version < list[j].Version
+	})
+	return list, nil
+}
+
+// Get returns the dataset referenced by name@version or the latest version of a name. The latest version is found
+// by the manifest keys of the name, only its manifest is read.
+func (r *Registry) Get(ctx context.Context, ref string) (*Dataset, error) {
+	name, version := ref, 0
+	if i := strings.LastIndex(ref, "@"); i >= 0 {
+		v, err := strconv.Atoi(ref[i+1:])
+		if err != nil || v < 1 {
+			return nil, fmt.Errorf("%w: '%s'", ErrNotFound, ref)
+		}
+		name, version = ref[:i], v
+	}
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: '%s'", ErrNotFound, ref)
+	}
+
+	if version > 0 {
+		ds, err := r.manifest(ctx, objectKey(name, version)+manifestExt)
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%w: '%s'", ErrNotFound, ref)
+		}
+		return ds, err
+	}
+	versions, err := r.versions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	// a manifest which can't be read is skipped as List does, the version before it is the latest then
+	for i := len(versions) - 1; i >= 0; i-- {
+		key := objectKey(name, versions[i]) + manifestExt
+		ds, err := r.manifest(ctx, key)
+		if err == nil {
+			return ds, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		r.log.Warnf("error reading dataset manifest '%s': %s", key, err)
+	}
+	return nil, fmt.Errorf("%w: '%s'", ErrNotFound, ref)
+}
+
+// Extract downloads the archive of the dataset, extracts it into dir and returns the entries of its files
+func (r *Registry) Extract(ctx context.Context, ds *Dataset, dir string) ([]compressor.Entry, error) {
+	object, _, err := r.storage.Get(ctx, ds.Archive)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("%w: the archive of '%s' is gone", ErrNotFound, ds.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+	if err := compressor.Extract(ctx, object, dir); err != nil {
+		return nil, fmt.Errorf("error extracting dataset '%s': %w", ds.ID, err)
+	}
+
+	entries := make([]compressor.Entry, 0, len(ds.Files))
+	for _, f := range ds.Files {
+		entry, err := compressor.FileEntry(dir, filepath.FromSlash(f.Name))
+		if err != nil {
+			return nil, fmt.Errorf("dataset '%s': %w", ds.ID, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (r *Registry) manifest(ctx context.Context, key string) (*Dataset, error) {
+	object, _, err := r.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(object, maxManifestSize))
+	if err != nil {
+		return nil, err
+	}
+	var ds Dataset
+	if err := json.Unmarshal(data, &ds); err != nil {
+		return nil, err
+	}
+	return &ds, nil
+}
+
+// uniqueSuffix returns a random suffix of the archive keys
+func uniqueSuffix() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// objectKey returns the key of the manifest of the dataset without the extension
+func objectKey(name string, version int) string {
+	return versionPrefix(name) + strconv.Itoa(version)
+}
+
+// versionPrefix is the key prefix of the manifests of the name, the names with other characters after it
+// don't match as the version is numeric
+func versionPrefix(name string) string {
+	return KeyPrefix + name + "_v"
+}
@@ -0,0 +1,167 @@
+package datasets
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func textEntry(name string, content string) compressor.Entry {
+	return compressor.Entry{
+		Name: name,
+		Size: int64(len(content)),
+		Open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(content)), nil
+		},
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	registry := NewRegistry(storage.NewFSStorage(t.TempDir(), logger.NewTestLogger()), logger.NewTestLogger())
+
+	racks, err := registry.Create(ctx, "racks", "inventory", []compressor.Entry{textEntry("racks/r.csv", "id\n1\n")})
+	assert.NoError(t, err)
+	assert.Equal(t, "racks@1", racks.ID)
+	assert.Regexp(t, `^dataset_racks_[0-9a-f]+\.tar\.gz$`, racks.Archive)
+	assert.Equal(t, []File{{Name: "racks/r.csv", Size: 5}}, racks.Files)
+
+	racks2, err := registry.Create(ctx, "racks", "", []compressor.Entry{textEntry("r.csv", "id\n1\n2\n")})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, racks2.Version)
+	_, err = registry.Create(ctx, "devices", "", []compressor.Entry{textEntry("d.csv", "d\n")})
+	assert.NoError(t, err)
+
+	list, err := registry.List(ctx)
+	assert.NoError(t, err)
+	ids := make([]string, 0, len(list))
+	for _, d := range list {
+		ids = append(ids, d.ID)
+	}
+	assert.Equal(t, []string{"devices@1", "racks@1", "racks@2"}, ids)
+	assert.Equal(t, "inventory", list[1].Description)
+
+	ds, err := registry.Get(ctx, "racks")
+	assert.NoError(t, err)
+	assert.Equal(t, "racks@2", ds.ID)
+	ds, err = registry.Get(ctx, "racks@1")
+	assert.NoError(t, err)
+	assert.Equal(t, racks.Files, ds.Files)
+
+	for _, ref := range []string{"racks@3", "racks@x", "racks@0", "rooms", "../racks@1", ""} {
+		_, err = registry.Get(ctx, ref)
+		assert.ErrorIs(t, err, ErrNotFound, ref)
+	}
+
+	dir := t.TempDir()
+	entries, err := registry.Extract(ctx, ds, dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "racks/r.csv", entries[0].Name)
+	content, err := ioutil.ReadFile(filepath.Join(dir, "racks", "r.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "id\n1\n", string(content))
+}
+
+func TestRegistry_CreateErrors(t *testing.T) {
+	ctx := context.Background()
+	registry := NewRegistry(storage.NewFSStorage(t.TempDir(), logger.NewTestLogger()), logger.NewTestLogger())
+
+	for _, name := range []string{"", "racks@1", "a/b", "a b", strings.Repeat("x", 65)} {
+		_, err := registry.Create(ctx, name, "", []compressor.Entry{textEntry("a.csv", "a\n")})
+		assert.ErrorIs(t, err, ErrInvalidName, name)
+	}
+	_, err := registry.Create(ctx, "racks", "", nil)
+	assert.ErrorIs(t, err, compressor.ErrNoFiles)
+
+	list, err := registry.List(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+// staleList hides the manifests of the other replicas from the first lists, as if they were created concurrently
+type staleList struct {
+	storage.Storage
+	stale int
+}
+
+func (s *staleList) List(ctx context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	if s.stale > 0 {
+		s.stale--
+		return nil, nil
+	}
+	return s.Storage.List(ctx, prefix)
+}
+
+func TestRegistry_CreateConcurrently(t *testing.T) {
+	ctx := context.Background()
+	bucket := t.TempDir()
+	fs := storage.NewFSStorage(bucket, logger.NewTestLogger())
+	_, err := NewRegistry(fs, logger.NewTestLogger()).Create(ctx, "racks", "other replica", []compressor.Entry{textEntry("r.csv", "id\n")})
+	assert.NoError(t, err)
+
+	// the version taken in the meantime is not overwritten, the next one is claimed
+	store := &staleList{Storage: fs, stale: 1}
+	registry := NewRegistry(store, logger.NewTestLogger())
+	ds, err := registry.Create(ctx, "racks", "", []compressor.Entry{textEntry("r.csv", "id\n1\n")})
+	assert.NoError(t, err)
+	assert.Equal(t, "racks@2", ds.ID)
+	first, err := registry.Get(ctx, "racks@1")
+	assert.NoError(t, err)
+	assert.Equal(t, "other replica", first.Description)
+
+	// the archive is removed if no version can be claimed
+	store.stale = maxCreateAttempts
+	_, err = registry.Create(ctx, "racks", "", []compressor.Entry{textEntry("r.csv", "id\n")})
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	objects, err := fs.List(ctx, KeyPrefix)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 4)
+}
+
+// countingGet records the keys read from the storage
+type countingGet struct {
+	storage.Storage
+	keys []string
+}
+
+func (s *countingGet) Get(ctx context.Context, key string) (io.ReadCloser, storage.ObjectInfo, error) {
+	s.keys = append(s.keys, key)
+	return s.Storage.Get(ctx, key)
+}
+
+func TestRegistry_GetLatest(t *testing.T) {
+	ctx := context.Background()
+	bucket := t.TempDir()
+	store := &countingGet{Storage: storage.NewFSStorage(bucket, logger.NewTestLogger())}
+	registry := NewRegistry(store, logger.NewTestLogger())
+	for _, name := range []string{"racks", "racks", "racks_v", "devices"} {
+		_, err := registry.Create(ctx, name, "", []compressor.Entry{textEntry("r.csv", "id\n")})
+		assert.NoError(t, err)
+	}
+
+	// only the manifest of the latest version is read
+	store.keys = nil
+	ds, err := registry.Get(ctx, "racks")
+	assert.NoError(t, err)
+	assert.Equal(t, "racks@2", ds.ID)
+	assert.Equal(t, []string{"dataset_racks_v2.json"}, store.keys)
+
+	// a manifest which can't be read is skipped
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(bucket, "dataset_racks_v3.json"), []byte("{"), os.ModePerm))
+	ds, err = registry.Get(ctx, "racks")
+	assert.NoError(t, err)
+	assert.Equal(t, "racks@2", ds.ID)
+	ds, err = registry.Get(ctx, "racks_v")
+	assert.NoError(t, err)
+	assert.Equal(t, "racks_v@1", ds.ID)
+}
@@ -0,0 +1,125 @@
+package jobs
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Outcomes of a result cache lookup
+const (
+	cacheHit       = "hit"
+	cacheMiss      = "miss"
+	cacheCoalesced = "coalesced"
+)
+
+var cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "result_cache_lookups_total",
+	Help: "Result cache lookups by outcome: hit, miss or coalesced with a running job",
+}, []string{"outcome"})
+
+// Cache keeps the succeeded jobs by the key of their input set, algorithm and parameters.
+// Entries expire after the TTL, the least recently used entry is evicted when the cache is full.
+type Cache struct {
+	ttl        time.Duration
+	maxEntries int
+	// storage is checked for the result archive on a hit, the retention janitor may have removed it
+	storage storage.Storage
+
+	mu sync.Mutex
+	// lru has the most recently used entry at the front
+	lru     *list.List
+	entries map[string]*list.Element
+	now     func() time.Time
+}
+
+type cacheEntry struct {
+	key      string
+	job      Job
+	storedAt time.Time
+}
+
+// NewCache returns a cache of at most maxEntries results, zero maxEntries or TTL disables the corresponding limit
+func NewCache(ttl time.Duration, maxEntries int, storage storage.Storage) *Cache {
+	return &Cache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		storage:    storage,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// Get returns the job which produced the result of the key. Expired entries and entries
+// whose result archive is gone from the storage are dropped, a failed check of the archive is a miss.
+func (c *Cache) Get(ctx context.Context, key string) (*Job, bool) {
+	c.mu.Lock()
+	el, ok := c.entries[key]
+	if !ok {
+		c.mu.Unlock()
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if c.ttl > 0 && c.now().Sub(e.storedAt) > c.ttl {
+		c.remove(el)
+		c.mu.Unlock()
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	job := e.job
+	c.mu.Unlock()
+
+	if c.storage != nil {
+		if _, err := c.storage.Stat(ctx, job.Result.Filepath); err != nil {
+			// a failed check is a miss, only a result known to be gone drops the entry
+			if errors.Is(err, storage.ErrNotFound) {
+				c.Delete(key)
+			}
+			return nil, false
+		}
+	}
+	return &job, true
+}
+
+// Put stores the succeeded job under the key
+func (c *Cache) Put(key string, job Job) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, job: job, storedAt: c.now()})
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// Delete drops the entry of the key
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Len returns the number of entries
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *Cache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
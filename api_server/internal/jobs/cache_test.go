@@ -0,0 +1,77 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/optimization"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func cachedJob(result string) Job {
+	return Job{ID: result, Status: StatusSucceeded, Result: &optimization.Response{Filepath: result}}
+}
+
+func TestCache_Limits(t *testing.T) {
+	now := time.Now()
+	c := NewCache(time.Minute, 2, nil)
+	c.now = func() time.Time { return now }
+
+	c.Put("a", cachedJob("a"))
+	c.Put("b", cachedJob("b"))
+	_, ok := c.Get(context.Background(), "a")
+	assert.True(t, ok)
+
+	// b is the least recently used entry
+	c.Put("c", cachedJob("c"))
+	assert.Equal(t, 2, c.Len())
+	_, ok = c.Get(context.Background(), "b")
+	assert.False(t, ok)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = c.Get(context.Background(), "a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestCache_RemovedResult(t *testing.T) {
+	bucket := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(bucket, "opt_result_1.tar.gz"), []byte("result"), os.ModePerm))
+	c := NewCache(0, 0, storage.NewFSStorage(bucket, logger.NewTestLogger()))
+
+	c.Put("a", cachedJob("opt_result_1.tar.gz"))
+	job, ok := c.Get(context.Background(), "a")
+	assert.True(t, ok)
+	assert.Equal(t, "opt_result_1.tar.gz", job.Result.Filepath)
+
+	assert.NoError(t, os.Remove(filepath.Join(bucket, "opt_result_1.tar.gz")))
+	_, ok = c.Get(context.Background(), "a")
+	assert.False(t, ok)
+	assert.Zero(t, c.Len())
+}
+
+// failingStat reports every result check as failed, e.g. for a network error
+type failingStat struct {
+	storage.Storage
+}
+
+func (failingStat) Stat(ctx context.Context, key string) (storage.ObjectInfo, error) {
+	return storage.ObjectInfo{}, errors.New("connection reset")
+}
+
+func TestCache_FailedResultCheck(t *testing.T) {
+	c := NewCache(0, 0, failingStat{})
+	c.Put("a", cachedJob("opt_result_1.tar.gz"))
+
+	// the entry is kept for the next lookup
+	_, ok := c.Get(context.Background(), "a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, c.Len())
+}
@@ -0,0 +1,65 @@
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/optimization"
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
+)
+
+const (
+	// ErrCodeUnavailable is set on jobs which failed because the optimization service could not be reached
+	ErrCodeUnavailable = "unavailable"
+	// ErrCodeCanceled is set on the canceled jobs
+	ErrCodeCanceled = "canceled"
+)
+
+// Job is a snapshot of an optimization run
+type Job struct {
+	ID string
+	// Key is the hash of the input set, algorithm and parameters of a job whose result is cached
+	Key        string
+	Request    optimization.Request
+	Status     Status
+	CreatedAt  time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+	ErrorCode  string
+	Error      string
+	// Script describes the failure of the optimization script
+	Script *models.ScriptFailure
+	// Violations lists the problems found in the input files
+	Violations []models.Violation
+	Result     *optimization.Response
+	// Cached is set on the jobs which returned the cached result of an earlier job
+	Cached bool
+	// Parent is the id of the job this job is a rerun of
+	Parent string
+	// Progress is the latest progress reported by the script, nil until the script reports any
+	Progress *optimization.Progress
+}
+
+// Done reports whether the job has reached a final state
+func (j *Job) Done() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed || j.Status == StatusCanceled
+}
+
+func newID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,532 @@
+package jobs
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/optimization"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+)
+
+const (
+	// maxBusyRetries limits how many times a job is put back into the queue when the optimization service is busy
+	maxBusyRetries    = 20
+	defaultRetryAfter = 5 * time.Second
+	// defaultProgressInterval is how often the progress of a running job is queried
+	defaultProgressInterval = time.Second
+	// defaultJobTTL and defaultMaxFinished limit the finished jobs kept in memory
+	defaultJobTTL      = 24 * time.Hour
+	defaultMaxFinished = 10000
+)
+
+var (
+	ErrNotFound = errors.New("job not found")
+	// ErrFinished is returned when a job which has reached a final state is canceled
+	ErrFinished = errors.New("job has already finished")
+)
+
+// errCanceled is the error text of the canceled jobs
+const errCanceled = "job canceled"
+
+// Optimizer runs an optimization over an input archive stored in the bucket, canceling ctx stops the run
+type Optimizer interface {
+	PostOptimize(ctx context.Context, req optimization.Request) (*optimization.Response, error)
+}
+
+// ProgressOptimizer is an Optimizer which reports the progress of the runs started with a run id.
+// The progress of the running jobs is queried from it and kept on the jobs.
+type ProgressOptimizer interface {
+	Optimizer
+	// Progress returns the latest progress of the run, nil if none has been reported
+	Progress(ctx context.Context, runID string) (*optimization.Progress, error)
+}
+
+type entry struct {
+	job  Job
+	done chan struct{}
+	// cancel stops the run of the job
+	cancel context.CancelFunc
+	// shared is set once the running job has been handed out for another request with the same key
+	shared bool
+	// changed is closed and replaced on every change of the job
+	changed chan struct{}
+	// finished is the element of the job in the finished list once it has reached a final state
+	finished *list.Element
+}
+
+// Manager runs optimization jobs in the background and keeps track of their state
+type Manager struct {
+	optimizer Optimizer
+	// cache keeps the results of the keyed jobs, nil disables the cache
+	cache *Cache
+	log   *logger.Logger
+	// progressInterval is how often the progress of a running job is queried from a ProgressOptimizer
+	progressInterval time.Duration
+	// ttl is how long a finished job is kept, maxFinished how many finished jobs are kept, zero disables a limit
+	ttl         time.Duration
+	maxFinished int
+	now         func() time.Time
+
+	mu   sync.RWMutex
+	jobs map[string]*entry
+	// running maps the keys of the unfinished keyed jobs to their entries
+	running map[string]*entry
+	// finished holds the entries of the finished jobs in the order they finished, the oldest at the front
+	finished *list.List
+}
+
+func NewManager(optimizer Optimizer, cache *Cache, log *logger.Logger) *Manager {
+	return &Manager{
+		optimizer:        optimizer,
+		cache:            cache,
+		log:              log,
+		progressInterval: defaultProgressInterval,
+		ttl:              defaultJobTTL,
+		maxFinished:      defaultMaxFinished,
+		now:              time.Now,
+		jobs:             make(map[string]*entry),
+		running:          make(map[string]*entry),
+		finished:         list.New(),
+	}
+}
+
+// SetRetention limits how long and how many finished jobs are kept, zero disables a limit.
+// The oldest finished jobs are dropped first, the running jobs are always kept.
+func (m *Manager) SetRetention(ttl time.Duration, maxFinished int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ttl, m.maxFinished = ttl, maxFinished
+	m.evict()
+}
+
+// Caching reports whether the results of the keyed jobs are cached
+func (m *Manager) Caching() bool {
+	return m.cache != nil
+}
+
+// Submit registers a new job for the request and starts it in the background.
+// The returned job is in the queued state.
+func (m *Manager) Submit(req optimization.Request) (*Job, error) {
+	return m.SubmitKeyed("", req)
+}
+
+// SubmitKeyed works like Submit for the request whose input set, algorithm and parameters hash to the key.
+// If a job with the same key is still running it is returned instead of starting another one,
+// the result of the job is cached once it succeeds. An empty key or a disabled cache starts a plain job.
+func (m *Manager) SubmitKeyed(key string, req optimization.Request) (*Job, error) {
+	return m.submit(key, "", req)
+}
+
+// Rerun starts a job for the request of a rerun of the parent job. The job always runs the optimization,
+// it is not shared with a running job of the same key, its result is cached under the key once it succeeds.
+func (m *Manager) Rerun(parent string, key string, req optimization.Request) (*Job, error) {
+	return m.submit(key, parent, req)
+}
+
+func (m *Manager) submit(key string, parent string, req optimization.Request) (*Job, error) {
+	if m.cache == nil {
+		key = ""
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &entry{
+		job: Job{
+			ID:        id,
+			Key:       key,
+			Request:   req,
+			Status:    StatusQueued,
+			CreatedAt: time.Now().UTC(),
+			Parent:    parent,
+		},
+		done:    make(chan struct{}),
+		changed: make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	if running, ok := m.running[key]; ok && key != "" && parent == "" {
+		running.shared = true
+		job := running.job
+		m.mu.Unlock()
+		cacheLookups.WithLabelValues(cacheCoalesced).Inc()
+		return &job, nil
+	}
+	m.evict()
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	m.jobs[id] = e
+	// a rerun doesn't take over the key of a running job
+	if _, ok := m.running[key]; key != "" && !ok {
+		m.running[key] = e
+	}
+	job := e.job
+	m.mu.Unlock()
+
+	go m.run(ctx, e)
+
+	return &job, nil
+}
+
+// Lookup returns a job for the key without running the optimization: the running job with the same key
+// or a new finished job carrying the cached result. It returns false if neither exists or the cache is disabled.
+func (m *Manager) Lookup(ctx context.Context, key string) (*Job, bool) {
+	if m.cache == nil || key == "" {
+		return nil, false
+	}
+	m.mu.Lock()
+	running, ok := m.running[key]
+	var job Job
+	if ok {
+		running.shared = true
+		job = running.job
+	}
+	m.mu.Unlock()
+	if ok {
+		cacheLookups.WithLabelValues(cacheCoalesced).Inc()
+		return &job, true
+	}
+
+	cached, ok := m.cache.Get(ctx, key)
+	if !ok {
+		cacheLookups.WithLabelValues(cacheMiss).Inc()
+		return nil, false
+	}
+	cacheLookups.WithLabelValues(cacheHit).Inc()
+	id, err := newID()
+	if err != nil {
+		m.log.Warnf("error creating the id of a cached job: %s", err)
+		return nil, false
+	}
+	now := time.Now().UTC()
+	e := &entry{
+		job: Job{
+			ID:         id,
+			Key:        key,
+			Request:    cached.Request,
+			Status:     StatusSucceeded,
+			Cached:     true,
+			CreatedAt:  now,
+			StartedAt:  now,
+			FinishedAt: now,
+			Result:     cached.Result,
+		},
+		done:    make(chan struct{}),
+		changed: make(chan struct{}),
+	}
+	close(e.done)
+
+	m.mu.Lock()
+	m.jobs[id] = e
+	m.retire(e)
+	job = e.job
+	m.mu.Unlock()
+	m.log.Debugf("job '%s' served from the cache, result: '%s'", id, job.Result.Filepath)
+	return &job, true
+}
+
+// Get returns a snapshot of the job with the given id
+func (m *Manager) Get(id string) (*Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	e, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	job := e.job
+	return &job, nil
+}
+
+// Wait blocks until the job reaches a final state or ctx is done
+func (m *Manager) Wait(ctx context.Context, id string) (*Job, error) {
+	m.mu.RLock()
+	e, ok := m.jobs[id]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	select {
+	case <-e.done:
+		return m.Get(id)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Watch returns a snapshot of the job and a channel which is closed on its next change
+func (m *Manager) Watch(id string) (*Job, <-chan struct{}, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	e, ok := m.jobs[id]
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+	job := e.job
+	return &job, e.changed, nil
+}
+
+// Cancel stops a queued or running job, the optimization service kills its script. The job is reported
+// as canceled right away, its key is released so that the next request with the same key runs again.
+func (m *Manager) Cancel(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if e.job.Done() {
+		return nil, ErrFinished
+	}
+	m.cancel(e)
+	job := e.job
+	return &job, nil
+}
+
+// Abandon cancels the job of a request whose client has gone away, unless the job has been
+// handed out for another request too. It reports whether the job has been canceled.
+func (m *Manager) Abandon(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.jobs[id]
+	if !ok || e.shared || e.job.Done() {
+		return false
+	}
+	m.cancel(e)
+	return true
+}
+
+// cancel marks the job canceled and stops its run, the caller holds the lock
+func (m *Manager) cancel(e *entry) {
+	e.job.Status = StatusCanceled
+	e.job.FinishedAt = time.Now().UTC()
+	e.job.ErrorCode, e.job.Error = ErrCodeCanceled, errCanceled
+	if key := e.job.Key; key != "" && m.running[key] == e {
+		delete(m.running, key)
+	}
+	e.cancel()
+	m.notify(e)
+	m.retire(e)
+	m.log.Infof("job '%s' canceled", e.job.ID)
+}
+
+// Forget drops the finished jobs whose result archive is one of the keys, e.g. the results removed
+// by the retention janitor. It returns the number of dropped jobs.
+func (m *Manager) Forget(keys []string) int {
+	removed := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		removed[key] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dropped := 0
+	for el := m.finished.Front(); el != nil; {
+		next := el.Next()
+		if e := el.Value.(*entry); e.job.Result != nil && removed[e.job.Result.Filepath] {
+			m.drop(e)
+			dropped++
+		}
+		el = next
+	}
+	return dropped
+}
+
+func (m *Manager) run(ctx context.Context, e *entry) {
+	defer close(e.done)
+	defer e.cancel()
+
+	var (
+		resp *optimization.Response
+		err  error
+	)
+	req := e.job.Request
+	progress, tracked := m.optimizer.(ProgressOptimizer)
+	if tracked {
+		req.RunID = e.job.ID
+	}
+	for attempt := 0; ; attempt++ {
+		m.update(e, func(job *Job) {
+			if job.Status == StatusCanceled {
+				return
+			}
+			job.Status = StatusRunning
+			job.StartedAt = time.Now().UTC()
+		})
+		m.log.Debugf("job '%s' started, input: '%s'", e.job.ID, e.job.Request.Filename)
+
+		if tracked {
+			stop := m.trackProgress(ctx, e, progress)
+			resp, err = m.optimizer.PostOptimize(ctx, req)
+			stop()
+		} else {
+			resp, err = m.optimizer.PostOptimize(ctx, req)
+		}
+
+		var optErr *optimization.Error
+		if !errors.As(err, &optErr) || !optErr.Busy() || attempt >= maxBusyRetries {
+			break
+		}
+
+		// The optimization service queue is full, wait in our own queue and try again
+		retryAfter := optErr.RetryAfter
+		if retryAfter <= 0 {
+			retryAfter = defaultRetryAfter
+		}
+		m.log.Warnf("optimization service is busy, job '%s' is requeued for %s", e.job.ID, retryAfter)
+		m.update(e, func(job *Job) {
+			if job.Status == StatusCanceled {
+				return
+			}
+			job.Status = StatusQueued
+			job.StartedAt = time.Time{}
+		})
+		timer := time.NewTimer(retryAfter)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	canceled := false
+	m.update(e, func(job *Job) {
+		// the job has been canceled while it was running, whatever the run returned
+		if canceled = job.Status == StatusCanceled; canceled {
+			return
+		}
+		job.FinishedAt = time.Now().UTC()
+		if err != nil {
+			job.Status = StatusFailed
+			job.ErrorCode, job.Error, job.Script, job.Violations = errorDetails(err)
+			return
+		}
+		job.Status = StatusSucceeded
+		job.Result = resp
+	})
+	m.finish(e)
+
+	switch {
+	case canceled:
+		m.log.Debugf("job '%s' stopped after it was canceled", e.job.ID)
+	case err != nil:
+		m.log.Errorf("job '%s' failed: %s", e.job.ID, err)
+	default:
+		m.log.Debugf("job '%s' succeeded, result: '%s'", e.job.ID, resp.Filepath)
+	}
+}
+
+// trackProgress queries the progress of the run of the job until the returned stop function is called
+func (m *Manager) trackProgress(ctx context.Context, e *entry, optimizer ProgressOptimizer) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(m.progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+			progress, err := optimizer.Progress(ctx, e.job.ID)
+			if err != nil {
+				if ctx.Err() == nil {
+					m.log.Debugf("error querying the progress of job '%s': %s", e.job.ID, err)
+				}
+				continue
+			}
+			if progress == nil {
+				continue
+			}
+			m.update(e, func(job *Job) {
+				job.Progress = progress
+			})
+		}
+	}()
+	return func() {
+		cancel()
+		<-stopped
+	}
+}
+
+// finish caches the result of a succeeded keyed job and releases its key
+func (m *Manager) finish(e *entry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.retire(e)
+	key := e.job.Key
+	if key == "" {
+		return
+	}
+	if m.running[key] == e {
+		delete(m.running, key)
+	}
+	if e.job.Status == StatusSucceeded {
+		m.cache.Put(key, e.job)
+	}
+}
+
+// retire moves the finished job to the finished list and evicts the jobs beyond the limits, the caller holds the lock
+func (m *Manager) retire(e *entry) {
+	if e.finished != nil {
+		return
+	}
+	e.finished = m.finished.PushBack(e)
+	m.evict()
+}
+
+// evict drops the finished jobs older than the TTL and the oldest ones beyond the count limit, the caller holds the lock
+func (m *Manager) evict() {
+	now := m.now()
+	for el := m.finished.Front(); el != nil; el = m.finished.Front() {
+		e := el.Value.(*entry)
+		expired := m.ttl > 0 && now.Sub(e.job.FinishedAt) > m.ttl
+		overflow := m.maxFinished > 0 && m.finished.Len() > m.maxFinished
+		if !expired && !overflow {
+			return
+		}
+		m.drop(e)
+	}
+}
+
+// drop forgets the finished job, the caller holds the lock
+func (m *Manager) drop(e *entry) {
+	m.finished.Remove(e.finished)
+	delete(m.jobs, e.job.ID)
+}
+
+func (m *Manager) update(e *entry, fn func(job *Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(&e.job)
+	m.notify(e)
+}
+
+// notify wakes up the watchers of the job, the caller holds the lock
+func (m *Manager) notify(e *entry) {
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+func errorDetails(err error) (code string, text string, script *models.ScriptFailure, violations []models.Violation) {
+	var optErr *optimization.Error
+	if errors.As(err, &optErr) {
+		return optErr.Code, optErr.Text, optErr.Script, optErr.Violations
+	}
+	return ErrCodeUnavailable, err.Error(), nil, nil
+}
@@ -0,0 +1,401 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/optimization"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+type optimizerFunc func(req optimization.Request) (*optimization.Response, error)
+
+func (f optimizerFunc) PostOptimize(ctx context.Context, req optimization.Request) (*optimization.Response, error) {
+	return f(req)
+}
+
+// blockingOptimizer runs until the run is canceled, it reports the started runs
+type blockingOptimizer chan string
+
+func (b blockingOptimizer) PostOptimize(ctx context.Context, req optimization.Request) (*optimization.Response, error) {
+	b <- req.Filename
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestManager_Succeeded(t *testing.T) {
+	release := make(chan struct{})
+	m := NewManager(optimizerFunc(func(req optimization.Request) (*optimization.Response, error) {
+		<-release
+		return &optimization.Response{Filepath: "opt_result_1.tar.gz", Location: "loc"}, nil
+	}), nil, logger.NewTestLogger())
+
+	job, err := m.Submit(optimization.Request{Filename: "input_files_1.tar.gz"})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusQueued, job.Status)
+	assert.NotEmpty(t, job.ID)
+
+	close(release)
+	job, err = m.Wait(context.Background(), job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, job.Status)
+	assert.Equal(t, "opt_result_1.tar.gz", job.Result.Filepath)
+	assert.False(t, job.StartedAt.IsZero())
+	assert.False(t, job.FinishedAt.Before(job.StartedAt))
+}
+
+func TestManager_Failed(t *testing.T) {
+	testCases := []struct {
+		name       string
+		err        error
+		code       string
+		script     *models.ScriptFailure
+		violations []models.Violation
+	}{
+		{
+			name: "optimization server error",
+			err:  &optimization.Error{StatusCode: 500, Code: "optimize", Text: "script error"},
+			code: "optimize",
+		},
+		{
+			name: "script failure",
+			err: &optimization.Error{StatusCode: 500, Code: "input_length_mismatch", Text: "input files have different lengths",
+				Script: &models.ScriptFailure{ExitCode: 126}},
+			code:   "input_length_mismatch",
+			script: &models.ScriptFailure{ExitCode: 126},
+		},
+		{
+			name: "invalid data",
+			err: &optimization.Error{StatusCode: 400, Code: "invalid_data", Text: "invalid input data",
+				Violations: []models.Violation{{File: "racks/r.csv", Line: 3, Column: "id", Message: "'x' is not an integer"}}},
+			code:       "invalid_data",
+			violations: []models.Violation{{File: "racks/r.csv", Line: 3, Column: "id", Message: "'x' is not an integer"}},
+		},
+		{
+			name: "transport error",
+			err:  errors.New("connection refused"),
+			code: ErrCodeUnavailable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewManager(optimizerFunc(func(req optimization.Request) (*optimization.Response, error) {
+				return nil, tc.err
+			}), nil, logger.NewTestLogger())
+
+			job, err := m.Submit(optimization.Request{Filename: "input"})
+			assert.NoError(t, err)
+			job, err = m.Wait(context.Background(), job.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, StatusFailed, job.Status)
+			assert.Equal(t, tc.code, job.ErrorCode)
+			assert.Equal(t, tc.script, job.Script)
+			assert.Equal(t, tc.violations, job.Violations)
+			assert.True(t, job.Done())
+		})
+	}
+}
+
+func TestManager_NotFound(t *testing.T) {
+	m := NewManager(nil, nil, logger.NewTestLogger())
+	_, err := m.Get("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = m.Wait(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestManager_BusyRetry(t *testing.T) {
+	calls := 0
+	m := NewManager(optimizerFunc(func(req optimization.Request) (*optimization.Response, error) {
+		calls++
+		if calls == 1 {
+			return nil, &optimization.Error{StatusCode: http.StatusTooManyRequests, Code: "busy", RetryAfter: time.Millisecond}
+		}
+		return &optimization.Response{Filepath: "result"}, nil
+	}), nil, logger.NewTestLogger())
+
+	job, err := m.Submit(optimization.Request{Filename: "input"})
+	assert.NoError(t, err)
+	job, err = m.Wait(context.Background(), job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, job.Status)
+	assert.Equal(t, 2, calls)
+}
+
+func TestManager_Keyed(t *testing.T) {
+	release := make(chan struct{})
+	calls := 0
+	m := NewManager(optimizerFunc(func(req optimization.Request) (*optimization.Response, error) {
+		calls++
+		<-release
+		return &optimization.Response{Filepath: "opt_result_" + req.Filename}, nil
+	}), NewCache(time.Hour, 10, nil), logger.NewTestLogger())
+	assert.True(t, m.Caching())
+
+	_, ok := m.Lookup(context.Background(), "key")
+	assert.False(t, ok)
+	first, err := m.SubmitKeyed("key", optimization.Request{Filename: "1"})
+	assert.NoError(t, err)
+
+	// identical requests share the running job
+	running, ok := m.Lookup(context.Background(), "key")
+	assert.True(t, ok)
+	assert.Equal(t, first.ID, running.ID)
+	second, err := m.SubmitKeyed("key", optimization.Request{Filename: "2"})
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+
+	close(release)
+	job, err := m.Wait(context.Background(), first.ID)
+	assert.NoError(t, err)
+	assert.False(t, job.Cached)
+
+	// the result is cached once the job succeeds
+	cached, ok := m.Lookup(context.Background(), "key")
+	assert.True(t, ok)
+	assert.NotEqual(t, first.ID, cached.ID)
+	assert.True(t, cached.Cached)
+	assert.Equal(t, StatusSucceeded, cached.Status)
+	assert.Equal(t, "opt_result_1", cached.Result.Filepath)
+	assert.Equal(t, "1", cached.Request.Filename)
+	cached, err = m.Wait(context.Background(), cached.ID)
+	assert.NoError(t, err)
+	assert.True(t, cached.Cached)
+	assert.Equal(t, 1, calls)
+}
+
+func TestManager_KeyedFailure(t *testing.T) {
+	m := NewManager(optimizerFunc(func(req optimization.Request) (*optimization.Response, error) {
+		return nil, &optimization.Error{StatusCode: http.StatusBadRequest, Code: "optimize"}
+	}), NewCache(time.Hour, 10, nil), logger.NewTestLogger())
+
+	job, err := m.SubmitKeyed("key", optimization.Request{Filename: "1"})
+	assert.NoError(t, err)
+	_, err = m.Wait(context.Background(), job.ID)
+	assert.NoError(t, err)
+
+	_, ok := m.Lookup(context.Background(), "key")
+	assert.False(t, ok)
+
+	// without the cache the key is ignored
+	m = NewManager(nil, nil, logger.NewTestLogger())
+	assert.False(t, m.Caching())
+	_, ok = m.Lookup(context.Background(), "key")
+	assert.False(t, ok)
+}
+
+func TestManager_Rerun(t *testing.T) {
+	release := make(chan struct{})
+	m := NewManager(optimizerFunc(func(req optimization.Request) (*optimization.Response, error) {
+		<-release
+		return &optimization.Response{Filepath: "opt_result_" + req.Filename}, nil
+	}), NewCache(time.Hour, 10, nil), logger.NewTestLogger())
+
+	first, err := m.SubmitKeyed("key", optimization.Request{Filename: "1"})
+	assert.NoError(t, err)
+	assert.Empty(t, first.Parent)
+
+	// a rerun runs even if a job of the same key is running
+	rerun, err := m.Rerun(first.ID, "key", optimization.Request{Filename: "2"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.ID, rerun.ID)
+	assert.Equal(t, first.ID, rerun.Parent)
+	running, ok := m.Lookup(context.Background(), "key")
+	assert.True(t, ok)
+	assert.Equal(t, first.ID, running.ID)
+
+	close(release)
+	rerun, err = m.Wait(context.Background(), rerun.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, rerun.Status)
+	assert.Equal(t, "opt_result_2", rerun.Result.Filepath)
+	assert.Equal(t, first.ID, rerun.Parent)
+}
+
+func TestManager_Cancel(t *testing.T) {
+	started := make(blockingOptimizer, 2)
+	m := NewManager(started, NewCache(time.Hour, 10, nil), logger.NewTestLogger())
+
+	job, err := m.SubmitKeyed("key", optimization.Request{Filename: "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", <-started)
+
+	canceled, err := m.Cancel(job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCanceled, canceled.Status)
+	assert.Equal(t, ErrCodeCanceled, canceled.ErrorCode)
+	assert.False(t, canceled.FinishedAt.IsZero())
+
+	// the run stops and the job stays canceled
+	job, err = m.Wait(context.Background(), job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCanceled, job.Status)
+	assert.True(t, job.Done())
+	_, err = m.Cancel(job.ID)
+	assert.ErrorIs(t, err, ErrFinished)
+	_, err = m.Cancel("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// the key of the canceled job is released and nothing is cached
+	_, ok := m.Lookup(context.Background(), "key")
+	assert.False(t, ok)
+	next, err := m.SubmitKeyed("key", optimization.Request{Filename: "2"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, job.ID, next.ID)
+	assert.Equal(t, "2", <-started)
+
+	// a job shared with another request is not abandoned
+	shared, ok := m.Lookup(context.Background(), "key")
+	assert.True(t, ok)
+	assert.Equal(t, next.ID, shared.ID)
+	assert.False(t, m.Abandon(next.ID))
+	_, err = m.Cancel(next.ID)
+	assert.NoError(t, err)
+
+	plain, err := m.Submit(optimization.Request{Filename: "3"})
+	assert.NoError(t, err)
+	assert.Equal(t, "3", <-started)
+	assert.True(t, m.Abandon(plain.ID))
+	plain, err = m.Wait(context.Background(), plain.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCanceled, plain.Status)
+}
+
+func TestManager_CancelQueued(t *testing.T) {
+	calls := 0
+	m := NewManager(optimizerFunc(func(req optimization.Request) (*optimization.Response, error) {
+		calls++
+		return nil, &optimization.Error{StatusCode: http.StatusTooManyRequests, Code: "busy", RetryAfter: time.Hour}
+	}), nil, logger.NewTestLogger())
+
+	job, err := m.Submit(optimization.Request{Filename: "input"})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		job, err := m.Get(job.ID)
+		return err == nil && job.Status == StatusQueued && calls > 0
+	}, time.Second, time.Millisecond)
+
+	// the job waiting for a retry is canceled without another attempt
+	_, err = m.Cancel(job.ID)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	job, err = m.Wait(ctx, job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCanceled, job.Status)
+	assert.Equal(t, 1, calls)
+}
+
+// progressOptimizer runs until released and reports the progress of its run
+type progressOptimizer struct {
+	release chan struct{}
+	runID   chan string
+	percent chan float64
+}
+
+func (o *progressOptimizer) PostOptimize(ctx context.Context, req optimization.Request) (*optimization.Response, error) {
+	o.runID <- req.RunID
+	<-o.release
+	return &optimization.Response{Filepath: "result"}, nil
+}
+
+func (o *progressOptimizer) Progress(ctx context.Context, runID string) (*optimization.Progress, error) {
+	select {
+	case percent := <-o.percent:
+		return &optimization.Progress{Percent: percent, Message: "solving"}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func TestManager_Progress(t *testing.T) {
+	opt := &progressOptimizer{release: make(chan struct{}), runID: make(chan string, 1), percent: make(chan float64, 1)}
+	m := NewManager(opt, nil, logger.NewTestLogger())
+	m.progressInterval = time.Millisecond
+
+	job, err := m.Submit(optimization.Request{Filename: "input"})
+	assert.NoError(t, err)
+	// the job id is the run id of the progress queries, it is not kept in the request of the job
+	assert.Equal(t, job.ID, <-opt.runID)
+
+	job, changed, err := m.Watch(job.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, job.Progress)
+	opt.percent <- 42
+	for job.Progress == nil {
+		select {
+		case <-changed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the progress of the job is not reported")
+		}
+		job, changed, err = m.Watch(job.ID)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, &optimization.Progress{Percent: 42, Message: "solving"}, job.Progress)
+	assert.Empty(t, job.Request.RunID)
+
+	// the latest progress is kept once the job has finished
+	close(opt.release)
+	job, err = m.Wait(context.Background(), job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, job.Status)
+	assert.Equal(t, 42.0, job.Progress.Percent)
+	select {
+	case <-changed:
+	default:
+		t.Error("the watchers are not notified of the finished job")
+	}
+
+	_, _, err = m.Watch("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestManager_Retention(t *testing.T) {
+	release := make(chan struct{})
+	m := NewManager(optimizerFunc(func(req optimization.Request) (*optimization.Response, error) {
+		if req.Filename == "blocked" {
+			<-release
+		}
+		return &optimization.Response{Filepath: "opt_" + req.Filename}, nil
+	}), nil, logger.NewTestLogger())
+	defer close(release)
+	run := func(n int) *Job {
+		job, err := m.Submit(optimization.Request{Filename: strconv.Itoa(n)})
+		assert.NoError(t, err)
+		job, err = m.Wait(context.Background(), job.ID)
+		assert.NoError(t, err)
+		return job
+	}
+	first, second, third := run(1), run(2), run(3)
+	running, err := m.Submit(optimization.Request{Filename: "blocked"})
+	assert.NoError(t, err)
+
+	// the oldest finished jobs beyond the count limit are dropped
+	m.SetRetention(time.Hour, 2)
+	_, err = m.Get(first.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = m.Get(second.ID)
+	assert.NoError(t, err)
+
+	// the jobs whose result has been removed from the bucket are dropped
+	assert.Equal(t, 1, m.Forget([]string{"opt_2", "opt_unknown"}))
+	_, err = m.Get(second.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// the expired jobs are dropped, the running jobs are kept
+	m.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	m.SetRetention(time.Hour, 2)
+	_, err = m.Get(third.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = m.Get(running.ID)
+	assert.NoError(t, err)
+}
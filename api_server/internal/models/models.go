@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type (
 	// HealthResponse - a model for health check api
 	HealthResponse struct {
@@ -9,26 +11,299 @@ type (
 	// ErrorResponse - a model used for general error responses
 	ErrorResponse struct {
 		Text string `json:"text"`
+		Code string `json:"code,omitempty"`
+		// Script describes the failure of the optimization script
+		Script *ScriptFailure `json:"script,omitempty"`
+		// Violations lists the problems found in the input files
+		Violations []Violation `json:"violations,omitempty"`
+	}
+
+	// Violation - a model of a problem found in an input file, line and column are omitted for the whole file
+	Violation struct {
+		File    string `json:"file"`
+		Line    int    `json:"line,omitempty"`
+		Column  string `json:"column,omitempty"`
+		Message string `json:"message"`
+	}
+
+	// ScriptFailure - a model describing a failed script run
+	ScriptFailure struct {
+		ExitCode int `json:"exitCode"`
+		// Stderr is the tail of the script stderr
+		Stderr    string     `json:"stderr,omitempty"`
+		Exception *Exception `json:"exception,omitempty"`
+	}
+
+	// Exception - a model of the Python exception which terminated the script
+	Exception struct {
+		Type    string  `json:"type"`
+		Message string  `json:"message"`
+		Frames  []Frame `json:"frames"`
+	}
+
+	// Frame - a model of a traceback frame
+	Frame struct {
+		File     string `json:"file"`
+		Line     int    `json:"line"`
+		Function string `json:"function,omitempty"`
+		Code     string `json:"code,omitempty"`
 	}
 
 	// UploadResponse - a model used to respond to the upload API request
 	UploadResponse struct {
+		// Algorithm is the name@version of the algorithm which produced the result
+		Algorithm      string `json:"algorithm,omitempty"`
 		BucketFileName string `json:"filename"`
 		BucketLocation string `json:"location"`
 		BucketETag     string `json:"etag"`
+		// Summary is the structured result reported by the script
+		Summary *ResultSummary `json:"summary,omitempty"`
+		// Parameters of the run with the defaults applied
+		Parameters map[string]interface{} `json:"parameters,omitempty"`
+		// Cached is set when the result of an earlier run of the same input is returned
+		Cached bool `json:"cached,omitempty"`
+	}
+
+	// ResultSummary - a model of the result.json written by the optimization script
+	ResultSummary struct {
+		Objective *float64 `json:"objective,omitempty"`
+		// Status is the solver status: optimal, feasible or infeasible
+		Status     string             `json:"status,omitempty"`
+		Iterations *int64             `json:"iterations,omitempty"`
+		Warnings   []string           `json:"warnings,omitempty"`
+		KPIs       map[string]float64 `json:"kpis,omitempty"`
+	}
+
+	// AlgorithmsResponse - a model listing the algorithms of the optimization service
+	AlgorithmsResponse struct {
+		Default    string      `json:"default"`
+		Algorithms []Algorithm `json:"algorithms"`
+	}
+
+	// Algorithm - a model describing a named version of the optimization script
+	Algorithm struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		Version     string `json:"version"`
+		Description string `json:"description,omitempty"`
+		Runner      string `json:"runner"`
+		// Timeout of the script in milliseconds
+		Timeout    int64       `json:"timeout"`
+		Outputs    Outputs     `json:"outputs"`
+		Parameters []Parameter `json:"parameters,omitempty"`
+		Inputs     []InputSlot `json:"inputs,omitempty"`
+	}
+
+	// InputSlot - a model of a named input file of the algorithm
+	InputSlot struct {
+		Name       string     `json:"name"`
+		Required   bool       `json:"required,omitempty"`
+		Extensions []string   `json:"extensions,omitempty"`
+		Flag       string     `json:"flag,omitempty"`
+		CSV        *CSVSchema `json:"csv,omitempty"`
+		EqualRows  bool       `json:"equalRows,omitempty"`
+	}
+
+	// CSVSchema - a model of the schema a CSV input is validated against
+	CSVSchema struct {
+		Delimiter string      `json:"delimiter,omitempty"`
+		Columns   []CSVColumn `json:"columns,omitempty"`
+		MinRows   int         `json:"minRows,omitempty"`
+		MaxRows   int         `json:"maxRows,omitempty"`
+	}
+
+	// CSVColumn - a model of a column of a CSV input
+	CSVColumn struct {
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		Required bool   `json:"required,omitempty"`
+	}
+
+	// Parameter - a model of a run parameter accepted by the algorithm
+	Parameter struct {
+		Name        string      `json:"name"`
+		Type        string      `json:"type"`
+		Description string      `json:"description,omitempty"`
+		Required    bool        `json:"required,omitempty"`
+		Default     interface{} `json:"default,omitempty"`
+		Min         *float64    `json:"min,omitempty"`
+		Max         *float64    `json:"max,omitempty"`
+		Enum        []string    `json:"enum,omitempty"`
+		Flag        string      `json:"flag"`
+	}
+
+	// Outputs - a model of the files the algorithm packages into the result archive
+	Outputs struct {
+		Dir      string       `json:"dir,omitempty"`
+		Files    []OutputFile `json:"files,omitempty"`
+		Manifest string       `json:"manifest,omitempty"`
+	}
+
+	// OutputFile - a model of a glob pattern of the algorithm outputs
+	OutputFile struct {
+		Pattern  string `json:"pattern"`
+		Required bool   `json:"required,omitempty"`
 	}
 
 	// OptimizationRequest - a model used to form a request to the optimization service
 	OptimizationRequest struct {
 		BucketFilename string `json:"filename"`
+		Format         string `json:"format,omitempty"`
+		Algorithm      string `json:"algorithm,omitempty"`
+		// Parameters of the run, validated by the optimization service
+		Parameters map[string]interface{} `json:"parameters,omitempty"`
+		// RunID makes the optimization service keep the progress of the run for the progress queries
+		RunID string `json:"runId,omitempty"`
+	}
+
+	// ProgressResponse - a model of the latest progress reported by the script of a run or a job
+	ProgressResponse struct {
+		// Percent is between 0 and 100
+		Percent   float64   `json:"percent"`
+		Message   string    `json:"message,omitempty"`
+		UpdatedAt time.Time `json:"updatedAt"`
 	}
 
 	// OptimizationResponse - a model representing optimization service response
 	OptimizationResponse struct {
-		BucketFilename string `json:"filename"`
-		BucketLocation string `json:"location"`
-		BucketETag     string `json:"etag"`
-		ExecutionTime  int64  `json:"executionTime"`
+		Algorithm      string                 `json:"algorithm,omitempty"`
+		BucketFilename string                 `json:"filename"`
+		BucketLocation string                 `json:"location"`
+		BucketETag     string                 `json:"etag"`
+		ExecutionTime  int64                  `json:"executionTime"`
+		Summary        *ResultSummary         `json:"summary,omitempty"`
+		Parameters     map[string]interface{} `json:"parameters,omitempty"`
+	}
+
+	// JobResponse - a model describing the state of an optimization job
+	JobResponse struct {
+		ID         string                 `json:"id"`
+		Status     string                 `json:"status"`
+		Input      string                 `json:"input"`
+		Algorithm  string                 `json:"algorithm,omitempty"`
+		Parameters map[string]interface{} `json:"parameters,omitempty"`
+		CreatedAt  time.Time              `json:"createdAt"`
+		StartedAt  *time.Time             `json:"startedAt,omitempty"`
+		FinishedAt *time.Time             `json:"finishedAt,omitempty"`
+		Error      *ErrorResponse         `json:"error,omitempty"`
+		Result     *UploadResponse        `json:"result,omitempty"`
+		// ExecutionTime is the script execution time in milliseconds
+		ExecutionTime int64 `json:"executionTime,omitempty"`
+		// Cached is set when the job returned the result of an earlier run of the same input
+		Cached bool `json:"cached,omitempty"`
+		// Datasets are the stored datasets the input set was staged from as [slot=]name@version
+		Datasets []string `json:"datasets,omitempty"`
+		// Parent is the id of the job this job is a rerun of
+		Parent string `json:"parent,omitempty"`
+		// Progress is the latest progress reported by the script of the running job
+		Progress *ProgressResponse `json:"progress,omitempty"`
+	}
+
+	// DatasetResponse - a model describing a stored version of an input set
+	DatasetResponse struct {
+		// ID is name@version
+		ID          string    `json:"id"`
+		Name        string    `json:"name"`
+		Version     int       `json:"version"`
+		Description string    `json:"description,omitempty"`
+		CreatedAt   time.Time `json:"createdAt"`
+		// Size is the size of the files in bytes
+		Size  int64         `json:"size"`
+		Files []DatasetFile `json:"files"`
+	}
+
+	// DatasetFile - a model of a file of a dataset
+	DatasetFile struct {
+		Name string `json:"name"`
+		Size int64  `json:"size"`
+	}
+
+	// DatasetsResponse - a model listing the stored datasets
+	DatasetsResponse struct {
+		Datasets []DatasetResponse `json:"datasets"`
+	}
+
+	// PreviewResponse - a model of a page of the CSV files of a job result
+	PreviewResponse struct {
+		ID     string        `json:"id"`
+		Result string        `json:"result"`
+		Offset int           `json:"offset"`
+		Limit  int           `json:"limit"`
+		Files  []FilePreview `json:"files"`
+	}
+
+	// DiffResponse - a model of the difference between the results of two jobs
+	DiffResponse struct {
+		A     string     `json:"a"`
+		B     string     `json:"b"`
+		Key   []string   `json:"key,omitempty"`
+		Files []FileDiff `json:"files"`
+		KPIs  []KPIDiff  `json:"kpis,omitempty"`
+	}
+
+	// FileDiff - a model of the difference between two CSV files of the same name
+	FileDiff struct {
+		Name string `json:"name"`
+		// Status is added or removed for the files only one result has, changed or equal otherwise,
+		// error if the files can't be compared
+		Status         string        `json:"status"`
+		AddedColumns   []string      `json:"addedColumns,omitempty"`
+		RemovedColumns []string      `json:"removedColumns,omitempty"`
+		RowsA          int           `json:"rowsA"`
+		RowsB          int           `json:"rowsB"`
+		Added          int           `json:"added"`
+		Removed        int           `json:"removed"`
+		Changed        int           `json:"changed"`
+		Columns        []ColumnDelta `json:"columns,omitempty"`
+		Rows           []RowDiff     `json:"rows,omitempty"`
+		// Truncated is set when the rows list has been capped
+		Truncated bool `json:"truncated,omitempty"`
+		// Error is set when a file can't be compared
+		Error string `json:"error,omitempty"`
+	}
+
+	// ColumnDelta - a model of the change of a numeric column
+	ColumnDelta struct {
+		Column  string  `json:"column"`
+		Changed int     `json:"changed"`
+		SumA    float64 `json:"sumA"`
+		SumB    float64 `json:"sumB"`
+		Delta   float64 `json:"delta"`
+	}
+
+	// RowDiff - a model of an added, removed or changed row
+	RowDiff struct {
+		Change string            `json:"change"`
+		Key    string            `json:"key"`
+		Values map[string]string `json:"values,omitempty"`
+		Cells  []CellDiff        `json:"cells,omitempty"`
+	}
+
+	// CellDiff - a model of a changed value, delta is set for numbers
+	CellDiff struct {
+		Column string   `json:"column"`
+		A      string   `json:"a"`
+		B      string   `json:"b"`
+		Delta  *float64 `json:"delta,omitempty"`
+	}
+
+	// KPIDiff - a model of the change of a KPI of result.json, the objective is reported as the objective KPI
+	KPIDiff struct {
+		Name  string   `json:"name"`
+		A     *float64 `json:"a"`
+		B     *float64 `json:"b"`
+		Delta *float64 `json:"delta,omitempty"`
+	}
+
+	// FilePreview - a model of a page of a CSV file
+	FilePreview struct {
+		Name   string   `json:"name"`
+		Header []string `json:"header"`
+		// RowCount is the number of rows without the header which match the filters
+		RowCount int        `json:"rowCount"`
+		Rows     [][]string `json:"rows"`
+		// Error is set when the file can't be read as CSV
+		Error string `json:"error,omitempty"`
 	}
 )
 
@@ -52,9 +327,12 @@ func NewUploadResponse(bucketLocation, bucketFilename, bucketEtag string) Upload
 	}
 }
 
-func NewOptimizationRequest(filename string) OptimizationRequest {
+func NewOptimizationRequest(filename string, format string, algorithm string, parameters map[string]interface{}) OptimizationRequest {
 	return OptimizationRequest{
 		BucketFilename: filename,
+		Format:         format,
+		Algorithm:      algorithm,
+		Parameters:     parameters,
 	}
 }
 
@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
@@ -14,44 +16,113 @@ import (
 )
 
 const (
-	optUrl = "http://%s:%s/api/v1/optimize"
+	optUrl        = "http://%s:%s/api/v1/optimize"
+	algorithmsUrl = "http://%s:%s/api/v1/algorithms"
+	progressUrl   = "http://%s:%s/api/v1/runs/%s/progress"
+	// queryTimeout bounds the requests which the service answers right away
+	queryTimeout = 30 * time.Second
 )
 
 type Client struct {
-	storage storage.Storage
-	optUrl  string
+	storage       storage.Storage
+	optUrl        string
+	algorithmsUrl string
+	endpoint      string
+	port          string
+	// timeout bounds the optimization requests
+	timeout time.Duration
 	log     *logger.Logger
 }
 
+// Error is returned when the optimization service responds with an error.
+// Code holds the error category reported by the service, e.g. "download" or "optimize".
+// RetryAfter is set when the service is busy and asks to retry the request later.
+// Script describes the script failure if the script has been run.
+// Violations lists the problems found in the input files if they failed the validation.
+type Error struct {
+	StatusCode int
+	Code       string
+	Text       string
+	RetryAfter time.Duration
+	Script     *models.ScriptFailure
+	Violations []models.Violation
+}
+
+// Busy reports whether the service rejected the request because its queue is full
+func (e *Error) Busy() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("optimization server error, status: %d, code: '%s', text: '%s'", e.StatusCode, e.Code, e.Text)
+}
+
+// Request describes an optimization run of an input archive stored in the bucket
+type Request struct {
+	Filename string
+	// Format of the result archive, the optimization service default is used when empty
+	Format string
+	// Algorithm is name@version or the name of the algorithm, the optimization service default is used when empty
+	Algorithm string
+	// Parameters of the run, validated against the parameter schema of the algorithm by the optimization service
+	Parameters map[string]interface{}
+	// Datasets are the datasets the input set was staged from as [slot=]name@version, they are not sent to the service
+	Datasets []string
+	// RunID makes the service keep the progress of the run for Progress, set for a single run only
+	RunID string
+}
+
+// Progress is the latest progress reported by the script of a run
+type Progress struct {
+	// Percent is between 0 and 100
+	Percent   float64
+	Message   string
+	UpdatedAt time.Time
+}
+
 type Response struct {
+	// Algorithm is the name@version of the algorithm which produced the result
+	Algorithm     string
 	Filepath      string
 	Location      string
 	ETag          string
 	ExecutionTime time.Duration
+	// Parameters of the run with the defaults applied
+	Parameters map[string]interface{}
+	// Summary is the result.json reported by the script, nil if the script hasn't written it
+	Summary *models.ResultSummary
 }
 
-func New(storage storage.Storage, endpoint string, port string, log *logger.Logger) *Client {
+// New creates the client of the optimization service, timeout bounds a run of PostOptimize
+func New(storage storage.Storage, endpoint string, port string, timeout time.Duration, log *logger.Logger) *Client {
 	return &Client{
-		storage: storage,
-		optUrl:  fmt.Sprintf(optUrl, endpoint, port),
-		log:     log,
+		storage:       storage,
+		optUrl:        fmt.Sprintf(optUrl, endpoint, port),
+		algorithmsUrl: fmt.Sprintf(algorithmsUrl, endpoint, port),
+		endpoint:      endpoint,
+		port:          port,
+		timeout:       timeout,
+		log:           log,
 	}
 }
 
-func (c *Client) PostOptimize(filename string) (*Response, error) {
+// PostOptimize runs the optimization and waits for its result. Canceling ctx or the timeout of the client
+// aborts the request, the optimization service kills the script then.
+func (c *Client) PostOptimize(ctx context.Context, req Request) (*Response, error) {
 	var requestBody bytes.Buffer
-	optimizationRequest := models.NewOptimizationRequest(filename)
+	optimizationRequest := models.NewOptimizationRequest(req.Filename, req.Format, req.Algorithm, req.Parameters)
+	optimizationRequest.RunID = req.RunID
 
 	if err := json.NewEncoder(&requestBody).Encode(&optimizationRequest); err != nil {
 		return nil, err
 	}
 
-	request, err := http.NewRequestWithContext(context.Background(), "GET", c.optUrl, &requestBody)
+	request, err := http.NewRequestWithContext(ctx, "GET", c.optUrl, &requestBody)
 	if err != nil {
 		return nil, err
 	}
 
-	httpClient := &http.Client{}
+	httpClient := &http.Client{Timeout: c.timeout}
 	response, err := httpClient.Do(request)
 	if err != nil {
 		return nil, err
@@ -59,19 +130,97 @@ func (c *Client) PostOptimize(filename string) (*Response, error) {
 	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("optimization server error, '%s", response.Body)
+		errorResponse := models.ErrorResponse{}
+		if err := json.NewDecoder(response.Body).Decode(&errorResponse); err != nil {
+			c.log.Warnf("failed to decode optimization server error response: %s", err)
+		}
+		return nil, &Error{
+			StatusCode: response.StatusCode,
+			Code:       errorResponse.Code,
+			Text:       errorResponse.Text,
+			RetryAfter: parseRetryAfter(response.Header.Get("Retry-After")),
+			Script:     errorResponse.Script,
+			Violations: errorResponse.Violations,
+		}
 	}
 
 	optimizationResponse := models.OptimizationResponse{}
-	if err := json.NewDecoder(response.Body).Decode(&optimizationResponse); err != nil {
+	decoder := json.NewDecoder(response.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&optimizationResponse); err != nil {
 		return nil, err
 	}
 	c.log.Debugf("optimization response received, filename: '%s', location: '%s'", optimizationResponse.BucketFilename, optimizationResponse.BucketLocation)
 
 	return &Response{
+		Algorithm:     optimizationResponse.Algorithm,
 		Filepath:      optimizationResponse.BucketFilename,
 		Location:      optimizationResponse.BucketLocation,
 		ETag:          optimizationResponse.BucketETag,
-		ExecutionTime: time.Duration(optimizationResponse.ExecutionTime),
+		ExecutionTime: time.Duration(optimizationResponse.ExecutionTime) * time.Millisecond,
+		Summary:       optimizationResponse.Summary,
+		Parameters:    optimizationResponse.Parameters,
 	}, nil
 }
+
+// GetAlgorithms lists the algorithms available in the optimization service
+func (c *Client) GetAlgorithms(ctx context.Context) (*models.AlgorithmsResponse, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", c.algorithmsUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{Timeout: queryTimeout}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, &Error{StatusCode: response.StatusCode}
+	}
+
+	algorithms := models.AlgorithmsResponse{}
+	if err := json.NewDecoder(response.Body).Decode(&algorithms); err != nil {
+		return nil, err
+	}
+	return &algorithms, nil
+}
+
+// Progress returns the latest progress of the run started with the run id, nil if the script hasn't reported any
+// or the run has finished
+func (c *Client) Progress(ctx context.Context, runID string) (*Progress, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf(progressUrl, c.endpoint, c.port, url.PathEscape(runID)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{Timeout: queryTimeout}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, &Error{StatusCode: response.StatusCode}
+	}
+
+	progress := models.ProgressResponse{}
+	if err := json.NewDecoder(response.Body).Decode(&progress); err != nil {
+		return nil, err
+	}
+	return &Progress{Percent: progress.Percent, Message: progress.Message, UpdatedAt: progress.UpdatedAt}, nil
+}
+
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,37 @@
+package optimization
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_PostOptimize(t *testing.T) {
+	delay := make(chan time.Duration, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(<-delay)
+		w.Write([]byte(`{"filename":"opt_result_1.tar.gz","location":"...","etag":"1","executionTime":300}`))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	assert.NoError(t, err)
+	client := New(nil, u.Hostname(), u.Port(), 200*time.Millisecond, logger.NewTestLogger())
+
+	delay <- 50 * time.Millisecond
+	resp, err := client.PostOptimize(context.Background(), Request{Filename: "input_files_1.tar.gz"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "opt_result_1.tar.gz", resp.Filepath)
+		assert.Equal(t, 300*time.Millisecond, resp.ExecutionTime)
+	}
+
+	// the run takes longer than the timeout of the client
+	delay <- 500 * time.Millisecond
+	_, err = client.PostOptimize(context.Background(), Request{Filename: "input_files_1.tar.gz"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,145 @@
+package retention
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	deletedObjects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "retention_deleted_objects_total",
+		Help: "Number of bucket objects removed by the retention janitor",
+	}, []string{"prefix", "dry_run"})
+	deletedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "retention_deleted_bytes_total",
+		Help: "Size of bucket objects removed by the retention janitor",
+	}, []string{"prefix", "dry_run"})
+)
+
+// Policy selects the objects to be removed under a key prefix.
+// An object is removed if it is older than MaxAge or if there are MaxCount newer objects.
+// Zero values disable the corresponding limit.
+type Policy struct {
+	Prefix   string
+	MaxAge   time.Duration
+	MaxCount int
+}
+
+// Report summarizes a single sweep
+type Report struct {
+	Deleted []storage.ObjectInfo
+	Bytes   int64
+}
+
+// Janitor periodically applies retention policies to the bucket.
+// In dry-run mode the objects are only reported, nothing is deleted.
+type Janitor struct {
+	storage  storage.Storage
+	policies []Policy
+	interval time.Duration
+	dryRun   bool
+	log      *logger.Logger
+	now      func() time.Time
+	// notify is called with the keys of the objects deleted by a sweep
+	notify func(keys []string)
+}
+
+func NewJanitor(storage storage.Storage, policies []Policy, interval time.Duration, dryRun bool, log *logger.Logger) *Janitor {
+	return &Janitor{
+		storage:  storage,
+		policies: policies,
+		interval: interval,
+		dryRun:   dryRun,
+		log:      log,
+		now:      time.Now,
+	}
+}
+
+// Notify makes the janitor call fn with the keys of the objects deleted by every sweep, e.g. to forget
+// the jobs whose results are gone. Nothing is reported in dry-run mode.
+func (j *Janitor) Notify(fn func(keys []string)) {
+	j.notify = fn
+}
+
+// Run sweeps the bucket every interval until ctx is done
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.Sweep(ctx); err != nil {
+			j.log.Errorf("retention sweep failed: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep applies every policy once
+func (j *Janitor) Sweep(ctx context.Context) (*Report, error) {
+	report := &Report{}
+	if j.notify != nil && !j.dryRun {
+		defer func() {
+			keys := make([]string, 0, len(report.Deleted))
+			for _, obj := range report.Deleted {
+				keys = append(keys, obj.Key)
+			}
+			j.notify(keys)
+		}()
+	}
+	for _, policy := range j.policies {
+		if err := j.apply(ctx, policy, report); err != nil {
+			return report, err
+		}
+	}
+	j.log.Infof("retention sweep finished, objects: %d, bytes: %d, dry run: %t", len(report.Deleted), report.Bytes, j.dryRun)
+	return report, nil
+}
+
+func (j *Janitor) apply(ctx context.Context, policy Policy, report *Report) error {
+	objects, err := j.storage.List(ctx, policy.Prefix)
+	if err != nil {
+		return err
+	}
+
+	// newest first, so that the count limit keeps the latest objects
+	sort.Slice(objects, func(a, b int) bool {
+		return objects[a].LastModified.After(objects[b].LastModified)
+	})
+
+	now := j.now()
+	for i, obj := range objects {
+		expired := policy.MaxAge > 0 && now.Sub(obj.LastModified) > policy.MaxAge
+		overflow := policy.MaxCount > 0 && i >= policy.MaxCount
+		if !expired && !overflow {
+			continue
+		}
+
+		if j.dryRun {
+			j.log.Infof("retention dry run: would delete '%s', size: %d, modified: %s", obj.Key, obj.Size, obj.LastModified)
+		} else {
+			if err := j.storage.Delete(ctx, obj.Key); err != nil {
+				j.log.Errorf("retention: error deleting '%s': %s", obj.Key, err)
+				continue
+			}
+			j.log.Debugf("retention: deleted '%s', size: %d", obj.Key, obj.Size)
+		}
+
+		labels := []string{policy.Prefix, strconv.FormatBool(j.dryRun)}
+		deletedObjects.WithLabelValues(labels...).Inc()
+		deletedBytes.WithLabelValues(labels...).Add(float64(obj.Size))
+		report.Deleted = append(report.Deleted, obj)
+		report.Bytes += obj.Size
+	}
+	return nil
+}
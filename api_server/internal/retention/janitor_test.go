@@ -0,0 +1,107 @@
+package retention
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestStorage(t *testing.T, ages map[string]time.Duration) (string, storage.Storage) {
+	bucket := t.TempDir()
+	fs := storage.NewFSStorage(bucket, logger.NewTestLogger())
+	for key, age := range ages {
+		_, err := fs.Put(context.Background(), key, strings.NewReader("data"), storage.PutOptions{})
+		assert.NoError(t, err)
+		modTime := now.Add(-age)
+		assert.NoError(t, os.Chtimes(filepath.Join(bucket, key), modTime, modTime))
+	}
+	return bucket, fs
+}
+
+func keys(objects []storage.ObjectInfo) []string {
+	res := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		res = append(res, obj.Key)
+	}
+	return res
+}
+
+func TestJanitor_Sweep(t *testing.T) {
+	ages := map[string]time.Duration{
+		"input_files_1.tar.gz": 48 * time.Hour,
+		"input_files_2.tar.gz": 2 * time.Hour,
+		"opt_result_1.tar.gz":  3 * time.Hour,
+		"opt_result_2.tar.gz":  2 * time.Hour,
+		"opt_result_3.tar.gz":  1 * time.Hour,
+		"other.txt":            100 * time.Hour,
+	}
+
+	testCases := []struct {
+		name      string
+		policies  []Policy
+		dryRun    bool
+		deleted   []string
+		remaining int
+	}{
+		{
+			name:      "max age",
+			policies:  []Policy{{Prefix: "input_files_", MaxAge: 24 * time.Hour}},
+			deleted:   []string{"input_files_1.tar.gz"},
+			remaining: 5,
+		},
+		{
+			name:      "max count",
+			policies:  []Policy{{Prefix: "opt_result_", MaxCount: 1}},
+			deleted:   []string{"opt_result_2.tar.gz", "opt_result_1.tar.gz"},
+			remaining: 4,
+		},
+		{
+			name:      "dry run",
+			policies:  []Policy{{Prefix: "opt_result_", MaxCount: 1}, {Prefix: "input_files_", MaxAge: time.Hour}},
+			dryRun:    true,
+			deleted:   []string{"opt_result_2.tar.gz", "opt_result_1.tar.gz", "input_files_2.tar.gz", "input_files_1.tar.gz"},
+			remaining: 6,
+		},
+		{
+			name:      "no limits",
+			policies:  []Policy{{Prefix: "opt_result_"}},
+			deleted:   []string{},
+			remaining: 6,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, fs := newTestStorage(t, ages)
+			j := NewJanitor(fs, tc.policies, time.Hour, tc.dryRun, logger.NewTestLogger())
+			j.now = func() time.Time { return now }
+			var notified []string
+			j.Notify(func(keys []string) {
+				notified = keys
+			})
+
+			report, err := j.Sweep(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, tc.deleted, keys(report.Deleted))
+			if tc.dryRun {
+				assert.Nil(t, notified)
+			} else {
+				assert.Equal(t, tc.deleted, notified)
+			}
+			assert.Equal(t, int64(4*len(tc.deleted)), report.Bytes)
+
+			objects, err := fs.List(context.Background(), "")
+			assert.NoError(t, err)
+			assert.Len(t, objects, tc.remaining)
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
+)
+
+// convert replaces the tsv, json and xlsx files with their CSV conversions written into dir.
+// The originals are kept in the .originals directory of the input archive.
+// The header of a converted JSON file starts with the columns of the CSV schema of its slot.
+// Files which can't be converted are reported as violations.
+func (s *inputStager) convert(entries []compressor.Entry, algorithm *models.Algorithm, sheet string, dir string) ([]compressor.Entry, error) {
+	converted := make([]compressor.Entry, 0, len(entries))
+	originals := make([]compressor.Entry, 0)
+	names := make(map[string]struct{}, len(entries))
+	dataErr := &dataError{}
+	for _, entry := range entries {
+		if format := tabular.ConvertFormat(entry.Name); format != "" {
+			s.log.Debugf("Convert %s file '%s' to csv", format, entry.Name)
+			csvEntry, err := convertEntry(entry, format, slotSchema(algorithm, entry.Name), sheet, dir)
+			if err != nil {
+				violation, ok := conversionViolation(entry.Name, err)
+				if !ok {
+					return nil, fmt.Errorf("error converting '%s': %w", entry.Name, err)
+				}
+				dataErr.violations = append(dataErr.violations, violation)
+				continue
+			}
+			original := entry
+			original.Name = path.Join(tabular.OriginalsDir, entry.Name)
+			originals = append(originals, original)
+			entry = csvEntry
+		}
+		// a converted file must not clash with an uploaded one
+		if _, ok := names[entry.Name]; ok {
+			return nil, fmt.Errorf("%w: '%s'", errDuplicateFile, entry.Name)
+		}
+		names[entry.Name] = struct{}{}
+		converted = append(converted, entry)
+	}
+	if len(dataErr.violations) > 0 {
+		return nil, dataErr
+	}
+	return append(converted, originals...), nil
+}
+
+// convertEntry writes the CSV conversion of the entry into dir and returns its entry
+func convertEntry(entry compressor.Entry, format string, schema *models.CSVSchema, sheet string, dir string) (compressor.Entry, error) {
+	name := tabular.CSVName(entry.Name)
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return compressor.Entry{}, err
+	}
+	out, err := os.Create(target)
+	if err != nil {
+		return compressor.Entry{}, err
+	}
+	in, err := entry.Open()
+	if err != nil {
+		_ = out.Close()
+		return compressor.Entry{}, err
+	}
+
+	switch format {
+	case tabular.FormatTSV:
+		err = tabular.ConvertTSV(in, out)
+	case tabular.FormatJSON:
+		err = tabular.ConvertJSON(in, out, schemaColumns(schema))
+	case tabular.FormatXLSX:
+		err = convertXLSX(in, entry.Size, sheet, out)
+	}
+	if cerr := in.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return compressor.Entry{}, err
+	}
+
+	csvEntry, err := compressor.FileEntry(dir, filepath.FromSlash(name))
+	if err != nil {
+		return compressor.Entry{}, err
+	}
+	csvEntry.Name = name
+	return csvEntry, nil
+}
+
+// convertXLSX converts the workbook, it is read into memory if the upload can't be read at random
+func convertXLSX(in io.Reader, size int64, sheet string, out io.Writer) error {
+	if readerAt, ok := in.(io.ReaderAt); ok {
+		return tabular.ConvertXLSX(readerAt, size, sheet, out)
+	}
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	return tabular.ConvertXLSX(bytes.NewReader(data), int64(len(data)), sheet, out)
+}
+
+func schemaColumns(schema *models.CSVSchema) []string {
+	if schema == nil {
+		return nil
+	}
+	columns := make([]string, 0, len(schema.Columns))
+	for _, c := range schema.Columns {
+		columns = append(columns, c.Name)
+	}
+	return columns
+}
+
+// conversionViolation describes the content of the file which can't be converted, ok is false for other errors
+func conversionViolation(name string, err error) (tabular.Violation, bool) {
+	var syntaxErr *tabular.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return tabular.Violation{File: name, Line: syntaxErr.Line, Message: syntaxErr.Msg}, true
+	case errors.Is(err, tabular.ErrInvalidFile):
+		return tabular.Violation{File: name, Message: strings.TrimPrefix(err.Error(), tabular.ErrInvalidFile.Error()+": ")}, true
+	}
+	return tabular.Violation{}, false
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+)
+
+const ErrMsgAlgorithms = "failed to list the algorithms"
+
+// algorithmLister lists the algorithms of the optimization service
+type algorithmLister interface {
+	GetAlgorithms(ctx context.Context) (*models.AlgorithmsResponse, error)
+}
+
+type AlgorithmsHandler struct {
+	algorithms algorithmLister
+	log        *logger.Logger
+}
+
+func NewAlgorithmsHandler(algorithms algorithmLister, log *logger.Logger) *AlgorithmsHandler {
+	return &AlgorithmsHandler{
+		algorithms: algorithms,
+		log:        log,
+	}
+}
+
+// Algorithms
+// @Summary List the algorithms
+// @Description List the algorithms available in the optimization service and the default one
+// @ID algorithms-handler
+// @Produce  json
+// @Success 200 {object} models.AlgorithmsResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Router /v1/algorithms [get]
+func (h *AlgorithmsHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+	algorithms, err := h.algorithms.GetAlgorithms(r.Context())
+	if err != nil {
+		h.log.Errorf("error listing the algorithms: %s", err)
+		writeResponse(writer, models.NewErrorResponse(ErrMsgAlgorithms), http.StatusBadGateway, h.log)
+		return
+	}
+	writeResponse(writer, algorithms, http.StatusOK, h.log)
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/optimization"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlgorithmsHandler(t *testing.T) {
+	const algorithms = `{"default":"rack-greedy@1.2","algorithms":[{"id":"rack-greedy@1.2","name":"rack-greedy","version":"1.2","runner":"python","timeout":5000,"outputs":{"files":[{"pattern":"def_output.csv","required":true}]}}]}`
+
+	testCases := []struct {
+		name           string
+		status         int
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "listed",
+			status:         http.StatusOK,
+			expectedStatus: http.StatusOK,
+			expectedBody:   algorithms,
+		},
+		{
+			name:           "optimization service error",
+			status:         http.StatusInternalServerError,
+			expectedStatus: http.StatusBadGateway,
+			expectedBody:   fmt.Sprintf(`{"text":"%s"}`, ErrMsgAlgorithms),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			optSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/api/v1/algorithms", r.URL.Path)
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(algorithms))
+			}))
+			defer optSrv.Close()
+			u, err := url.Parse(optSrv.URL)
+			assert.NoError(t, err)
+			client := optimization.New(nil, u.Hostname(), u.Port(), time.Minute, logger.NewTestLogger())
+
+			req, err := http.NewRequestWithContext(context.Background(), "GET", "/api/v1/algorithms", nil)
+			assert.NoError(t, err)
+			r := httptest.NewRecorder()
+			NewAlgorithmsHandler(client, logger.NewTestLogger()).ServeHTTP(r, req)
+
+			assert.Equal(t, tc.expectedStatus, r.Code)
+			assert.JSONEq(t, tc.expectedBody, r.Body.String())
+		})
+	}
+}
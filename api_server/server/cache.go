@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
+)
+
+// Numeric parameter types of the algorithm schemas
+const (
+	paramTypeInteger = "integer"
+	paramTypeNumber  = "number"
+)
+
+// errUnresolvedAlgorithm is returned when the algorithm of the request can't be resolved to a version
+var errUnresolvedAlgorithm = errors.New("the algorithm can't be resolved")
+
+// noCache reports whether the nocache query parameter bypasses the result cache
+func noCache(r *http.Request) bool {
+	value := r.URL.Query().Get("nocache")
+	if value == "" {
+		return false
+	}
+	skip, err := strconv.ParseBool(value)
+	return err != nil || skip
+}
+
+// key hashes the input set together with the version of the algorithm, the parameters and the result format.
+// The files are hashed by name and content in name order after the conversion to CSV. The CSV files are hashed
+// normalized, so the same data saved with a byte order mark, CRLF line endings or ';' delimiters shares the key.
+// The originals of the converted files are skipped, an xlsx upload and its CSV share the key as well.
+func (in *stagedInput) key(ctx context.Context) (string, error) {
+	algorithm := in.algorithm
+	if algorithm == nil {
+		algorithm = in.stager.algorithm(ctx, in.ref)
+	}
+	if algorithm == nil {
+		return "", errUnresolvedAlgorithm
+	}
+	format := in.format
+	if format == "" {
+		format = string(compressor.TarGz)
+	}
+	// the parameters are marshalled with sorted keys after the defaults have been applied
+	params, err := json.Marshal(normalizeParameters(algorithm.Parameters, in.params))
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	writeField(h, algorithm.ID)
+	writeField(h, format)
+	writeField(h, string(params))
+
+	entries := append([]compressor.Entry(nil), in.entries...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	for _, entry := range entries {
+		if isOriginal(entry.Name) {
+			continue
+		}
+		sum, err := entrySum(entry, slotSchema(algorithm, entry.Name))
+		if err != nil {
+			return "", err
+		}
+		writeField(h, entry.Name)
+		writeField(h, sum)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// normalizeParameters applies the defaults of the algorithm schema and writes the numeric parameters
+// in a canonical form, so that a run hashes to the same key however its parameters were sent.
+// The parameters unknown to the schema are kept as sent, the optimization service rejects them.
+func normalizeParameters(schema []models.Parameter, params map[string]interface{}) map[string]interface{} {
+	values := make(map[string]interface{}, len(params)+len(schema))
+	for name, value := range params {
+		values[name] = value
+	}
+	for _, p := range schema {
+		value, ok := values[p.Name]
+		if !ok || value == nil {
+			if p.Default == nil {
+				delete(values, p.Name)
+				continue
+			}
+			value = p.Default
+		}
+		if p.Type == paramTypeInteger || p.Type == paramTypeNumber {
+			value = canonicalNumber(value)
+		}
+		values[p.Name] = value
+	}
+	return values
+}
+
+// canonicalNumber writes the number the same way whether it was sent as 1, 1.0 or 1e0,
+// other values are returned as they are
+func canonicalNumber(value interface{}) interface{} {
+	var f float64
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return json.Number(strconv.FormatInt(i, 10))
+		}
+		parsed, err := v.Float64()
+		if err != nil {
+			return value
+		}
+		f = parsed
+	case float64:
+		f = v
+	default:
+		return value
+	}
+	if i := int64(f); float64(i) == f {
+		return json.Number(strconv.FormatInt(i, 10))
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+// writeField writes the value prefixed with its length, so that the fields can't run into each other
+func writeField(h hash.Hash, value string) {
+	fmt.Fprintf(h, "%d:%s", len(value), value)
+}
+
+// entrySum hashes the content of the entry, a CSV file is hashed as the normalized output of its validation.
+// A CSV file which can't be read as CSV is hashed as it is.
+func entrySum(entry compressor.Entry, slot *models.CSVSchema) (string, error) {
+	if strings.EqualFold(path.Ext(entry.Name), ".csv") {
+		// the columns don't change the normalized output, only the delimiter of the slot is used
+		schema := tabular.Schema{}
+		if slot != nil {
+			schema.Delimiter = csvSchema(slot).Delimiter
+		}
+		h := sha256.New()
+		report, err := hashEntry(entry, func(r io.Reader) (*tabular.Report, error) {
+			return schema.Validate(entry.Name, r, h)
+		})
+		if err != nil {
+			return "", err
+		}
+		if report.Valid() {
+			return hex.EncodeToString(h.Sum(nil)), nil
+		}
+	}
+
+	h := sha256.New()
+	if _, err := hashEntry(entry, func(r io.Reader) (*tabular.Report, error) {
+		_, err := io.Copy(h, r)
+		return nil, err
+	}); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashEntry opens the entry and passes its content to fn
+func hashEntry(entry compressor.Entry, fn func(r io.Reader) (*tabular.Report, error)) (*tabular.Report, error) {
+	file, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	report, err := fn(file)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing '%s': %w", entry.Name, err)
+	}
+	return report, nil
+}
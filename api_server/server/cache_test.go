@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/jobs"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/optimization"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInputStager_Submit(t *testing.T) {
+	bucket := t.TempDir()
+	fs := storage.NewFSStorage(bucket, logger.NewTestLogger())
+	list := &models.AlgorithmsResponse{Default: "rack-greedy@1.2", Algorithms: []models.Algorithm{
+		{ID: "rack-greedy@1.2", Name: "rack-greedy", Version: "1.2"},
+		{ID: "rack-milp@2.0", Name: "rack-milp", Version: "2.0"},
+		{ID: "rack-anneal@1.0", Name: "rack-anneal", Version: "1.0", Parameters: []models.Parameter{
+			{Name: "seed", Type: "integer", Default: float64(42)},
+			{Name: "ratio", Type: "number"},
+		}},
+	}}
+	stager := newInputStager(fs, algorithmsFunc(func(ctx context.Context) (*models.AlgorithmsResponse, error) {
+		return list, nil
+	}), nil, logger.NewTestLogger())
+
+	runs := 0
+	m := jobs.NewManager(optimizerFunc(func(req optimization.Request) (*optimization.Response, error) {
+		runs++
+		result := "opt_result_" + strconv.Itoa(runs) + ".tar.gz"
+		if err := ioutil.WriteFile(filepath.Join(bucket, result), []byte("result"), os.ModePerm); err != nil {
+			return nil, err
+		}
+		return &optimization.Response{Filepath: result}, nil
+	}), jobs.NewCache(time.Hour, 10, fs), logger.NewTestLogger())
+
+	submit := func(url string, fields map[string]string, content string) *jobs.Job {
+		r := newUploadRequest(t, fields, testUpload{name: "a.csv", content: []byte(content)})
+		r.URL.RawQuery = url
+		job, err := stager.submit(r, m)
+		assert.NoError(t, err)
+		job, err = m.Wait(context.Background(), job.ID)
+		assert.NoError(t, err)
+		return job
+	}
+
+	first := submit("", nil, "a\n1\n")
+	assert.False(t, first.Cached)
+	assert.Equal(t, "opt_result_1.tar.gz", first.Result.Filepath)
+
+	// the default algorithm is resolved, naming it hits the same entry
+	cached := submit("", map[string]string{"algorithm": "rack-greedy"}, "a\n1\n")
+	assert.True(t, cached.Cached)
+	assert.Equal(t, "opt_result_1.tar.gz", cached.Result.Filepath)
+	assert.Equal(t, first.Request.Filename, cached.Request.Filename)
+
+	for name, tc := range map[string]struct {
+		query   string
+		fields  map[string]string
+		content string
+	}{
+		"content":    {content: "a\n2\n"},
+		"algorithm":  {fields: map[string]string{"algorithm": "rack-milp"}, content: "a\n1\n"},
+		"parameters": {fields: map[string]string{"parameters": `{"seed": 1}`}, content: "a\n1\n"},
+		"format":     {fields: map[string]string{"format": "zip"}, content: "a\n1\n"},
+		"nocache":    {query: "nocache=1", content: "a\n1\n"},
+	} {
+		job := submit(tc.query, tc.fields, tc.content)
+		assert.False(t, job.Cached, name)
+	}
+	assert.Equal(t, 6, runs)
+
+	// the parameters are hashed with the defaults applied and the numbers in a canonical form
+	anneal := submit("", map[string]string{"algorithm": "rack-anneal"}, "a\n1\n")
+	assert.False(t, anneal.Cached)
+	for _, params := range []string{`{"seed": 42}`, `{"seed": 42.0, "ratio": null}`, `{"seed": 4.2e1}`} {
+		job := submit("", map[string]string{"algorithm": "rack-anneal", "parameters": params}, "a\n1\n")
+		assert.True(t, job.Cached, params)
+	}
+	job := submit("", map[string]string{"algorithm": "rack-anneal", "parameters": `{"ratio": 0.50}`}, "a\n1\n")
+	assert.False(t, job.Cached)
+	job = submit("", map[string]string{"algorithm": "rack-anneal", "parameters": `{"ratio": 0.5, "seed": 42}`}, "a\n1\n")
+	assert.True(t, job.Cached)
+	assert.Equal(t, 8, runs)
+
+	// a removed result is not served from the cache
+	assert.NoError(t, os.Remove(filepath.Join(bucket, "opt_result_1.tar.gz")))
+	job = submit("", nil, "a\n1\n")
+	assert.False(t, job.Cached)
+	assert.Equal(t, 9, runs)
+}
+
+func TestInputStager_SubmitUnresolvedAlgorithm(t *testing.T) {
+	stager := newInputStager(storage.NewFSStorage(t.TempDir(), logger.NewTestLogger()), nil, nil, logger.NewTestLogger())
+	runs := 0
+	m := jobs.NewManager(optimizerFunc(func(req optimization.Request) (*optimization.Response, error) {
+		runs++
+		return &optimization.Response{Filepath: "opt_result_1.tar.gz"}, nil
+	}), jobs.NewCache(time.Hour, 10, nil), logger.NewTestLogger())
+
+	// without the algorithm list the version is unknown and the cache is skipped
+	for i := 0; i < 2; i++ {
+		job, err := stager.submit(newUploadRequest(t, nil, testUpload{name: "a.csv", content: []byte("a\n")}), m)
+		assert.NoError(t, err)
+		job, err = m.Wait(context.Background(), job.ID)
+		assert.NoError(t, err)
+		assert.False(t, job.Cached)
+	}
+	assert.Equal(t, 2, runs)
+}
+
+func TestStagedInput_KeyNormalized(t *testing.T) {
+	list := &models.AlgorithmsResponse{Default: "rack-greedy@1.2", Algorithms: []models.Algorithm{
+		{ID: "rack-greedy@1.2", Name: "rack-greedy", Version: "1.2", Inputs: []models.InputSlot{
+			{Name: "racks", CSV: &models.CSVSchema{Columns: []models.CSVColumn{{Name: "id"}}}},
+		}},
+	}}
+	stager := newInputStager(storage.NewFSStorage(t.TempDir(), logger.NewTestLogger()), algorithmsFunc(func(ctx context.Context) (*models.AlgorithmsResponse, error) {
+		return list, nil
+	}), nil, logger.NewTestLogger())
+
+	key := func(files ...testUpload) string {
+		input, err := stager.prepare(newUploadRequest(t, nil, files...))
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		defer input.close()
+		key, err := input.key(context.Background())
+		assert.NoError(t, err)
+		return key
+	}
+
+	plain := key(testUpload{name: "a.csv", content: []byte("a,b\n1,2\n")}, testUpload{name: "r.csv", content: []byte("id,n\n1,x\n"), field: "racks"})
+	assert.Equal(t, plain, key(
+		testUpload{name: "a.csv", content: []byte("\xef\xbb\xbfa;b\r\n1;2\r\n")},
+		testUpload{name: "r.csv", content: []byte("\xef\xbb\xbfid;n\r\n1;x\r\n"), field: "racks"},
+	))
+	// the workbook is hashed as its CSV conversion, its original is skipped
+	assert.Equal(t, plain, key(
+		testUpload{name: "a.csv", content: []byte("a,b\n1,2\n")},
+		testUpload{name: "r.xlsx", content: newXLSX(t, []string{"id", "n"}, []string{"1", "x"}), field: "racks"},
+	))
+	assert.NotEqual(t, plain, key(testUpload{name: "a.csv", content: []byte("a,b\n1,3\n")}, testUpload{name: "r.csv", content: []byte("id,n\n1,x\n"), field: "racks"}))
+}
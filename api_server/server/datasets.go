@@ -0,0 +1,303 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/datasets"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
+)
+
+// combinedDir is the directory of the slot files combined from several datasets in the datasets dir
+const combinedDir = "combined"
+
+// datasetRef is a stored dataset referenced by the request, its file is staged into the slot if it is set
+type datasetRef struct {
+	slot    string
+	dataset *datasets.Dataset
+}
+
+func (ref datasetRef) String() string {
+	if ref.slot == "" {
+		return ref.dataset.ID
+	}
+	return ref.slot + "=" + ref.dataset.ID
+}
+
+// slotted reports whether the dataset fills input slots, either its own slot or the slot folders of its files
+func (ref datasetRef) slotted() bool {
+	if ref.slot != "" {
+		return true
+	}
+	for _, f := range ref.dataset.Files {
+		if slot, _ := path.Split(f.Name); slot != "" && !isOriginal(f.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// datasetRefs resolves the dataset form fields of the request, [slot=]name@version or [slot=]name for the latest version
+func (s *inputStager) datasetRefs(r *http.Request) ([]datasetRef, error) {
+	values := r.PostForm[datasetField]
+	if len(values) == 0 {
+		return nil, nil
+	}
+	if s.datasets == nil {
+		return nil, fmt.Errorf("%w: the datasets are not available", datasets.ErrNotFound)
+	}
+	refs := make([]datasetRef, 0, len(values))
+	for _, value := range values {
+		slot, id := "", strings.TrimSpace(value)
+		if i := strings.Index(id, "="); i >= 0 {
+			slot, id = strings.TrimSpace(id[:i]), strings.TrimSpace(id[i+1:])
+			if !slotName.MatchString(slot) {
+				return nil, fmt.Errorf("%w '%s' of dataset '%s'", errInputSlot, slot, id)
+			}
+		}
+		ds, err := s.datasets.Get(r.Context(), id)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, datasetRef{slot: slot, dataset: ds})
+	}
+	return refs, nil
+}
+
+// datasetEntries extracts the referenced datasets into dir and returns their files. The files of a dataset
+// referenced with a slot are staged as <slot>/<filename>, the dataset must have a single file besides the originals.
+// The rows of several datasets referenced with the same slot are combined into the file of the first one.
+func (s *inputStager) datasetEntries(ctx context.Context, refs []datasetRef, algorithm *models.Algorithm, dir string) ([]compressor.Entry, error) {
+	shared := make(map[string]int)
+	for _, ref := range refs {
+		if ref.slot != "" {
+			shared[ref.slot]++
+		}
+	}
+	combined := make(map[string][]slotInput)
+
+	entries := make([]compressor.Entry, 0)
+	for i, ref := range refs {
+		s.log.Debugf("Stage dataset '%s'", ref)
+		target := filepath.Join(dir, strconv.Itoa(i))
+		if err := os.MkdirAll(target, os.ModePerm); err != nil {
+			return nil, err
+		}
+		files, err := s.datasets.Extract(ctx, ref.dataset, target)
+		if err != nil {
+			return nil, err
+		}
+		if ref.slot != "" {
+			if files, err = slotEntries(ref, files); err != nil {
+				return nil, err
+			}
+		}
+		if shared[ref.slot] < 2 {
+			entries = append(entries, files...)
+			continue
+		}
+
+		// the originals of the combined files are dropped, they don't match the combined file
+		for _, f := range files {
+			if !isOriginal(f.Name) {
+				combined[ref.slot] = append(combined[ref.slot], slotInput{ref: ref, entry: f})
+			}
+		}
+		if len(combined[ref.slot]) == shared[ref.slot] {
+			s.log.Debugf("Combine %d datasets into slot '%s'", shared[ref.slot], ref.slot)
+			var comma rune
+			if schema := slotSchema(algorithm, ref.slot+"/"); schema != nil {
+				comma = csvSchema(schema).Delimiter
+			}
+			entry, err := combineEntries(combined[ref.slot], comma, filepath.Join(dir, combinedDir))
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// slotInput is the file of a dataset referenced with a slot
+type slotInput struct {
+	ref   datasetRef
+	entry compressor.Entry
+}
+
+// combineEntries writes the rows of the CSV files one after another into dir, the header is written once.
+// The file is named after the first file, the files must have the same header. They are read with comma
+// or the detected delimiter and written with comma or ',', so the combined file matches the schema of the slot.
+func combineEntries(inputs []slotInput, comma rune, dir string) (compressor.Entry, error) {
+	name := inputs[0].entry.Name
+	for _, input := range inputs {
+		if !strings.EqualFold(path.Ext(input.entry.Name), ".csv") {
+			return compressor.Entry{}, fmt.Errorf("%w '%s', only the CSV files of several datasets can be combined, dataset '%s' has '%s'",
+				errInputSlot, input.ref.slot, input.ref.dataset.ID, path.Base(input.entry.Name))
+		}
+	}
+
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return compressor.Entry{}, err
+	}
+	out, err := os.Create(target)
+	if err != nil {
+		return compressor.Entry{}, err
+	}
+	writer := csv.NewWriter(out)
+	if comma != 0 {
+		writer.Comma = comma
+	}
+	var header []string
+	for _, input := range inputs {
+		if header, err = appendRows(writer, input, header, comma); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		writer.Flush()
+		err = writer.Error()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return compressor.Entry{}, err
+	}
+
+	entry, err := compressor.FileEntry(dir, filepath.FromSlash(name))
+	if err != nil {
+		return compressor.Entry{}, err
+	}
+	entry.Name = name
+	return entry, nil
+}
+
+// appendRows writes the records of the file to the writer, its header only if it is the first file.
+// It returns the header of the combined file, the header of the file must match it.
+func appendRows(writer *csv.Writer, input slotInput, header []string, comma rune) ([]string, error) {
+	file, err := input.entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader, err := tabular.NewReader(file, comma)
+	if err != nil {
+		return nil, err
+	}
+
+	// the problems of the content are reported with the dataset, the combined files may have the same name
+	invalid := func(line int, msg string) error {
+		return &dataError{violations: []tabular.Violation{{File: input.entry.Name, Line: line,
+			Message: fmt.Sprintf("dataset '%s': %s", input.ref.dataset.ID, msg)}}}
+	}
+	read := func() ([]string, error) {
+		record, _, err := reader.Read()
+		var syntaxErr *tabular.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, invalid(syntaxErr.Line, syntaxErr.Msg)
+		}
+		return record, err
+	}
+
+	first, err := read()
+	if err == io.EOF {
+		return nil, invalid(0, "empty file, a header is expected")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		header = first
+		if err := writer.Write(header); err != nil {
+			return nil, err
+		}
+	} else if !sameHeader(header, first) {
+		return nil, invalid(1, fmt.Sprintf("the header '%s' differs from '%s' of the other datasets of the slot",
+			strings.Join(first, ","), strings.Join(header, ",")))
+	}
+
+	for {
+		record, err := read()
+		if err == io.EOF {
+			return header, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sameHeader compares the column names of the headers, the spaces around the names are ignored
+func sameHeader(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if strings.TrimSpace(a[i]) != strings.TrimSpace(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// slotEntries moves the files of the dataset into its slot, the originals of the converted files into the slot of the originals folder
+func slotEntries(ref datasetRef, files []compressor.Entry) ([]compressor.Entry, error) {
+	inputs := 0
+	for i, f := range files {
+		if isOriginal(f.Name) {
+			files[i].Name = path.Join(tabular.OriginalsDir, ref.slot, path.Base(f.Name))
+			continue
+		}
+		files[i].Name = path.Join(ref.slot, path.Base(f.Name))
+		inputs++
+	}
+	if inputs != 1 {
+		return nil, fmt.Errorf("%w '%s', dataset '%s' has %d files", errInputSlot, ref.slot, ref.dataset.ID, inputs)
+	}
+	return files, nil
+}
+
+// mergeEntries adds the files of the datasets to the uploaded ones. The names must be unique
+// and every slot must still have a single file.
+func mergeEntries(uploaded []compressor.Entry, stored []compressor.Entry) ([]compressor.Entry, error) {
+	entries := append(uploaded, stored...)
+	names := make(map[string]struct{}, len(entries))
+	slots := make(map[string]struct{})
+	for _, entry := range entries {
+		if _, ok := names[entry.Name]; ok {
+			return nil, fmt.Errorf("%w: '%s'", errDuplicateFile, entry.Name)
+		}
+		names[entry.Name] = struct{}{}
+
+		slot, _ := path.Split(entry.Name)
+		if slot == "" || isOriginal(entry.Name) {
+			continue
+		}
+		if _, ok := slots[slot]; ok {
+			return nil, fmt.Errorf("%w '%s', a slot takes a single file", errInputSlot, strings.TrimSuffix(slot, "/"))
+		}
+		slots[slot] = struct{}{}
+	}
+	return entries, nil
+}
+
+// isOriginal reports whether the file is an uploaded file kept next to its CSV conversion
+func isOriginal(name string) bool {
+	return strings.HasPrefix(name, tabular.OriginalsDir+"/")
+}
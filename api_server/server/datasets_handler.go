@@ -0,0 +1,164 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/datasets"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/gorilla/mux"
+)
+
+const (
+	ErrMsgDatasetName     = "invalid dataset name, letters, digits, '_' and '-' are allowed"
+	ErrMsgDatasetNotFound = "dataset not found"
+	ErrMsgDatasets        = "failed to list the datasets"
+	ErrMsgDatasetUpload   = "failed to store the dataset"
+)
+
+type CreateDatasetHandler struct {
+	stager   *inputStager
+	datasets *datasets.Registry
+	log      *logger.Logger
+}
+
+func NewCreateDatasetHandler(stager *inputStager, datasets *datasets.Registry, log *logger.Logger) *CreateDatasetHandler {
+	return &CreateDatasetHandler{
+		stager:   stager,
+		datasets: datasets,
+		log:      log,
+	}
+}
+
+// Create dataset
+// @Summary Store a named input set
+// @Description Store the uploaded files as the next version of the named dataset and return its id, name@version.
+// @Description The files are expanded, converted and validated as the files of a job and can be referenced by the jobs
+// @Description in the dataset form field instead of being uploaded again.
+// @ID create-dataset-handler
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param   name formData string true "dataset name, letters, digits, '_' and '-'"
+// @Param   description formData string false "description of the dataset"
+// @Param   file formData file false "input files or a single zip, tar.gz, tar.zst or tar archive"
+// @Param   {slot} formData file false "the file of an input slot of the algorithm, e.g. racks"
+// @Param   algorithm formData string false "algorithm name@version or name, the slot files are validated against its schemas"
+// @Param   sheet formData string false "sheet of the uploaded xlsx files, the first sheet by default"
+// @Success 201 {object} models.DatasetResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /v1/datasets [post]
+func (h *CreateDatasetHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+	input, err := h.stager.prepare(r)
+	if err != nil {
+		writeStageError(writer, err, h.log)
+		return
+	}
+	defer input.close()
+
+	ds, err := h.datasets.Create(r.Context(), strings.TrimSpace(r.FormValue("name")), strings.TrimSpace(r.FormValue("description")), input.entries)
+	if errors.Is(err, datasets.ErrInvalidName) {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgDatasetName), http.StatusBadRequest, h.log)
+		return
+	}
+	if err != nil {
+		h.log.Errorf("error storing the dataset: %s", err)
+		writeResponse(writer, models.NewErrorResponse(ErrMsgDatasetUpload), http.StatusInternalServerError, h.log)
+		return
+	}
+
+	writeResponse(writer, newDatasetResponse(ds), http.StatusCreated, h.log)
+}
+
+type DatasetsHandler struct {
+	datasets *datasets.Registry
+	log      *logger.Logger
+}
+
+func NewDatasetsHandler(datasets *datasets.Registry, log *logger.Logger) *DatasetsHandler {
+	return &DatasetsHandler{
+		datasets: datasets,
+		log:      log,
+	}
+}
+
+// Datasets
+// @Summary List the datasets
+// @Description List the stored datasets ordered by name and version with their files
+// @ID datasets-handler
+// @Produce  json
+// @Param   name query string false "list the versions of a single dataset"
+// @Success 200 {object} models.DatasetsResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /v1/datasets [get]
+func (h *DatasetsHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+	list, err := h.datasets.List(r.Context())
+	if err != nil {
+		h.log.Errorf("error listing the datasets: %s", err)
+		writeResponse(writer, models.NewErrorResponse(ErrMsgDatasets), http.StatusInternalServerError, h.log)
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	resp := models.DatasetsResponse{Datasets: make([]models.DatasetResponse, 0, len(list))}
+	for i := range list {
+		if name == "" || list[i].Name == name {
+			resp.Datasets = append(resp.Datasets, newDatasetResponse(&list[i]))
+		}
+	}
+	writeResponse(writer, resp, http.StatusOK, h.log)
+}
+
+type DatasetHandler struct {
+	datasets *datasets.Registry
+	log      *logger.Logger
+}
+
+func NewDatasetHandler(datasets *datasets.Registry, log *logger.Logger) *DatasetHandler {
+	return &DatasetHandler{
+		datasets: datasets,
+		log:      log,
+	}
+}
+
+// Dataset
+// @Summary Describe a dataset
+// @Description Describe the dataset referenced by name@version or the latest version of a name
+// @ID dataset-handler
+// @Produce  json
+// @Param   id path string true "dataset name@version or name"
+// @Success 200 {object} models.DatasetResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /v1/datasets/{id} [get]
+func (h *DatasetHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+	ds, err := h.datasets.Get(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, datasets.ErrNotFound) {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgDatasetNotFound), http.StatusNotFound, h.log)
+		return
+	}
+	if err != nil {
+		h.log.Errorf("error reading the dataset: %s", err)
+		writeResponse(writer, models.NewErrorResponse(ErrMsgInternal), http.StatusInternalServerError, h.log)
+		return
+	}
+
+	writeResponse(writer, newDatasetResponse(ds), http.StatusOK, h.log)
+}
+
+func newDatasetResponse(ds *datasets.Dataset) models.DatasetResponse {
+	resp := models.DatasetResponse{
+		ID:          ds.ID,
+		Name:        ds.Name,
+		Version:     ds.Version,
+		Description: ds.Description,
+		CreatedAt:   ds.CreatedAt,
+		Size:        ds.Size(),
+		Files:       make([]models.DatasetFile, 0, len(ds.Files)),
+	}
+	for _, f := range ds.Files {
+		resp.Files = append(resp.Files, models.DatasetFile{Name: f.Name, Size: f.Size})
+	}
+	return resp
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/datasets"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// newDatasetRequest returns an upload request with a dataset form field per reference
+func newDatasetRequest(t *testing.T, refs []string, files ...testUpload) *http.Request {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for _, f := range files {
+		field := f.field
+		if field == "" {
+			field = fileField
+		}
+		w, err := mw.CreateFormFile(field, f.name)
+		assert.NoError(t, err)
+		_, err = w.Write(f.content)
+		assert.NoError(t, err)
+	}
+	for _, ref := range refs {
+		assert.NoError(t, mw.WriteField(datasetField, ref))
+	}
+	assert.NoError(t, mw.Close())
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "/api/v1/upload", body)
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestDatasetHandlers(t *testing.T) {
+	fs := storage.NewFSStorage(t.TempDir(), logger.NewTestLogger())
+	registry := datasets.NewRegistry(fs, logger.NewTestLogger())
+	stager := newInputStager(fs, nil, registry, logger.NewTestLogger())
+
+	r := mux.NewRouter()
+	r.Handle("/api/v1/datasets", NewCreateDatasetHandler(stager, registry, logger.NewTestLogger())).Methods(http.MethodPost)
+	r.Handle("/api/v1/datasets", NewDatasetsHandler(registry, logger.NewTestLogger())).Methods(http.MethodGet)
+	r.Handle("/api/v1/datasets/{id}", NewDatasetHandler(registry, logger.NewTestLogger())).Methods(http.MethodGet)
+
+	serve := func(req *http.Request, expectedStatus int, v interface{}) string {
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		assert.Equal(t, expectedStatus, recorder.Code)
+		if v != nil {
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), v))
+		}
+		return recorder.Body.String()
+	}
+	create := func(fields map[string]string, files ...testUpload) *http.Request {
+		req := newUploadRequest(t, fields, files...)
+		req.URL.Path = "/api/v1/datasets"
+		return req
+	}
+	get := func(url string) *http.Request {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+		assert.NoError(t, err)
+		return req
+	}
+
+	var ds models.DatasetResponse
+	serve(create(map[string]string{"name": "racks", "description": "inventory"}, testUpload{name: "r.tsv", content: []byte("id\n1\n"), field: "racks"}), http.StatusCreated, &ds)
+	assert.Equal(t, "racks@1", ds.ID)
+	assert.Equal(t, "inventory", ds.Description)
+	assert.Equal(t, []models.DatasetFile{{Name: "racks/r.csv", Size: 5}, {Name: ".originals/racks/r.tsv", Size: 5}}, ds.Files)
+	assert.Equal(t, int64(10), ds.Size)
+
+	serve(create(map[string]string{"name": "racks"}, testUpload{name: "r.csv", content: []byte("id\n2\n")}), http.StatusCreated, &ds)
+	assert.Equal(t, "racks@2", ds.ID)
+	serve(create(map[string]string{"name": "devices"}, testUpload{name: "d.csv", content: []byte("d\n")}), http.StatusCreated, &ds)
+
+	body := serve(create(map[string]string{"name": "racks@3"}, testUpload{name: "r.csv", content: []byte("id\n")}), http.StatusBadRequest, nil)
+	assert.JSONEq(t, `{"text":"`+ErrMsgDatasetName+`"}`, body)
+	body = serve(create(map[string]string{"name": "racks"}), http.StatusBadRequest, nil)
+	assert.JSONEq(t, `{"text":"`+ErrMsgInternal+`"}`, body)
+
+	var list models.DatasetsResponse
+	serve(get("/api/v1/datasets"), http.StatusOK, &list)
+	ids := make([]string, 0)
+	for _, d := range list.Datasets {
+		ids = append(ids, d.ID)
+	}
+	assert.Equal(t, []string{"devices@1", "racks@1", "racks@2"}, ids)
+	serve(get("/api/v1/datasets?name=devices"), http.StatusOK, &list)
+	assert.Len(t, list.Datasets, 1)
+
+	serve(get("/api/v1/datasets/racks"), http.StatusOK, &ds)
+	assert.Equal(t, "racks@2", ds.ID)
+	assert.Equal(t, []models.DatasetFile{{Name: "r.csv", Size: 5}}, ds.Files)
+	body = serve(get("/api/v1/datasets/racks@9"), http.StatusNotFound, nil)
+	assert.JSONEq(t, `{"text":"dataset not found"}`, body)
+}
+
+func TestInputStager_Datasets(t *testing.T) {
+	ctx := context.Background()
+	bucket := t.TempDir()
+	fs := storage.NewFSStorage(bucket, logger.NewTestLogger())
+	registry := datasets.NewRegistry(fs, logger.NewTestLogger())
+	stager := newInputStager(fs, nil, registry, logger.NewTestLogger())
+
+	for _, d := range []struct {
+		name  string
+		files []testUpload
+	}{
+		{"inventory", []testUpload{{name: "r.csv", content: []byte("id\n1\n"), field: "racks"}, {name: "d.csv", content: []byte("d\n"), field: "devices"}}},
+		{"racks", []testUpload{{name: "r.tsv", content: []byte("id\n2\n")}}},
+		{"pair", []testUpload{{name: "a.csv", content: []byte("a\n")}, {name: "b.csv", content: []byte("b\n")}}},
+		{"more", []testUpload{{name: "m.csv", content: []byte("\xef\xbb\xbfid\r\n3\r\n\r\n4\r\n")}}},
+		{"names", []testUpload{{name: "n.csv", content: []byte("name\nx\n")}}},
+		{"notes", []testUpload{{name: "n.txt", content: []byte("x")}}},
+	} {
+		input, err := stager.prepare(newUploadRequest(t, nil, d.files...))
+		assert.NoError(t, err)
+		_, err = registry.Create(ctx, d.name, "", input.entries)
+		input.close()
+		assert.NoError(t, err)
+	}
+
+	// a single dataset is the input archive as is
+	req, err := stager.stage(newDatasetRequest(t, []string{"inventory"}))
+	assert.NoError(t, err)
+	assert.Regexp(t, `^dataset_inventory_[0-9a-f]+\.tar\.gz$`, req.Filename)
+	assert.Equal(t, []string{"inventory@1"}, req.Datasets)
+
+	// datasets assigned to slots are staged with the uploads into a new archive
+	req, err = stager.stage(newDatasetRequest(t, []string{"racks=racks@1", "pair"}, testUpload{name: "d.csv", content: []byte("d\n"), field: "devices"}))
+	assert.NoError(t, err)
+	assert.Regexp(t, `^input_files_\d+\.tar\.gz$`, req.Filename)
+	assert.Equal(t, []string{"racks=racks@1", "pair@1"}, req.Datasets)
+	dir := t.TempDir()
+	assert.NoError(t, compressor.Decompress(ctx, filepath.Join(bucket, req.Filename), dir))
+	for name, content := range map[string]string{"racks/r.csv": "id\n2\n", ".originals/racks/r.tsv": "id\n2\n", "devices/d.csv": "d\n", "a.csv": "a\n", "b.csv": "b\n"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(data), name)
+	}
+
+	// the rows of the datasets of a slot are combined into the file of the first one
+	req, err = stager.stage(newDatasetRequest(t, []string{"racks=racks@1", "racks=more", "pair"}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"racks=racks@1", "racks=more@1", "pair@1"}, req.Datasets)
+	dir = t.TempDir()
+	assert.NoError(t, compressor.Decompress(ctx, filepath.Join(bucket, req.Filename), dir))
+	data, err := ioutil.ReadFile(filepath.Join(dir, "racks", "r.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "id\n2\n3\n4\n", string(data))
+	_, err = os.Stat(filepath.Join(dir, ".originals"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = stager.stage(newDatasetRequest(t, []string{"racks=racks@1", "racks=names"}))
+	assert.ErrorIs(t, err, errInvalidData)
+	assert.Contains(t, err.Error(), "dataset 'names@1': the header 'name' differs from 'id' of the other datasets of the slot")
+	_, err = stager.stage(newDatasetRequest(t, []string{"racks=racks@1", "racks=notes"}))
+	assert.ErrorIs(t, err, errInputSlot)
+
+	_, err = stager.stage(newDatasetRequest(t, []string{"rooms"}))
+	assert.ErrorIs(t, err, datasets.ErrNotFound)
+	// a slot takes a single file
+	_, err = stager.stage(newDatasetRequest(t, []string{"racks=pair"}))
+	assert.ErrorIs(t, err, errInputSlot)
+	_, err = stager.stage(newDatasetRequest(t, []string{"inventory"}, testUpload{name: "other.csv", content: []byte("id\n"), field: "racks"}))
+	assert.ErrorIs(t, err, errInputSlot)
+	_, err = stager.stage(newDatasetRequest(t, []string{"racks=racks@1", "racks=inventory"}))
+	assert.ErrorIs(t, err, errInputSlot)
+	_, err = stager.stage(newDatasetRequest(t, []string{"pair", "pair"}))
+	assert.ErrorIs(t, err, errDuplicateFile)
+
+	_, err = newInputStager(fs, nil, nil, logger.NewTestLogger()).stage(newDatasetRequest(t, []string{"pair"}))
+	assert.ErrorIs(t, err, datasets.ErrNotFound)
+}
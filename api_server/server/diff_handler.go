@@ -0,0 +1,320 @@
+package server
+
+import (
+	"encoding/csv"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/jobs"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
+)
+
+const (
+	ErrMsgDiffQuery = "invalid diff query, the a and b job ids are required and the format is json or csv"
+
+	// maxDiffRows caps the row changes reported for a file
+	maxDiffRows = 1000
+	// objectiveKPI is the name the objective of result.json is compared under
+	objectiveKPI = "objective"
+
+	fileAdded   = "added"
+	fileRemoved = "removed"
+	fileChanged = "changed"
+	fileEqual   = "equal"
+	fileError   = "error"
+)
+
+// diffCSVHeader is the header of the diff downloaded as CSV
+var diffCSVHeader = []string{"file", "change", "key", "column", "a", "b", "delta"}
+
+type DiffHandler struct {
+	jobs    *jobs.Manager
+	storage storage.Storage
+	log     *logger.Logger
+}
+
+func NewDiffHandler(jobs *jobs.Manager, storage storage.Storage, log *logger.Logger) *DiffHandler {
+	return &DiffHandler{
+		jobs:    jobs,
+		storage: storage,
+		log:     log,
+	}
+}
+
+// Diff job results
+// @Summary Compare the results of two jobs
+// @Description Compare the CSV files of the same name in the results of two succeeded jobs row by row and the KPIs of their result.json.
+// @Description The rows are matched by the key columns, by the row number without a key.
+// @ID diff-handler
+// @Produce  json,text/csv
+// @Param   a query string true "id of the job the comparison starts from"
+// @Param   b query string true "id of the job compared with a"
+// @Param   key query string false "comma separated key columns, e.g. id or rack,slot"
+// @Param   format query string false "json (default) or csv"
+// @Success 200 {object} models.DiffResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /v1/results/diff [get]
+func (h *DiffHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := strings.ToLower(query.Get("format"))
+	if query.Get("a") == "" || query.Get("b") == "" || (format != "" && format != exportJSON && format != exportCSV) {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgDiffQuery), http.StatusBadRequest, h.log)
+		return
+	}
+	var key []string
+	for _, column := range strings.Split(query.Get("key"), ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			key = append(key, column)
+		}
+	}
+
+	jobA, ok := resultJob(writer, h.jobs, query.Get("a"), h.log)
+	if !ok {
+		return
+	}
+	jobB, ok := resultJob(writer, h.jobs, query.Get("b"), h.log)
+	if !ok {
+		return
+	}
+
+	resp := models.DiffResponse{A: jobA.ID, B: jobB.ID, Key: key, Files: make([]models.FileDiff, 0)}
+	indexes := make(map[string]*resultIndex)
+	var names []string
+	err := h.walkResult(r, jobA, func(name string, content io.Reader) {
+		ix, err := tabular.NewIndex(content, key)
+		indexes[name] = &resultIndex{ix: ix, err: err}
+		names = append(names, name)
+	})
+	if err == nil {
+		err = h.walkResult(r, jobB, func(name string, content io.Reader) {
+			resp.Files = append(resp.Files, diffFile(name, indexes[name], content))
+			if ix, ok := indexes[name]; ok {
+				ix.seen = true
+			}
+		})
+	}
+	if err != nil {
+		writeDownloadError(writer, err, h.log)
+		return
+	}
+	for _, name := range names {
+		if ix := indexes[name]; !ix.seen {
+			file := models.FileDiff{Name: name, Status: fileRemoved, Error: errorText(ix.err)}
+			if ix.ix != nil {
+				file.RowsA = ix.ix.Len()
+			}
+			resp.Files = append(resp.Files, file)
+		}
+	}
+	// the archive order differs between the results, the files are listed by name
+	sort.Slice(resp.Files, func(i, j int) bool {
+		return resp.Files[i].Name < resp.Files[j].Name
+	})
+	resp.KPIs = diffKPIs(jobA, jobB)
+
+	if format == exportCSV {
+		h.writeCSV(writer, resp)
+		return
+	}
+	writeResponse(writer, resp, http.StatusOK, h.log)
+}
+
+// resultIndex is a CSV file of the first result, seen is set once the second result has the file as well
+type resultIndex struct {
+	ix   *tabular.Index
+	err  error
+	seen bool
+}
+
+// walkResult streams the result archive of the job and calls fn for every CSV file in it
+func (h *DiffHandler) walkResult(r *http.Request, job *jobs.Job, fn func(name string, content io.Reader)) error {
+	body, _, err := h.storage.Get(r.Context(), job.Result.Filepath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := body.Close(); err != nil {
+			h.log.Warnf("error closing object '%s': %s", job.Result.Filepath, err)
+		}
+	}()
+
+	return compressor.Walk(r.Context(), body, func(name string, content io.Reader) error {
+		if strings.EqualFold(path.Ext(name), ".csv") {
+			fn(name, content)
+		}
+		return nil
+	})
+}
+
+func (h *DiffHandler) writeCSV(writer http.ResponseWriter, resp models.DiffResponse) {
+	name := "diff_" + resp.A + "_" + resp.B + ".csv"
+	writer.Header().Set("Content-Type", csvContentType)
+	writer.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	writer.WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(writer)
+	_ = w.Write(diffCSVHeader)
+	for _, file := range resp.Files {
+		if file.Status == fileAdded || file.Status == fileRemoved {
+			_ = w.Write([]string{file.Name, file.Status, "", "", "", "", ""})
+		}
+		for _, column := range file.AddedColumns {
+			_ = w.Write([]string{file.Name, "column_added", "", column, "", "", ""})
+		}
+		for _, column := range file.RemovedColumns {
+			_ = w.Write([]string{file.Name, "column_removed", "", column, "", "", ""})
+		}
+		for _, row := range file.Rows {
+			for _, record := range diffRecords(file.Name, row) {
+				_ = w.Write(record)
+			}
+		}
+	}
+	for _, kpi := range resp.KPIs {
+		_ = w.Write([]string{"result.json", "kpi", "", kpi.Name, formatFloat(kpi.A), formatFloat(kpi.B), formatFloat(kpi.Delta)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		h.log.Warnf("error writing diff '%s': %s", name, err)
+	}
+}
+
+// diffRecords returns the CSV lines of a row change, a line per cell
+func diffRecords(file string, row models.RowDiff) [][]string {
+	var records [][]string
+	for _, cell := range row.Cells {
+		records = append(records, []string{file, row.Change, row.Key, cell.Column, cell.A, cell.B, formatFloat(cell.Delta)})
+	}
+	columns := make([]string, 0, len(row.Values))
+	for column := range row.Values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		a, b := row.Values[column], ""
+		if row.Change == tabular.RowAdded {
+			a, b = "", a
+		}
+		records = append(records, []string{file, row.Change, row.Key, column, a, b, ""})
+	}
+	return records
+}
+
+func diffFile(name string, a *resultIndex, content io.Reader) models.FileDiff {
+	file := models.FileDiff{Name: name, Status: fileAdded}
+	if a == nil {
+		page, err := tabular.ReadPage(content, 0, 0)
+		if err != nil {
+			file.Error = err.Error()
+			return file
+		}
+		file.RowsB = page.Rows
+		return file
+	}
+	file.Status = fileError
+	if a.err != nil {
+		file.Error = a.err.Error()
+		return file
+	}
+	diff, err := a.ix.Diff(content, maxDiffRows)
+	if err != nil {
+		file.Error = err.Error()
+		return file
+	}
+
+	file.Status = fileEqual
+	if diff.Added+diff.Removed+diff.Changed+len(diff.AddedColumns)+len(diff.RemovedColumns) > 0 {
+		file.Status = fileChanged
+	}
+	file.AddedColumns = diff.AddedColumns
+	file.RemovedColumns = diff.RemovedColumns
+	file.RowsA, file.RowsB = diff.RowsA, diff.RowsB
+	file.Added, file.Removed, file.Changed = diff.Added, diff.Removed, diff.Changed
+	file.Truncated = diff.Truncated
+	for _, c := range diff.Columns {
+		file.Columns = append(file.Columns, models.ColumnDelta{Column: c.Column, Changed: c.Changed, SumA: c.SumA, SumB: c.SumB, Delta: c.Delta})
+	}
+	for _, row := range diff.Rows {
+		change := models.RowDiff{Change: row.Kind, Key: row.Key}
+		if row.Values != nil {
+			change.Values = make(map[string]string, len(row.Header))
+			for i, column := range row.Header {
+				if i < len(row.Values) {
+					change.Values[column] = row.Values[i]
+				}
+			}
+		}
+		for _, cell := range row.Cells {
+			change.Cells = append(change.Cells, models.CellDiff{Column: cell.Column, A: cell.A, B: cell.B, Delta: cell.Delta})
+		}
+		file.Rows = append(file.Rows, change)
+	}
+	return file
+}
+
+// diffKPIs compares the objective and the KPIs of the result summaries, nil if neither job has a summary
+func diffKPIs(a *jobs.Job, b *jobs.Job) []models.KPIDiff {
+	kpisA, kpisB := summaryKPIs(a.Result.Summary), summaryKPIs(b.Result.Summary)
+	names := make([]string, 0, len(kpisA)+len(kpisB))
+	for name := range kpisA {
+		names = append(names, name)
+	}
+	for name := range kpisB {
+		if _, ok := kpisA[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var kpis []models.KPIDiff
+	for _, name := range names {
+		kpi := models.KPIDiff{Name: name, A: kpisA[name], B: kpisB[name]}
+		if kpi.A != nil && kpi.B != nil {
+			delta := *kpi.B - *kpi.A
+			kpi.Delta = &delta
+		}
+		kpis = append(kpis, kpi)
+	}
+	return kpis
+}
+
+func summaryKPIs(summary *models.ResultSummary) map[string]*float64 {
+	kpis := make(map[string]*float64)
+	if summary == nil {
+		return kpis
+	}
+	for name, value := range summary.KPIs {
+		value := value
+		kpis[name] = &value
+	}
+	if summary.Objective != nil {
+		kpis[objectiveKPI] = summary.Objective
+	}
+	return kpis
+}
+
+func formatFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
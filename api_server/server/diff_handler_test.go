@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/jobs"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/optimization"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestResult(t *testing.T, bucket string, name string, format compressor.Format, files map[string]string) {
+	workDir := t.TempDir()
+	names := make([]string, 0, len(files))
+	for file, content := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(workDir, file), []byte(content), os.ModePerm))
+		names = append(names, file)
+	}
+	sort.Strings(names)
+	_, err := compressor.CompressFormat(context.Background(), format, filepath.Join(bucket, name), workDir, names...)
+	assert.NoError(t, err)
+}
+
+func TestDiffHandler(t *testing.T) {
+	bucket := t.TempDir()
+	writeTestResult(t, bucket, "opt_result_1", compressor.Zip, map[string]string{
+		resultOutputFilename: "id,cost\n1,10\n2,5\n",
+		"only_a.csv":         "id\n1\n",
+	})
+	writeTestResult(t, bucket, "opt_result_2", compressor.TarGz, map[string]string{
+		resultOutputFilename: "id,cost\n1,12.5\n3,1\n",
+		"only_b.csv":         "x\n1\n2\n",
+	})
+
+	objective := 3.0
+	summaries := map[string]*models.ResultSummary{
+		"opt_result_1.zip":    {Objective: &objective, KPIs: map[string]float64{"racks": 4, "power": 10}},
+		"opt_result_2.tar.gz": {KPIs: map[string]float64{"racks": 3, "cost": 1}},
+	}
+	m := jobs.NewManager(optimizerFunc(func(req optimization.Request) (*optimization.Response, error) {
+		if req.Filename == "" {
+			return nil, &optimization.Error{StatusCode: http.StatusBadRequest, Text: "script error"}
+		}
+		return &optimization.Response{Filepath: req.Filename, Summary: summaries[req.Filename]}, nil
+	}), nil, logger.NewTestLogger())
+	a := newTestJob(t, m, "opt_result_1.zip")
+	b := newTestJob(t, m, "opt_result_2.tar.gz")
+	failed := newTestJob(t, m, "")
+
+	r := mux.NewRouter()
+	r.Handle("/api/v1/results/diff", NewDiffHandler(m, storage.NewFSStorage(bucket, logger.NewTestLogger()), logger.NewTestLogger()))
+
+	testCases := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		expectedBody   string
+		contentType    string
+	}{
+		{
+			name:           "json",
+			url:            "/api/v1/results/diff?a=" + a + "&b=" + b + "&key=id",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"a":"` + a + `","b":"` + b + `","key":["id"],"files":[` +
+				`{"name":"def_output.csv","status":"changed","rowsA":2,"rowsB":2,"added":1,"removed":1,"changed":1,` +
+				`"columns":[{"column":"id","changed":0,"sumA":3,"sumB":4,"delta":1},{"column":"cost","changed":1,"sumA":15,"sumB":13.5,"delta":-1.5}],` +
+				`"rows":[{"change":"changed","key":"1","cells":[{"column":"cost","a":"10","b":"12.5","delta":2.5}]},` +
+				`{"change":"added","key":"3","values":{"cost":"1","id":"3"}},{"change":"removed","key":"2","values":{"cost":"5","id":"2"}}]},` +
+				`{"name":"only_a.csv","status":"removed","rowsA":1,"rowsB":0,"added":0,"removed":0,"changed":0},` +
+				`{"name":"only_b.csv","status":"added","rowsA":0,"rowsB":2,"added":0,"removed":0,"changed":0}],` +
+				`"kpis":[{"name":"cost","a":null,"b":1},{"name":"objective","a":3,"b":null},{"name":"power","a":10,"b":null},{"name":"racks","a":4,"b":3,"delta":-1}]}`,
+			contentType: "application/json",
+		},
+		{
+			name:           "unknown key",
+			url:            "/api/v1/results/diff?a=" + a + "&b=" + b + "&key=rack",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"a":"` + a + `","b":"` + b + `","key":["rack"],"files":[` +
+				`{"name":"def_output.csv","status":"error","rowsA":0,"rowsB":0,"added":0,"removed":0,"changed":0,"error":"key column 'rack' missing in the header"},` +
+				`{"name":"only_a.csv","status":"removed","rowsA":0,"rowsB":0,"added":0,"removed":0,"changed":0,"error":"key column 'rack' missing in the header"},` +
+				`{"name":"only_b.csv","status":"added","rowsA":0,"rowsB":2,"added":0,"removed":0,"changed":0}],` +
+				`"kpis":[{"name":"cost","a":null,"b":1},{"name":"objective","a":3,"b":null},{"name":"power","a":10,"b":null},{"name":"racks","a":4,"b":3,"delta":-1}]}`,
+			contentType: "application/json",
+		},
+		{
+			name:           "missing job id",
+			url:            "/api/v1/results/diff?a=" + a,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"text":"` + ErrMsgDiffQuery + `"}`,
+			contentType:    "application/json",
+		},
+		{
+			name:           "failed job",
+			url:            "/api/v1/results/diff?a=" + a + "&b=" + failed,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"text":"job has no result"}`,
+			contentType:    "application/json",
+		},
+		{
+			name:           "unknown job",
+			url:            "/api/v1/results/diff?a=unknown&b=" + b,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"text":"job not found"}`,
+			contentType:    "application/json",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, tc.url, nil)
+			assert.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.expectedStatus, recorder.Code)
+			assert.JSONEq(t, tc.expectedBody, recorder.Body.String())
+			assert.Equal(t, tc.contentType, recorder.Header().Get("Content-Type"))
+		})
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/api/v1/results/diff?a="+a+"&b="+b+"&key=id&format=csv", nil)
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, csvContentType, recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=diff_"+a+"_"+b+".csv", recorder.Header().Get("Content-Disposition"))
+	assert.Equal(t, "file,change,key,column,a,b,delta\n"+
+		"def_output.csv,changed,1,cost,10,12.5,2.5\n"+
+		"def_output.csv,added,3,cost,,1,\n"+
+		"def_output.csv,added,3,id,,3,\n"+
+		"def_output.csv,removed,2,cost,5,,\n"+
+		"def_output.csv,removed,2,id,2,,\n"+
+		"only_a.csv,removed,,,,,\n"+
+		"only_b.csv,added,,,,,\n"+
+		"result.json,kpi,,cost,,1,\n"+
+		"result.json,kpi,,objective,3,,\n"+
+		"result.json,kpi,,power,10,,\n"+
+		"result.json,kpi,,racks,4,3,-1\n", recorder.Body.String())
+}
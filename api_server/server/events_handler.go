@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/jobs"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/gorilla/mux"
+)
+
+const (
+	ErrMsgStreaming = "event streams are not supported"
+
+	// eventStatus is the event carrying the state of the job
+	eventStatus = "status"
+	// eventsRetry is the reconnection delay suggested to the client in milliseconds
+	eventsRetry = 1000
+)
+
+type JobEventsHandler struct {
+	jobs *jobs.Manager
+	log  *logger.Logger
+}
+
+func NewJobEventsHandler(jobs *jobs.Manager, log *logger.Logger) *JobEventsHandler {
+	return &JobEventsHandler{
+		jobs: jobs,
+		log:  log,
+	}
+}
+
+// Job events
+// @Summary Stream the state of an optimization job
+// @Description Server-Sent Events stream of the job: a status event with the job on connect and on every change,
+// @Description e.g. the progress reported by the script. The stream ends once the job has finished or the client goes away.
+// @ID job-events-handler
+// @Produce  text/event-stream
+// @Param   id path string true "job id"
+// @Success 200 {object} models.JobResponse "status events"
+// @Failure 404 {object} models.ErrorResponse
+// @Router /v1/jobs/{id}/events [get]
+func (h *JobEventsHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	job, changed, err := h.jobs.Watch(id)
+	if errors.Is(err, jobs.ErrNotFound) {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgJobNotFound), http.StatusNotFound, h.log)
+		return
+	}
+	if err != nil {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgInternal), http.StatusInternalServerError, h.log)
+		return
+	}
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgStreaming), http.StatusInternalServerError, h.log)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("X-Accel-Buffering", "no")
+	writer.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintf(writer, "retry: %d\n\n", eventsRetry); err != nil {
+		return
+	}
+
+	for {
+		if err := writeEvent(writer, eventStatus, newJobResponse(job)); err != nil {
+			h.log.Debugf("event stream of job '%s' closed: %s", id, err)
+			return
+		}
+		flusher.Flush()
+		if job.Done() {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+		if job, changed, err = h.jobs.Watch(id); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes a Server-Sent Event with the JSON of data
+func writeEvent(writer http.ResponseWriter, event string, data interface{}) error {
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", event, jsonBytes)
+	return err
+}
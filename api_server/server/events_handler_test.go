@@ -0,0 +1,122 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/jobs"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/optimization"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/metrics"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// readEvents returns the status events of the stream, onEvent is called after each one
+func readEvents(t *testing.T, resp *http.Response, onEvent func(models.JobResponse)) []models.JobResponse {
+	events := make([]models.JobResponse, 0)
+	scanner := bufio.NewScanner(resp.Body)
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			assert.Equal(t, eventStatus, event)
+			var job models.JobResponse
+			assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &job))
+			events = append(events, job)
+			if onEvent != nil {
+				onEvent(job)
+			}
+		}
+	}
+	assert.NoError(t, scanner.Err())
+	return events
+}
+
+func TestJobEventsHandler(t *testing.T) {
+	opt := newCancelableOptimizer()
+	m := jobs.NewManager(opt, nil, logger.NewTestLogger())
+	handler := NewJobEventsHandler(m, logger.NewTestLogger())
+	r := mux.NewRouter()
+	r.Use(metrics.PrometheusMiddleware)
+	r.Handle("/api/v1/jobs/{id}/events", handler)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	get := func(id string) *http.Response {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL+"/api/v1/jobs/"+id+"/events", nil)
+		assert.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	job, err := m.Submit(optimization.Request{Filename: "input_files_1.tar.gz"})
+	assert.NoError(t, err)
+	<-opt.started
+
+	// the state on connect and on every change, the stream ends with the finished job
+	resp := get(job.ID)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	events := readEvents(t, resp, func(event models.JobResponse) {
+		if event.Status == string(jobs.StatusRunning) {
+			_, err := m.Cancel(job.ID)
+			assert.NoError(t, err)
+		}
+	})
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, job.ID, events[0].ID)
+		assert.Equal(t, string(jobs.StatusRunning), events[0].Status)
+		assert.Equal(t, string(jobs.StatusCanceled), events[1].Status)
+	}
+
+	// a finished job is reported once
+	resp = get(job.ID)
+	defer resp.Body.Close()
+	assert.Len(t, readEvents(t, resp, nil), 1)
+
+	// the stream of an unchanged job stays open until the job finishes
+	running, err := m.Submit(optimization.Request{Filename: "input_files_2.tar.gz"})
+	assert.NoError(t, err)
+	<-opt.started
+	resp = get(running.ID)
+	defer resp.Body.Close()
+	events = readEvents(t, resp, func(event models.JobResponse) {
+		if event.Status == string(jobs.StatusRunning) {
+			time.AfterFunc(100*time.Millisecond, func() {
+				_, err := m.Cancel(running.ID)
+				assert.NoError(t, err)
+			})
+		}
+	})
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, string(jobs.StatusCanceled), events[1].Status)
+	}
+
+	resp = get("unknown")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"text":"job not found"}`, string(body))
+}
+
+func TestNewJobResponse_Progress(t *testing.T) {
+	updated := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	resp := newJobResponse(&jobs.Job{ID: "1", Status: jobs.StatusRunning,
+		Progress: &optimization.Progress{Percent: 42, Message: "placing rack row 3", UpdatedAt: updated}})
+	assert.Equal(t, &models.ProgressResponse{Percent: 42, Message: "placing rack row 3", UpdatedAt: updated}, resp.Progress)
+	assert.Nil(t, newJobResponse(&jobs.Job{ID: "1"}).Progress)
+}
@@ -0,0 +1,425 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/datasets"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/jobs"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/optimization"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/environment"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	inputFileName  = "input_files"
+	inputEnvPrefix = "tmp_input_"
+	// expandDir and convertDir are the directories of the expanded archive and the converted files in the temp dir
+	expandDir  = "expanded"
+	convertDir = "converted"
+	datasetDir = "datasets"
+	// fileField is the form field of the files which are not assigned to an input slot
+	fileField = "file"
+	// datasetField is the form field of the datasets the input set is staged from
+	datasetField = "dataset"
+
+	maxMemory = 10 << 20 // 10 MB buffer
+)
+
+var (
+	errBucketUpload = errors.New("error uploading archive to the bucket")
+	// errResultFormat is returned when the requested result format is not supported
+	errResultFormat = errors.New("unsupported result format")
+	// errParameters is returned when the parameters form field is not a JSON object
+	errParameters = errors.New("parameters must be a JSON object")
+	// errInputSlot is returned when a slot form field has an invalid name or several files
+	errInputSlot = errors.New("invalid input slot")
+	// errDuplicateFile is returned when an uploaded archive has several files with the same name
+	errDuplicateFile = errors.New("duplicate file in the input archive")
+	// errSubmit is returned when the job of a staged input could not be started
+	errSubmit = errors.New("error submitting a job")
+)
+
+// slotName is the name of an input slot form field
+var slotName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+var (
+	uiUploadDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ui_upload_duration_time_seconds",
+		Help:    "Duration of files uploading",
+		Buckets: prometheus.DefBuckets,
+	})
+	storageUploadDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "storage_upload_duration_time_seconds",
+		Help:    "Duration of compressing files and uploading the archive to the storage",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+// inputStager receives the files uploaded by the user, packs them into an archive and puts it into the bucket
+type inputStager struct {
+	storage storage.Storage
+	// algorithms provides the CSV schemas of the input slots, the slots are not validated if nil
+	algorithms algorithmLister
+	// datasets provides the stored input sets referenced by the requests, the references are rejected if nil
+	datasets *datasets.Registry
+	log      *logger.Logger
+}
+
+func newInputStager(storage storage.Storage, algorithms algorithmLister, datasets *datasets.Registry, log *logger.Logger) *inputStager {
+	return &inputStager{
+		storage:    storage,
+		algorithms: algorithms,
+		datasets:   datasets,
+		log:        log,
+	}
+}
+
+// stage puts the input archive built from the multipart request into the bucket
+// and returns the optimization request for it.
+// The archive is compressed on the fly while it is uploaded to the bucket.
+// A single uploaded archive is expanded and its files become the input set.
+// The files of the input slot form fields are stored as <slot>/<filename>. The tsv, json and xlsx files are converted
+// to CSV, the optional "sheet" form field selects the sheet of the xlsx files.
+// Every "dataset" form field adds a stored dataset to the input set, [slot=]name@version or [slot=]name for the latest version.
+// CSV files are validated against the schema of their slot before the upload.
+// The optional "format" form field selects the format of the result archive,
+// the optional "algorithm" form field selects the algorithm by name@version or by name
+// and the optional "parameters" form field holds a JSON object with the parameters of the run.
+func (s *inputStager) stage(r *http.Request) (optimization.Request, error) {
+	input, err := s.prepare(r)
+	if err != nil {
+		return optimization.Request{}, err
+	}
+	defer input.close()
+	return input.upload(r.Context())
+}
+
+// submit stages the input of the request and starts a job for it. Unless the nocache query parameter is set,
+// the input set, the algorithm and the parameters are hashed into the key of the result cache: a cached result
+// is returned as a finished job without uploading the input and a running job with the same key is shared.
+func (s *inputStager) submit(r *http.Request, manager *jobs.Manager) (*jobs.Job, error) {
+	input, err := s.prepare(r)
+	if err != nil {
+		return nil, err
+	}
+	defer input.close()
+
+	key := ""
+	if manager.Caching() && !noCache(r) {
+		key, err = input.key(r.Context())
+		if err != nil {
+			s.log.Warnf("result cache skipped: %s", err)
+		}
+		if job, ok := manager.Lookup(r.Context(), key); ok {
+			return job, nil
+		}
+	}
+
+	req, err := input.upload(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	job, err := manager.SubmitKeyed(key, req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errSubmit, err)
+	}
+	return job, nil
+}
+
+// stagedInput is the validated input set of a request, its files are kept until close
+type stagedInput struct {
+	stager    *inputStager
+	env       *environment.Provider
+	form      *multipart.Form
+	entries   []compressor.Entry
+	format    string
+	ref       string
+	algorithm *models.Algorithm
+	params    map[string]interface{}
+	datasets  []datasetRef
+	// inherited are the datasets of the parent job a rerun keeps
+	inherited []string
+	// archive is the key of a stored archive which is the input set as is, nothing is uploaded if set
+	archive string
+}
+
+// prepare receives, expands, converts and validates the files of the request, the caller must close the input
+func (s *inputStager) prepare(r *http.Request) (*stagedInput, error) {
+	// Receive files from the UI
+	s.log.Debugf("Start uploading files")
+	timer := prometheus.NewTimer(uiUploadDuration)
+	entries, slotted, err := s.parseFileUpload(r)
+	timer.ObserveDuration()
+	input := &stagedInput{stager: s, form: r.MultipartForm}
+	if err != nil {
+		input.close()
+		return nil, err
+	}
+	if err := input.prepare(r, entries, slotted); err != nil {
+		input.close()
+		return nil, err
+	}
+	return input, nil
+}
+
+func (in *stagedInput) prepare(r *http.Request, entries []compressor.Entry, slotted bool) error {
+	s := in.stager
+	var err error
+	if in.format, err = resultFormat(r); err != nil {
+		return err
+	}
+	if in.params, err = runParameters(r); err != nil {
+		return err
+	}
+	if in.datasets, err = s.datasetRefs(r); err != nil {
+		return err
+	}
+	if len(entries) == 0 && len(in.datasets) == 0 {
+		return compressor.ErrNoFiles
+	}
+	for _, ref := range in.datasets {
+		slotted = slotted || ref.slotted()
+	}
+	in.ref = strings.TrimSpace(r.FormValue("algorithm"))
+	if slotted {
+		in.algorithm = s.algorithm(r.Context(), in.ref)
+	}
+
+	in.env = environment.New(os.TempDir(), inputEnvPrefix)
+	if err := in.env.CreateTempDir(); err != nil {
+		in.env = nil
+		return fmt.Errorf("error creating tempdir: %s", err)
+	}
+	if len(entries) == 1 && !slotted {
+		entries, err = s.expand(r, entries[0], filepath.Join(in.env.Dir(), expandDir))
+		if err != nil {
+			return err
+		}
+	}
+	entries, err = s.convert(entries, in.algorithm, r.FormValue("sheet"), filepath.Join(in.env.Dir(), convertDir))
+	if err != nil {
+		return err
+	}
+	// the datasets have been converted when they were stored
+	if len(in.datasets) > 0 {
+		stored, err := s.datasetEntries(r.Context(), in.datasets, in.algorithm, filepath.Join(in.env.Dir(), datasetDir))
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 && len(in.datasets) == 1 && in.datasets[0].slot == "" {
+			in.archive = in.datasets[0].dataset.Archive
+		}
+		if entries, err = mergeEntries(entries, stored); err != nil {
+			return err
+		}
+	}
+	if in.algorithm != nil {
+		if err := validateSlots(in.algorithm, entries); err != nil {
+			return err
+		}
+	}
+	in.entries = entries
+	return nil
+}
+
+// upload streams the archive of the input set to the bucket and returns the optimization request for it
+func (in *stagedInput) upload(ctx context.Context) (optimization.Request, error) {
+	s := in.stager
+	if in.archive != "" {
+		s.log.Debugf("Reuse the stored archive '%s' as the input", in.archive)
+		return in.request(in.archive), nil
+	}
+	filename := (environment.Filename)(inputFileName).WithUnixSuffix() + compressor.TarGz.Ext()
+	s.log.Debugf("Upload files archive '%s' to the bucket", filename)
+	timer := prometheus.NewTimer(storageUploadDuration)
+	archive := compressor.Reader(ctx, compressor.TarGz, in.entries...)
+	_, err := s.storage.Put(ctx, filename, archive, storage.PutOptions{ContentType: compressor.TarGz.ContentType()})
+	timer.ObserveDuration()
+	if cerr := archive.Close(); cerr != nil {
+		s.log.Warnf("error closing archive stream: %s", cerr)
+	}
+	if err != nil {
+		s.log.Errorf("error uploading archive '%s' to the bucket: %s", filename, err)
+		return optimization.Request{}, errBucketUpload
+	}
+	return in.request(filename), nil
+}
+
+// request returns the optimization request of the input archive
+func (in *stagedInput) request(filename string) optimization.Request {
+	req := optimization.Request{
+		Filename:   filename,
+		Format:     in.format,
+		Algorithm:  in.ref,
+		Parameters: in.params,
+		Datasets:   append([]string(nil), in.inherited...),
+	}
+	for _, ref := range in.datasets {
+		req.Datasets = append(req.Datasets, ref.String())
+	}
+	return req
+}
+
+// close removes the temporary files of the input
+func (in *stagedInput) close() {
+	if in.env != nil {
+		cleanUpEnv(in.env, in.stager.log)
+	}
+	if in.form != nil {
+		if err := in.form.RemoveAll(); err != nil {
+			in.stager.log.Warnf("error removing multipart form files: %s", err)
+		}
+	}
+}
+
+// expand extracts the entry into dir if it is an archive and returns the files it contains.
+// Files from nested folders are flattened, other entries are returned as is.
+func (s *inputStager) expand(r *http.Request, entry compressor.Entry, dir string) ([]compressor.Entry, error) {
+	file, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			s.log.Warnf("error closing uploaded file '%s': %s", entry.Name, err)
+		}
+	}()
+
+	format, archive, err := compressor.Detect(file)
+	if errors.Is(err, compressor.ErrUnknownFormat) {
+		return []compressor.Entry{entry}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Debugf("Expand uploaded %s archive '%s'", format, entry.Name)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err := compressor.Extract(r.Context(), archive, dir); err != nil {
+		return nil, err
+	}
+
+	entries := make([]compressor.Entry, 0)
+	names := make(map[string]struct{})
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name := filepath.Base(path)
+		if _, ok := names[name]; ok {
+			return fmt.Errorf("%w: '%s'", errDuplicateFile, name)
+		}
+		names[name] = struct{}{}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		fileEntry, err := compressor.FileEntry(dir, rel)
+		if err != nil {
+			return err
+		}
+		fileEntry.Name = name
+		entries = append(entries, fileEntry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, compressor.ErrNoFiles
+	}
+	return entries, nil
+}
+
+// resultFormat returns the normalized result format requested in the form, empty if not set
+func resultFormat(r *http.Request) (string, error) {
+	value := r.FormValue("format")
+	if value == "" {
+		return "", nil
+	}
+	format, err := compressor.ParseFormat(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", errResultFormat, err)
+	}
+	return string(format), nil
+}
+
+// runParameters decodes the JSON object of the parameters form field, nil if not set.
+// The parameters are validated by the optimization service against the schema of the algorithm.
+func runParameters(r *http.Request) (map[string]interface{}, error) {
+	value := strings.TrimSpace(r.FormValue("parameters"))
+	if value == "" {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+	var params map[string]interface{}
+	if err := decoder.Decode(&params); err != nil || params == nil || decoder.More() {
+		return nil, errParameters
+	}
+	return params, nil
+}
+
+// parseFileUpload returns the uploaded files, none if the input set is staged from datasets only. The files of the "file" field are not assigned to a slot,
+// every other file field is an input slot with a single file. slotted is set if there are slot files.
+func (s *inputStager) parseFileUpload(r *http.Request) (entries []compressor.Entry, slotted bool, err error) {
+	if err := r.ParseMultipartForm(maxMemory); err != nil {
+		return nil, false, fmt.Errorf("error parsing file: %s", err)
+	}
+
+	fields := make([]string, 0, len(r.MultipartForm.File))
+	for field := range r.MultipartForm.File {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		headers := r.MultipartForm.File[field]
+		slot := ""
+		if field != fileField {
+			if !slotName.MatchString(field) || len(headers) != 1 {
+				return nil, false, fmt.Errorf("%w '%s', a slot takes a single file", errInputSlot, field)
+			}
+			slot = field
+			slotted = true
+		}
+		for _, header := range headers {
+			s.log.Debugf("Uploaded File: %+v, slot: '%s', size: %+v, mime: %+v", header.Filename, slot, header.Size, header.Header)
+			entries = append(entries, multipartEntry(header, slot))
+		}
+	}
+	return entries, slotted, nil
+}
+
+// multipartEntry stores the file of a slot as <slot>/<filename>, other files as <filename>
+func multipartEntry(header *multipart.FileHeader, slot string) compressor.Entry {
+	name := filepath.Base(header.Filename)
+	if slot != "" {
+		name = path.Join(slot, name)
+	}
+	return compressor.Entry{
+		Name: name,
+		Size: header.Size,
+		Open: func() (io.ReadCloser, error) {
+			return header.Open()
+		},
+	}
+}
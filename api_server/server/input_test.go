@@ -0,0 +1,294 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
+	"github.com/stretchr/testify/assert"
+)
+
+type testUpload struct {
+	name    string
+	content []byte
+	// field is the form field of the file, "file" if empty
+	field string
+}
+
+func newUploadRequest(t *testing.T, fields map[string]string, files ...testUpload) *http.Request {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for _, f := range files {
+		field := f.field
+		if field == "" {
+			field = fileField
+		}
+		w, err := mw.CreateFormFile(field, f.name)
+		assert.NoError(t, err)
+		_, err = w.Write(f.content)
+		assert.NoError(t, err)
+	}
+	for name, value := range fields {
+		assert.NoError(t, mw.WriteField(name, value))
+	}
+	assert.NoError(t, mw.Close())
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "/api/v1/upload", body)
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func newZip(t *testing.T, files ...testUpload) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		assert.NoError(t, err)
+		_, err = w.Write(f.content)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// stagedFiles extracts the staged input archive and returns its files by name
+func stagedFiles(t *testing.T, bucket string, filename string) map[string]string {
+	dir := t.TempDir()
+	assert.NoError(t, compressor.Decompress(context.Background(), filepath.Join(bucket, filename), dir))
+
+	files := make(map[string]string)
+	infos, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	for _, info := range infos {
+		content, err := ioutil.ReadFile(filepath.Join(dir, info.Name()))
+		assert.NoError(t, err)
+		files[info.Name()] = string(content)
+	}
+	return files
+}
+
+func TestInputStager_Files(t *testing.T) {
+	bucket := t.TempDir()
+	stager := newInputStager(storage.NewFSStorage(bucket, logger.NewTestLogger()), nil, nil, logger.NewTestLogger())
+
+	req, err := stager.stage(newUploadRequest(t, nil, testUpload{name: "a.csv", content: []byte("a\n1\n")}, testUpload{name: "b.csv", content: []byte("b\n2\n")}))
+	assert.NoError(t, err)
+	assert.Regexp(t, `^input_files_\d+\.tar\.gz$`, req.Filename)
+	assert.Empty(t, req.Format)
+	assert.Empty(t, req.Algorithm)
+	assert.Equal(t, map[string]string{"a.csv": "a\n1\n", "b.csv": "b\n2\n"}, stagedFiles(t, bucket, req.Filename))
+}
+
+func TestInputStager_Archive(t *testing.T) {
+	bucket := t.TempDir()
+	stager := newInputStager(storage.NewFSStorage(bucket, logger.NewTestLogger()), nil, nil, logger.NewTestLogger())
+
+	archive := newZip(t, testUpload{name: "data/a.csv", content: []byte("a\n1\n")}, testUpload{name: "b.csv", content: []byte("b\n2\n")})
+	req, err := stager.stage(newUploadRequest(t, map[string]string{"format": "zip", "algorithm": "rack-milp@2.0", "parameters": `{"seed": 42, "weights": {"cost": 0.5}}`}, testUpload{name: "input.zip", content: archive}))
+	assert.NoError(t, err)
+	assert.Equal(t, "zip", req.Format)
+	assert.Equal(t, "rack-milp@2.0", req.Algorithm)
+	assert.Equal(t, map[string]interface{}{"seed": json.Number("42"), "weights": map[string]interface{}{"cost": json.Number("0.5")}}, req.Parameters)
+	assert.Equal(t, map[string]string{"a.csv": "a\n1\n", "b.csv": "b\n2\n"}, stagedFiles(t, bucket, req.Filename))
+}
+
+func TestInputStager_Slots(t *testing.T) {
+	bucket := t.TempDir()
+	stager := newInputStager(storage.NewFSStorage(bucket, logger.NewTestLogger()), nil, nil, logger.NewTestLogger())
+
+	archive := newZip(t, testUpload{name: "a.csv", content: []byte("a\n")})
+	req, err := stager.stage(newUploadRequest(t, nil,
+		testUpload{name: "racks.zip", content: archive, field: "racks"},
+		testUpload{name: "list.csv", content: []byte("d\n"), field: "devices"},
+		testUpload{name: "notes.txt", content: []byte("n\n")},
+	))
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	assert.NoError(t, compressor.Decompress(context.Background(), filepath.Join(bucket, req.Filename), dir))
+	for name, content := range map[string]string{"racks/racks.zip": string(archive), "devices/list.csv": "d\n", "notes.txt": "n\n"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(data), name)
+	}
+
+	_, err = stager.stage(newUploadRequest(t, nil,
+		testUpload{name: "a.csv", content: []byte("a\n"), field: "racks"},
+		testUpload{name: "b.csv", content: []byte("b\n"), field: "racks"},
+	))
+	assert.ErrorIs(t, err, errInputSlot)
+
+	_, err = stager.stage(newUploadRequest(t, nil, testUpload{name: "a.csv", content: []byte("a\n"), field: "racks.csv"}))
+	assert.ErrorIs(t, err, errInputSlot)
+}
+
+// algorithmsFunc lists the algorithms with a function
+type algorithmsFunc func(ctx context.Context) (*models.AlgorithmsResponse, error)
+
+func (f algorithmsFunc) GetAlgorithms(ctx context.Context) (*models.AlgorithmsResponse, error) {
+	return f(ctx)
+}
+
+func TestInputStager_Validation(t *testing.T) {
+	list := &models.AlgorithmsResponse{Default: "rack-greedy@1.2", Algorithms: []models.Algorithm{
+		{ID: "rack-greedy@1.1", Name: "rack-greedy", Version: "1.1"},
+		{ID: "rack-greedy@1.2", Name: "rack-greedy", Version: "1.2", Inputs: []models.InputSlot{
+			{Name: "racks", EqualRows: true, CSV: &models.CSVSchema{Columns: []models.CSVColumn{{Name: "id", Type: "integer", Required: true}}}},
+			{Name: "devices", EqualRows: true, CSV: &models.CSVSchema{Delimiter: ";"}},
+		}},
+	}}
+	listed := 0
+	stager := newInputStager(storage.NewFSStorage(t.TempDir(), logger.NewTestLogger()), algorithmsFunc(func(ctx context.Context) (*models.AlgorithmsResponse, error) {
+		listed++
+		return list, nil
+	}), nil, logger.NewTestLogger())
+
+	racks := testUpload{name: "r.csv", content: []byte("id,name\r\n1,a\r\n2,b\r\n"), field: "racks"}
+	devices := testUpload{name: "d.csv", content: []byte("\xef\xbb\xbfname;size\nd1;1\nd2;2\n"), field: "devices"}
+	for _, algorithm := range []string{"", "rack-greedy", "rack-greedy@1.2"} {
+		_, err := stager.stage(newUploadRequest(t, map[string]string{"algorithm": algorithm}, racks, devices))
+		assert.NoError(t, err, algorithm)
+	}
+
+	_, err := stager.stage(newUploadRequest(t, nil,
+		testUpload{name: "r.csv", content: []byte("id,name\n1,a\nx,b\n,c\n"), field: "racks"}, devices))
+	var dataErr *dataError
+	if assert.ErrorAs(t, err, &dataErr) {
+		assert.Equal(t, []tabular.Violation{
+			{File: "racks/r.csv", Line: 3, Column: "id", Message: "'x' is not an integer"},
+			{File: "racks/r.csv", Line: 4, Column: "id", Message: "value is required"},
+		}, dataErr.violations)
+	}
+
+	_, err = stager.stage(newUploadRequest(t, nil, racks, testUpload{name: "d.csv", content: []byte("name\nd1\n"), field: "devices"}))
+	assert.EqualError(t, err, "invalid input data: devices/d.csv: has 1 rows, the racks input has 2")
+
+	// the files which are not in a slot and the algorithms without schemas are not validated
+	listed = 0
+	_, err = stager.stage(newUploadRequest(t, nil, testUpload{name: "r.csv", content: []byte("x\n")}))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, listed)
+	_, err = stager.stage(newUploadRequest(t, map[string]string{"algorithm": "rack-greedy@1.1"}, testUpload{name: "r.csv", content: []byte("x\n"), field: "racks"}))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, listed)
+}
+
+// newXLSX builds a workbook with the Racks sheet of the given rows of inline strings
+func newXLSX(t *testing.T, rows ...[]string) []byte {
+	var sheet strings.Builder
+	for _, row := range rows {
+		sheet.WriteString("<row>")
+		for _, value := range row {
+			sheet.WriteString(`<c t="inlineStr"><is><t>` + value + `</t></is></c>`)
+		}
+		sheet.WriteString("</row>")
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Racks" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml":   `<worksheet><sheetData>` + sheet.String() + `</sheetData></worksheet>`,
+	} {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestInputStager_Convert(t *testing.T) {
+	bucket := t.TempDir()
+	list := &models.AlgorithmsResponse{Default: "rack-greedy@1.2", Algorithms: []models.Algorithm{
+		{ID: "rack-greedy@1.2", Name: "rack-greedy", Version: "1.2", Inputs: []models.InputSlot{
+			{Name: "racks", CSV: &models.CSVSchema{Columns: []models.CSVColumn{{Name: "id", Type: "integer", Required: true}}}},
+			{Name: "devices", CSV: &models.CSVSchema{Columns: []models.CSVColumn{{Name: "rack"}, {Name: "name"}}}},
+		}},
+	}}
+	stager := newInputStager(storage.NewFSStorage(bucket, logger.NewTestLogger()), algorithmsFunc(func(ctx context.Context) (*models.AlgorithmsResponse, error) {
+		return list, nil
+	}), nil, logger.NewTestLogger())
+
+	inventory := newXLSX(t, []string{"id", "name"}, []string{"1", "r1"})
+	devices := `[{"name": "d1", "rack": 1}, {"name": "d2", "rack": 1, "u": 2}]`
+	req, err := stager.stage(newUploadRequest(t, map[string]string{"sheet": "Racks"},
+		testUpload{name: "inventory.xlsx", content: inventory, field: "racks"},
+		testUpload{name: "devices.json", content: []byte(devices), field: "devices"},
+		testUpload{name: "notes.tsv", content: []byte("a\tb\n1\t2\n")},
+	))
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	assert.NoError(t, compressor.Decompress(context.Background(), filepath.Join(bucket, req.Filename), dir))
+	for name, content := range map[string]string{
+		"racks/inventory.csv":             "id,name\n1,r1\n",
+		"devices/devices.csv":             "rack,name,u\n1,d1,\n1,d2,2\n",
+		"notes.csv":                       "a,b\n1,2\n",
+		".originals/racks/inventory.xlsx": string(inventory),
+		".originals/devices/devices.json": devices,
+		".originals/notes.tsv":            "a\tb\n1\t2\n",
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(data), name)
+	}
+
+	_, err = stager.stage(newUploadRequest(t, map[string]string{"sheet": "Devices"},
+		testUpload{name: "inventory.xlsx", content: inventory, field: "racks"},
+		testUpload{name: "devices.json", content: []byte(`{"name": "d1"}`), field: "devices"},
+	))
+	var dataErr *dataError
+	if assert.ErrorAs(t, err, &dataErr) {
+		assert.Equal(t, []tabular.Violation{
+			{File: "devices/devices.json", Message: "an array of objects is expected"},
+			{File: "racks/inventory.xlsx", Message: "sheet 'Devices' not found, the workbook has: Racks"},
+		}, dataErr.violations)
+	}
+
+	// the converted files are validated
+	_, err = stager.stage(newUploadRequest(t, nil, testUpload{name: "inventory.xlsx", content: newXLSX(t, []string{"id"}, []string{"x"}), field: "racks"}))
+	assert.EqualError(t, err, "invalid input data: racks/inventory.csv:2: column 'id': 'x' is not an integer")
+
+	_, err = stager.stage(newUploadRequest(t, nil, testUpload{name: "a.csv", content: []byte("a\n")}, testUpload{name: "a.tsv", content: []byte("a\n")}))
+	assert.ErrorIs(t, err, errDuplicateFile)
+}
+
+func TestInputStager_Errors(t *testing.T) {
+	bucket := t.TempDir()
+	stager := newInputStager(storage.NewFSStorage(bucket, logger.NewTestLogger()), nil, nil, logger.NewTestLogger())
+
+	_, err := stager.stage(newUploadRequest(t, map[string]string{"format": "rar"}, testUpload{name: "a.csv", content: []byte("a\n")}))
+	assert.ErrorIs(t, err, errResultFormat)
+
+	for _, params := range []string{`[1]`, `{"seed": 1`, `null`, `{} {}`} {
+		_, err = stager.stage(newUploadRequest(t, map[string]string{"parameters": params}, testUpload{name: "a.csv", content: []byte("a\n")}))
+		assert.ErrorIs(t, err, errParameters, params)
+	}
+
+	archive := newZip(t, testUpload{name: "x/a.csv", content: []byte("1")}, testUpload{name: "y/a.csv", content: []byte("2")})
+	_, err = stager.stage(newUploadRequest(t, nil, testUpload{name: "input.zip", content: archive}))
+	assert.ErrorIs(t, err, errDuplicateFile)
+
+	archive = newZip(t, testUpload{name: "../a.csv", content: []byte("1")})
+	_, err = stager.stage(newUploadRequest(t, nil, testUpload{name: "input.zip", content: archive}))
+	assert.True(t, compressor.IsInvalidArchive(err))
+
+	_, err = stager.stage(newUploadRequest(t, nil))
+	assert.ErrorIs(t, err, compressor.ErrNoFiles)
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/jobs"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/gorilla/mux"
+)
+
+const (
+	ErrMsgJobNotFound    = "job not found"
+	ErrMsgJobFinished    = "job has already finished"
+	ErrMsgRerunOverride  = "a rerun replaces a single input slot, by a slot file or a dataset with a slot"
+	ErrMsgRerunInput     = "the input archive of the job is not in the bucket"
+	ErrMsgRerunAlgorithm = "the algorithm of the job can't be resolved, select it by name"
+)
+
+type CreateJobHandler struct {
+	stager *inputStager
+	jobs   *jobs.Manager
+	log    *logger.Logger
+}
+
+func NewCreateJobHandler(stager *inputStager, jobs *jobs.Manager, log *logger.Logger) *CreateJobHandler {
+	return &CreateJobHandler{
+		stager: stager,
+		jobs:   jobs,
+		log:    log,
+	}
+}
+
+// Create job
+// @Summary Upload files and start an optimization job
+// @Description Upload files for optimization script and return the id of the queued job right away.
+// @Description A cached result of the same input set, algorithm and parameters is returned as a succeeded job,
+// @Description a running job with the same input is returned instead of starting another one.
+// @ID create-job-handler
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param   file formData file false "input files or a single zip, tar.gz, tar.zst or tar archive"
+// @Param   {slot} formData file false "the file of an input slot of the algorithm, e.g. racks"
+// @Param   format formData string false "result archive format: tar.gz (default), zip, tar.zst or tar"
+// @Param   algorithm formData string false "algorithm name@version or name, the default algorithm is run when empty"
+// @Param   parameters formData string false "JSON object with the run parameters, e.g. {\"seed\": 42}"
+// @Param   sheet formData string false "sheet of the uploaded xlsx files, the first sheet by default"
+// @Param   dataset formData string false "stored dataset [slot=]name@version or [slot=]name added to the input set, repeat it for several datasets; the rows of the datasets of the same slot are combined"
+// @Param   nocache query string false "1 to run the optimization even if the result of the same input is cached"
+// @Success 202 {object} models.JobResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /v1/jobs [post]
+func (h *CreateJobHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+	job, err := h.stager.submit(r, h.jobs)
+	if err != nil {
+		writeStageError(writer, err, h.log)
+		return
+	}
+
+	writeResponse(writer, newJobResponse(job), http.StatusAccepted, h.log)
+}
+
+type RerunJobHandler struct {
+	stager *inputStager
+	jobs   *jobs.Manager
+	log    *logger.Logger
+}
+
+func NewRerunJobHandler(stager *inputStager, jobs *jobs.Manager, log *logger.Logger) *RerunJobHandler {
+	return &RerunJobHandler{
+		stager: stager,
+		jobs:   jobs,
+		log:    log,
+	}
+}
+
+// Rerun job
+// @Summary Run a job again on its input
+// @Description Start a job on the input archive of an earlier job and return its id right away, the new job links to the earlier one as its parent.
+// @Description The parameters, the algorithm or its version and a single input slot can be changed, the other settings are taken from the earlier job.
+// @Description The slot files, the kept ones included, are validated against the algorithm of the rerun, the rerun always runs the optimization.
+// @ID rerun-job-handler
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param   id path string true "id of the job to run again"
+// @Param   {slot} formData file false "the file replacing an input slot, e.g. racks"
+// @Param   dataset formData string false "stored dataset slot=name@version or slot=name replacing an input slot, repeat it to combine several datasets into the slot"
+// @Param   format formData string false "result archive format: tar.gz, zip, tar.zst or tar, the format of the earlier job by default"
+// @Param   algorithm formData string false "algorithm name@version or name, the algorithm of the earlier job by default"
+// @Param   version formData string false "version of the algorithm which ran the earlier job"
+// @Param   parameters formData string false "JSON object merged into the parameters of the earlier job, null drops a parameter"
+// @Param   sheet formData string false "sheet of the uploaded xlsx file, the first sheet by default"
+// @Success 202 {object} models.JobResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Router /v1/jobs/{id}/rerun [post]
+func (h *RerunJobHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+	parent, err := h.jobs.Get(mux.Vars(r)["id"])
+	if errors.Is(err, jobs.ErrNotFound) {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgJobNotFound), http.StatusNotFound, h.log)
+		return
+	}
+	if err != nil {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgInternal), http.StatusInternalServerError, h.log)
+		return
+	}
+
+	job, err := h.stager.rerun(r, h.jobs, parent)
+	switch {
+	case errors.Is(err, errRerunOverride):
+		writeResponse(writer, models.NewErrorResponse(ErrMsgRerunOverride), http.StatusBadRequest, h.log)
+		return
+	case errors.Is(err, errRerunInput):
+		h.log.Errorf("error rerunning job '%s': %s", parent.ID, err)
+		writeResponse(writer, models.NewErrorResponse(ErrMsgRerunInput), http.StatusGone, h.log)
+		return
+	case errors.Is(err, errRerunAlgorithm):
+		writeResponse(writer, models.NewErrorResponse(ErrMsgRerunAlgorithm), http.StatusBadRequest, h.log)
+		return
+	case err != nil:
+		writeStageError(writer, err, h.log)
+		return
+	}
+
+	writeResponse(writer, newJobResponse(job), http.StatusAccepted, h.log)
+}
+
+type JobStatusHandler struct {
+	jobs *jobs.Manager
+	log  *logger.Logger
+}
+
+func NewJobStatusHandler(jobs *jobs.Manager, log *logger.Logger) *JobStatusHandler {
+	return &JobStatusHandler{
+		jobs: jobs,
+		log:  log,
+	}
+}
+
+// Job status
+// @Summary Get the state of an optimization job
+// @Description Report the status, timings, error category and result location of a job
+// @ID job-status-handler
+// @Produce  json
+// @Param   id path string true "job id"
+// @Success 200 {object} models.JobResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /v1/jobs/{id} [get]
+func (h *JobStatusHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.Get(mux.Vars(r)["id"])
+	if errors.Is(err, jobs.ErrNotFound) {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgJobNotFound), http.StatusNotFound, h.log)
+		return
+	}
+	if err != nil {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgInternal), http.StatusInternalServerError, h.log)
+		return
+	}
+
+	writeResponse(writer, newJobResponse(job), http.StatusOK, h.log)
+}
+
+type CancelJobHandler struct {
+	jobs *jobs.Manager
+	log  *logger.Logger
+}
+
+func NewCancelJobHandler(jobs *jobs.Manager, log *logger.Logger) *CancelJobHandler {
+	return &CancelJobHandler{
+		jobs: jobs,
+		log:  log,
+	}
+}
+
+// Cancel job
+// @Summary Cancel an optimization job
+// @Description Cancel a queued job or stop a running one, the optimization script is killed
+// @ID cancel-job-handler
+// @Produce  json
+// @Param   id path string true "job id"
+// @Success 200 {object} models.JobResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /v1/jobs/{id} [delete]
+func (h *CancelJobHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.Cancel(mux.Vars(r)["id"])
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		writeResponse(writer, models.NewErrorResponse(ErrMsgJobNotFound), http.StatusNotFound, h.log)
+		return
+	case errors.Is(err, jobs.ErrFinished):
+		writeResponse(writer, models.NewErrorResponse(ErrMsgJobFinished), http.StatusConflict, h.log)
+		return
+	case err != nil:
+		writeResponse(writer, models.NewErrorResponse(ErrMsgInternal), http.StatusInternalServerError, h.log)
+		return
+	}
+
+	writeResponse(writer, newJobResponse(job), http.StatusOK, h.log)
+}
+
+func newJobResponse(job *jobs.Job) models.JobResponse {
+	resp := models.JobResponse{
+		ID:         job.ID,
+		Status:     string(job.Status),
+		Input:      job.Request.Filename,
+		Algorithm:  job.Request.Algorithm,
+		Parameters: job.Request.Parameters,
+		CreatedAt:  job.CreatedAt,
+		StartedAt:  timeOrNil(job.StartedAt),
+		FinishedAt: timeOrNil(job.FinishedAt),
+		Cached:     job.Cached,
+		Datasets:   job.Request.Datasets,
+		Parent:     job.Parent,
+	}
+	if job.Status == jobs.StatusFailed || job.Status == jobs.StatusCanceled {
+		resp.Error = &models.ErrorResponse{
+			Text:       job.Error,
+			Code:       job.ErrorCode,
+			Script:     job.Script,
+			Violations: job.Violations,
+		}
+	}
+	if p := job.Progress; p != nil {
+		resp.Progress = &models.ProgressResponse{Percent: p.Percent, Message: p.Message, UpdatedAt: p.UpdatedAt}
+	}
+	if job.Result != nil {
+		result := newUploadResponse(job.Result)
+		resp.Result = &result
+		resp.ExecutionTime = job.Result.ExecutionTime.Milliseconds()
+	}
+	return resp
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/jobs"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/optimization"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// cancelableOptimizer runs until the run is canceled, it reports the started and the canceled runs
+type cancelableOptimizer struct {
+	started  chan string
+	canceled chan string
+}
+
+func newCancelableOptimizer() *cancelableOptimizer {
+	return &cancelableOptimizer{started: make(chan string, 1), canceled: make(chan string, 1)}
+}
+
+func (o *cancelableOptimizer) PostOptimize(ctx context.Context, req optimization.Request) (*optimization.Response, error) {
+	o.started <- req.Filename
+	<-ctx.Done()
+	o.canceled <- req.Filename
+	return nil, ctx.Err()
+}
+
+func TestCancelJobHandler(t *testing.T) {
+	opt := newCancelableOptimizer()
+	m := jobs.NewManager(opt, nil, logger.NewTestLogger())
+	r := mux.NewRouter()
+	r.Handle("/api/v1/jobs/{id}", NewCancelJobHandler(m, logger.NewTestLogger())).Methods(http.MethodDelete)
+	cancel := func(id string, expectedStatus int) string {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodDelete, "/api/v1/jobs/"+id, nil)
+		assert.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		assert.Equal(t, expectedStatus, recorder.Code)
+		return recorder.Body.String()
+	}
+
+	job, err := m.Submit(optimization.Request{Filename: "input_files_1.tar.gz"})
+	assert.NoError(t, err)
+	assert.Equal(t, "input_files_1.tar.gz", <-opt.started)
+
+	var resp models.JobResponse
+	assert.NoError(t, json.Unmarshal([]byte(cancel(job.ID, http.StatusOK)), &resp))
+	assert.Equal(t, "canceled", resp.Status)
+	assert.Equal(t, &models.ErrorResponse{Text: "job canceled", Code: jobs.ErrCodeCanceled}, resp.Error)
+	assert.NotNil(t, resp.FinishedAt)
+	assert.Equal(t, "input_files_1.tar.gz", <-opt.canceled)
+
+	job, err = m.Wait(context.Background(), job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, jobs.StatusCanceled, job.Status)
+
+	assert.JSONEq(t, `{"text":"`+ErrMsgJobFinished+`"}`, cancel(job.ID, http.StatusConflict))
+	assert.JSONEq(t, `{"text":"`+ErrMsgJobNotFound+`"}`, cancel("unknown", http.StatusNotFound))
+}
+
+func TestUploadHandler_ClientDisconnect(t *testing.T) {
+	opt := newCancelableOptimizer()
+	m := jobs.NewManager(opt, nil, logger.NewTestLogger())
+	stager := newInputStager(storage.NewFSStorage(t.TempDir(), logger.NewTestLogger()), nil, nil, logger.NewTestLogger())
+	handler := NewUploadHandler(stager, m, logger.NewTestLogger())
+
+	ctx, disconnect := context.WithCancel(context.Background())
+	defer disconnect()
+	req := newUploadRequest(t, nil, testUpload{name: "a.csv", content: []byte("a\n1\n")}).WithContext(ctx)
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	// the run of the job is stopped once the client disconnects
+	input := <-opt.started
+	disconnect()
+	select {
+	case canceled := <-opt.canceled:
+		assert.Equal(t, input, canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job of the disconnected client is still running")
+	}
+	<-served
+}
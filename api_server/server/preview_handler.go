@@ -0,0 +1,176 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/jobs"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
+	"github.com/gorilla/mux"
+)
+
+const (
+	ErrMsgJobNoResult   = "job has no result"
+	ErrMsgPreviewQuery  = "invalid preview query, offset and limit must be non-negative numbers and a filter column=value"
+	ErrMsgPreviewFile   = "file not found in the result"
+	ErrMsgPreviewResult = "failed to read the result"
+
+	defaultPreviewLimit = 100
+	maxPreviewLimit     = 1000
+)
+
+type PreviewHandler struct {
+	jobs    *jobs.Manager
+	storage storage.Storage
+	log     *logger.Logger
+}
+
+func NewPreviewHandler(jobs *jobs.Manager, storage storage.Storage, log *logger.Logger) *PreviewHandler {
+	return &PreviewHandler{
+		jobs:    jobs,
+		storage: storage,
+		log:     log,
+	}
+}
+
+// previewQuery is the parsed query of a preview request
+type previewQuery struct {
+	offset  int
+	limit   int
+	file    string
+	filters []tabular.Filter
+}
+
+// Preview job result
+// @Summary Preview the CSV files of a job result
+// @Description Stream the result archive of a succeeded job and return the header, the row count and a page of rows of every CSV file.
+// @Description The filters keep the rows whose column equals the value, a file without the column has no matching rows.
+// @ID preview-handler
+// @Produce  json
+// @Param   id path string true "job id"
+// @Param   offset query int false "index of the first row of the page, 0 by default"
+// @Param   limit query int false "number of rows of the page, 100 by default, at most 1000"
+// @Param   file query string false "name of the CSV file in the result, every CSV file by default"
+// @Param   filter query []string false "column=value, repeat for several columns"
+// @Success 200 {object} models.PreviewResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /v1/jobs/{id}/preview [get]
+func (h *PreviewHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+	job, ok := resultJob(writer, h.jobs, mux.Vars(r)["id"], h.log)
+	if !ok {
+		return
+	}
+	query, ok := parsePreviewQuery(r)
+	if !ok {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgPreviewQuery), http.StatusBadRequest, h.log)
+		return
+	}
+
+	body, _, err := h.storage.Get(r.Context(), job.Result.Filepath)
+	if err != nil {
+		writeDownloadError(writer, err, h.log)
+		return
+	}
+	defer func() {
+		if err := body.Close(); err != nil {
+			h.log.Warnf("error closing object '%s': %s", job.Result.Filepath, err)
+		}
+	}()
+
+	resp := models.PreviewResponse{
+		ID:     job.ID,
+		Result: job.Result.Filepath,
+		Offset: query.offset,
+		Limit:  query.limit,
+		Files:  make([]models.FilePreview, 0),
+	}
+	err = compressor.Walk(r.Context(), body, func(name string, content io.Reader) error {
+		if !strings.EqualFold(path.Ext(name), ".csv") || (query.file != "" && name != query.file) {
+			return nil
+		}
+		resp.Files = append(resp.Files, newFilePreview(name, content, query))
+		return nil
+	})
+	if err != nil {
+		h.log.Errorf("error reading result '%s': %s", job.Result.Filepath, err)
+		writeResponse(writer, models.NewErrorResponse(ErrMsgPreviewResult), http.StatusInternalServerError, h.log)
+		return
+	}
+	if query.file != "" && len(resp.Files) == 0 {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgPreviewFile), http.StatusNotFound, h.log)
+		return
+	}
+
+	writeResponse(writer, resp, http.StatusOK, h.log)
+}
+
+// resultJob returns the job if it has a result, otherwise it writes the error response and returns false
+func resultJob(writer http.ResponseWriter, manager *jobs.Manager, id string, log *logger.Logger) (*jobs.Job, bool) {
+	job, err := manager.Get(id)
+	if errors.Is(err, jobs.ErrNotFound) {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgJobNotFound), http.StatusNotFound, log)
+		return nil, false
+	}
+	if err != nil {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgInternal), http.StatusInternalServerError, log)
+		return nil, false
+	}
+	if job.Result == nil {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgJobNoResult), http.StatusConflict, log)
+		return nil, false
+	}
+	return job, true
+}
+
+func newFilePreview(name string, content io.Reader, query previewQuery) models.FilePreview {
+	preview := models.FilePreview{Name: name, Header: []string{}, Rows: [][]string{}}
+	page, err := tabular.ReadPage(content, query.offset, query.limit, query.filters...)
+	if err != nil {
+		preview.Error = err.Error()
+		return preview
+	}
+	if page.Header != nil {
+		preview.Header = page.Header
+	}
+	preview.RowCount = page.Rows
+	preview.Rows = page.Records
+	return preview
+}
+
+func parsePreviewQuery(r *http.Request) (previewQuery, bool) {
+	values := r.URL.Query()
+	query := previewQuery{limit: defaultPreviewLimit, file: values.Get("file")}
+	for param, target := range map[string]*int{"offset": &query.offset, "limit": &query.limit} {
+		value := values.Get(param)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return query, false
+		}
+		*target = n
+	}
+	if query.limit > maxPreviewLimit {
+		query.limit = maxPreviewLimit
+	}
+	for _, filter := range values["filter"] {
+		parts := strings.SplitN(filter, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return query, false
+		}
+		query.filters = append(query.filters, tabular.Filter{Column: strings.TrimSpace(parts[0]), Value: strings.TrimSpace(parts[1])})
+	}
+	return query, true
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/jobs"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/optimization"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type optimizerFunc func(req optimization.Request) (*optimization.Response, error)
+
+func (f optimizerFunc) PostOptimize(ctx context.Context, req optimization.Request) (*optimization.Response, error) {
+	return f(req)
+}
+
+// newTestJob runs a job to completion, the job succeeds with the result if it is not empty
+func newTestJob(t *testing.T, m *jobs.Manager, result string) string {
+	job, err := m.Submit(optimization.Request{Filename: result})
+	assert.NoError(t, err)
+	_, err = m.Wait(context.Background(), job.ID)
+	assert.NoError(t, err)
+	return job.ID
+}
+
+func TestPreviewHandler(t *testing.T) {
+	bucket := t.TempDir()
+	workDir := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(workDir, "extra"), os.ModePerm))
+	for name, content := range map[string]string{
+		resultOutputFilename: "id,status\n1,ok\n2,failed\n3,ok\n",
+		"extra/kpis.csv":     "kpi;value\ncost;10\n",
+		"result.json":        "{}",
+		"broken.csv":         "id\n\"1\n",
+	} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(workDir, name), []byte(content), os.ModePerm))
+	}
+	_, err := compressor.CompressFormat(context.Background(), compressor.Zip, filepath.Join(bucket, "opt_result_1"), workDir,
+		resultOutputFilename, "extra/kpis.csv", "result.json", "broken.csv")
+	assert.NoError(t, err)
+
+	m := jobs.NewManager(optimizerFunc(func(req optimization.Request) (*optimization.Response, error) {
+		if req.Filename == "" {
+			return nil, &optimization.Error{StatusCode: http.StatusBadRequest, Text: "script error"}
+		}
+		return &optimization.Response{Filepath: req.Filename}, nil
+	}), nil, logger.NewTestLogger())
+	succeeded := newTestJob(t, m, "opt_result_1.zip")
+	failed := newTestJob(t, m, "")
+	missing := newTestJob(t, m, "opt_result_2.tar.gz")
+
+	r := mux.NewRouter()
+	r.Handle("/api/v1/jobs/{id}/preview", NewPreviewHandler(m, storage.NewFSStorage(bucket, logger.NewTestLogger()), logger.NewTestLogger()))
+
+	testCases := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "every csv file",
+			url:            "/api/v1/jobs/" + succeeded + "/preview?limit=1",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"id":"` + succeeded + `","result":"opt_result_1.zip","offset":0,"limit":1,"files":[` +
+				`{"name":"def_output.csv","header":["id","status"],"rowCount":3,"rows":[["1","ok"]]},` +
+				`{"name":"extra/kpis.csv","header":["kpi","value"],"rowCount":1,"rows":[["cost","10"]]},` +
+				`{"name":"broken.csv","header":[],"rowCount":0,"rows":[],"error":"malformed csv: line 2: unterminated quoted field"}]}`,
+		},
+		{
+			name:           "file, offset and filter",
+			url:            "/api/v1/jobs/" + succeeded + "/preview?file=def_output.csv&offset=1&filter=status%3Dok",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"id":"` + succeeded + `","result":"opt_result_1.zip","offset":1,"limit":100,"files":[` +
+				`{"name":"def_output.csv","header":["id","status"],"rowCount":2,"rows":[["3","ok"]]}]}`,
+		},
+		{
+			name:           "unknown file",
+			url:            "/api/v1/jobs/" + succeeded + "/preview?file=result.csv",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"text":"file not found in the result"}`,
+		},
+		{
+			name:           "invalid limit",
+			url:            "/api/v1/jobs/" + succeeded + "/preview?limit=-1",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"text":"` + ErrMsgPreviewQuery + `"}`,
+		},
+		{
+			name:           "invalid filter",
+			url:            "/api/v1/jobs/" + succeeded + "/preview?filter=status",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"text":"` + ErrMsgPreviewQuery + `"}`,
+		},
+		{
+			name:           "failed job",
+			url:            "/api/v1/jobs/" + failed + "/preview",
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"text":"job has no result"}`,
+		},
+		{
+			name:           "result removed",
+			url:            "/api/v1/jobs/" + missing + "/preview",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"text":"result not found"}`,
+		},
+		{
+			name:           "unknown job",
+			url:            "/api/v1/jobs/unknown/preview",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"text":"job not found"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, tc.url, nil)
+			assert.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.expectedStatus, recorder.Code)
+			assert.JSONEq(t, tc.expectedBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestPreviewHandler_LimitCapped(t *testing.T) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/preview?limit=5000", nil)
+	assert.NoError(t, err)
+
+	query, ok := parsePreviewQuery(req)
+	assert.True(t, ok)
+	assert.Equal(t, maxPreviewLimit, query.limit)
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/jobs"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/environment"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
+)
+
+// restoreDir is the directory of the input archive of the parent job in the temp dir
+const restoreDir = "parent"
+
+var (
+	// errRerunOverride is returned when a rerun replaces anything but a single input slot
+	errRerunOverride = errors.New("a rerun replaces a single input slot")
+	// errRerunInput is returned when the input archive of the parent job is gone from the bucket
+	errRerunInput = errors.New("the input archive of the job is not in the bucket")
+	// errRerunAlgorithm is returned when a version is requested but the algorithm of the parent job is unknown
+	errRerunAlgorithm = errors.New("the algorithm of the job can't be resolved")
+)
+
+// rerun starts a job for the input archive of the parent job. The optional "parameters" form field is merged into
+// the parameters of the parent, a null value drops a parameter; the optional "algorithm" form field selects another
+// algorithm and the optional "version" form field another version of the algorithm of the parent.
+// A single input slot can be replaced by a slot file field or by the dataset fields of the slot, the archive is rebuilt then.
+// The algorithm of the rerun is resolved whenever the input has slot files or the rerun changes the algorithm, the slot
+// files are validated against it, the kept ones included; the flat files have no schema. The rerun always runs the optimization.
+func (s *inputStager) rerun(r *http.Request, manager *jobs.Manager, parent *jobs.Job) (*jobs.Job, error) {
+	upload, err := s.parseOverride(r)
+	input := &stagedInput{stager: s, form: r.MultipartForm}
+	defer input.close()
+	if err != nil {
+		return nil, err
+	}
+	if err := input.prepareRerun(r, parent, upload); err != nil {
+		return nil, err
+	}
+
+	key := ""
+	if manager.Caching() {
+		if key, err = input.key(r.Context()); err != nil {
+			s.log.Warnf("result cache skipped: %s", err)
+		}
+	}
+	req, err := input.upload(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	job, err := manager.Rerun(parent.ID, key, req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errSubmit, err)
+	}
+	return job, nil
+}
+
+// parseOverride returns the uploaded file of the slot replaced by a rerun, none if the form has no files.
+// The form of a rerun may be multipart, url encoded or empty.
+func (s *inputStager) parseOverride(r *http.Request) ([]compressor.Entry, error) {
+	if err := r.ParseMultipartForm(maxMemory); err != nil {
+		if errors.Is(err, http.ErrNotMultipart) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error parsing form: %s", err)
+	}
+	entries, slotted, err := s.parseFileUpload(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 1 || (len(entries) == 1 && !slotted) {
+		return nil, errRerunOverride
+	}
+	return entries, nil
+}
+
+func (in *stagedInput) prepareRerun(r *http.Request, parent *jobs.Job, upload []compressor.Entry) error {
+	s := in.stager
+	format, err := resultFormat(r)
+	if err != nil {
+		return err
+	}
+	in.format = parent.Request.Format
+	if format != "" {
+		in.format = format
+	}
+	params, err := runParameters(r)
+	if err != nil {
+		return err
+	}
+	in.params = mergeParameters(parent.Request.Parameters, params)
+	if in.ref, err = s.rerunAlgorithm(r, parent); err != nil {
+		return err
+	}
+	if in.datasets, err = s.datasetRefs(r); err != nil {
+		return err
+	}
+
+	// the replaced slot, it may be filled by several datasets
+	slot := ""
+	switch {
+	case len(upload) > 0 && len(in.datasets) > 0:
+		return errRerunOverride
+	case len(upload) == 1:
+		slot, _ = path.Split(upload[0].Name)
+	case len(in.datasets) > 0:
+		for _, ref := range in.datasets {
+			if ref.slot == "" || ref.slot != in.datasets[0].slot {
+				return errRerunOverride
+			}
+		}
+		slot = in.datasets[0].slot + "/"
+	}
+
+	in.env = environment.New(os.TempDir(), inputEnvPrefix)
+	if err := in.env.CreateTempDir(); err != nil {
+		in.env = nil
+		return fmt.Errorf("error creating tempdir: %s", err)
+	}
+	restored, err := s.restore(r.Context(), parent.Request.Filename, filepath.Join(in.env.Dir(), restoreDir))
+	if err != nil {
+		return err
+	}
+
+	entries := make([]compressor.Entry, 0, len(restored))
+	slotted := slot != ""
+	for _, entry := range restored {
+		if slot != "" && (strings.HasPrefix(entry.Name, slot) || strings.HasPrefix(entry.Name, path.Join(tabular.OriginalsDir, slot)+"/")) {
+			continue
+		}
+		if dir, _ := path.Split(entry.Name); dir != "" && !isOriginal(entry.Name) {
+			slotted = true
+		}
+		entries = append(entries, entry)
+	}
+	// the kept slot files are checked again as they may be run by another algorithm
+	if slotted || in.ref != parent.Request.Algorithm {
+		in.algorithm = s.algorithm(r.Context(), in.ref)
+	}
+
+	if len(upload) > 0 {
+		converted, err := s.convert(upload, in.algorithm, r.FormValue("sheet"), filepath.Join(in.env.Dir(), convertDir))
+		if err != nil {
+			return err
+		}
+		entries = append(entries, converted...)
+	}
+	stored, err := s.datasetEntries(r.Context(), in.datasets, in.algorithm, filepath.Join(in.env.Dir(), datasetDir))
+	if err != nil {
+		return err
+	}
+	if entries, err = mergeEntries(entries, stored); err != nil {
+		return err
+	}
+	if in.algorithm != nil {
+		if err := validateSlots(in.algorithm, entries); err != nil {
+			return err
+		}
+	}
+	in.entries = entries
+
+	// the datasets of the parent are kept unless they filled the replaced slot
+	for _, ref := range parent.Request.Datasets {
+		if slot == "" || !strings.HasPrefix(ref, strings.TrimSuffix(slot, "/")+"=") {
+			in.inherited = append(in.inherited, ref)
+		}
+	}
+	if slot == "" {
+		in.archive = parent.Request.Filename
+	}
+	return nil
+}
+
+// rerunAlgorithm returns the algorithm reference of a rerun: the algorithm form field, the version form field
+// applied to the algorithm which ran the parent or the algorithm requested by the parent
+func (s *inputStager) rerunAlgorithm(r *http.Request, parent *jobs.Job) (string, error) {
+	if ref := strings.TrimSpace(r.FormValue("algorithm")); ref != "" {
+		return ref, nil
+	}
+	version := strings.TrimSpace(r.FormValue("version"))
+	if version == "" {
+		return parent.Request.Algorithm, nil
+	}
+
+	name := parent.Request.Algorithm
+	if parent.Result != nil && parent.Result.Algorithm != "" {
+		name = parent.Result.Algorithm
+	}
+	if name == "" {
+		// the parent ran the default algorithm
+		algorithm := s.algorithm(r.Context(), "")
+		if algorithm == nil {
+			return "", errRerunAlgorithm
+		}
+		name = algorithm.Name
+	}
+	if i := strings.LastIndex(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	return name + "@" + version, nil
+}
+
+// restore downloads the input archive from the bucket, extracts it into dir and returns its files
+func (s *inputStager) restore(ctx context.Context, filename string, dir string) ([]compressor.Entry, error) {
+	object, _, err := s.storage.Get(ctx, filename)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("%w: '%s'", errRerunInput, filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	s.log.Debugf("Restore the input archive '%s'", filename)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err := compressor.Extract(ctx, object, dir); err != nil {
+		return nil, err
+	}
+
+	entries := make([]compressor.Entry, 0)
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		entry, err := compressor.FileEntry(dir, rel)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// mergeParameters applies the overrides to the parameters of the parent run, a null override drops the parameter
+func mergeParameters(parent map[string]interface{}, overrides map[string]interface{}) map[string]interface{} {
+	if overrides == nil {
+		return parent
+	}
+	merged := make(map[string]interface{}, len(parent)+len(overrides))
+	for name, value := range parent {
+		merged[name] = value
+	}
+	for name, value := range overrides {
+		if value == nil {
+			delete(merged, name)
+			continue
+		}
+		merged[name] = value
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/jobs"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/optimization"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRerunJobHandler(t *testing.T) {
+	ctx := context.Background()
+	bucket := t.TempDir()
+	fs := storage.NewFSStorage(bucket, logger.NewTestLogger())
+	list := &models.AlgorithmsResponse{Default: "rack-greedy@1.2", Algorithms: []models.Algorithm{
+		{ID: "rack-greedy@1.2", Name: "rack-greedy", Version: "1.2", Inputs: []models.InputSlot{
+			{Name: "racks", CSV: &models.CSVSchema{Columns: []models.CSVColumn{{Name: "id", Type: "integer"}}}},
+			{Name: "devices"},
+		}},
+		{ID: "rack-milp@1.0", Name: "rack-milp", Version: "1.0", Inputs: []models.InputSlot{
+			{Name: "racks", CSV: &models.CSVSchema{Columns: []models.CSVColumn{{Name: "id", Type: "integer"}}, MinRows: 2}},
+		}},
+	}}
+	stager := newInputStager(fs, algorithmsFunc(func(ctx context.Context) (*models.AlgorithmsResponse, error) {
+		return list, nil
+	}), nil, logger.NewTestLogger())
+	m := jobs.NewManager(optimizerFunc(func(req optimization.Request) (*optimization.Response, error) {
+		return &optimization.Response{Algorithm: "rack-greedy@1.2", Filepath: "opt_result_" + req.Filename}, nil
+	}), nil, logger.NewTestLogger())
+
+	parent, err := stager.submit(newUploadRequest(t, map[string]string{"parameters": `{"seed": 1, "limit": 5}`, "format": "zip"},
+		testUpload{name: "r.csv", content: []byte("id\n1\n"), field: "racks"},
+		testUpload{name: "d.csv", content: []byte("d\n"), field: "devices"},
+	), m)
+	assert.NoError(t, err)
+	parent, err = m.Wait(ctx, parent.ID)
+	assert.NoError(t, err)
+
+	r := mux.NewRouter()
+	r.Handle("/api/v1/jobs/{id}/rerun", NewRerunJobHandler(stager, m, logger.NewTestLogger()))
+	rerun := func(id string, req *http.Request, expectedStatus int) (*jobs.Job, string) {
+		if req == nil {
+			req, err = http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+			assert.NoError(t, err)
+		}
+		req.URL.Path = "/api/v1/jobs/" + id + "/rerun"
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		assert.Equal(t, expectedStatus, recorder.Code, recorder.Body.String())
+		if recorder.Code != http.StatusAccepted {
+			return nil, recorder.Body.String()
+		}
+		var resp models.JobResponse
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+		assert.Equal(t, id, resp.Parent)
+		job, err := m.Wait(ctx, resp.ID)
+		assert.NoError(t, err)
+		return job, ""
+	}
+
+	// the same input archive and settings
+	job, _ := rerun(parent.ID, nil, http.StatusAccepted)
+	assert.Equal(t, parent.ID, job.Parent)
+	assert.Equal(t, parent.Request.Filename, job.Request.Filename)
+	assert.Equal(t, parent.Request.Parameters, job.Request.Parameters)
+	assert.Equal(t, "zip", job.Request.Format)
+	assert.Empty(t, job.Request.Algorithm)
+
+	// the parameters are merged, the version applies to the algorithm of the parent
+	form := strings.NewReader(`parameters={"seed":2,"limit":null}&version=2.0`)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", form)
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	job, _ = rerun(parent.ID, req, http.StatusAccepted)
+	assert.Equal(t, map[string]interface{}{"seed": json.Number("2")}, job.Request.Parameters)
+	assert.Equal(t, "rack-greedy@2.0", job.Request.Algorithm)
+	assert.Equal(t, parent.Request.Filename, job.Request.Filename)
+
+	// a replaced slot rebuilds the archive, a rerun of a rerun links to its own parent
+	child, _ := rerun(job.ID, newUploadRequest(t, map[string]string{"algorithm": "rack-greedy"}, testUpload{name: "new.csv", content: []byte("id\n2\n"), field: "racks"}), http.StatusAccepted)
+	assert.Equal(t, job.ID, child.Parent)
+	assert.NotEqual(t, parent.Request.Filename, child.Request.Filename)
+	assert.Equal(t, "rack-greedy", child.Request.Algorithm)
+	dir := t.TempDir()
+	assert.NoError(t, compressor.Decompress(ctx, filepath.Join(bucket, child.Request.Filename), dir))
+	for name, content := range map[string]string{"racks/new.csv": "id\n2\n", "devices/d.csv": "d\n"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(data), name)
+	}
+	_, err = os.Stat(filepath.Join(dir, "racks", "r.csv"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, body := rerun(parent.ID, newUploadRequest(t, nil, testUpload{name: "new.csv", content: []byte("id\nx\n"), field: "racks"}), http.StatusBadRequest)
+	assert.Contains(t, body, `"code":"invalid_data"`)
+	// the kept slot files are validated against the algorithm of the rerun
+	form = strings.NewReader(`algorithm=rack-milp`)
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, "/", form)
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, body = rerun(parent.ID, req, http.StatusBadRequest)
+	assert.Contains(t, body, `"code":"invalid_data"`)
+	_, body = rerun(parent.ID, newUploadRequest(t, nil, testUpload{name: "new.csv", content: []byte("id\n2\n")}), http.StatusBadRequest)
+	assert.JSONEq(t, `{"text":"`+ErrMsgRerunOverride+`"}`, body)
+	_, body = rerun("unknown", nil, http.StatusNotFound)
+	assert.JSONEq(t, `{"text":"job not found"}`, body)
+
+	assert.NoError(t, os.Remove(filepath.Join(bucket, parent.Request.Filename)))
+	_, body = rerun(parent.ID, nil, http.StatusGone)
+	assert.JSONEq(t, `{"text":"`+ErrMsgRerunInput+`"}`, body)
+}
+
+func TestMergeParameters(t *testing.T) {
+	parent := map[string]interface{}{"seed": 1, "limit": 5}
+	assert.Equal(t, parent, mergeParameters(parent, nil))
+	assert.Equal(t, map[string]interface{}{"seed": 2, "limit": 5, "mode": "fast"}, mergeParameters(parent, map[string]interface{}{"seed": 2, "mode": "fast"}))
+	assert.Nil(t, mergeParameters(parent, map[string]interface{}{"seed": nil, "limit": nil}))
+	assert.Equal(t, map[string]interface{}{"seed": 1, "limit": 5}, parent)
+}
@@ -0,0 +1,402 @@
+package server
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/environment"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
+	"github.com/gorilla/mux"
+)
+
+const (
+	ErrMsgResultNotFound = "result not found"
+	ErrMsgResultName     = "invalid result filename"
+	ErrMsgExportFormat   = "unsupported export format, expected csv, json, xlsx or zip"
+
+	resultTempPattern    = "tmp_result_*"
+	exportPrefix         = "opt_export_"
+	resultOutputFilename = "def_output.csv"
+	csvContentType       = "text/csv"
+	jsonContentType      = "application/json"
+	xlsxSheetName        = "Result"
+)
+
+// Export formats of a result
+const (
+	exportCSV  = "csv"
+	exportJSON = "json"
+	exportXLSX = "xlsx"
+	exportZip  = "zip"
+)
+
+// errEntryRead stops the walk of a result archive once the requested file has been read
+var errEntryRead = errors.New("entry read")
+
+// resultFilenamePattern matches the archives produced by the optimization service
+var resultFilenamePattern = regexp.MustCompile(`^opt_result_\d+\.(tar\.gz|tar\.zst|tar|zip)$`)
+
+type ResultHandler struct {
+	storage storage.Storage
+	log     *logger.Logger
+}
+
+func NewResultHandler(storage storage.Storage, log *logger.Logger) *ResultHandler {
+	return &ResultHandler{
+		storage: storage,
+		log:     log,
+	}
+}
+
+// Download result
+// @Summary Download the result archive
+// @Description Stream the result archive from the storage, HTTP Range requests are supported.
+// @Description With the format parameter the result is converted: csv is the def_output.csv file, json and xlsx are its conversions,
+// @Description zip holds every file of the result. The conversions are cached in the storage next to the archive
+// @Description and served while the archive exists.
+// @ID result-handler
+// @Produce  application/gzip,application/zstd,application/x-tar,application/zip,text/csv,application/json,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param   filename path string true "result archive filename"
+// @Param   format query string false "export format: csv, json, xlsx or zip"
+// @Success 200
+// @Success 206
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /v1/results/{filename} [get]
+func (h *ResultHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+	filename := mux.Vars(r)["filename"]
+	if !resultFilenamePattern.MatchString(filename) {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgResultName), http.StatusBadRequest, h.log)
+		return
+	}
+
+	archiveFormat, _ := compressor.FormatFromName(filename)
+	export := strings.ToLower(r.URL.Query().Get("format"))
+	switch {
+	case export == "" || (export == exportZip && archiveFormat == compressor.Zip):
+		h.serveCached(writer, r, filename, filename, archiveFormat.ContentType())
+		return
+	case exportContentType(export) == "":
+		writeResponse(writer, models.NewErrorResponse(ErrMsgExportFormat), http.StatusBadRequest, h.log)
+		return
+	}
+
+	// the cached conversion outlives the archive removed by the retention, it is served only while the archive exists
+	if _, err := h.storage.Stat(r.Context(), filename); err != nil {
+		if r.Context().Err() != nil {
+			h.log.Debugf("reading result '%s' stopped: %s", filename, r.Context().Err())
+			return
+		}
+		writeDownloadError(writer, err, h.log)
+		return
+	}
+	name := strings.TrimSuffix(filename, archiveFormat.Ext()) + "." + export
+	key := exportKey(filename, export)
+	if h.serveCached(writer, r, key, name, exportContentType(export)) {
+		return
+	}
+	h.export(writer, r, filename, export, key, name)
+}
+
+// serveCached streams the object if it exists. It returns false without writing the response if the object
+// is missing and is not the archive itself, the caller converts the archive then.
+func (h *ResultHandler) serveCached(writer http.ResponseWriter, r *http.Request, key string, name string, contentType string) bool {
+	body, info, err := h.storage.Get(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) && key != name {
+		return false
+	}
+	if err != nil && r.Context().Err() != nil {
+		h.log.Debugf("reading result '%s' stopped: %s", key, r.Context().Err())
+		return true
+	}
+	if err != nil {
+		writeDownloadError(writer, err, h.log)
+		return true
+	}
+	defer func() {
+		if err := body.Close(); err != nil {
+			h.log.Warnf("error closing object '%s': %s", key, err)
+		}
+	}()
+
+	serveObject(writer, r, body, info, name, contentType, h.log)
+	return true
+}
+
+// export converts the result archive and stores the conversion under key before serving it
+func (h *ResultHandler) export(writer http.ResponseWriter, r *http.Request, filename string, export string, key string, name string) {
+	out, err := ioutil.TempFile("", resultTempPattern)
+	if err != nil {
+		h.log.Errorf("error creating temp file: %s", err)
+		writeResponse(writer, models.NewErrorResponse(ErrMsgInternal), http.StatusInternalServerError, h.log)
+		return
+	}
+	defer removeTempFile(out, h.log)
+
+	info, err := exportResult(r.Context(), h.storage, filename, export, out)
+	if err != nil {
+		writeResultError(writer, r, filename, err, h.log)
+		return
+	}
+	if err := h.cache(r.Context(), key, out, exportContentType(export)); err != nil {
+		h.log.Warnf("error caching export '%s': %s", key, err)
+	}
+	serveTempFile(writer, r, out, info, name, exportContentType(export), h.log)
+}
+
+func (h *ResultHandler) cache(ctx context.Context, key string, file *os.File, contentType string) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := h.storage.Put(ctx, key, file, storage.PutOptions{ContentType: contentType})
+	return err
+}
+
+// exportResult streams the result archive from the storage and writes it to out in the export format.
+// Only the output file is read for the csv, json and xlsx formats, the walk stops once it has been converted.
+func exportResult(ctx context.Context, store storage.Storage, filename string, export string, out io.Writer) (storage.ObjectInfo, error) {
+	if export == exportZip {
+		zw := zip.NewWriter(out)
+		files := 0
+		info, err := walkResultArchive(ctx, store, filename, func(name string, content io.Reader) error {
+			files++
+			fw, err := zw.Create(name)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(fw, content)
+			return err
+		})
+		if err == nil && files == 0 {
+			err = os.ErrNotExist
+		}
+		if err != nil {
+			return info, err
+		}
+		return info, zw.Close()
+	}
+
+	return readResultEntry(ctx, store, filename, resultOutputFilename, func(in io.Reader) error {
+		switch export {
+		case exportJSON:
+			return tabular.ExportJSON(in, out)
+		case exportXLSX:
+			return tabular.ExportXLSX(in, out, xlsxSheetName)
+		}
+		_, err := io.Copy(out, in)
+		return err
+	})
+}
+
+// walkResultArchive streams the result archive from the storage and calls fn for every file in it
+func walkResultArchive(ctx context.Context, store storage.Storage, filename string, fn compressor.WalkFunc) (storage.ObjectInfo, error) {
+	body, info, err := store.Get(ctx, filename)
+	if err != nil {
+		return info, err
+	}
+	defer body.Close()
+	return info, compressor.Walk(ctx, body, fn)
+}
+
+// readResultEntry streams the result archive from the storage and calls fn with the content of the file name,
+// the rest of the archive is not read. It returns os.ErrNotExist if the archive doesn't have the file.
+func readResultEntry(ctx context.Context, store storage.Storage, filename string, name string, fn func(r io.Reader) error) (storage.ObjectInfo, error) {
+	info, err := walkResultArchive(ctx, store, filename, func(entry string, content io.Reader) error {
+		if entry != name {
+			return nil
+		}
+		if err := fn(content); err != nil {
+			return err
+		}
+		return errEntryRead
+	})
+	switch {
+	case errors.Is(err, errEntryRead):
+		return info, nil
+	case err == nil:
+		return info, os.ErrNotExist
+	}
+	return info, err
+}
+
+// exportKey is the storage key of a cached conversion, e.g. opt_export_opt_result_1.tar.gz.json.
+// The conversions have their own prefix, so the retention janitor doesn't count them as results.
+func exportKey(filename string, export string) string {
+	return exportPrefix + filename + "." + export
+}
+
+func exportContentType(export string) string {
+	switch export {
+	case exportCSV:
+		return csvContentType
+	case exportJSON:
+		return jsonContentType
+	case exportXLSX:
+		return tabular.XLSXContentType
+	case exportZip:
+		return compressor.Zip.ContentType()
+	}
+	return ""
+}
+
+type ResultOutputHandler struct {
+	storage storage.Storage
+	log     *logger.Logger
+}
+
+func NewResultOutputHandler(storage storage.Storage, log *logger.Logger) *ResultOutputHandler {
+	return &ResultOutputHandler{
+		storage: storage,
+		log:     log,
+	}
+}
+
+// Download result output
+// @Summary Download the script output file
+// @Description Stream the def_output.csv file out of the result archive, HTTP Range requests are supported
+// @ID result-output-handler
+// @Produce  text/csv
+// @Param   filename path string true "result archive filename"
+// @Success 200
+// @Success 206
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /v1/results/{filename}/output [get]
+func (h *ResultOutputHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+	filename := mux.Vars(r)["filename"]
+	if !resultFilenamePattern.MatchString(filename) {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgResultName), http.StatusBadRequest, h.log)
+		return
+	}
+
+	// a range is served from a copy of the output file, the whole file is streamed otherwise
+	if r.Header.Get("Range") == "" {
+		h.stream(writer, r, filename)
+		return
+	}
+	out, err := ioutil.TempFile("", resultTempPattern)
+	if err != nil {
+		h.log.Errorf("error creating temp file: %s", err)
+		writeResponse(writer, models.NewErrorResponse(ErrMsgInternal), http.StatusInternalServerError, h.log)
+		return
+	}
+	defer removeTempFile(out, h.log)
+
+	info, err := readResultEntry(r.Context(), h.storage, filename, resultOutputFilename, func(content io.Reader) error {
+		_, err := io.Copy(out, content)
+		return err
+	})
+	if err != nil {
+		writeResultError(writer, r, filename, err, h.log)
+		return
+	}
+	serveTempFile(writer, r, out, info, resultOutputFilename, csvContentType, h.log)
+}
+
+// stream copies the output file from the result archive to the response as it is unpacked
+func (h *ResultOutputHandler) stream(writer http.ResponseWriter, r *http.Request, filename string) {
+	started := false
+	_, err := readResultEntry(r.Context(), h.storage, filename, resultOutputFilename, func(content io.Reader) error {
+		started = true
+		writer.Header().Set("Content-Type", csvContentType)
+		writer.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": resultOutputFilename}))
+		writer.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodHead {
+			return nil
+		}
+		_, err := io.Copy(writer, content)
+		return err
+	})
+	switch {
+	case err != nil && started:
+		h.log.Warnf("error streaming the output of '%s': %s", filename, err)
+	case err != nil:
+		writeResultError(writer, r, filename, err, h.log)
+	}
+}
+
+// serveTempFile writes the file as an attachment. Content-Length and Range requests are handled by http.ServeContent.
+func serveTempFile(writer http.ResponseWriter, r *http.Request, file *os.File, info storage.ObjectInfo, name string, contentType string, log *logger.Logger) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		log.Errorf("error reading file '%s': %s", file.Name(), err)
+		writeResponse(writer, models.NewErrorResponse(ErrMsgInternal), http.StatusInternalServerError, log)
+		return
+	}
+	writer.Header().Set("Content-Type", contentType)
+	writer.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	http.ServeContent(writer, r, name, info.LastModified, file)
+}
+
+// writeResultError writes the error response of a result which couldn't be read, nothing is written
+// if the client has gone away
+func writeResultError(writer http.ResponseWriter, r *http.Request, filename string, err error, log *logger.Logger) {
+	switch {
+	case r.Context().Err() != nil:
+		log.Debugf("reading result '%s' stopped: %s", filename, r.Context().Err())
+	case errors.Is(err, storage.ErrNotFound), errors.Is(err, os.ErrNotExist):
+		writeResponse(writer, models.NewErrorResponse(ErrMsgResultNotFound), http.StatusNotFound, log)
+	default:
+		log.Errorf("error reading result '%s': %s", filename, err)
+		writeResponse(writer, models.NewErrorResponse(ErrMsgInternal), http.StatusInternalServerError, log)
+	}
+}
+
+func removeTempFile(file *os.File, log *logger.Logger) {
+	if err := file.Close(); err != nil {
+		log.Warnf("error closing temp file '%s': %s", file.Name(), err)
+	}
+	if err := os.Remove(file.Name()); err != nil {
+		log.Warnf("error removing temp file '%s': %s", file.Name(), err)
+	}
+}
+
+// serveObject streams an object from the storage as an attachment.
+// Range requests are supported if the object reader is seekable.
+func serveObject(writer http.ResponseWriter, r *http.Request, body io.Reader, info storage.ObjectInfo, name string, contentType string, log *logger.Logger) {
+	writer.Header().Set("Content-Type", contentType)
+	writer.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+
+	if rs, ok := body.(io.ReadSeeker); ok {
+		http.ServeContent(writer, r, name, info.LastModified, rs)
+		return
+	}
+
+	writer.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	writer.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(writer, body); err != nil {
+		log.Warnf("error streaming '%s': %s", name, err)
+	}
+}
+
+func writeDownloadError(writer http.ResponseWriter, err error, log *logger.Logger) {
+	if errors.Is(err, storage.ErrNotFound) {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgResultNotFound), http.StatusNotFound, log)
+		return
+	}
+	log.Errorf("error downloading from the storage: %s", err)
+	writeResponse(writer, models.NewErrorResponse(ErrMsgDownload), http.StatusInternalServerError, log)
+}
+
+func cleanUpEnv(env *environment.Provider, log *logger.Logger) {
+	if err := env.CleanUp(); err != nil {
+		log.Warnf("error cleaning up temporary directory %s, error: %s", env.Dir(), err)
+	}
+}
@@ -0,0 +1,280 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/retention"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testResultFilename = "opt_result_1.tar.gz"
+	testOutput         = "value\n1\n2\n3\n"
+)
+
+func newTestBucket(t *testing.T) string {
+	bucket := t.TempDir()
+
+	workDir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(workDir, resultOutputFilename), []byte(testOutput), os.ModePerm))
+	_, err := compressor.Compress(context.Background(), filepath.Join(bucket, "opt_result_1"), workDir, resultOutputFilename)
+	assert.NoError(t, err)
+	_, err = compressor.CompressFormat(context.Background(), compressor.Zip, filepath.Join(bucket, "opt_result_2"), workDir, resultOutputFilename)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(workDir, "log.txt"), []byte("log"), os.ModePerm))
+	_, err = compressor.Compress(context.Background(), filepath.Join(bucket, "opt_result_4"), workDir, "log.txt")
+	assert.NoError(t, err)
+
+	return bucket
+}
+
+func serveResults(t *testing.T, bucket string, req *http.Request) *httptest.ResponseRecorder {
+	fs := storage.NewFSStorage(bucket, logger.NewTestLogger())
+	r := mux.NewRouter()
+	r.Handle("/api/v1/results/{filename}", NewResultHandler(fs, logger.NewTestLogger()))
+	r.Handle("/api/v1/results/{filename}/output", NewResultOutputHandler(fs, logger.NewTestLogger()))
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestResultHandler(t *testing.T) {
+	bucket := newTestBucket(t)
+	archive, err := ioutil.ReadFile(filepath.Join(bucket, testResultFilename))
+	assert.NoError(t, err)
+	zipArchive, err := ioutil.ReadFile(filepath.Join(bucket, "opt_result_2.zip"))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name           string
+		url            string
+		rangeHeader    string
+		expectedStatus int
+		expectedBody   []byte
+		contentType    string
+	}{
+		{
+			name:           "archive",
+			url:            "/api/v1/results/" + testResultFilename,
+			expectedStatus: http.StatusOK,
+			expectedBody:   archive,
+			contentType:    compressor.TarGz.ContentType(),
+		},
+		{
+			name:           "archive range",
+			url:            "/api/v1/results/" + testResultFilename,
+			rangeHeader:    "bytes=0-9",
+			expectedStatus: http.StatusPartialContent,
+			expectedBody:   archive[:10],
+			contentType:    compressor.TarGz.ContentType(),
+		},
+		{
+			name:           "output",
+			url:            "/api/v1/results/" + testResultFilename + "/output",
+			expectedStatus: http.StatusOK,
+			expectedBody:   []byte(testOutput),
+			contentType:    csvContentType,
+		},
+		{
+			name:           "output range",
+			url:            "/api/v1/results/" + testResultFilename + "/output",
+			rangeHeader:    "bytes=6-9",
+			expectedStatus: http.StatusPartialContent,
+			expectedBody:   []byte(testOutput[6:10]),
+			contentType:    csvContentType,
+		},
+		{
+			name:           "output not in the result",
+			url:            "/api/v1/results/opt_result_4.tar.gz/output",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   []byte(`{"text":"result not found"}`),
+			contentType:    "application/json",
+		},
+		{
+			name:           "zip output",
+			url:            "/api/v1/results/opt_result_2.zip/output",
+			expectedStatus: http.StatusOK,
+			expectedBody:   []byte(testOutput),
+			contentType:    csvContentType,
+		},
+		{
+			name:           "csv export",
+			url:            "/api/v1/results/" + testResultFilename + "?format=csv",
+			expectedStatus: http.StatusOK,
+			expectedBody:   []byte(testOutput),
+			contentType:    csvContentType,
+		},
+		{
+			name:           "json export",
+			url:            "/api/v1/results/opt_result_2.zip?format=JSON",
+			expectedStatus: http.StatusOK,
+			expectedBody:   []byte(`[{"value":1},{"value":2},{"value":3}]` + "\n"),
+			contentType:    jsonContentType,
+		},
+		{
+			name:           "zip of a zip result",
+			url:            "/api/v1/results/opt_result_2.zip?format=zip",
+			expectedStatus: http.StatusOK,
+			expectedBody:   zipArchive,
+			contentType:    compressor.Zip.ContentType(),
+		},
+		{
+			name:           "unknown export format",
+			url:            "/api/v1/results/" + testResultFilename + "?format=pdf",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   []byte(`{"text":"unsupported export format, expected csv, json, xlsx or zip"}`),
+			contentType:    "application/json",
+		},
+		{
+			name:           "export not found",
+			url:            "/api/v1/results/opt_result_3.tar.gz?format=json",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   []byte(`{"text":"result not found"}`),
+			contentType:    "application/json",
+		},
+		{
+			name:           "not found",
+			url:            "/api/v1/results/opt_result_3.tar.gz",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   []byte(`{"text":"result not found"}`),
+			contentType:    "application/json",
+		},
+		{
+			name:           "invalid name",
+			url:            "/api/v1/results/input_files_1.tar.gz",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   []byte(`{"text":"invalid result filename"}`),
+			contentType:    "application/json",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, tc.url, nil)
+			assert.NoError(t, err)
+			if tc.rangeHeader != "" {
+				req.Header.Set("Range", tc.rangeHeader)
+			}
+
+			r := serveResults(t, bucket, req)
+			assert.Equal(t, tc.expectedStatus, r.Code)
+			assert.Equal(t, tc.expectedBody, r.Body.Bytes())
+			assert.Equal(t, tc.contentType, r.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestResultHandler_Headers(t *testing.T) {
+	bucket := newTestBucket(t)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/api/v1/results/"+testResultFilename, nil)
+	assert.NoError(t, err)
+
+	r := serveResults(t, bucket, req)
+	assert.Equal(t, `attachment; filename=opt_result_1.tar.gz`, r.Header().Get("Content-Disposition"))
+	assert.Equal(t, "bytes", r.Header().Get("Accept-Ranges"))
+	assert.NotEmpty(t, r.Header().Get("Content-Length"))
+
+	req, err = http.NewRequestWithContext(context.Background(), http.MethodGet, "/api/v1/results/opt_result_2.zip", nil)
+	assert.NoError(t, err)
+
+	r = serveResults(t, bucket, req)
+	assert.Equal(t, http.StatusOK, r.Code)
+	assert.Equal(t, compressor.Zip.ContentType(), r.Header().Get("Content-Type"))
+}
+
+func TestResultHandler_Export(t *testing.T) {
+	bucket := newTestBucket(t)
+
+	for _, export := range []string{exportXLSX, exportZip} {
+		t.Run(export, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/api/v1/results/"+testResultFilename+"?format="+export, nil)
+			assert.NoError(t, err)
+
+			r := serveResults(t, bucket, req)
+			assert.Equal(t, http.StatusOK, r.Code)
+			assert.Equal(t, exportContentType(export), r.Header().Get("Content-Type"))
+			assert.Equal(t, "attachment; filename=opt_result_1."+export, r.Header().Get("Content-Disposition"))
+
+			// the conversion is cached next to the archive and served on the next request
+			cached, err := ioutil.ReadFile(filepath.Join(bucket, exportKey(testResultFilename, export)))
+			assert.NoError(t, err)
+			assert.Equal(t, cached, r.Body.Bytes())
+
+			r = serveResults(t, bucket, req)
+			assert.Equal(t, http.StatusOK, r.Code)
+			assert.Equal(t, cached, r.Body.Bytes())
+		})
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/api/v1/results/"+testResultFilename+"?format=xlsx", nil)
+	assert.NoError(t, err)
+	body := serveResults(t, bucket, req).Body.Bytes()
+	var csv bytes.Buffer
+	assert.NoError(t, tabular.ConvertXLSX(bytes.NewReader(body), int64(len(body)), xlsxSheetName, &csv))
+	assert.Equal(t, testOutput, csv.String())
+
+	output := t.TempDir()
+	assert.NoError(t, compressor.Decompress(context.Background(), filepath.Join(bucket, exportKey(testResultFilename, exportZip)), output))
+	content, err := ioutil.ReadFile(filepath.Join(output, resultOutputFilename))
+	assert.NoError(t, err)
+	assert.Equal(t, testOutput, string(content))
+}
+
+func TestResultHandler_ExportRetention(t *testing.T) {
+	bucket := newTestBucket(t)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/api/v1/results/"+testResultFilename+"?format=json", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, serveResults(t, bucket, req).Code)
+
+	// the cached conversion doesn't count toward the results kept by the janitor
+	fs := storage.NewFSStorage(bucket, logger.NewTestLogger())
+	janitor := retention.NewJanitor(fs, []retention.Policy{{Prefix: "opt_result_", MaxCount: 3}}, time.Hour, false, logger.NewTestLogger())
+	report, err := janitor.Sweep(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, report.Deleted)
+	_, err = os.Stat(filepath.Join(bucket, exportKey(testResultFilename, exportJSON)))
+	assert.NoError(t, err)
+}
+
+func TestResultHandler_ExportRemovedResult(t *testing.T) {
+	bucket := newTestBucket(t)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/api/v1/results/"+testResultFilename+"?format=json", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, serveResults(t, bucket, req).Code)
+
+	// the cached conversion is not served once the archive has been removed
+	assert.NoError(t, os.Remove(filepath.Join(bucket, testResultFilename)))
+	r := serveResults(t, bucket, req)
+	assert.Equal(t, http.StatusNotFound, r.Code)
+	assert.JSONEq(t, `{"text":"`+ErrMsgResultNotFound+`"}`, r.Body.String())
+}
+
+func TestResultOutputHandler_ClientGone(t *testing.T) {
+	bucket := newTestBucket(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// nothing is read nor written once the client has gone away
+	for _, url := range []string{"/api/v1/results/" + testResultFilename + "/output", "/api/v1/results/" + testResultFilename + "?format=json"} {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		assert.NoError(t, err)
+		r := serveResults(t, bucket, req)
+		assert.Empty(t, r.Body.Bytes(), url)
+	}
+	_, err := os.Stat(filepath.Join(bucket, exportKey(testResultFilename, exportJSON)))
+	assert.True(t, os.IsNotExist(err))
+}
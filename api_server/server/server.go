@@ -10,7 +10,10 @@ import (
 	"time"
 
 	"github.com/cxrdevelop/optimization_engine/api_server/config"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/datasets"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/jobs"
 	"github.com/cxrdevelop/optimization_engine/api_server/internal/optimization"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/retention"
 	"github.com/cxrdevelop/optimization_engine/pkg/logger"
 	"github.com/cxrdevelop/optimization_engine/pkg/metrics"
 	"github.com/cxrdevelop/optimization_engine/pkg/storage"
@@ -21,13 +24,23 @@ import (
 
 const (
 	gracefulShutdownTimeoutMs = 5000
+	defaultRetentionInterval  = time.Hour
+	defaultHandlerTimeout     = 30 * time.Second
+
+	ErrMsgHandlerTimeout = "request timeout"
 )
 
 type Server struct {
-	config  *config.Config
-	storage storage.Storage
-	client  *optimization.Client
-	logger  *logger.Logger
+	config   *config.Config
+	storage  storage.Storage
+	client   *optimization.Client
+	jobs     *jobs.Manager
+	datasets *datasets.Registry
+	janitor  *retention.Janitor
+	logger   *logger.Logger
+
+	// handlerTimeout bounds every handler but the optimization runs, the job events and the result downloads
+	handlerTimeout time.Duration
 }
 
 func New(config *config.Config) *Server {
@@ -39,15 +52,15 @@ func (s *Server) Start() {
 
 	s.SetDefaults()
 
-	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%s", s.config.Application.Port),
-		WriteTimeout: time.Second * 30,
-		ReadTimeout:  time.Second * 30,
-		IdleTimeout:  time.Second * 60,
-		Handler:      s.SetupRoutes(),
-	}
+	srv := s.newHTTPServer()
 	s.logger.Infof("api server is running at port %s", s.config.Application.Port)
 
+	janitorCtx, stopJanitor := context.WithCancel(context.Background())
+	defer stopJanitor()
+	if s.janitor != nil {
+		go s.janitor.Run(janitorCtx)
+	}
+
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			s.logger.Fatalf("error occurred while running http server: %s\n", err)
@@ -70,6 +83,18 @@ func (s *Server) Start() {
 	s.logger.Warn("api server shutting down")
 }
 
+// newHTTPServer creates the http server of the routes. It has no write timeout: an upload waits for its run,
+// the job events are streamed until the job finishes and the result downloads last as long as the transfer;
+// they all stop when the client goes away. The other handlers are bounded by the handler timeout instead.
+func (s *Server) newHTTPServer() *http.Server {
+	return &http.Server{
+		Addr:        fmt.Sprintf(":%s", s.config.Application.Port),
+		ReadTimeout: time.Second * 30,
+		IdleTimeout: time.Second * 60,
+		Handler:     s.SetupRoutes(),
+	}
+}
+
 func (s *Server) SetDefaults() {
 	if s.logger == nil {
 		level, err := logrus.ParseLevel(s.config.Application.LogLevel)
@@ -90,8 +115,46 @@ func (s *Server) SetDefaults() {
 		}
 	}
 
+	if s.handlerTimeout <= 0 {
+		s.handlerTimeout = defaultHandlerTimeout
+	}
+
 	if s.client == nil {
-		s.client = optimization.New(s.storage, s.config.OptSrv.Endpoint, s.config.OptSrv.Port, s.logger)
+		s.client = optimization.New(s.storage, s.config.OptSrv.Endpoint, s.config.OptSrv.Port, s.config.OptSrv.Timeout, s.logger)
+	}
+
+	if s.jobs == nil {
+		var cache *jobs.Cache
+		if s.config.Cache.Enabled {
+			cache = jobs.NewCache(s.config.Cache.TTL, s.config.Cache.MaxEntries, s.storage)
+		}
+		s.jobs = jobs.NewManager(s.client, cache, s.logger)
+		s.jobs.SetRetention(s.config.Jobs.TTL, s.config.Jobs.MaxFinished)
+	}
+
+	if s.datasets == nil {
+		s.datasets = datasets.NewRegistry(s.storage, s.logger)
+	}
+
+	if s.janitor == nil && s.config.Retention.Enabled {
+		interval := s.config.Retention.Interval
+		if interval <= 0 {
+			interval = defaultRetentionInterval
+			s.logger.Errorf("incorrect retention interval %s, switching to default value of %s", s.config.Retention.Interval, interval)
+		}
+		policies := make([]retention.Policy, 0, len(s.config.Retention.Prefixes))
+		for _, prefix := range s.config.Retention.Prefixes {
+			policies = append(policies, retention.Policy{
+				Prefix:   prefix,
+				MaxAge:   s.config.Retention.MaxAge,
+				MaxCount: s.config.Retention.MaxCount,
+			})
+		}
+		s.janitor = retention.NewJanitor(s.storage, policies, interval, s.config.Retention.DryRun, s.logger)
+		// the jobs whose results have been removed are not reported any more
+		s.janitor.Notify(func(keys []string) {
+			s.jobs.Forget(keys)
+		})
 	}
 }
 
@@ -104,14 +167,87 @@ func (s *Server) SetupRoutes() *mux.Router {
 	apiPrefix := r.PathPrefix("/api/v1").Subrouter()
 
 	wrappedHealthHandler := handlers.LoggingHandler(s.logger.Writer(),
-		NewHealthHandler(s.logger),
+		s.bounded(NewHealthHandler(s.logger)),
 	)
 	apiPrefix.Handle("/health", wrappedHealthHandler).Methods(http.MethodGet, http.MethodOptions)
 
+	stager := newInputStager(s.storage, s.client, s.datasets, s.logger)
+
 	wrappedUploadHandler := handlers.LoggingHandler(s.logger.Writer(),
-		NewUploadHandler(s.storage, s.client, s.logger),
+		NewUploadHandler(stager, s.jobs, s.logger),
 	)
 	apiPrefix.Handle("/upload", wrappedUploadHandler).Methods(http.MethodPost, http.MethodOptions)
 
+	wrappedCreateJobHandler := handlers.LoggingHandler(s.logger.Writer(),
+		s.bounded(NewCreateJobHandler(stager, s.jobs, s.logger)),
+	)
+	apiPrefix.Handle("/jobs", wrappedCreateJobHandler).Methods(http.MethodPost, http.MethodOptions)
+
+	wrappedJobStatusHandler := handlers.LoggingHandler(s.logger.Writer(),
+		s.bounded(NewJobStatusHandler(s.jobs, s.logger)),
+	)
+	apiPrefix.Handle("/jobs/{id}", wrappedJobStatusHandler).Methods(http.MethodGet, http.MethodOptions)
+
+	wrappedCancelJobHandler := handlers.LoggingHandler(s.logger.Writer(),
+		s.bounded(NewCancelJobHandler(s.jobs, s.logger)),
+	)
+	apiPrefix.Handle("/jobs/{id}", wrappedCancelJobHandler).Methods(http.MethodDelete)
+
+	wrappedJobEventsHandler := handlers.LoggingHandler(s.logger.Writer(),
+		NewJobEventsHandler(s.jobs, s.logger),
+	)
+	apiPrefix.Handle("/jobs/{id}/events", wrappedJobEventsHandler).Methods(http.MethodGet, http.MethodOptions)
+
+	wrappedRerunJobHandler := handlers.LoggingHandler(s.logger.Writer(),
+		s.bounded(NewRerunJobHandler(stager, s.jobs, s.logger)),
+	)
+	apiPrefix.Handle("/jobs/{id}/rerun", wrappedRerunJobHandler).Methods(http.MethodPost, http.MethodOptions)
+
+	wrappedPreviewHandler := handlers.LoggingHandler(s.logger.Writer(),
+		s.bounded(NewPreviewHandler(s.jobs, s.storage, s.logger)),
+	)
+	apiPrefix.Handle("/jobs/{id}/preview", wrappedPreviewHandler).Methods(http.MethodGet, http.MethodOptions)
+
+	wrappedCreateDatasetHandler := handlers.LoggingHandler(s.logger.Writer(),
+		s.bounded(NewCreateDatasetHandler(stager, s.datasets, s.logger)),
+	)
+	apiPrefix.Handle("/datasets", wrappedCreateDatasetHandler).Methods(http.MethodPost)
+
+	wrappedDatasetsHandler := handlers.LoggingHandler(s.logger.Writer(),
+		s.bounded(NewDatasetsHandler(s.datasets, s.logger)),
+	)
+	apiPrefix.Handle("/datasets", wrappedDatasetsHandler).Methods(http.MethodGet, http.MethodOptions)
+
+	wrappedDatasetHandler := handlers.LoggingHandler(s.logger.Writer(),
+		s.bounded(NewDatasetHandler(s.datasets, s.logger)),
+	)
+	apiPrefix.Handle("/datasets/{id}", wrappedDatasetHandler).Methods(http.MethodGet, http.MethodOptions)
+
+	wrappedAlgorithmsHandler := handlers.LoggingHandler(s.logger.Writer(),
+		s.bounded(NewAlgorithmsHandler(s.client, s.logger)),
+	)
+	apiPrefix.Handle("/algorithms", wrappedAlgorithmsHandler).Methods(http.MethodGet, http.MethodOptions)
+
+	// registered before the result downloads, diff would match the filename otherwise
+	wrappedDiffHandler := handlers.LoggingHandler(s.logger.Writer(),
+		s.bounded(NewDiffHandler(s.jobs, s.storage, s.logger)),
+	)
+	apiPrefix.Handle("/results/diff", wrappedDiffHandler).Methods(http.MethodGet, http.MethodOptions)
+
+	wrappedResultHandler := handlers.LoggingHandler(s.logger.Writer(),
+		NewResultHandler(s.storage, s.logger),
+	)
+	apiPrefix.Handle("/results/{filename}", wrappedResultHandler).Methods(http.MethodGet, http.MethodHead, http.MethodOptions)
+
+	wrappedResultOutputHandler := handlers.LoggingHandler(s.logger.Writer(),
+		NewResultOutputHandler(s.storage, s.logger),
+	)
+	apiPrefix.Handle("/results/{filename}/output", wrappedResultOutputHandler).Methods(http.MethodGet, http.MethodHead, http.MethodOptions)
+
 	return r
 }
+
+// bounded limits the time of the handler, its response is buffered until it returns
+func (s *Server) bounded(handler http.Handler) http.Handler {
+	return http.TimeoutHandler(handler, s.handlerTimeout, ErrMsgHandlerTimeout)
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/config"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/jobs"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/optimization"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_LongUpload(t *testing.T) {
+	fs := storage.NewFSStorage(t.TempDir(), logger.NewTestLogger())
+	// the run lasts longer than the timeout of the other handlers
+	opt := optimizerFunc(func(req optimization.Request) (*optimization.Response, error) {
+		time.Sleep(300 * time.Millisecond)
+		return &optimization.Response{Filepath: "opt_result_1.tar.gz"}, nil
+	})
+	s := &Server{
+		config: &config.Config{},
+		// the algorithms can't be listed, the inputs are not validated
+		client:         optimization.New(fs, "127.0.0.1", "1", time.Second, logger.NewTestLogger()),
+		storage:        fs,
+		jobs:           jobs.NewManager(opt, nil, logger.NewTestLogger()),
+		handlerTimeout: 100 * time.Millisecond,
+		logger:         logger.NewTestLogger(),
+	}
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config = s.newHTTPServer()
+	srv.Start()
+	defer srv.Close()
+
+	req := newUploadRequest(t, nil, testUpload{name: "a.csv", content: []byte("a\n1\n")})
+	target, err := url.Parse(srv.URL + "/api/v1/upload")
+	assert.NoError(t, err)
+	req.URL = target
+	resp, err := http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	assert.Zero(t, srv.Config.WriteTimeout)
+}
@@ -1,21 +1,15 @@
 package server
 
 import (
-	"context"
-	"fmt"
-	"io/ioutil"
-	"mime/multipart"
+	"errors"
 	"net/http"
-	"os"
-	"path"
-	"path/filepath"
 
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/datasets"
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/jobs"
 	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
 	"github.com/cxrdevelop/optimization_engine/api_server/internal/optimization"
 	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
-	"github.com/cxrdevelop/optimization_engine/pkg/environment"
 	"github.com/cxrdevelop/optimization_engine/pkg/logger"
-	"github.com/cxrdevelop/optimization_engine/pkg/storage"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -28,29 +22,16 @@ const (
 	ErrMsgDownload     = "failed to download files"
 	ErrMsgScript       = "script error"
 	ErrMsgUpload       = "failed to upload the result"
-
-	envPrefix     = "tmp_uploaded_"
-	inputFileName = "input_files"
-
-	maxMemory = 10 << 20 // 10 MB buffer
+	ErrMsgBucketUpload = "error uploading archive to the bucket"
+	ErrMsgScriptExec   = "script execution error"
+	ErrMsgArchive      = "invalid input archive"
+	ErrMsgFormat       = "unsupported result format"
+	ErrMsgParameters   = "parameters must be a JSON object"
+	ErrMsgInputSlot    = "invalid input slot, a slot takes a single file"
+	ErrMsgData         = "invalid input data"
 )
 
 var (
-	uiUploadDuration = promauto.NewHistogram(prometheus.HistogramOpts{
-		Name:    "ui_upload_duration_time_seconds",
-		Help:    "Duration of files uploading",
-		Buckets: prometheus.DefBuckets,
-	})
-	compresionDuration = promauto.NewHistogram(prometheus.HistogramOpts{
-		Name:    "compression_time_seconds",
-		Help:    "Duration of files compressing",
-		Buckets: prometheus.DefBuckets,
-	})
-	storageUploadDuration = promauto.NewHistogram(prometheus.HistogramOpts{
-		Name:    "storage_upload_duration_time_seconds",
-		Help:    "Duration of files uploading files to the storage",
-		Buckets: prometheus.DefBuckets,
-	})
 	optimizationRequestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
 		Name:    "optimization_request_duration_time_seconds",
 		Help:    "Duration of optimization request",
@@ -59,136 +40,120 @@ var (
 )
 
 type UploadHandler struct {
-	storage storage.Storage
-	client  *optimization.Client
-	log     *logger.Logger
+	stager *inputStager
+	jobs   *jobs.Manager
+	log    *logger.Logger
 }
 
-func NewUploadHandler(storage storage.Storage, client *optimization.Client, log *logger.Logger) *UploadHandler {
+func NewUploadHandler(stager *inputStager, jobs *jobs.Manager, log *logger.Logger) *UploadHandler {
 	return &UploadHandler{
-		storage: storage,
-		client:  client,
-		log:     log,
+		stager: stager,
+		jobs:   jobs,
+		log:    log,
 	}
 }
 
 // Upload files
-// @Summary Upload files and start optimization process
-// @Description Upload files for optimization script and request an optimization run
+// @Summary Upload files and run optimization synchronously
+// @Description Upload files for optimization script, start an optimization job and wait for its result
 // @ID upload-handler
 // @Accept  multipart/form-data
 // @Produce  json
-// @Param   file formData file true  "filename"
+// @Param   file formData file false "input files or a single zip, tar.gz, tar.zst or tar archive"
+// @Param   {slot} formData file false "the file of an input slot of the algorithm, e.g. racks"
+// @Param   format formData string false "result archive format: tar.gz (default), zip, tar.zst or tar"
+// @Param   algorithm formData string false "algorithm name@version or name, the default algorithm is run when empty"
+// @Param   parameters formData string false "JSON object with the run parameters, e.g. {\"seed\": 42}"
+// @Param   sheet formData string false "sheet of the uploaded xlsx files, the first sheet by default"
+// @Param   dataset formData string false "stored dataset [slot=]name@version or [slot=]name added to the input set, repeat it for several datasets; the rows of the datasets of the same slot are combined"
+// @Param   nocache query string false "1 to run the optimization even if the result of the same input is cached"
 // @Success 200 {object} models.UploadResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Router /v1/upload [post]
 func (h *UploadHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
-
-	// Create a temporary directory
-	env := environment.New(os.TempDir(), envPrefix)
-	if err := env.CreateTempDir(); err != nil {
-		h.log.Errorf("error creating tempdir: %s", err)
-		writeResponse(writer, models.NewErrorResponse(err.Error()), http.StatusInternalServerError, h.log)
-	}
-	defer func() {
-		if err := env.CleanUp(); err != nil {
-			h.log.Warnf("error cleaning up temporary directory %s, error: %s", env.Dir(), err)
-		}
-	}()
-
-	// Upload files from the UI
-	h.log.Debugf("Start uploading files")
-	timer := prometheus.NewTimer(uiUploadDuration)
-	filenames, err := h.serveFileUpload(env.Dir(), r)
-	timer.ObserveDuration()
+	// Start an optimization job and wait for it to finish
+	timer := prometheus.NewTimer(optimizationRequestDuration)
+	job, err := h.stager.submit(r, h.jobs)
 	if err != nil {
-		writeResponse(writer, models.NewErrorResponse(ErrMsgInternal), http.StatusBadRequest, h.log)
+		writeStageError(writer, err, h.log)
 		return
 	}
-
-	// Create zip archive from user provided files
-	h.log.Debugf("Add files to zip archive: '%s'", filenames)
-	timer = prometheus.NewTimer(compresionDuration)
-	absPathToArch := path.Join(env.Dir(), (environment.Filename)(inputFileName).WithUnixSuffix())
-	archFilesPath, err := compressor.Compress(context.Background(), absPathToArch, env.Dir(), filenames...)
+	h.log.Debugf("Wait for the optimization job '%s', filename: %s", job.ID, job.Request.Filename)
+	finished, err := h.jobs.Wait(r.Context(), job.ID)
 	timer.ObserveDuration()
 	if err != nil {
-		writeResponse(writer, models.NewErrorResponse(ErrMsgInternal), http.StatusBadRequest, h.log)
-		return
-	}
-
-	// Upload files to the bucket
-	timer = prometheus.NewTimer(storageUploadDuration)
-	filename := filepath.Base(archFilesPath)
-	timer.ObserveDuration()
-	if _, err := h.storage.UploadFiles(env.Dir(), filename); err != nil {
-		writeResponse(writer, models.NewErrorResponse("error uploading archive to the bucket"), http.StatusBadRequest, h.log)
+		// the client has gone away, its job is stopped unless another request waits for it too
+		if h.jobs.Abandon(job.ID) {
+			h.log.Infof("optimization job '%s' canceled, the client disconnected", job.ID)
+		}
+		writeResponse(writer, models.NewErrorResponse(ErrMsgScriptExec), http.StatusBadRequest, h.log)
 		return
 	}
-
-	// POST a request to the optimization service
-	h.log.Debugf("Post a request to the optimization service, filename: %s", filename)
-	timer = prometheus.NewTimer(optimizationRequestDuration)
-	resp, err := h.client.PostOptimize(filename)
-	timer.ObserveDuration()
-	if err != nil {
-		writeResponse(writer, models.NewErrorResponse("script execution error"), http.StatusBadRequest, h.log)
+	job = finished
+	if job.Status != jobs.StatusSucceeded {
+		writeResponse(writer, newJobErrorResponse(job), http.StatusBadRequest, h.log)
 		return
 	}
 
 	// Write response
-	writeResponse(writer, models.NewUploadResponse(resp.Location, resp.Filepath, resp.ETag), http.StatusOK, h.log)
+	resp := newUploadResponse(job.Result)
+	resp.Cached = job.Cached
+	writeResponse(writer, resp, http.StatusOK, h.log)
 }
 
-func (h *UploadHandler) serveFileUpload(workDir string, r *http.Request) ([]string, error) {
-	if err := r.ParseMultipartForm(maxMemory); err != nil {
-		return nil, fmt.Errorf("error parsing file: %s", err)
-	}
-
-	formdata := r.MultipartForm.File["file"]
-	filenames := make([]string, 0, len(formdata))
-	for _, header := range formdata {
-		filename, err := h.uploadFile(workDir, header)
-		if err != nil {
-			return nil, err
-		}
-		filenames = append(filenames, filename)
+func writeStageError(writer http.ResponseWriter, err error, log *logger.Logger) {
+	log.Errorf("error staging input files: %s", err)
+	var dataErr *dataError
+	switch {
+	case errors.As(err, &dataErr):
+		resp := models.ErrorResponse{Text: ErrMsgData, Code: codeInvalidData, Violations: newViolations(dataErr.violations)}
+		writeResponse(writer, resp, http.StatusBadRequest, log)
+		return
+	case errors.Is(err, datasets.ErrNotFound):
+		writeResponse(writer, models.NewErrorResponse(ErrMsgDatasetNotFound), http.StatusBadRequest, log)
+		return
+	case errors.Is(err, errSubmit):
+		writeResponse(writer, models.NewErrorResponse(ErrMsgInternal), http.StatusInternalServerError, log)
+		return
+	case errors.Is(err, errBucketUpload):
+		writeResponse(writer, models.NewErrorResponse(ErrMsgBucketUpload), http.StatusBadRequest, log)
+		return
+	case errors.Is(err, errResultFormat):
+		writeResponse(writer, models.NewErrorResponse(ErrMsgFormat), http.StatusBadRequest, log)
+		return
+	case errors.Is(err, errInputSlot):
+		writeResponse(writer, models.NewErrorResponse(ErrMsgInputSlot), http.StatusBadRequest, log)
+		return
+	case errors.Is(err, errParameters):
+		writeResponse(writer, models.NewErrorResponse(ErrMsgParameters), http.StatusBadRequest, log)
+		return
+	case errors.Is(err, errDuplicateFile), compressor.IsInvalidArchive(err):
+		writeResponse(writer, models.NewErrorResponse(ErrMsgArchive), http.StatusBadRequest, log)
+		return
 	}
-	return filenames, nil
+	writeResponse(writer, models.NewErrorResponse(ErrMsgInternal), http.StatusBadRequest, log)
 }
 
-func (h *UploadHandler) uploadFile(workDir string, header *multipart.FileHeader) (string, error) {
-	file, err := header.Open()
-	if err != nil {
-		return "", fmt.Errorf("error retreiveing file: %w", err)
+// newJobErrorResponse reports the failure of the job as returned by the optimization service
+func newJobErrorResponse(job *jobs.Job) models.ErrorResponse {
+	resp := models.ErrorResponse{
+		Text:       ErrMsgScriptExec,
+		Code:       job.ErrorCode,
+		Script:     job.Script,
+		Violations: job.Violations,
 	}
-	defer func() {
-		if err := file.Close(); err != nil {
-			h.log.Warnf("error closing file: %s", err)
-		}
-	}()
-
-	filename := header.Filename
-	h.log.Debugf("Uploaded File: %+v, size: %+v, mime: %+v", filename, header.Size, header.Header)
-
-	tempFile, err := os.Create(fmt.Sprintf("%s/%s", workDir, filename))
-	if err != nil {
-		h.log.Errorf("error creating temporary file for upload, dir: %s, filename: %s", workDir, filename)
-		return "", err
+	if job.ErrorCode != jobs.ErrCodeUnavailable && job.Error != "" {
+		resp.Text = job.Error
 	}
-	defer func() {
-		if err := tempFile.Close(); err != nil {
-			h.log.Warnf("error closing file: %s", err)
-		}
-	}()
+	return resp
+}
 
-	fileBytes, err := ioutil.ReadAll(file)
-	if err != nil {
-		return "", fmt.Errorf("error reading file: %w", err)
-	}
-	if _, err := tempFile.Write(fileBytes); err != nil {
-		return "", fmt.Errorf("error writing to file: %w", err)
-	}
-	return filename, nil
+// newUploadResponse describes the result archive of a finished job
+func newUploadResponse(res *optimization.Response) models.UploadResponse {
+	resp := models.NewUploadResponse(res.Location, res.Filepath, res.ETag)
+	resp.Algorithm = res.Algorithm
+	resp.Summary = res.Summary
+	resp.Parameters = res.Parameters
+	return resp
 }
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cxrdevelop/optimization_engine/api_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
+)
+
+// codeInvalidData is the error code the optimization service reports for the same violations
+const codeInvalidData = "invalid_data"
+
+// errInvalidData is returned when the files of the input slots don't match the CSV schemas of the algorithm
+var errInvalidData = errors.New("invalid input data")
+
+// dataError lists the violations found in the files of the input slots
+type dataError struct {
+	violations []tabular.Violation
+}
+
+func (e *dataError) Error() string {
+	reasons := make([]string, 0, len(e.violations))
+	for _, v := range e.violations {
+		reasons = append(reasons, v.String())
+	}
+	return fmt.Sprintf("%s: %s", errInvalidData, strings.Join(reasons, "; "))
+}
+
+func (e *dataError) Is(target error) bool {
+	return target == errInvalidData
+}
+
+// algorithm returns the algorithm of the optimization service referenced by ref. It returns nil if the algorithms
+// can't be listed or the algorithm is unknown, the optimization service validates the inputs again before the run.
+func (s *inputStager) algorithm(ctx context.Context, ref string) *models.Algorithm {
+	if s.algorithms == nil {
+		return nil
+	}
+	list, err := s.algorithms.GetAlgorithms(ctx)
+	if err != nil {
+		s.log.Warnf("error listing the algorithms, the inputs are not validated: %s", err)
+		return nil
+	}
+	// the optimization service reports an unknown algorithm
+	return findAlgorithm(list, ref)
+}
+
+// validateSlots checks the CSV files of the input slots against the schemas of the algorithm before
+// they are uploaded to the bucket
+func validateSlots(algorithm *models.Algorithm, entries []compressor.Entry) error {
+	files := make(map[string]compressor.Entry, len(entries))
+	for _, entry := range entries {
+		if slot, _ := path.Split(entry.Name); slot != "" && strings.EqualFold(path.Ext(entry.Name), ".csv") {
+			files[strings.TrimSuffix(slot, "/")] = entry
+		}
+	}
+
+	// the slots are checked in the order of the algorithm as the optimization service does
+	inputs := make([]tabular.Input, 0, len(algorithm.Inputs))
+	for _, slot := range algorithm.Inputs {
+		if entry, ok := files[slot.Name]; ok && slot.CSV != nil {
+			inputs = append(inputs, tabular.Input{Slot: slot.Name, File: entry.Name, Schema: csvSchema(slot.CSV), EqualRows: slot.EqualRows})
+		}
+	}
+	report, err := tabular.ValidateInputs(inputs, func(input tabular.Input) (*tabular.Report, error) {
+		return validateEntry(files[input.Slot], input.Schema)
+	})
+	if err != nil {
+		return err
+	}
+	if !report.Valid() {
+		return &dataError{violations: report.Violations}
+	}
+	return nil
+}
+
+// slotSchema returns the CSV schema of the slot of the entry, nil if there is none
+func slotSchema(algorithm *models.Algorithm, name string) *models.CSVSchema {
+	slot, _ := path.Split(name)
+	if algorithm == nil || slot == "" {
+		return nil
+	}
+	for _, s := range algorithm.Inputs {
+		if s.Name == strings.TrimSuffix(slot, "/") {
+			return s.CSV
+		}
+	}
+	return nil
+}
+
+func validateEntry(entry compressor.Entry, schema tabular.Schema) (*tabular.Report, error) {
+	file, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return schema.Validate(entry.Name, file, nil)
+}
+
+// findAlgorithm returns the algorithm referenced by name@version, by name or the default one for an empty reference.
+// The algorithms are listed in the ascending version order, the last one of a name is the latest.
+func findAlgorithm(list *models.AlgorithmsResponse, ref string) *models.Algorithm {
+	if ref == "" {
+		ref = list.Default
+	}
+	var found *models.Algorithm
+	for i, a := range list.Algorithms {
+		if a.ID == ref || a.Name == ref {
+			found = &list.Algorithms[i]
+		}
+	}
+	return found
+}
+
+// csvSchema converts the schema listed by the optimization service
+func csvSchema(m *models.CSVSchema) tabular.Schema {
+	schema := tabular.Schema{MinRows: m.MinRows, MaxRows: m.MaxRows}
+	if delimiter := []rune(m.Delimiter); len(delimiter) == 1 {
+		schema.Delimiter = delimiter[0]
+	}
+	for _, c := range m.Columns {
+		schema.Columns = append(schema.Columns, tabular.Column{Name: c.Name, Type: c.Type, Required: c.Required})
+	}
+	return schema
+}
+
+func newViolations(violations []tabular.Violation) []models.Violation {
+	list := make([]models.Violation, 0, len(violations))
+	for _, v := range violations {
+		list = append(list, models.Violation{File: v.File, Line: v.Line, Column: v.Column, Message: v.Message})
+	}
+	return list
+}
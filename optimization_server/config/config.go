@@ -11,6 +11,12 @@ const (
 	S3    = "s3"
 )
 
+// Script runners
+const (
+	RunnerPython  = "python"
+	RunnerCommand = "command"
+)
+
 type Config struct {
 	Application struct {
 		Port     string `yaml:"port" env:"PORT" env-default:"8080"`
@@ -21,9 +27,43 @@ type Config struct {
 		Dir         string        `yaml:"dir" env:"SCRIPT_DIR" env-default:"script"`
 		Prefix      string        `yaml:"prefix" env:"SCRIPT_PREFIX" env-default:"tmp"`
 		Path        string        `yaml:"path" env:"SCRIPT_PATH" env-default:"main.py"`
+		Runner      string        `yaml:"runner" env:"SCRIPT_RUNNER" env-default:"python"`
+		Interpreter string        `yaml:"interpreter" env:"SCRIPT_INTERPRETER" env-default:"python3"`
+		Command     string        `yaml:"command" env:"SCRIPT_COMMAND"`
+		Args        []string      `yaml:"args" env:"SCRIPT_ARGS" env-separator:" "`
 		Timeout     time.Duration `yaml:"timeout" env:"SCRIPT_TIMEOUT" env-default:"5000ms"`
 		Concurrency int           `yaml:"concurrency" env:"SCRIPT_CONCURRENCY" env-default:"8"`
+		QueueDepth  int           `yaml:"queueDepth" env:"SCRIPT_QUEUE_DEPTH" env-default:"16"`
+		RetryAfter  time.Duration `yaml:"retryAfter" env:"SCRIPT_RETRY_AFTER" env-default:"5s"`
+		KillGrace   time.Duration `yaml:"killGrace" env:"SCRIPT_KILL_GRACE" env-default:"5s"`
+		MaxOutput   int64         `yaml:"maxOutput" env:"SCRIPT_MAX_OUTPUT" env-default:"1048576"`
+		// Limits are applied to the script process with setrlimit, zero disables a limit
+		Limits struct {
+			AddressSpace uint64        `yaml:"addressSpace" env:"SCRIPT_LIMIT_ADDRESS_SPACE" env-default:"0"`
+			CPUTime      time.Duration `yaml:"cpuTime" env:"SCRIPT_LIMIT_CPU_TIME" env-default:"0s"`
+			FileSize     uint64        `yaml:"fileSize" env:"SCRIPT_LIMIT_FILE_SIZE" env-default:"0"`
+			OpenFiles    uint64        `yaml:"openFiles" env:"SCRIPT_LIMIT_OPEN_FILES" env-default:"0"`
+		} `yaml:"limits"`
+		// ExitCodes map the exit codes of the script to the errors reported to the user
+		ExitCodes []ExitCode `yaml:"exitCodes"`
+		// Parameters is the schema of the run parameters passed to the script
+		Parameters []Parameter `yaml:"parameters"`
+		// Inputs are the named input slots of the script, every input file is passed if empty
+		Inputs []InputSlot `yaml:"inputs"`
 	} `yaml:"script"`
+	// Archive limits the input archives accepted for decompression, zero disables a limit
+	Archive struct {
+		MaxEntries   int   `yaml:"maxEntries" env:"ARCHIVE_MAX_ENTRIES" env-default:"1000"`
+		MaxFileSize  int64 `yaml:"maxFileSize" env:"ARCHIVE_MAX_FILE_SIZE" env-default:"536870912"`
+		MaxTotalSize int64 `yaml:"maxTotalSize" env:"ARCHIVE_MAX_TOTAL_SIZE" env-default:"2147483648"`
+	} `yaml:"archive"`
+	// Output declares the files produced by the script, only def_output.csv is packaged if nothing is set
+	Output Output `yaml:"output"`
+	// Algorithms are the named script versions, the script section is the only algorithm if the list is empty
+	Algorithms struct {
+		Default string      `yaml:"default" env:"ALGORITHMS_DEFAULT"`
+		List    []Algorithm `yaml:"list"`
+	} `yaml:"algorithms"`
 	Storage struct {
 		Type   string `yaml:"type" env:"STORAGE_TYPE" env-default:"local"`
 		Region string `yaml:"region" env:"STORAGE_REGION"`
@@ -32,6 +72,85 @@ type Config struct {
 	} `yaml:"storage"`
 }
 
+// Output declares the files packaged into the result archive
+type Output struct {
+	Dir      string       `yaml:"dir" env:"OUTPUT_DIR"`
+	Files    []OutputFile `yaml:"files"`
+	Manifest string       `yaml:"manifest" env:"OUTPUT_MANIFEST"`
+}
+
+// Algorithm is a named version of the script. The empty settings are taken from the script and output sections.
+type Algorithm struct {
+	Name        string        `yaml:"name"`
+	Version     string        `yaml:"version"`
+	Description string        `yaml:"description"`
+	Path        string        `yaml:"path"`
+	Runner      string        `yaml:"runner"`
+	Interpreter string        `yaml:"interpreter"`
+	Command     string        `yaml:"command"`
+	Args        []string      `yaml:"args"`
+	Timeout     time.Duration `yaml:"timeout"`
+	ExitCodes   []ExitCode    `yaml:"exitCodes"`
+	Output      *Output       `yaml:"output"`
+	Parameters  []Parameter   `yaml:"parameters"`
+	Inputs      []InputSlot   `yaml:"inputs"`
+}
+
+// InputSlot is a named input file of the script, passed in the slot order or with the flag if it's set
+type InputSlot struct {
+	Name       string   `yaml:"name"`
+	Required   bool     `yaml:"required"`
+	Extensions []string `yaml:"extensions"`
+	Flag       string   `yaml:"flag"`
+	// CSV is the schema the file is validated against before the run
+	CSV *CSVSchema `yaml:"csv"`
+	// EqualRows requires the file to have as many rows as the other slots with equalRows set
+	EqualRows bool `yaml:"equalRows"`
+}
+
+// CSVSchema describes the header columns and the row limits of a CSV input.
+// The delimiter is detected from the header, ',' or ';', if it's not set.
+type CSVSchema struct {
+	Delimiter string      `yaml:"delimiter"`
+	Columns   []CSVColumn `yaml:"columns"`
+	MinRows   int         `yaml:"minRows"`
+	MaxRows   int         `yaml:"maxRows"`
+}
+
+// CSVColumn is a column of a CSV input of the given type: string, integer, number or boolean
+type CSVColumn struct {
+	Name     string `yaml:"name"`
+	Type     string `yaml:"type"`
+	Required bool   `yaml:"required"`
+}
+
+// Parameter describes a run parameter: its type (string, integer, number, boolean or object),
+// the allowed values and the command line flag, --<name> by default
+type Parameter struct {
+	Name        string      `yaml:"name"`
+	Type        string      `yaml:"type"`
+	Description string      `yaml:"description"`
+	Required    bool        `yaml:"required"`
+	Default     interface{} `yaml:"default"`
+	Min         *float64    `yaml:"min"`
+	Max         *float64    `yaml:"max"`
+	Enum        []string    `yaml:"enum"`
+	Flag        string      `yaml:"flag"`
+}
+
+// ExitCode describes the error reported to the user when the script exits with the code
+type ExitCode struct {
+	ExitCode int    `yaml:"exitCode"`
+	Code     string `yaml:"code"`
+	Message  string `yaml:"message"`
+}
+
+// OutputFile is a glob pattern of the script outputs, relative to the script working directory
+type OutputFile struct {
+	Pattern  string `yaml:"pattern"`
+	Required bool   `yaml:"required"`
+}
+
 // ReadConfig first reads the config file at provided path, then overwrites its values with environment variables of fallbacks to default values.
 func ReadConfig(path string) (*Config, error) {
 	cfg := &Config{}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -21,3 +22,70 @@ func TestConfig_NotFound(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, cfg)
 }
+
+func TestConfig_SchedulerDefaults(t *testing.T) {
+	cfg, err := ReadConfig("test_data/test.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, 8, cfg.Script.Concurrency)
+	assert.Equal(t, 16, cfg.Script.QueueDepth)
+	assert.Equal(t, 5*time.Second, cfg.Script.RetryAfter)
+}
+
+func TestConfig_ScriptLimitsDefaults(t *testing.T) {
+	cfg, err := ReadConfig("test_data/test.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, cfg.Script.KillGrace)
+	assert.Equal(t, int64(1048576), cfg.Script.MaxOutput)
+	assert.Zero(t, cfg.Script.Limits.AddressSpace)
+	assert.Zero(t, cfg.Script.Limits.CPUTime)
+}
+
+func TestConfig_ExitCodes(t *testing.T) {
+	cfg, err := ReadConfig("test_data/test.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, []ExitCode{{ExitCode: 126, Code: "input_length_mismatch", Message: "input files have different lengths"}}, cfg.Script.ExitCodes)
+}
+
+func TestConfig_RunnerDefaults(t *testing.T) {
+	cfg, err := ReadConfig("test_data/test.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, RunnerPython, cfg.Script.Runner)
+	assert.Equal(t, "python3", cfg.Script.Interpreter)
+	assert.Empty(t, cfg.Script.Command)
+
+	assert.NoError(t, os.Setenv("SCRIPT_RUNNER", "command"))
+	assert.NoError(t, os.Setenv("SCRIPT_ARGS", "--model {script} {inputs}"))
+	defer os.Clearenv()
+	cfg, err = ReadConfig("test_data/test.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, RunnerCommand, cfg.Script.Runner)
+	assert.Equal(t, []string{"--model", "{script}", "{inputs}"}, cfg.Script.Args)
+}
+
+func TestConfig_Output(t *testing.T) {
+	cfg, err := ReadConfig("test_data/test.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "output", cfg.Output.Dir)
+	assert.Equal(t, []OutputFile{{Pattern: "def_output.csv", Required: true}, {Pattern: "*.log"}}, cfg.Output.Files)
+	assert.Empty(t, cfg.Output.Manifest)
+}
+
+func TestConfig_Algorithms(t *testing.T) {
+	gapMin, gapMax := 0.0, 1.0
+	cfg, err := ReadConfig("test_data/test.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "rack-greedy@1.2", cfg.Algorithms.Default)
+	assert.Equal(t, []Algorithm{
+		{Name: "rack-greedy", Version: "1.2", Path: "python_script/greedy.py", Inputs: []InputSlot{
+			{Name: "racks", Required: true, Extensions: []string{".csv"}, EqualRows: true, CSV: &CSVSchema{
+				Delimiter: ";", Columns: []CSVColumn{{Name: "id", Type: "integer", Required: true}, {Name: "name"}}, MaxRows: 1000}},
+			{Name: "devices", Flag: "--devices"},
+		}},
+		{Name: "rack-milp", Version: "2.0", Runner: RunnerCommand, Command: "/usr/local/bin/milp", Args: []string{"{params}", "{inputs}"},
+			Timeout: time.Minute, Output: &Output{Dir: "output"}, Parameters: []Parameter{
+				{Name: "seed", Type: "integer", Default: 42},
+				{Name: "weights", Type: "object", Default: map[interface{}]interface{}{"cost": 1}},
+				{Name: "gap", Type: "number", Min: &gapMin, Max: &gapMax, Flag: "-g"},
+			}},
+	}, cfg.Algorithms.List)
+}
@@ -2,6 +2,10 @@ package models
 
 import (
 	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
 )
 
 type (
@@ -13,19 +17,161 @@ type (
 	// ErrorResponse - a model used for general error responses
 	ErrorResponse struct {
 		Text string `json:"text"`
+		Code string `json:"code,omitempty"`
+		// Script describes the failure of the optimization script
+		Script *ScriptFailure `json:"script,omitempty"`
+		// Violations lists the problems found in the input files
+		Violations []Violation `json:"violations,omitempty"`
+	}
+
+	// Violation - a model of a problem found in an input file, line and column are omitted for the whole file
+	Violation struct {
+		File    string `json:"file"`
+		Line    int    `json:"line,omitempty"`
+		Column  string `json:"column,omitempty"`
+		Message string `json:"message"`
+	}
+
+	// ScriptFailure - a model describing a failed script run
+	ScriptFailure struct {
+		ExitCode int `json:"exitCode"`
+		// Stderr is the tail of the script stderr
+		Stderr    string     `json:"stderr,omitempty"`
+		Exception *Exception `json:"exception,omitempty"`
+	}
+
+	// Exception - a model of the Python exception which terminated the script
+	Exception struct {
+		Type    string  `json:"type"`
+		Message string  `json:"message"`
+		Frames  []Frame `json:"frames"`
+	}
+
+	// Frame - a model of a traceback frame
+	Frame struct {
+		File     string `json:"file"`
+		Line     int    `json:"line"`
+		Function string `json:"function,omitempty"`
+		Code     string `json:"code,omitempty"`
 	}
 
 	OptimizationRequest struct {
 		Filename string `json:"filename"`
+		// Format of the result archive: tar.gz (default), tar.zst, tar or zip
+		Format string `json:"format,omitempty"`
+		// Algorithm is name@version or the name of the algorithm, the default algorithm is run when empty
+		Algorithm string `json:"algorithm,omitempty"`
+		// Parameters of the run, validated against the parameter schema of the algorithm
+		Parameters map[string]interface{} `json:"parameters,omitempty"`
+		// RunID identifies the run in the progress queries of the caller, the progress isn't kept when empty
+		RunID string `json:"runId,omitempty"`
 	}
 	OptimizationResponse struct {
+		Algorithm      string `json:"algorithm,omitempty"`
 		BucketFilename string `json:"filename"`
 		BucketLocation string `json:"location"`
 		BucketETag     string `json:"etag"`
 		ExecutionTime  int64  `json:"executionTime"`
+		// Summary is the result.json written by the script
+		Summary *ResultSummary `json:"summary,omitempty"`
+		// Parameters of the run with the defaults applied
+		Parameters map[string]interface{} `json:"parameters,omitempty"`
+	}
+
+	// ProgressResponse - a model of the latest progress reported by a running script
+	ProgressResponse struct {
+		// Percent is between 0 and 100
+		Percent   float64   `json:"percent"`
+		Message   string    `json:"message,omitempty"`
+		UpdatedAt time.Time `json:"updatedAt"`
+	}
+
+	// ResultSummary - a model of the structured result reported by the script
+	ResultSummary struct {
+		Objective *float64 `json:"objective,omitempty"`
+		// Status is the solver status: optimal, feasible or infeasible
+		Status     string             `json:"status,omitempty"`
+		Iterations *int64             `json:"iterations,omitempty"`
+		Warnings   []string           `json:"warnings,omitempty"`
+		KPIs       map[string]float64 `json:"kpis,omitempty"`
+	}
+
+	// AlgorithmsResponse - a model listing the available algorithms
+	AlgorithmsResponse struct {
+		// Default is the name@version of the algorithm run when a request doesn't select one
+		Default    string      `json:"default"`
+		Algorithms []Algorithm `json:"algorithms"`
+	}
+
+	// Algorithm - a model describing a named version of the optimization script
+	Algorithm struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		Version     string `json:"version"`
+		Description string `json:"description,omitempty"`
+		Runner      string `json:"runner"`
+		// Timeout of the script in milliseconds
+		Timeout    int64       `json:"timeout"`
+		Outputs    Outputs     `json:"outputs"`
+		Parameters []Parameter `json:"parameters,omitempty"`
+		Inputs     []InputSlot `json:"inputs,omitempty"`
+	}
+
+	// InputSlot - a model of a named input file of the algorithm
+	InputSlot struct {
+		Name       string     `json:"name"`
+		Required   bool       `json:"required,omitempty"`
+		Extensions []string   `json:"extensions,omitempty"`
+		Flag       string     `json:"flag,omitempty"`
+		CSV        *CSVSchema `json:"csv,omitempty"`
+		EqualRows  bool       `json:"equalRows,omitempty"`
+	}
+
+	// CSVSchema - a model of the schema a CSV input is validated against
+	CSVSchema struct {
+		Delimiter string      `json:"delimiter,omitempty"`
+		Columns   []CSVColumn `json:"columns,omitempty"`
+		MinRows   int         `json:"minRows,omitempty"`
+		MaxRows   int         `json:"maxRows,omitempty"`
+	}
+
+	// CSVColumn - a model of a column of a CSV input
+	CSVColumn struct {
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		Required bool   `json:"required,omitempty"`
+	}
+
+	// Parameter - a model of a run parameter accepted by the algorithm
+	Parameter struct {
+		Name        string      `json:"name"`
+		Type        string      `json:"type"`
+		Description string      `json:"description,omitempty"`
+		Required    bool        `json:"required,omitempty"`
+		Default     interface{} `json:"default,omitempty"`
+		Min         *float64    `json:"min,omitempty"`
+		Max         *float64    `json:"max,omitempty"`
+		Enum        []string    `json:"enum,omitempty"`
+		Flag        string      `json:"flag"`
+	}
+
+	// Outputs - a model of the output contract, the files the algorithm packages into the result archive
+	Outputs struct {
+		Dir      string       `json:"dir,omitempty"`
+		Files    []OutputFile `json:"files,omitempty"`
+		Manifest string       `json:"manifest,omitempty"`
+	}
+
+	// OutputFile - a model of a glob pattern of the algorithm outputs
+	OutputFile struct {
+		Pattern  string `json:"pattern"`
+		Required bool   `json:"required,omitempty"`
 	}
 )
 
+// runIDPattern is the format of the run ids, e.g. the job ids of the api server
+var runIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
 func (o *OptimizationRequest) Validate() url.Values {
 	errs := url.Values{}
 
@@ -33,6 +179,14 @@ func (o *OptimizationRequest) Validate() url.Values {
 		errs.Add("filename", "not set")
 	}
 	// TODO: add regexp to check for extension
+	if len(o.Format) > 0 {
+		if _, err := compressor.ParseFormat(o.Format); err != nil {
+			errs.Add("format", err.Error())
+		}
+	}
+	if len(o.RunID) > 0 && !runIDPattern.MatchString(o.RunID) {
+		errs.Add("runId", "invalid run id")
+	}
 
 	return errs
 }
@@ -49,6 +203,32 @@ func NewErrorResponse(errMsg string) ErrorResponse {
 	}
 }
 
+// NewCodedErrorResponse creates an error response carrying a machine readable error code
+func NewCodedErrorResponse(code, errMsg string) ErrorResponse {
+	return ErrorResponse{
+		Text: errMsg,
+		Code: code,
+	}
+}
+
+// NewScriptErrorResponse creates an error response describing a failed script run
+func NewScriptErrorResponse(code, errMsg string, script ScriptFailure) ErrorResponse {
+	return ErrorResponse{
+		Text:   errMsg,
+		Code:   code,
+		Script: &script,
+	}
+}
+
+// NewViolationsErrorResponse creates an error response listing the problems found in the input files
+func NewViolationsErrorResponse(code, errMsg string, violations []Violation) ErrorResponse {
+	return ErrorResponse{
+		Text:       errMsg,
+		Code:       code,
+		Violations: violations,
+	}
+}
+
 func NewOptimizationResponse(bucketLocation, bucketFilename, bucketEtag string, execTime int64) OptimizationResponse {
 	return OptimizationResponse{
 		BucketLocation: bucketLocation,
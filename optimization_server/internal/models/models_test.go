@@ -33,9 +33,18 @@ func TestValidation_OptimizationRequest(t *testing.T) {
 			name: "valid",
 			req: OptimizationRequest{
 				Filename: "1.tar.gz",
+				RunID:    "5f2b0c1d",
 			},
 			expected: url.Values{},
 		},
+		{
+			name: "invalid run id",
+			req: OptimizationRequest{
+				Filename: "1.tar.gz",
+				RunID:    "../run",
+			},
+			expected: url.Values{"runId": []string{"invalid run id"}},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,155 @@
+package optimizer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/runner"
+)
+
+// ErrUnknownAlgorithm is returned when the requested algorithm is not in the registry
+var ErrUnknownAlgorithm = errors.New("unknown algorithm")
+
+// Algorithm is a named version of the optimization script
+type Algorithm struct {
+	Name        string
+	Version     string
+	Description string
+	Runner      runner.Runner
+	// RunnerType is the kind of the runner, e.g. python or command
+	RunnerType string
+	Timeout    time.Duration
+	ExitCodes  map[int]ExitCode
+	Outputs    OutputContract
+	// Parameters is the schema of the run parameters, no parameters are accepted if it's empty
+	Parameters ParameterSchema
+	// Inputs are the input slots, every input file is passed in the alphabetical order if it's empty
+	Inputs InputSchema
+}
+
+// ID is the algorithm reference in the name@version form
+func (a *Algorithm) ID() string {
+	return a.Name + "@" + a.Version
+}
+
+// Registry holds the algorithms available for the optimization runs
+type Registry struct {
+	algorithms []*Algorithm
+	byID       map[string]*Algorithm
+	def        *Algorithm
+}
+
+// NewRegistry creates a registry of the algorithms. The default is referenced by name@version or by name,
+// the first algorithm is the default if it's empty.
+func NewRegistry(def string, algorithms ...*Algorithm) (*Registry, error) {
+	if len(algorithms) == 0 {
+		return nil, errors.New("no algorithms registered")
+	}
+	r := &Registry{
+		algorithms: algorithms,
+		byID:       make(map[string]*Algorithm, len(algorithms)),
+	}
+	for _, a := range algorithms {
+		if a.Name == "" || a.Version == "" || strings.Contains(a.Name, "@") {
+			return nil, fmt.Errorf("invalid algorithm '%s'", a.ID())
+		}
+		if _, ok := r.byID[a.ID()]; ok {
+			return nil, fmt.Errorf("duplicate algorithm '%s'", a.ID())
+		}
+		if err := a.Parameters.Check(); err != nil {
+			return nil, fmt.Errorf("algorithm '%s': %w", a.ID(), err)
+		}
+		if err := a.Inputs.Check(); err != nil {
+			return nil, fmt.Errorf("algorithm '%s': %w", a.ID(), err)
+		}
+		r.byID[a.ID()] = a
+	}
+
+	r.def = algorithms[0]
+	if def != "" {
+		a, err := r.Get(def)
+		if err != nil {
+			return nil, fmt.Errorf("default algorithm: %w", err)
+		}
+		r.def = a
+	}
+	return r, nil
+}
+
+// Get returns the algorithm referenced by name@version. The latest version is returned for a bare name
+// and the default algorithm for an empty reference.
+func (r *Registry) Get(ref string) (*Algorithm, error) {
+	if ref == "" {
+		return r.def, nil
+	}
+	if strings.Contains(ref, "@") {
+		if a, ok := r.byID[ref]; ok {
+			return a, nil
+		}
+		return nil, fmt.Errorf("%w '%s'", ErrUnknownAlgorithm, ref)
+	}
+
+	var latest *Algorithm
+	for _, a := range r.algorithms {
+		if a.Name == ref && (latest == nil || compareVersions(a.Version, latest.Version) > 0) {
+			latest = a
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("%w '%s'", ErrUnknownAlgorithm, ref)
+	}
+	return latest, nil
+}
+
+// Default returns the algorithm used when a request doesn't select one
+func (r *Registry) Default() *Algorithm {
+	return r.def
+}
+
+// List returns the algorithms sorted by name and version
+func (r *Registry) List() []*Algorithm {
+	list := make([]*Algorithm, len(r.algorithms))
+	copy(list, r.algorithms)
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return compareVersions(list[i].Version, list[j].Version) < 0
+	})
+	return list
+}
+
+// compareVersions compares dot separated versions, numeric parts are compared as numbers
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var pa, pb string
+		if i < len(as) {
+			pa = as[i]
+		}
+		if i < len(bs) {
+			pb = bs[i]
+		}
+		na, errA := strconv.Atoi(pa)
+		nb, errB := strconv.Atoi(pb)
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+		case pa != pb:
+			if pa < pb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,67 @@
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	greedy1 := &Algorithm{Name: "rack-greedy", Version: "1.2"}
+	greedy2 := &Algorithm{Name: "rack-greedy", Version: "1.10"}
+	milp := &Algorithm{Name: "rack-milp", Version: "2.0"}
+
+	registry, err := NewRegistry("rack-milp@2.0", greedy2, milp, greedy1)
+	assert.NoError(t, err)
+	assert.Equal(t, milp, registry.Default())
+	assert.Equal(t, []*Algorithm{greedy1, greedy2, milp}, registry.List())
+
+	testCases := []struct {
+		ref      string
+		expected *Algorithm
+	}{
+		{ref: "", expected: milp},
+		{ref: "rack-greedy@1.2", expected: greedy1},
+		{ref: "rack-greedy", expected: greedy2},
+		{ref: "rack-milp", expected: milp},
+		{ref: "rack-greedy@3.0"},
+		{ref: "rack-tabu"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.ref, func(t *testing.T) {
+			a, err := registry.Get(tc.ref)
+			if tc.expected == nil {
+				assert.ErrorIs(t, err, ErrUnknownAlgorithm)
+				assert.Equal(t, CodeAlgorithm, ErrorCode(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, a)
+		})
+	}
+}
+
+func TestNewRegistry(t *testing.T) {
+	greedy := &Algorithm{Name: "rack-greedy", Version: "1.2"}
+
+	registry, err := NewRegistry("", greedy, &Algorithm{Name: "rack-milp", Version: "2.0"})
+	assert.NoError(t, err)
+	assert.Equal(t, greedy, registry.Default())
+
+	_, err = NewRegistry("")
+	assert.Error(t, err)
+	_, err = NewRegistry("", greedy, &Algorithm{Name: "rack-greedy", Version: "1.2"})
+	assert.EqualError(t, err, "duplicate algorithm 'rack-greedy@1.2'")
+	_, err = NewRegistry("", &Algorithm{Name: "rack-greedy"})
+	assert.Error(t, err)
+	_, err = NewRegistry("rack-milp", greedy)
+	assert.ErrorIs(t, err, ErrUnknownAlgorithm)
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 0, compareVersions("1.2", "1.2"))
+	assert.Equal(t, -1, compareVersions("1.2", "1.10"))
+	assert.Equal(t, 1, compareVersions("2.0", "1.10"))
+	assert.Equal(t, 1, compareVersions("1.2.1", "1.2"))
+	assert.Equal(t, -1, compareVersions("1.0-beta", "1.0-rc"))
+}
@@ -0,0 +1,181 @@
+package optimizer
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
+)
+
+// ErrInvalidInputs is returned when the input files don't match the input slots of the algorithm
+var ErrInvalidInputs = errors.New("invalid input files")
+
+// InputSlot is a named input of an algorithm, e.g. racks or devices
+type InputSlot struct {
+	Name     string
+	Required bool
+	// Extensions lists the accepted file extensions, e.g. .csv, any extension is accepted if empty
+	Extensions []string
+	// Flag passes the file as <flag>=<path>, the file is a positional argument in the slot order if empty
+	Flag string
+	// CSV is the schema the file is validated against before the run, the file isn't checked if nil
+	CSV *tabular.Schema
+	// EqualRows requires the file to have as many rows as the other slots with EqualRows set
+	EqualRows bool
+}
+
+// InputSchema lists the input slots of an algorithm in the order of the positional arguments
+type InputSchema []InputSlot
+
+// InputsError lists the slots and the files which failed the validation
+type InputsError struct {
+	// Errors maps the slot or the file name to the reason it was rejected
+	Errors map[string]string
+}
+
+func (e *InputsError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	reasons := make([]string, 0, len(names))
+	for _, name := range names {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", name, e.Errors[name]))
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidInputs, strings.Join(reasons, "; "))
+}
+
+func (e *InputsError) Is(target error) bool {
+	return target == ErrInvalidInputs
+}
+
+// Check verifies the slots themselves
+func (s InputSchema) Check() error {
+	seen := make(map[string]struct{}, len(s))
+	for _, slot := range s {
+		if slot.Name == "" || strings.ContainsAny(slot.Name, `/\.`) {
+			return fmt.Errorf("invalid input slot name '%s'", slot.Name)
+		}
+		if _, ok := seen[slot.Name]; ok {
+			return fmt.Errorf("duplicate input slot '%s'", slot.Name)
+		}
+		seen[slot.Name] = struct{}{}
+		if slot.CSV != nil {
+			if err := slot.CSV.Check(); err != nil {
+				return fmt.Errorf("input slot '%s': %w", slot.Name, err)
+			}
+		} else if slot.EqualRows {
+			return fmt.Errorf("input slot '%s': equal rows require a csv schema", slot.Name)
+		}
+	}
+	return nil
+}
+
+// Resolve finds the file of every slot in workDir. The file of a slot is either the only file
+// of the <slot> directory or a <slot>.<ext> file. Missing required slots, files of a wrong
+// extension and files matching no slot are rejected. The originals of the converted files are skipped.
+// The returned paths are relative to workDir and slash separated.
+func (s InputSchema) Resolve(workDir string) (map[string]string, error) {
+	slots := make(map[string]*InputSlot, len(s))
+	for i := range s {
+		slots[s[i].Name] = &s[i]
+	}
+
+	infos, err := ioutil.ReadDir(workDir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string, len(s))
+	errs := make(map[string]string)
+	assign := func(slot string, file string) {
+		if _, ok := files[slot]; ok {
+			errs[slot] = "expects a single file"
+			return
+		}
+		files[slot] = file
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if name == tabular.OriginalsDir {
+			continue
+		}
+		if info.IsDir() {
+			if _, ok := slots[name]; !ok {
+				errs[name] = "unexpected directory"
+				continue
+			}
+			slotFiles, err := ioutil.ReadDir(filepath.Join(workDir, name))
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range slotFiles {
+				if !f.Mode().IsRegular() {
+					errs[path.Join(name, f.Name())] = "unexpected file"
+					continue
+				}
+				assign(name, path.Join(name, f.Name()))
+			}
+			continue
+		}
+		stem := strings.TrimSuffix(name, filepath.Ext(name))
+		if _, ok := slots[stem]; !ok || !info.Mode().IsRegular() {
+			errs[name] = "unexpected file"
+			continue
+		}
+		assign(stem, name)
+	}
+
+	for _, slot := range s {
+		file, ok := files[slot.Name]
+		if !ok {
+			if slot.Required {
+				errs[slot.Name] = "required"
+			}
+			continue
+		}
+		if len(slot.Extensions) > 0 && !hasExtension(file, slot.Extensions) {
+			errs[slot.Name] = fmt.Sprintf("must have extension %s", strings.Join(slot.Extensions, ", "))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &InputsError{Errors: errs}
+	}
+	return files, nil
+}
+
+// Args returns the files of the slots without a flag in the slot order and the flags of the others
+func (s InputSchema) Args(files map[string]string) ([]string, []string) {
+	inputs := make([]string, 0, len(files))
+	flags := make([]string, 0)
+	for _, slot := range s {
+		file, ok := files[slot.Name]
+		if !ok {
+			continue
+		}
+		if slot.Flag != "" {
+			flags = append(flags, slot.Flag+"="+file)
+			continue
+		}
+		inputs = append(inputs, file)
+	}
+	return inputs, flags
+}
+
+func hasExtension(file string, extensions []string) bool {
+	ext := strings.ToLower(filepath.Ext(file))
+	for _, e := range extensions {
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		if strings.ToLower(e) == ext {
+			return true
+		}
+	}
+	return false
+}
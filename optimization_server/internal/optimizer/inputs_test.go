@@ -0,0 +1,134 @@
+package optimizer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
+	"github.com/stretchr/testify/assert"
+)
+
+var testInputs = InputSchema{
+	{Name: "racks", Required: true, Extensions: []string{".csv"}},
+	{Name: "devices", Required: true, Extensions: []string{"csv", ".TSV"}},
+	{Name: "constraints", Flag: "--constraints"},
+}
+
+func TestInputSchema_Resolve(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    []string
+		expected map[string]string
+		errors   map[string]string
+	}{
+		{
+			name:     "slot directories",
+			files:    []string{"racks/r.csv", "devices/d.tsv"},
+			expected: map[string]string{"racks": "racks/r.csv", "devices": "devices/d.tsv"},
+		},
+		{
+			name:     "slot names",
+			files:    []string{"racks.CSV", "devices.csv", "constraints.json", ".originals/racks.xlsx"},
+			expected: map[string]string{"racks": "racks.CSV", "devices": "devices.csv", "constraints": "constraints.json"},
+		},
+		{
+			name:  "missing and extra",
+			files: []string{"racks.csv", "notes.txt", "other/a.csv"},
+			errors: map[string]string{
+				"devices":   "required",
+				"notes.txt": "unexpected file",
+				"other":     "unexpected directory",
+			},
+		},
+		{
+			name:  "several files and wrong extension",
+			files: []string{"racks.csv", "racks/b.csv", "devices.xlsx"},
+			errors: map[string]string{
+				"racks":   "expects a single file",
+				"devices": "must have extension csv, .TSV",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			writeFiles(t, workDir, tc.files...)
+			files, err := testInputs.Resolve(workDir)
+			if tc.errors != nil {
+				var inputsErr *InputsError
+				if assert.ErrorAs(t, err, &inputsErr) {
+					assert.Equal(t, tc.errors, inputsErr.Errors)
+				}
+				assert.Equal(t, CodeInputs, ErrorCode(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, files)
+		})
+	}
+}
+
+func TestInputSchema_Args(t *testing.T) {
+	inputs, flags := testInputs.Args(map[string]string{"devices": "devices.csv", "racks": "racks/r.csv", "constraints": "constraints.json"})
+	assert.Equal(t, []string{"racks/r.csv", "devices.csv"}, inputs)
+	assert.Equal(t, []string{"--constraints=constraints.json"}, flags)
+
+	inputs, flags = testInputs.Args(map[string]string{"devices": "devices.csv", "racks": "racks.csv"})
+	assert.Equal(t, []string{"racks.csv", "devices.csv"}, inputs)
+	assert.Empty(t, flags)
+}
+
+func TestInputSchema_Check(t *testing.T) {
+	assert.NoError(t, testInputs.Check())
+	assert.Error(t, InputSchema{{Name: "racks"}, {Name: "racks"}}.Check())
+	assert.Error(t, InputSchema{{Name: "racks.csv"}}.Check())
+	assert.Error(t, InputSchema{{Name: ""}}.Check())
+	assert.Error(t, InputSchema{{Name: "racks", EqualRows: true}}.Check())
+	assert.Error(t, InputSchema{{Name: "racks", CSV: &tabular.Schema{MinRows: 2, MaxRows: 1}}}.Check())
+}
+
+func TestInputSchema_Validate(t *testing.T) {
+	schema := InputSchema{
+		{Name: "racks", CSV: &tabular.Schema{Columns: []tabular.Column{{Name: "id", Type: tabular.TypeInteger, Required: true}}}, EqualRows: true},
+		{Name: "devices", CSV: &tabular.Schema{}, EqualRows: true},
+		{Name: "notes"},
+	}
+	workDir := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(workDir, "racks"), os.ModePerm))
+	write := func(name string, content string) {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(workDir, filepath.FromSlash(name)), []byte(content), os.ModePerm))
+	}
+	write("racks/r.csv", "\xef\xbb\xbfid;size\r\n1;2\r\n2;3\r\n")
+	write("devices.csv", "name\nd1\nd2\n")
+	write("notes.txt", "a;b\r\n")
+	files := map[string]string{"racks": "racks/r.csv", "devices": "devices.csv", "notes": "notes.txt"}
+
+	assert.NoError(t, schema.Validate(workDir, files))
+	for name, content := range map[string]string{"racks/r.csv": "id,size\n1,2\n2,3\n", "devices.csv": "name\nd1\nd2\n", "notes.txt": "a;b\r\n"} {
+		data, err := ioutil.ReadFile(filepath.Join(workDir, filepath.FromSlash(name)))
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(data), name)
+	}
+
+	write("racks/r.csv", "id,size\n1,2\nx,3\n")
+	write("devices.csv", "name\nd1\n")
+	err := schema.Validate(workDir, files)
+	assert.ErrorIs(t, err, ErrInvalidData)
+	assert.EqualError(t, err, "invalid input data: racks/r.csv:3: column 'id': 'x' is not an integer")
+
+	write("racks/r.csv", "id,size\n1,2\n2,3\n")
+	err = schema.Validate(workDir, files)
+	var dataErr *DataError
+	assert.ErrorAs(t, err, &dataErr)
+	assert.Equal(t, []tabular.Violation{{File: "devices.csv", Message: "has 1 rows, the racks input has 2"}}, dataErr.Violations)
+}
+
+func TestInputSchema_ResolveSymlink(t *testing.T) {
+	workDir := t.TempDir()
+	writeFiles(t, workDir, "devices.csv", "target.csv")
+	assert.NoError(t, os.Symlink(filepath.Join(workDir, "target.csv"), filepath.Join(workDir, "racks.csv")))
+	_, err := InputSchema{{Name: "racks"}, {Name: "devices"}, {Name: "target"}}.Resolve(workDir)
+	assert.EqualError(t, err, "invalid input files: racks.csv: unexpected file")
+}
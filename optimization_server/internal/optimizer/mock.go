@@ -1,6 +1,8 @@
 package optimizer
 
 import (
+	"context"
+
 	"github.com/stretchr/testify/mock"
 )
 
@@ -14,7 +16,7 @@ func NewMockOptimizer() *MockOptimizer {
 	return &MockOptimizer{}
 }
 
-func (r *MockOptimizer) Execute(filenames string) (*Result, error) {
-	args := r.Called(filenames)
+func (r *MockOptimizer) Execute(ctx context.Context, req Request) (*Result, error) {
+	args := r.Called(req)
 	return args.Get(0).(*Result), args.Error(1)
 }
@@ -1,27 +1,118 @@
 package optimizer
 
 import (
+	"context"
 	"errors"
 	"time"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
 )
 
 var (
 	ErrEnvCreate  = errors.New("error creating tempdir")
 	ErrDownload   = errors.New("download error")
 	ErrDecompress = errors.New("decompression error")
-	ErrInternal   = errors.New("internal error")
-	ErrCompress   = errors.New("compression error")
-	ErrOptimize   = errors.New("optimization script error")
-	ErrUpload     = errors.New("upload error")
+	// ErrInvalidArchive is returned when the input archive is rejected, e.g. for unsafe paths or exceeded limits
+	ErrInvalidArchive = errors.New("invalid input archive")
+	ErrInternal       = errors.New("internal error")
+	ErrCompress       = errors.New("compression error")
+	ErrOptimize       = errors.New("optimization script error")
+	// ErrTimeout is returned when the script was killed because it ran longer than the timeout
+	ErrTimeout = errors.New("optimization script timeout")
+	// ErrLimit is returned when the script was killed for exceeding a resource limit
+	ErrLimit  = errors.New("optimization script exceeded a resource limit")
+	ErrUpload = errors.New("upload error")
+	// ErrCanceled is returned when the run was canceled by the caller, a running script is killed
+	ErrCanceled = errors.New("optimization canceled")
+)
+
+// Error codes let the callers of the optimization service tell the failure categories apart
+const (
+	CodeEnvCreate      = "env_create"
+	CodeDownload       = "download"
+	CodeDecompress     = "decompress"
+	CodeInvalidArchive = "invalid_archive"
+	CodeInternal       = "internal"
+	CodeCompress       = "compress"
+	CodeOptimize       = "optimize"
+	CodeTimeout        = "timeout"
+	CodeLimit          = "limit"
+	CodeMissingOutput  = "missing_output"
+	CodeInvalidOutput  = "invalid_output"
+	CodeUpload         = "upload"
+	CodeBusy           = "busy"
+	CodeAlgorithm      = "unknown_algorithm"
+	CodeParameters     = "invalid_parameters"
+	CodeInputs         = "invalid_inputs"
+	CodeData           = "invalid_data"
+	CodeCanceled       = "canceled"
 )
 
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrEnvCreate, CodeEnvCreate},
+	{ErrDownload, CodeDownload},
+	{ErrDecompress, CodeDecompress},
+	{ErrInvalidArchive, CodeInvalidArchive},
+	{ErrCompress, CodeCompress},
+	{ErrOptimize, CodeOptimize},
+	{ErrTimeout, CodeTimeout},
+	{ErrLimit, CodeLimit},
+	{ErrMissingOutput, CodeMissingOutput},
+	{ErrInvalidOutput, CodeInvalidOutput},
+	{ErrUpload, CodeUpload},
+	{ErrBusy, CodeBusy},
+	{ErrUnknownAlgorithm, CodeAlgorithm},
+	{ErrInvalidParameters, CodeParameters},
+	{ErrInvalidInputs, CodeInputs},
+	{ErrInvalidData, CodeData},
+	{ErrCanceled, CodeCanceled},
+	{ErrInternal, CodeInternal},
+}
+
+// Request describes a single optimization run
+type Request struct {
+	// Filename of the input archive in the bucket
+	Filename string
+	// Format of the result archive, tar.gz when empty
+	Format compressor.Format
+	// Algorithm is the name@version or the name of the algorithm, the default algorithm is run when empty
+	Algorithm string
+	// Parameters of the run, validated against the parameter schema of the algorithm
+	Parameters map[string]interface{}
+}
+
 type Result struct {
+	// Algorithm is the name@version of the algorithm which produced the result
+	Algorithm     string
 	Filename      string
 	Location      string
 	ETag          string
 	ExecutionTime time.Duration
+	// Summary is parsed from result.json, nil if the script hasn't written it
+	Summary *Summary
+	// Parameters of the run with the defaults applied
+	Parameters map[string]interface{}
 }
 
+// Optimizer runs the optimization. Once ctx is done the run is abandoned and ErrCanceled is returned.
 type Optimizer interface {
-	Execute(filename string) (*Result, error)
+	Execute(ctx context.Context, req Request) (*Result, error)
+}
+
+// ErrorCode maps an error returned by Execute to its error code. Unknown errors are reported as internal.
+// Script errors are reported with the code from the exit code table if there is one.
+func ErrorCode(err error) string {
+	var scriptErr *ScriptError
+	if errors.As(err, &scriptErr) && scriptErr.Code != "" {
+		return scriptErr.Code
+	}
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return CodeInternal
 }
@@ -0,0 +1,34 @@
+package optimizer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/runner"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCode(t *testing.T) {
+	assert.Equal(t, CodeDownload, ErrorCode(ErrDownload))
+	assert.Equal(t, CodeOptimize, ErrorCode(fmt.Errorf("wrapped: %w", ErrOptimize)))
+	assert.Equal(t, CodeTimeout, ErrorCode(ErrTimeout))
+	assert.Equal(t, CodeLimit, ErrorCode(ErrLimit))
+	assert.Equal(t, CodeInternal, ErrorCode(errors.New("unknown")))
+}
+
+func TestScriptError(t *testing.T) {
+	res := &runner.Result{ExitCode: 126, ScriptError: strings.Repeat("x", maxStderrTail) + "tail"}
+	err := newScriptError(res, DefaultExitCodes)
+	assert.ErrorIs(t, err, ErrOptimize)
+	assert.Equal(t, "input_length_mismatch", ErrorCode(err))
+	assert.Equal(t, "input files have different lengths", err.Message)
+	assert.Len(t, err.Stderr, maxStderrTail)
+	assert.True(t, strings.HasSuffix(err.Stderr, "tail"))
+
+	err = newScriptError(&runner.Result{ExitCode: 3}, DefaultExitCodes)
+	assert.Equal(t, CodeOptimize, ErrorCode(err))
+	assert.Empty(t, err.Message)
+}
@@ -0,0 +1,243 @@
+package optimizer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+)
+
+var (
+	// ErrMissingOutput is returned when a required output of the script is missing
+	ErrMissingOutput = errors.New("required script output is missing")
+	// ErrInvalidOutput is returned for unreadable manifests and outputs which are not regular files inside the working directory
+	ErrInvalidOutput = errors.New("invalid script output")
+)
+
+// MissingOutputError lists the required outputs the script hasn't produced
+type MissingOutputError struct {
+	Missing []string
+}
+
+func (e *MissingOutputError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrMissingOutput, strings.Join(e.Missing, ", "))
+}
+
+func (e *MissingOutputError) Is(target error) bool {
+	return target == ErrMissingOutput
+}
+
+// OutputFile is a glob pattern of files produced by the script, relative to the script working directory
+type OutputFile struct {
+	Pattern  string
+	Required bool
+}
+
+// OutputContract declares the files produced by the script which are packaged into the result archive.
+// All the ways may be combined.
+type OutputContract struct {
+	// Dir is a directory the script writes its outputs into. Every file in it is packaged
+	// with the path relative to Dir. The directory is required to have at least one file.
+	Dir string
+	// Files are packaged with the path relative to the working directory. Input files never match.
+	Files []OutputFile
+	// Manifest is a JSON file written by the script: {"files": ["report.html", "charts/racks.png"]}.
+	// The manifest and every file listed in it are required.
+	Manifest string
+}
+
+// DefaultOutputContract packages the def_output.csv file
+var DefaultOutputContract = OutputContract{
+	Files: []OutputFile{{Pattern: scriptResultFilename, Required: true}},
+}
+
+type outputManifest struct {
+	Files []string `json:"files"`
+}
+
+// Collect returns the archive entries of the outputs found in workDir
+func (c OutputContract) Collect(workDir string, inputs []string) ([]compressor.Entry, error) {
+	collector := &outputCollector{
+		workDir: workDir,
+		inputs:  make(map[string]struct{}, len(inputs)),
+		entries: make(map[string]compressor.Entry),
+	}
+	for _, input := range inputs {
+		collector.inputs[filepath.ToSlash(input)] = struct{}{}
+	}
+
+	missing := make([]string, 0)
+	if c.Dir != "" {
+		found, err := collector.dir(c.Dir)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			missing = append(missing, c.Dir+"/")
+		}
+	}
+	for _, file := range c.Files {
+		found, err := collector.glob(file.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		if !found && file.Required {
+			missing = append(missing, file.Pattern)
+		}
+	}
+	if c.Manifest != "" {
+		notFound, err := collector.manifest(c.Manifest)
+		if err != nil {
+			return nil, err
+		}
+		missing = append(missing, notFound...)
+	}
+	if len(missing) > 0 {
+		return nil, &MissingOutputError{Missing: missing}
+	}
+	if len(collector.entries) == 0 {
+		return nil, &MissingOutputError{Missing: []string{"any output"}}
+	}
+
+	names := make([]string, 0, len(collector.entries))
+	for name := range collector.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	entries := make([]compressor.Entry, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, collector.entries[name])
+	}
+	return entries, nil
+}
+
+type outputCollector struct {
+	workDir string
+	inputs  map[string]struct{}
+	// entries by archive name
+	entries map[string]compressor.Entry
+}
+
+func (c *outputCollector) dir(dir string) (bool, error) {
+	if !isLocal(dir) {
+		return false, fmt.Errorf("%w: output dir '%s' is outside of the working directory", ErrInvalidOutput, dir)
+	}
+	root := filepath.Join(c.workDir, filepath.FromSlash(dir))
+	found := false
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && p == root {
+			return filepath.SkipDir
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if err := c.add(root, rel); err != nil {
+			return err
+		}
+		found = true
+		return nil
+	})
+	return found, err
+}
+
+func (c *outputCollector) glob(pattern string) (bool, error) {
+	if !isLocal(pattern) {
+		return false, fmt.Errorf("%w: output pattern '%s' is outside of the working directory", ErrInvalidOutput, pattern)
+	}
+	matches, err := filepath.Glob(filepath.Join(c.workDir, filepath.FromSlash(pattern)))
+	if err != nil {
+		return false, fmt.Errorf("%w: output pattern '%s': %s", ErrInvalidOutput, pattern, err)
+	}
+	found := false
+	for _, match := range matches {
+		rel, err := filepath.Rel(c.workDir, match)
+		if err != nil {
+			return false, err
+		}
+		if _, ok := c.inputs[filepath.ToSlash(rel)]; ok {
+			continue
+		}
+		if info, err := os.Lstat(match); err == nil && info.IsDir() {
+			continue
+		}
+		if err := c.add(c.workDir, rel); err != nil {
+			return false, err
+		}
+		found = true
+	}
+	return found, nil
+}
+
+// manifest adds the files listed in the manifest and returns the missing ones
+func (c *outputCollector) manifest(name string) ([]string, error) {
+	if !isLocal(name) {
+		return nil, fmt.Errorf("%w: manifest '%s' is outside of the working directory", ErrInvalidOutput, name)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(c.workDir, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return []string{name}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := outputManifest{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%w: manifest '%s': %s", ErrInvalidOutput, name, err)
+	}
+
+	missing := make([]string, 0)
+	for _, file := range m.Files {
+		if !isLocal(file) {
+			return nil, fmt.Errorf("%w: manifest file '%s' is outside of the working directory", ErrInvalidOutput, file)
+		}
+		_, err := os.Lstat(filepath.Join(c.workDir, filepath.FromSlash(file)))
+		if os.IsNotExist(err) {
+			missing = append(missing, file)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := c.add(c.workDir, filepath.FromSlash(file)); err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
+
+// add packages the regular file root/rel under the name rel, symlinks are rejected
+func (c *outputCollector) add(root string, rel string) error {
+	info, err := os.Lstat(filepath.Join(root, rel))
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: '%s' is not a regular file", ErrInvalidOutput, filepath.ToSlash(rel))
+	}
+	entry, err := compressor.FileEntry(root, rel)
+	if err != nil {
+		return err
+	}
+	c.entries[entry.Name] = entry
+	return nil
+}
+
+// isLocal reports whether the slash separated name stays inside the directory it is relative to
+func isLocal(name string) bool {
+	if name == "" || path.IsAbs(name) || filepath.IsAbs(name) {
+		return false
+	}
+	clean := path.Clean(filepath.ToSlash(name))
+	return clean != ".." && !strings.HasPrefix(clean, "../")
+}
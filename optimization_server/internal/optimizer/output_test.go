@@ -0,0 +1,101 @@
+package optimizer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFiles(t *testing.T, dir string, files ...string) {
+	for _, name := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(name), os.ModePerm))
+	}
+}
+
+func entryNames(entries []compressor.Entry) []string {
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestOutputContract_Collect(t *testing.T) {
+	workDir := t.TempDir()
+	writeFiles(t, workDir, "input1.csv", "input2.csv", "def_output.csv", "run.log",
+		"output/placement.csv", "output/charts/racks.png", "report.html")
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(workDir, "manifest.json"), []byte(`{"files":["report.html"]}`), os.ModePerm))
+	inputs := []string{"input1.csv", "input2.csv"}
+
+	testCases := []struct {
+		name     string
+		contract OutputContract
+		expected []string
+	}{
+		{
+			name:     "default",
+			contract: DefaultOutputContract,
+			expected: []string{"def_output.csv"},
+		},
+		{
+			name:     "glob skips inputs",
+			contract: OutputContract{Files: []OutputFile{{Pattern: "*.csv", Required: true}, {Pattern: "*.log"}, {Pattern: "*.txt"}}},
+			expected: []string{"def_output.csv", "run.log"},
+		},
+		{
+			name:     "dir",
+			contract: OutputContract{Dir: "output"},
+			expected: []string{"charts/racks.png", "placement.csv"},
+		},
+		{
+			name:     "manifest",
+			contract: OutputContract{Manifest: "manifest.json", Files: []OutputFile{{Pattern: "def_output.csv"}}},
+			expected: []string{"def_output.csv", "report.html"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := tc.contract.Collect(workDir, inputs)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, entryNames(entries))
+		})
+	}
+}
+
+func TestOutputContract_Errors(t *testing.T) {
+	workDir := t.TempDir()
+	writeFiles(t, workDir, "input.csv")
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(workDir, "manifest.json"), []byte(`{"files":["report.html","chart.png"]}`), os.ModePerm))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(workDir, "unsafe.json"), []byte(`{"files":["../secret"]}`), os.ModePerm))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(workDir, "broken.json"), []byte(`{"files":`), os.ModePerm))
+	assert.NoError(t, os.Symlink("/etc/passwd", filepath.Join(workDir, "def_output.csv")))
+
+	_, err := OutputContract{Dir: "output", Manifest: "manifest.json", Files: []OutputFile{{Pattern: "*.xlsx", Required: true}}}.Collect(workDir, []string{"input.csv"})
+	var missingErr *MissingOutputError
+	if assert.ErrorAs(t, err, &missingErr) {
+		assert.Equal(t, []string{"output/", "*.xlsx", "report.html", "chart.png"}, missingErr.Missing)
+	}
+	assert.ErrorIs(t, err, ErrMissingOutput)
+	assert.Equal(t, CodeMissingOutput, ErrorCode(err))
+
+	_, err = OutputContract{Files: []OutputFile{{Pattern: "*.log"}}}.Collect(workDir, []string{"input.csv"})
+	assert.ErrorIs(t, err, ErrMissingOutput)
+
+	for _, contract := range []OutputContract{
+		{Manifest: "unsafe.json"},
+		{Manifest: "broken.json"},
+		{Dir: "../"},
+		{Files: []OutputFile{{Pattern: "/etc/*"}}},
+		DefaultOutputContract,
+	} {
+		_, err = contract.Collect(workDir, []string{"input.csv"})
+		assert.ErrorIs(t, err, ErrInvalidOutput)
+		assert.Equal(t, CodeInvalidOutput, ErrorCode(err))
+	}
+}
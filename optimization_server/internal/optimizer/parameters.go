@@ -0,0 +1,251 @@
+package optimizer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// paramsFilename is written into the script working directory with the parameters of the run
+const paramsFilename = "params.json"
+
+// Parameter types
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	// TypeObject is any JSON object, e.g. objective weights. Its flag value is JSON.
+	TypeObject = "object"
+)
+
+// ErrInvalidParameters is returned when the run parameters don't match the parameter schema of the algorithm
+var ErrInvalidParameters = errors.New("invalid parameters")
+
+// Parameter describes a parameter accepted by an algorithm
+type Parameter struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+	// Default is used when the parameter is not given, nil for no default
+	Default interface{}
+	// Min and Max limit the integer and number parameters
+	Min *float64
+	Max *float64
+	// Enum lists the allowed values of a string parameter
+	Enum []string
+	// Flag is the command line flag of the parameter, --<name> if empty
+	Flag string
+}
+
+// ParameterSchema lists the parameters accepted by an algorithm, in the order of the flags
+type ParameterSchema []Parameter
+
+// ParametersError lists the parameters which failed the validation
+type ParametersError struct {
+	// Errors maps the parameter name to the reason it was rejected
+	Errors map[string]string
+}
+
+func (e *ParametersError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	reasons := make([]string, 0, len(names))
+	for _, name := range names {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", name, e.Errors[name]))
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidParameters, strings.Join(reasons, "; "))
+}
+
+func (e *ParametersError) Is(target error) bool {
+	return target == ErrInvalidParameters
+}
+
+// Check verifies the schema itself, e.g. the types and the defaults
+func (s ParameterSchema) Check() error {
+	seen := make(map[string]struct{}, len(s))
+	for _, p := range s {
+		if p.Name == "" {
+			return errors.New("empty parameter name")
+		}
+		if _, ok := seen[p.Name]; ok {
+			return fmt.Errorf("duplicate parameter '%s'", p.Name)
+		}
+		seen[p.Name] = struct{}{}
+		switch p.Type {
+		case TypeString, TypeInteger, TypeNumber, TypeBoolean, TypeObject:
+		default:
+			return fmt.Errorf("parameter '%s' has unknown type '%s'", p.Name, p.Type)
+		}
+		if p.Default != nil {
+			if _, err := p.normalize(p.Default); err != nil {
+				return fmt.Errorf("default of parameter '%s': %s", p.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks the parameters against the schema and returns them with the defaults applied
+func (s ParameterSchema) Validate(params map[string]interface{}) (map[string]interface{}, error) {
+	errs := make(map[string]string)
+	known := make(map[string]struct{}, len(s))
+	values := make(map[string]interface{}, len(s))
+	for _, p := range s {
+		known[p.Name] = struct{}{}
+		value, ok := params[p.Name]
+		if !ok || value == nil {
+			if p.Required {
+				errs[p.Name] = "required"
+				continue
+			}
+			if p.Default == nil {
+				continue
+			}
+			value = p.Default
+		}
+		normalized, err := p.normalize(value)
+		if err != nil {
+			errs[p.Name] = err.Error()
+			continue
+		}
+		values[p.Name] = normalized
+	}
+	for name := range params {
+		if _, ok := known[name]; !ok {
+			errs[name] = "unknown parameter"
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &ParametersError{Errors: errs}
+	}
+	return values, nil
+}
+
+// Flags converts the validated parameters into command line flags: --name=value.
+// A true boolean parameter is passed as --name, a false one is omitted.
+func (s ParameterSchema) Flags(values map[string]interface{}) []string {
+	flags := make([]string, 0, len(values))
+	for _, p := range s {
+		value, ok := values[p.Name]
+		if !ok {
+			continue
+		}
+		flag := p.Flag
+		if flag == "" {
+			flag = "--" + p.Name
+		}
+		switch v := value.(type) {
+		case bool:
+			if v {
+				flags = append(flags, flag)
+			}
+		case string:
+			flags = append(flags, flag+"="+v)
+		case json.Number:
+			flags = append(flags, flag+"="+v.String())
+		default:
+			// the objects are converted to JSON, they have been decoded from JSON
+			data, _ := json.Marshal(v)
+			flags = append(flags, flag+"="+string(data))
+		}
+	}
+	return flags
+}
+
+// normalize checks the value against the parameter and converts the numbers to json.Number
+func (p *Parameter) normalize(value interface{}) (interface{}, error) {
+	switch p.Type {
+	case TypeString:
+		s, ok := value.(string)
+		if !ok {
+			return nil, errors.New("must be a string")
+		}
+		if len(p.Enum) > 0 && !contains(p.Enum, s) {
+			return nil, fmt.Errorf("must be one of %s", strings.Join(p.Enum, ", "))
+		}
+		return s, nil
+	case TypeBoolean:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, errors.New("must be a boolean")
+		}
+		return b, nil
+	case TypeObject:
+		o, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("must be an object")
+		}
+		return o, nil
+	case TypeInteger, TypeNumber:
+		n, f, ok := toNumber(value)
+		if p.Type == TypeInteger && ok {
+			_, err := strconv.ParseInt(n.String(), 10, 64)
+			ok = err == nil
+		}
+		if !ok {
+			if p.Type == TypeInteger {
+				return nil, errors.New("must be an integer")
+			}
+			return nil, errors.New("must be a number")
+		}
+		if p.Min != nil && f < *p.Min {
+			return nil, fmt.Errorf("must be at least %v", *p.Min)
+		}
+		if p.Max != nil && f > *p.Max {
+			return nil, fmt.Errorf("must be at most %v", *p.Max)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("unknown type '%s'", p.Type)
+}
+
+// toNumber converts the numbers decoded from JSON or YAML
+func toNumber(value interface{}) (json.Number, float64, bool) {
+	var n json.Number
+	switch v := value.(type) {
+	case json.Number:
+		n = v
+	case float64:
+		n = json.Number(strconv.FormatFloat(v, 'f', -1, 64))
+	case int:
+		n = json.Number(strconv.Itoa(v))
+	case int64:
+		n = json.Number(strconv.FormatInt(v, 10))
+	default:
+		return "", 0, false
+	}
+	f, err := n.Float64()
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", 0, false
+	}
+	return n, f, true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// writeParams writes the parameters into params.json in workDir
+func writeParams(workDir string, values map[string]interface{}) error {
+	data, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(workDir, paramsFilename), data, 0644)
+}
@@ -0,0 +1,108 @@
+package optimizer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSchema = ParameterSchema{
+	{Name: "seed", Type: TypeInteger, Default: 42},
+	{Name: "time_limit", Type: TypeNumber, Min: floatPtr(0), Max: floatPtr(3600), Flag: "-t"},
+	{Name: "strategy", Type: TypeString, Enum: []string{"greedy", "random"}, Required: true},
+	{Name: "verbose", Type: TypeBoolean},
+	{Name: "weights", Type: TypeObject},
+}
+
+func TestParameterSchema_Validate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		params   map[string]interface{}
+		expected map[string]interface{}
+		errors   map[string]string
+	}{
+		{
+			name:     "defaults",
+			params:   map[string]interface{}{"strategy": "greedy"},
+			expected: map[string]interface{}{"seed": json.Number("42"), "strategy": "greedy"},
+		},
+		{
+			name: "all set",
+			params: map[string]interface{}{
+				"seed": json.Number("9007199254740993"), "time_limit": json.Number("1.5"), "strategy": "random",
+				"verbose": true, "weights": map[string]interface{}{"cost": json.Number("2")},
+			},
+			expected: map[string]interface{}{
+				"seed": json.Number("9007199254740993"), "time_limit": json.Number("1.5"), "strategy": "random",
+				"verbose": true, "weights": map[string]interface{}{"cost": json.Number("2")},
+			},
+		},
+		{
+			name:   "violations",
+			params: map[string]interface{}{"seed": json.Number("1.5"), "time_limit": float64(4000), "verbose": "yes", "weights": []interface{}{}, "depth": 3},
+			errors: map[string]string{
+				"seed":       "must be an integer",
+				"time_limit": "must be at most 3600",
+				"strategy":   "required",
+				"verbose":    "must be a boolean",
+				"weights":    "must be an object",
+				"depth":      "unknown parameter",
+			},
+		},
+		{
+			name:   "enum",
+			params: map[string]interface{}{"strategy": "milp", "seed": "1"},
+			errors: map[string]string{"strategy": "must be one of greedy, random", "seed": "must be an integer"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			values, err := testSchema.Validate(tc.params)
+			if tc.errors != nil {
+				var paramsErr *ParametersError
+				if assert.ErrorAs(t, err, &paramsErr) {
+					assert.Equal(t, tc.errors, paramsErr.Errors)
+				}
+				assert.ErrorIs(t, err, ErrInvalidParameters)
+				assert.Equal(t, CodeParameters, ErrorCode(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, values)
+		})
+	}
+
+	_, err := ParameterSchema(nil).Validate(map[string]interface{}{"seed": 1})
+	assert.EqualError(t, err, "invalid parameters: seed: unknown parameter")
+}
+
+func TestParameterSchema_Flags(t *testing.T) {
+	values, err := testSchema.Validate(map[string]interface{}{
+		"strategy": "greedy", "time_limit": json.Number("30"), "verbose": true,
+		"weights": map[string]interface{}{"cost": json.Number("2")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"--seed=42", "-t=30", "--strategy=greedy", "--verbose", `--weights={"cost":2}`}, testSchema.Flags(values))
+
+	values["verbose"] = false
+	assert.NotContains(t, testSchema.Flags(values), "--verbose")
+}
+
+func TestParameterSchema_Check(t *testing.T) {
+	assert.NoError(t, testSchema.Check())
+	assert.Error(t, ParameterSchema{{Name: "seed", Type: "int"}}.Check())
+	assert.Error(t, ParameterSchema{{Name: "seed", Type: TypeInteger}, {Name: "seed", Type: TypeInteger}}.Check())
+	assert.Error(t, ParameterSchema{{Name: "seed", Type: TypeInteger, Default: "42"}}.Check())
+	assert.Error(t, ParameterSchema{{Type: TypeInteger}}.Check())
+}
+
+func TestWriteParams(t *testing.T) {
+	workDir := t.TempDir()
+	assert.NoError(t, writeParams(workDir, map[string]interface{}{"seed": json.Number("42"), "strategy": "greedy"}))
+	data, err := ioutil.ReadFile(filepath.Join(workDir, paramsFilename))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"seed":42,"strategy":"greedy"}`, string(data))
+}
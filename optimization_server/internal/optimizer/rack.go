@@ -2,16 +2,19 @@ package optimizer
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 
-	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/python"
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/runner"
 	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
 	"github.com/cxrdevelop/optimization_engine/pkg/environment"
 	"github.com/cxrdevelop/optimization_engine/pkg/logger"
 	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
 )
 
 const (
@@ -23,24 +26,42 @@ const (
 var _ Optimizer = (*RackOptimizer)(nil)
 
 type RackOptimizer struct {
-	wrapper *python.Wrapper
-	storage storage.Storage
-	workDir string
-	prefix  string
-	log     *logger.Logger
+	algorithms *Registry
+	storage    storage.Storage
+	workDir    string
+	prefix     string
+	limits     compressor.Limits
+	log        *logger.Logger
 }
 
-func NewRackOptimizer(wrapper *python.Wrapper, storage storage.Storage, workDir string, prefix string, log *logger.Logger) *RackOptimizer {
+func NewRackOptimizer(algorithms *Registry, storage storage.Storage, workDir string, prefix string, limits compressor.Limits, log *logger.Logger) *RackOptimizer {
 	return &RackOptimizer{
-		wrapper: wrapper,
-		storage: storage,
-		workDir: workDir,
-		prefix:  prefix,
-		log:     log,
+		algorithms: algorithms,
+		storage:    storage,
+		workDir:    workDir,
+		prefix:     prefix,
+		limits:     limits,
+		log:        log,
 	}
 }
 
-func (r *RackOptimizer) Execute(filename string) (*Result, error) {
+func (r *RackOptimizer) Execute(ctx context.Context, req Request) (*Result, error) {
+	filename := req.Filename
+	format := req.Format
+	if format == "" {
+		format = compressor.TarGz
+	}
+	algorithm, err := r.algorithms.Get(req.Algorithm)
+	if err != nil {
+		r.log.Errorf("error selecting algorithm: %s", err)
+		return nil, err
+	}
+	params, err := algorithm.Parameters.Validate(req.Parameters)
+	if err != nil {
+		r.log.Errorf("error validating parameters of algorithm %s: %s", algorithm.ID(), err)
+		return nil, err
+	}
+
 	// Create temp dir
 	env := environment.New(r.workDir, r.prefix)
 	if err := env.CreateTempDir(); err != nil {
@@ -63,49 +84,166 @@ func (r *RackOptimizer) Execute(filename string) (*Result, error) {
 		return nil, ErrDownload
 	}
 
-	// Decompress downloaded zip archive into the workDir
-	if err := compressor.Decompress(context.Background(), path.Join(env.Dir(), filename), scriptWorkDir); err != nil {
+	// Decompress downloaded archive into the workDir, the format is detected from the content
+	if err := compressor.Decompress(ctx, path.Join(env.Dir(), filename), scriptWorkDir, compressor.WithLimits(r.limits)); err != nil {
 		r.log.Errorf("error decompressing files: %s", err)
+		if ctx.Err() != nil {
+			return nil, ErrCanceled
+		}
+		if compressor.IsInvalidArchive(err) {
+			return nil, ErrInvalidArchive
+		}
 		return nil, ErrDecompress
 	}
+	if err := checkReservedNames(scriptWorkDir); err != nil {
+		r.log.Errorf("error checking input files: %s", err)
+		if errors.Is(err, ErrInvalidArchive) {
+			return nil, err
+		}
+		return nil, ErrInternal
+	}
 
-	// Get filenames
-	filenames, err := getFilenames(scriptWorkDir)
+	// Map the input files to the input slots of the algorithm and validate them
+	inputs, inputFlags, inputFiles, err := resolveInputs(algorithm, scriptWorkDir)
 	if err != nil {
-		r.log.Errorf("error reading script directory: %s", err)
+		r.log.Errorf("error resolving input files: %s", err)
+		if errors.Is(err, ErrInvalidInputs) || errors.Is(err, ErrInvalidData) {
+			return nil, err
+		}
 		return nil, ErrInternal
 	}
 
+	// Write the parameters next to the inputs, they are passed as flags as well
+	if len(algorithm.Parameters) > 0 {
+		if err := writeParams(scriptWorkDir, params); err != nil {
+			r.log.Errorf("error writing parameters: %s", err)
+			return nil, ErrInternal
+		}
+	}
+
 	// Execute script
-	scriptRes := r.wrapper.Optimize(scriptWorkDir, filenames...)
+	r.log.Debugf("running algorithm %s", algorithm.ID())
+	flags := append(algorithm.Parameters.Flags(params), inputFlags...)
+	scriptRes := algorithm.Runner.Run(ctx, scriptWorkDir, flags, inputs...)
+	switch scriptRes.Termination {
+	case runner.TerminationNone:
+	case runner.TerminationTimeout:
+		r.log.Errorf("optimization script timeout: %s", scriptRes.ShellOutput)
+		return nil, ErrTimeout
+	case runner.TerminationCanceled:
+		r.log.Infof("optimization script killed, the run was canceled")
+		return nil, ErrCanceled
+	default:
+		r.log.Errorf("optimization script killed for exceeding a limit: %s", scriptRes.ShellOutput)
+		return nil, ErrLimit
+	}
+	if scriptRes.OutputTruncated {
+		r.log.Warnf("optimization script output was truncated")
+	}
 	if scriptRes.ExitCode != 0 {
-		r.log.Errorf("optimization script error: exit code: %d", scriptRes.ExitCode)
-		return nil, ErrOptimize
+		scriptErr := newScriptError(scriptRes, algorithm.ExitCodes)
+		r.log.Errorf("%s", scriptErr)
+		return nil, scriptErr
+	}
+
+	// Read the optional result summary
+	summary, err := readSummary(scriptWorkDir)
+	if err != nil {
+		r.log.Errorf("error reading result summary: %s", err)
+		if errors.Is(err, ErrInvalidOutput) {
+			return nil, err
+		}
+		return nil, ErrInternal
 	}
 
-	// Compress the result
-	absPathToArch := path.Join(env.Dir(), (environment.Filename)(uploadPrefix).WithUnixSuffix())
-	archPath, err := compressor.Compress(context.Background(), absPathToArch, scriptWorkDir, scriptResultFilename)
+	// Collect the outputs declared by the contract
+	entries, err := algorithm.Outputs.Collect(scriptWorkDir, append(inputFiles, paramsFilename))
 	if err != nil {
-		r.log.Errorf("error compressing file: '%s', error: %s", scriptResultFilename, err)
+		r.log.Errorf("error collecting script outputs: %s", err)
+		if errors.Is(err, ErrMissingOutput) || errors.Is(err, ErrInvalidOutput) {
+			return nil, err
+		}
 		return nil, ErrCompress
 	}
+	// The parameters are packaged to record them with the result
+	if len(algorithm.Parameters) > 0 {
+		paramsEntry, err := compressor.FileEntry(scriptWorkDir, paramsFilename)
+		if err != nil {
+			r.log.Errorf("error reading parameters: %s", err)
+			return nil, ErrCompress
+		}
+		entries = append(entries, paramsEntry)
+	}
+
+	// Compress the result and stream the archive to the bucket
+	archive := compressor.Reader(ctx, format, entries...)
+	defer func() {
+		if err := archive.Close(); err != nil {
+			r.log.Warnf("error closing archive stream: %s", err)
+		}
+	}()
 
-	// Upload the compressed file
-	uploadRes, err := r.storage.UploadFiles(env.Dir(), filepath.Base(archPath))
+	archName := (environment.Filename)(uploadPrefix).WithUnixSuffix() + format.Ext()
+	uploadRes, err := r.storage.Put(ctx, archName, archive, storage.PutOptions{ContentType: format.ContentType()})
 	if err != nil {
 		r.log.Errorf("error uploading files: %s", err)
+		if ctx.Err() != nil {
+			return nil, ErrCanceled
+		}
 		return nil, ErrUpload
 	}
 
 	return &Result{
-		Filename:      uploadRes[0].Filename,
-		Location:      uploadRes[0].Location,
-		ETag:          uploadRes[0].ETag,
+		Algorithm:     algorithm.ID(),
+		Filename:      uploadRes.Filename,
+		Location:      uploadRes.Location,
+		ETag:          uploadRes.ETag,
 		ExecutionTime: scriptRes.ExecutionTime,
+		Summary:       summary,
+		Parameters:    params,
 	}, nil
 }
 
+// resolveInputs returns the positional inputs, the input flags and every input file of the run.
+// The files of the slots with a CSV schema are validated and normalized.
+// Without the input slots every file of workDir is a positional input, except for the originals of the converted files.
+func resolveInputs(algorithm *Algorithm, workDir string) ([]string, []string, []string, error) {
+	if len(algorithm.Inputs) == 0 {
+		filenames, err := getFilenames(workDir)
+		return filenames, nil, filenames, err
+	}
+	files, err := algorithm.Inputs.Resolve(workDir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := algorithm.Inputs.Validate(workDir, files); err != nil {
+		return nil, nil, nil, err
+	}
+	inputs, flags := algorithm.Inputs.Args(files)
+	all := make([]string, 0, len(files))
+	for _, file := range files {
+		all = append(all, file)
+	}
+	return inputs, flags, all, nil
+}
+
+// reservedFilenames are written into the script working directory by the run, they can't come with the input archive
+var reservedFilenames = []string{summaryFilename, paramsFilename}
+
+// checkReservedNames rejects the input archive if it has one of the reserved files
+func checkReservedNames(workDir string) error {
+	for _, name := range reservedFilenames {
+		_, err := os.Lstat(filepath.Join(workDir, name))
+		if err == nil {
+			return fmt.Errorf("%w: %s is a reserved filename", ErrInvalidArchive, name)
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
 func getFilenames(dir string) ([]string, error) {
 	files, err := ioutil.ReadDir(dir)
 	if err != nil {
@@ -113,6 +251,9 @@ func getFilenames(dir string) ([]string, error) {
 	}
 	filenames := make([]string, 0, len(files))
 	for _, file := range files {
+		if file.Name() == tabular.OriginalsDir {
+			continue
+		}
 		filenames = append(filenames, file.Name())
 	}
 	return filenames, nil
@@ -0,0 +1,204 @@
+package optimizer
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/runner"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testInput = "input_files_1.tar.gz"
+	// testSlotInput has the files of the racks and devices slots
+	testSlotInput = "input_files_2.tar.gz"
+)
+
+// fakeRunner writes the given files into the work directory and records the run
+type fakeRunner struct {
+	files  map[string]string
+	params []string
+	inputs []string
+	json   string
+	// termination is reported instead of running the script
+	termination runner.Termination
+}
+
+func (f *fakeRunner) Run(ctx context.Context, workDir string, params []string, inputs ...string) *runner.Result {
+	f.params = params
+	f.inputs = inputs
+	if f.termination != runner.TerminationNone {
+		return &runner.Result{ExitCode: -1, Termination: f.termination}
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(workDir, paramsFilename)); err == nil {
+		f.json = string(data)
+	}
+	for name, content := range f.files {
+		if err := ioutil.WriteFile(filepath.Join(workDir, name), []byte(content), os.ModePerm); err != nil {
+			return &runner.Result{ExitCode: 1, ShellOutput: err.Error()}
+		}
+	}
+	return &runner.Result{}
+}
+
+func newTestRack(t *testing.T, algorithms ...*Algorithm) (*RackOptimizer, string) {
+	bucket := t.TempDir()
+	inputDir := t.TempDir()
+	writeFiles(t, inputDir, "a.csv", "b.csv")
+	_, err := compressor.Compress(context.Background(), filepath.Join(bucket, "input_files_1"), inputDir, "a.csv", "b.csv")
+	assert.NoError(t, err)
+	slotDir := t.TempDir()
+	writeFiles(t, slotDir, "racks/r.csv", "devices.csv")
+	_, err = compressor.Compress(context.Background(), filepath.Join(bucket, "input_files_2"), slotDir, "racks/r.csv", "devices.csv")
+	assert.NoError(t, err)
+
+	registry, err := NewRegistry("", algorithms...)
+	assert.NoError(t, err)
+	log := logger.NewTestLogger()
+	return NewRackOptimizer(registry, storage.NewFSStorage(bucket, log), t.TempDir(), "tmp", compressor.DefaultLimits, log), bucket
+}
+
+// resultFiles extracts the result archive and returns the names of its files
+func resultFiles(t *testing.T, bucket string, filename string) []string {
+	dir := t.TempDir()
+	assert.NoError(t, compressor.Decompress(context.Background(), filepath.Join(bucket, filename), dir))
+	names := make([]string, 0)
+	infos, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRackOptimizer_Execute(t *testing.T) {
+	greedy := &fakeRunner{files: map[string]string{
+		"def_output.csv": "value\n1\n",
+		"result.json":    `{"objective": 3, "status": "feasible"}`,
+	}}
+	milp := &fakeRunner{files: map[string]string{"def_output.csv": "value\n2\n"}}
+	rack, bucket := newTestRack(t,
+		&Algorithm{Name: "rack-greedy", Version: "1.2", Runner: greedy, Outputs: DefaultOutputContract},
+		&Algorithm{Name: "rack-milp", Version: "2.0", Runner: milp, Outputs: DefaultOutputContract,
+			Parameters: ParameterSchema{{Name: "seed", Type: TypeInteger, Default: 42}, {Name: "fast", Type: TypeBoolean}}},
+	)
+
+	res, err := rack.Execute(context.Background(), Request{Filename: testInput})
+	assert.NoError(t, err)
+	assert.Equal(t, "rack-greedy@1.2", res.Algorithm)
+	assert.Equal(t, []string{"a.csv", "b.csv"}, greedy.inputs)
+	assert.Empty(t, greedy.params)
+	assert.Empty(t, greedy.json)
+	if assert.NotNil(t, res.Summary) {
+		assert.Equal(t, StatusFeasible, res.Summary.Status)
+	}
+	assert.Equal(t, []string{"def_output.csv"}, resultFiles(t, bucket, res.Filename))
+
+	res, err = rack.Execute(context.Background(), Request{Filename: testInput, Format: compressor.Zip, Algorithm: "rack-milp",
+		Parameters: map[string]interface{}{"fast": true}})
+	assert.NoError(t, err)
+	assert.Equal(t, "rack-milp@2.0", res.Algorithm)
+	assert.Nil(t, res.Summary)
+	assert.Equal(t, map[string]interface{}{"seed": json.Number("42"), "fast": true}, res.Parameters)
+	assert.Equal(t, []string{"--seed=42", "--fast"}, milp.params)
+	assert.Equal(t, []string{"a.csv", "b.csv"}, milp.inputs)
+	assert.JSONEq(t, `{"seed":42,"fast":true}`, milp.json)
+	assert.Equal(t, []string{"def_output.csv", "params.json"}, resultFiles(t, bucket, res.Filename))
+}
+
+func TestRackOptimizer_ExecuteErrors(t *testing.T) {
+	rack, _ := newTestRack(t,
+		&Algorithm{Name: "rack-greedy", Version: "1.2", Outputs: DefaultOutputContract,
+			Runner: &fakeRunner{files: map[string]string{"def_output.csv": "value\n", "result.json": `{"status": "done"}`}}},
+	)
+
+	_, err := rack.Execute(context.Background(), Request{Filename: testInput, Algorithm: "rack-milp"})
+	assert.ErrorIs(t, err, ErrUnknownAlgorithm)
+
+	_, err = rack.Execute(context.Background(), Request{Filename: testInput, Parameters: map[string]interface{}{"seed": 1}})
+	assert.ErrorIs(t, err, ErrInvalidParameters)
+
+	_, err = rack.Execute(context.Background(), Request{Filename: testInput})
+	assert.ErrorIs(t, err, ErrInvalidOutput)
+}
+
+func TestRackOptimizer_ExecuteReservedNames(t *testing.T) {
+	fake := &fakeRunner{files: map[string]string{"def_output.csv": "value\n"}}
+	rack, bucket := newTestRack(t, &Algorithm{Name: "rack-greedy", Version: "1.2", Runner: fake, Outputs: DefaultOutputContract})
+
+	for i, name := range []string{"result.json", "params.json"} {
+		inputDir := t.TempDir()
+		writeFiles(t, inputDir, "a.csv", name)
+		archive := "input_files_reserved_" + strconv.Itoa(i)
+		_, err := compressor.Compress(context.Background(), filepath.Join(bucket, archive), inputDir, "a.csv", name)
+		assert.NoError(t, err)
+
+		_, err = rack.Execute(context.Background(), Request{Filename: archive + compressor.TarGz.Ext()})
+		assert.ErrorIs(t, err, ErrInvalidArchive, name)
+		assert.Equal(t, CodeInvalidArchive, ErrorCode(err))
+		assert.Nil(t, fake.inputs, "the script must not run")
+	}
+}
+
+func TestRackOptimizer_ExecuteInputSlots(t *testing.T) {
+	fake := &fakeRunner{files: map[string]string{"def_output.csv": "value\n"}}
+	rack, _ := newTestRack(t,
+		&Algorithm{Name: "rack-greedy", Version: "1.2", Runner: fake, Outputs: OutputContract{Files: []OutputFile{{Pattern: "*.csv"}}},
+			Inputs: InputSchema{{Name: "devices", Required: true, Flag: "--devices"}, {Name: "racks", Required: true}}},
+	)
+
+	res, err := rack.Execute(context.Background(), Request{Filename: testSlotInput})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"racks/r.csv"}, fake.inputs)
+	assert.Equal(t, []string{"--devices=devices.csv"}, fake.params)
+	assert.NotNil(t, res)
+
+	fake.inputs = nil
+	_, err = rack.Execute(context.Background(), Request{Filename: testInput})
+	assert.ErrorIs(t, err, ErrInvalidInputs)
+	assert.Nil(t, fake.inputs, "the script must not run")
+}
+
+func TestRackOptimizer_ExecuteInvalidData(t *testing.T) {
+	fake := &fakeRunner{files: map[string]string{"def_output.csv": "value\n"}}
+	rack, _ := newTestRack(t,
+		&Algorithm{Name: "rack-greedy", Version: "1.2", Runner: fake,
+			Inputs: InputSchema{{Name: "racks", CSV: &tabular.Schema{Columns: []tabular.Column{{Name: "id"}}}}, {Name: "devices"}}},
+	)
+
+	_, err := rack.Execute(context.Background(), Request{Filename: testSlotInput})
+	assert.ErrorIs(t, err, ErrInvalidData)
+	assert.Equal(t, CodeData, ErrorCode(err))
+	var dataErr *DataError
+	if assert.ErrorAs(t, err, &dataErr) {
+		assert.Equal(t, []tabular.Violation{{File: "racks/r.csv", Line: 1, Column: "id", Message: "missing in the header"}}, dataErr.Violations)
+	}
+	assert.Nil(t, fake.inputs, "the script must not run")
+}
+
+func TestRackOptimizer_ExecuteCanceled(t *testing.T) {
+	fake := &fakeRunner{termination: runner.TerminationCanceled}
+	rack, _ := newTestRack(t, &Algorithm{Name: "rack-greedy", Version: "1.2", Runner: fake, Outputs: DefaultOutputContract})
+
+	_, err := rack.Execute(context.Background(), Request{Filename: testInput})
+	assert.ErrorIs(t, err, ErrCanceled)
+	assert.Equal(t, CodeCanceled, ErrorCode(err))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fake.inputs = nil
+	_, err = rack.Execute(ctx, Request{Filename: testInput})
+	assert.ErrorIs(t, err, ErrCanceled)
+	assert.Nil(t, fake.inputs, "the script must not run")
+}
@@ -0,0 +1,68 @@
+package optimizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/scheduler"
+)
+
+// ErrBusy is returned when there is no room left in the run queue
+var ErrBusy = errors.New("optimization queue is full")
+
+// BusyError is returned by ScheduledOptimizer when the run can't be queued.
+// RetryAfter is a hint of how long the caller should wait before trying again.
+type BusyError struct {
+	RetryAfter time.Duration
+}
+
+func (e *BusyError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrBusy, e.RetryAfter)
+}
+
+func (e *BusyError) Is(target error) bool {
+	return target == ErrBusy
+}
+
+var _ Optimizer = (*ScheduledOptimizer)(nil)
+
+// ScheduledOptimizer runs the wrapped optimizer on the scheduler workers
+type ScheduledOptimizer struct {
+	next       Optimizer
+	scheduler  *scheduler.Scheduler
+	retryAfter time.Duration
+}
+
+func NewScheduledOptimizer(next Optimizer, scheduler *scheduler.Scheduler, retryAfter time.Duration) *ScheduledOptimizer {
+	return &ScheduledOptimizer{
+		next:       next,
+		scheduler:  scheduler,
+		retryAfter: retryAfter,
+	}
+}
+
+// Execute queues the run. A run canceled while it waits in the queue is skipped once a worker picks it up.
+func (o *ScheduledOptimizer) Execute(ctx context.Context, req Request) (*Result, error) {
+	var (
+		res *Result
+		err error
+	)
+	done, qerr := o.scheduler.Submit(func() {
+		if ctx.Err() != nil {
+			err = ErrCanceled
+			return
+		}
+		res, err = o.next.Execute(ctx, req)
+	})
+	if qerr != nil {
+		return nil, &BusyError{RetryAfter: o.retryAfter}
+	}
+	select {
+	case <-done:
+		return res, err
+	case <-ctx.Done():
+		return nil, ErrCanceled
+	}
+}
@@ -0,0 +1,54 @@
+package optimizer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+// optimizerFunc adapts a function to the Optimizer interface
+type optimizerFunc func(ctx context.Context, req Request) (*Result, error)
+
+func (f optimizerFunc) Execute(ctx context.Context, req Request) (*Result, error) {
+	return f(ctx, req)
+}
+
+func TestScheduledOptimizer_Canceled(t *testing.T) {
+	s := scheduler.New(1, 2)
+	defer s.Stop()
+
+	release := make(chan struct{})
+	started := make(chan string, 2)
+	o := NewScheduledOptimizer(optimizerFunc(func(ctx context.Context, req Request) (*Result, error) {
+		started <- req.Filename
+		if req.Filename == "blocking" {
+			<-release
+		}
+		return &Result{Filename: req.Filename}, nil
+	}), s, time.Second)
+
+	go func() {
+		_, _ = o.Execute(context.Background(), Request{Filename: "blocking"})
+	}()
+	assert.Equal(t, "blocking", <-started)
+
+	// the queued run returns once it is canceled and never runs
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	_, err := o.Execute(ctx, Request{Filename: "queued"})
+	assert.ErrorIs(t, err, ErrCanceled)
+
+	close(release)
+	res, err := o.Execute(context.Background(), Request{Filename: "next"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "next", res.Filename)
+	}
+	// the canceled run was skipped by the worker
+	assert.Equal(t, "next", <-started)
+}
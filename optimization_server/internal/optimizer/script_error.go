@@ -0,0 +1,64 @@
+package optimizer
+
+import (
+	"fmt"
+
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/runner"
+)
+
+// maxStderrTail limits the part of the script stderr reported to the user
+const maxStderrTail = 4096
+
+// ExitCode is the error reported to the user for an exit code of the script
+type ExitCode struct {
+	Code    string
+	Message string
+}
+
+// DefaultExitCodes describe the exit codes of the sample script
+var DefaultExitCodes = map[int]ExitCode{
+	2:   {Code: "invalid_arguments", Message: "invalid script arguments"},
+	14:  {Code: "script_exception", Message: "script raised an exception"},              // errno.EFAULT
+	126: {Code: "input_length_mismatch", Message: "input files have different lengths"}, // errno.ENOKEY
+}
+
+// ScriptError is returned when the script exits with a non-zero code
+type ScriptError struct {
+	ExitCode int
+	// Code and Message come from the exit code table, both are empty for unknown exit codes
+	Code    string
+	Message string
+	// Stderr is the tail of the script stderr
+	Stderr    string
+	Traceback *runner.Traceback
+}
+
+func newScriptError(res *runner.Result, exitCodes map[int]ExitCode) *ScriptError {
+	stderr := res.ScriptError
+	if len(stderr) > maxStderrTail {
+		stderr = stderr[len(stderr)-maxStderrTail:]
+	}
+	exitCode := exitCodes[res.ExitCode]
+	return &ScriptError{
+		ExitCode:  res.ExitCode,
+		Code:      exitCode.Code,
+		Message:   exitCode.Message,
+		Stderr:    stderr,
+		Traceback: res.Traceback,
+	}
+}
+
+func (e *ScriptError) Error() string {
+	msg := fmt.Sprintf("%s: exit code %d", ErrOptimize, e.ExitCode)
+	if e.Message != "" {
+		msg += ", " + e.Message
+	}
+	if e.Traceback != nil {
+		msg += fmt.Sprintf(", %s: %s", e.Traceback.Type, e.Traceback.Message)
+	}
+	return msg
+}
+
+func (e *ScriptError) Unwrap() error {
+	return ErrOptimize
+}
@@ -0,0 +1,84 @@
+package optimizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// summaryFilename is the optional result summary written by the script
+	summaryFilename = "result.json"
+	maxSummarySize  = 1 << 20 // 1 MB
+)
+
+// SolverStatus is the outcome reported by the solver
+type SolverStatus string
+
+const (
+	StatusOptimal    SolverStatus = "optimal"
+	StatusFeasible   SolverStatus = "feasible"
+	StatusInfeasible SolverStatus = "infeasible"
+)
+
+// Summary is the structured result the script may write into result.json:
+//
+//	{"objective": 12.5, "status": "optimal", "iterations": 120, "warnings": ["..."], "kpis": {"racks_used": 14}}
+//
+// All the fields are optional, unknown fields are ignored.
+type Summary struct {
+	Objective  *float64           `json:"objective,omitempty"`
+	Status     SolverStatus       `json:"status,omitempty"`
+	Iterations *int64             `json:"iterations,omitempty"`
+	Warnings   []string           `json:"warnings,omitempty"`
+	KPIs       map[string]float64 `json:"kpis,omitempty"`
+}
+
+// Validate checks the values which can't be checked by decoding
+func (s *Summary) Validate() error {
+	switch s.Status {
+	case "", StatusOptimal, StatusFeasible, StatusInfeasible:
+	default:
+		return fmt.Errorf("unknown solver status '%s'", s.Status)
+	}
+	if s.Iterations != nil && *s.Iterations < 0 {
+		return fmt.Errorf("negative iteration count %d", *s.Iterations)
+	}
+	for name := range s.KPIs {
+		if name == "" {
+			return fmt.Errorf("empty KPI name")
+		}
+	}
+	return nil
+}
+
+// readSummary parses result.json in workDir, nil is returned if the script hasn't written it
+func readSummary(workDir string) (*Summary, error) {
+	file, err := os.Open(filepath.Join(workDir, summaryFilename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSummarySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSummarySize {
+		return nil, fmt.Errorf("%w: %s is larger than %d bytes", ErrInvalidOutput, summaryFilename, maxSummarySize)
+	}
+
+	summary := &Summary{}
+	if err := json.Unmarshal(data, summary); err != nil {
+		return nil, fmt.Errorf("%w: %s: %s", ErrInvalidOutput, summaryFilename, err)
+	}
+	if err := summary.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %s: %s", ErrInvalidOutput, summaryFilename, err)
+	}
+	return summary, nil
+}
@@ -0,0 +1,95 @@
+package optimizer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadSummary(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		expected *Summary
+		err      string
+	}{
+		{
+			name:     "no result.json",
+			expected: nil,
+		},
+		{
+			name:    "full summary",
+			content: `{"objective": 12.5, "status": "optimal", "iterations": 120, "warnings": ["slow"], "kpis": {"racks": 14}, "extra": true}`,
+			expected: &Summary{
+				Objective:  floatPtr(12.5),
+				Status:     StatusOptimal,
+				Iterations: int64Ptr(120),
+				Warnings:   []string{"slow"},
+				KPIs:       map[string]float64{"racks": 14},
+			},
+		},
+		{
+			name:     "empty object",
+			content:  `{}`,
+			expected: &Summary{},
+		},
+		{
+			name:    "broken json",
+			content: `{"objective":`,
+			err:     "unexpected end of JSON input",
+		},
+		{
+			name:    "wrong type",
+			content: `{"objective": "high"}`,
+			err:     "cannot unmarshal string",
+		},
+		{
+			name:    "unknown status",
+			content: `{"status": "done"}`,
+			err:     "unknown solver status 'done'",
+		},
+		{
+			name:    "negative iterations",
+			content: `{"iterations": -1}`,
+			err:     "negative iteration count",
+		},
+		{
+			name:    "non numeric kpi",
+			content: `{"kpis": {"racks": "many"}}`,
+			err:     "cannot unmarshal string",
+		},
+		{
+			name:    "too large",
+			content: `{"warnings": ["` + strings.Repeat("a", maxSummarySize) + `"]}`,
+			err:     "larger than",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			if tc.content != "" {
+				assert.NoError(t, ioutil.WriteFile(filepath.Join(workDir, summaryFilename), []byte(tc.content), os.ModePerm))
+			}
+			summary, err := readSummary(workDir)
+			if tc.err != "" {
+				assert.ErrorIs(t, err, ErrInvalidOutput)
+				assert.Contains(t, err.Error(), tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, summary)
+		})
+	}
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
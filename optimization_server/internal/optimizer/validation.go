@@ -0,0 +1,64 @@
+package optimizer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
+)
+
+// ErrInvalidData is returned when the content of the input files doesn't match the CSV schemas of the input slots
+var ErrInvalidData = errors.New("invalid input data")
+
+// maxErrorViolations caps the violations listed in the error message
+const maxErrorViolations = 10
+
+// DataError lists the violations found in the input files
+type DataError struct {
+	Violations []tabular.Violation
+	// Truncated is set when some violations have been dropped
+	Truncated bool
+}
+
+func (e *DataError) Error() string {
+	reasons := make([]string, 0, maxErrorViolations)
+	for i, v := range e.Violations {
+		if i == maxErrorViolations {
+			reasons = append(reasons, fmt.Sprintf("and %d more", len(e.Violations)-i))
+			break
+		}
+		reasons = append(reasons, v.String())
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidData, strings.Join(reasons, "; "))
+}
+
+func (e *DataError) Is(target error) bool {
+	return target == ErrInvalidData
+}
+
+// Validate checks the files of the slots with a CSV schema and normalizes them in place:
+// UTF-8 without the byte order mark, comma separated, LF line endings.
+// The files of the slots with EqualRows must have the same number of rows.
+func (s InputSchema) Validate(workDir string, files map[string]string) error {
+	inputs := make([]tabular.Input, 0, len(s))
+	for _, slot := range s {
+		if file, ok := files[slot.Name]; ok && slot.CSV != nil {
+			inputs = append(inputs, tabular.Input{Slot: slot.Name, File: file, Schema: *slot.CSV, EqualRows: slot.EqualRows})
+		}
+	}
+	report, err := tabular.ValidateInputs(inputs, func(input tabular.Input) (*tabular.Report, error) {
+		report, err := input.Schema.NormalizeFile(workDir, input.File)
+		if err != nil {
+			return nil, fmt.Errorf("error validating '%s': %w", input.File, err)
+		}
+		return report, nil
+	})
+	if err != nil {
+		return err
+	}
+	if !report.Valid() {
+		return &DataError{Violations: report.Violations, Truncated: report.Truncated}
+	}
+	return nil
+}
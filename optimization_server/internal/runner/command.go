@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+)
+
+// Argument template placeholders
+const (
+	// ArgScript is replaced with the script path
+	ArgScript = "{script}"
+	// ArgInputs is replaced with the input filenames, each one becomes a separate argument.
+	// It must be a whole argument.
+	ArgInputs = "{inputs}"
+	// ArgWorkDir is replaced with the working directory of the run
+	ArgWorkDir = "{workDir}"
+	// ArgParams is replaced with the parameter flags of the run, each one becomes a separate argument.
+	// It must be a whole argument.
+	ArgParams = "{params}"
+)
+
+// DefaultArgs pass the script path followed by the parameter flags and the input files
+var DefaultArgs = []string{ArgScript, ArgParams, ArgInputs}
+
+var ErrEmptyExecutable = errors.New("executable can't be empty")
+
+var _ Runner = (*Command)(nil)
+
+// Command runs any executable, e.g. julia, Rscript or a compiled solver,
+// with the arguments built from a template
+type Command struct {
+	executable string
+	scriptPath string
+	args       []string
+	process    *process
+}
+
+// NewCommand creates a runner for the executable. The args template may use the ArgScript,
+// ArgInputs, ArgParams and ArgWorkDir placeholders, DefaultArgs are used if it is empty.
+func NewCommand(executable string, scriptPath string, args []string, timeout time.Duration, log *logger.Logger, opts ...Option) (*Command, error) {
+	if executable == "" {
+		return nil, ErrEmptyExecutable
+	}
+	if len(args) == 0 {
+		args = DefaultArgs
+	}
+	return &Command{
+		executable: executable,
+		scriptPath: scriptPath,
+		args:       args,
+		process:    newProcess(timeout, log, opts),
+	}, nil
+}
+
+func (c *Command) Run(ctx context.Context, workDir string, params []string, inputs ...string) *Result {
+	return c.process.run(ctx, workDir, c.executable, c.expand(workDir, params, inputs))
+}
+
+// expand builds the command arguments from the template
+func (c *Command) expand(workDir string, params []string, inputs []string) []string {
+	args := make([]string, 0, len(c.args)+len(params)+len(inputs))
+	for _, arg := range c.args {
+		switch arg {
+		case ArgInputs:
+			args = append(args, inputs...)
+			continue
+		case ArgParams:
+			args = append(args, params...)
+			continue
+		}
+		arg = strings.ReplaceAll(arg, ArgScript, c.scriptPath)
+		arg = strings.ReplaceAll(arg, ArgWorkDir, workDir)
+		args = append(args, arg)
+	}
+	return args
+}
@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommand_Expand(t *testing.T) {
+	cmd, err := NewCommand("julia", "/opt/solver/main.jl", []string{"--project={workDir}/env", ArgScript, "--out", "def_output.csv", ArgParams, ArgInputs}, time.Second, logger.NewTestLogger())
+	assert.NoError(t, err)
+	assert.Equal(t,
+		[]string{"--project=/tmp/run/env", "/opt/solver/main.jl", "--out", "def_output.csv", "--seed=42", "a.csv", "b.csv"},
+		cmd.expand("/tmp/run", []string{"--seed=42"}, []string{"a.csv", "b.csv"}))
+
+	cmd, err = NewCommand("python3", "main.py", nil, time.Second, logger.NewTestLogger())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"main.py", "a.csv"}, cmd.expand("/tmp/run", nil, []string{"a.csv"}))
+	assert.Equal(t, []string{"main.py", "--fast", "a.csv"}, cmd.expand("/tmp/run", []string{"--fast"}, []string{"a.csv"}))
+
+	_, err = NewCommand("", "main.py", nil, time.Second, logger.NewTestLogger())
+	assert.ErrorIs(t, err, ErrEmptyExecutable)
+}
+
+func TestCommand_Run(t *testing.T) {
+	workDir := t.TempDir()
+	cmd, err := NewCommand("sh", "", []string{"-c", "pwd; echo $@; echo failed >&2; exit 3", "sh", ArgInputs}, 5*time.Second, logger.NewTestLogger())
+	assert.NoError(t, err)
+
+	result := cmd.Run(context.Background(), workDir, nil, "a.csv", "b.csv")
+	assert.Equal(t, 3, result.ExitCode)
+	dir, err := filepath.EvalSymlinks(workDir)
+	assert.NoError(t, err)
+	assert.Equal(t, dir+"\na.csv b.csv\n", result.ScriptOutput)
+	assert.Equal(t, "failed\n", result.ScriptError)
+	assert.Nil(t, result.Traceback)
+	assert.NotEmpty(t, result.ShellOutput)
+	assert.Equal(t, TerminationNone, result.Termination)
+}
+
+func TestCommand_RunLimits(t *testing.T) {
+	workDir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(workDir, "run.sh"), []byte("#!/bin/sh\nulimit -n\nsh -c 'ulimit -n'\n"), 0o755))
+
+	// the relative executable is resolved in the work dir and the forked shell has the limit too
+	cmd, err := NewCommand("./run.sh", "", []string{}, 5*time.Second, logger.NewTestLogger(), WithLimits(Limits{OpenFiles: 32}))
+	assert.NoError(t, err)
+	result := cmd.Run(context.Background(), workDir, nil)
+	assert.Equal(t, 0, result.ExitCode)
+	assert.Equal(t, "32\n32\n", result.ScriptOutput)
+
+	cmd, err = NewCommand("no-such-executable", "", nil, 5*time.Second, logger.NewTestLogger(), WithLimits(Limits{OpenFiles: 32}))
+	assert.NoError(t, err)
+	result = cmd.Run(context.Background(), workDir, nil)
+	assert.Equal(t, -1, result.ExitCode)
+	assert.Contains(t, result.ShellOutput, "no-such-executable")
+}
+
+func TestPython_Interpreter(t *testing.T) {
+	scriptPath, err := filepath.Abs("mock_scripts/main.py")
+	assert.NoError(t, err)
+
+	result := NewPython("no-such-python", scriptPath, 5*time.Second, logger.NewTestLogger()).Run(context.Background(), "", nil, "success")
+	assert.Equal(t, -1, result.ExitCode)
+	assert.True(t, strings.Contains(result.ShellOutput, "no-such-python"))
+}
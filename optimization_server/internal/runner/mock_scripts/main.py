@@ -0,0 +1,54 @@
+import sys, os, errno, getopt, time, signal, subprocess
+
+def main(argv):
+    if len(argv) > 0 and argv[0]=="raise":
+        sys.stderr.write("warning\n")
+        raise ValueError(argv[1])
+
+    try:
+        if len(argv) == 0:
+            return
+
+        if argv[0]=="error":    
+            sys.exit(errno.EFAULT)
+        if argv[0]=="success":
+            sys.exit(0)
+        if argv[0]=="sleep":
+            time.sleep(float(argv[1])/1000.0)
+            return
+        if argv[0]=="print":
+            print(argv[1])
+            return
+        if argv[0]=="flood":
+            sys.stdout.write("x" * int(argv[1]))
+            return
+        if argv[0]=="fork":
+            child = subprocess.Popen([sys.executable, "-c", "import time; time.sleep(60)"])
+            print(child.pid, flush=True)
+            time.sleep(float(argv[1])/1000.0)
+            return
+        if argv[0]=="progress":
+            print('##progress 10 "reading input"')
+            print("solving")
+            with open(os.environ["OPTIMIZATION_PROGRESS_FILE"], "w") as f:
+                f.write('##progress 60 "placing rack row 3"\n')
+            return
+        if argv[0]=="ignore_term":
+            signal.signal(signal.SIGTERM, signal.SIG_IGN)
+            time.sleep(float(argv[1])/1000.0)
+            return
+        if argv[0]=="child_limit":
+            subprocess.run([sys.executable, "-c", "import resource; print(resource.getrlimit(resource.RLIMIT_NOFILE)[0])"])
+            return
+        if argv[0]=="kill_self":
+            os.kill(os.getpid(), signal.SIGKILL)
+        if argv[0]=="burn":
+            while True:
+                pass
+    
+    except Exception as e:
+        print('Exception:', e)
+        sys.exit(errno.EFAULT)
+
+if __name__ == "__main__":
+   main(sys.argv[1:])
@@ -0,0 +1,202 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+)
+
+const (
+	// ProgressPrefix starts a progress line of the script: ##progress <percent> ["message"]
+	ProgressPrefix = "##progress"
+	// ProgressFileEnv is the environment variable with the path of the progress file of the run.
+	// The script may write its progress lines there instead of stdout, the last line is the current progress.
+	ProgressFileEnv = "OPTIMIZATION_PROGRESS_FILE"
+
+	// maxProgressLine caps a buffered stdout line, longer lines are not progress lines
+	maxProgressLine = 4096
+	// progressPollInterval is how often the progress file is read
+	progressPollInterval = 500 * time.Millisecond
+)
+
+// Progress is the latest progress reported by the script
+type Progress struct {
+	// Percent is between 0 and 100
+	Percent float64
+	Message string
+	// Time is when the progress was reported
+	Time time.Time
+}
+
+// ProgressFunc receives the progress of a run as the script reports it
+type ProgressFunc func(Progress)
+
+type progressKey struct{}
+
+// WithProgress returns a context which makes the runners report the progress of the script to fn
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+func progressFunc(ctx context.Context) ProgressFunc {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return fn
+}
+
+// ParseProgress parses a progress line, e.g. ##progress 42 "placing rack row 3".
+// The percent is clamped to 0-100, the message may be quoted or not.
+func ParseProgress(line string) (Progress, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, ProgressPrefix) {
+		return Progress{}, false
+	}
+	rest := line[len(ProgressPrefix):]
+	if rest == "" || (rest[0] != ' ' && rest[0] != '\t') {
+		return Progress{}, false
+	}
+	fields := strings.Fields(rest)
+	percent, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || math.IsNaN(percent) {
+		return Progress{}, false
+	}
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+
+	message := strings.TrimSpace(rest[strings.Index(rest, fields[0])+len(fields[0]):])
+	if unquoted, err := strconv.Unquote(message); err == nil {
+		message = unquoted
+	}
+	return Progress{Percent: percent, Message: message, Time: time.Now().UTC()}, true
+}
+
+// progressReporter passes the progress lines of the script output and of the progress file to fn
+type progressReporter struct {
+	fn  ProgressFunc
+	log *logger.Logger
+
+	mu   sync.Mutex
+	line []byte
+	// long is set while the rest of a line which exceeded maxProgressLine is skipped
+	long bool
+
+	file string
+	last string
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newProgressReporter(fn ProgressFunc, log *logger.Logger) *progressReporter {
+	return &progressReporter{fn: fn, log: log}
+}
+
+// Write scans the stdout of the script for the progress lines
+func (p *progressReporter) Write(data []byte) (int, error) {
+	n := len(data)
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			p.buffer(data)
+			break
+		}
+		p.buffer(data[:i])
+		if !p.long {
+			p.report(string(p.line))
+		}
+		p.line = p.line[:0]
+		p.long = false
+		data = data[i+1:]
+	}
+	return n, nil
+}
+
+func (p *progressReporter) buffer(data []byte) {
+	if p.long {
+		return
+	}
+	if len(p.line)+len(data) > maxProgressLine {
+		p.line = p.line[:0]
+		p.long = true
+		return
+	}
+	p.line = append(p.line, data...)
+}
+
+func (p *progressReporter) report(line string) {
+	progress, ok := ParseProgress(line)
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fn(progress)
+}
+
+// watch creates the progress file and reads it until stopped
+func (p *progressReporter) watch() (string, error) {
+	f, err := ioutil.TempFile("", "progress-*")
+	if err != nil {
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		p.log.Warnf("error closing progress file: %s", err)
+	}
+	p.file = f.Name()
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(progressPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.readFile()
+			case <-p.stop:
+				p.readFile()
+				return
+			}
+		}
+	}()
+	return p.file, nil
+}
+
+// readFile reports the last line of the progress file if it has changed
+func (p *progressReporter) readFile() {
+	data, err := ioutil.ReadFile(p.file)
+	if err != nil {
+		p.log.Debugf("error reading progress file: %s", err)
+		return
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	line := lines[len(lines)-1]
+	if line == "" || line == p.last {
+		return
+	}
+	p.last = line
+	p.report(line)
+}
+
+// close stops reading the progress file and removes it
+func (p *progressReporter) close() {
+	if p.file == "" {
+		return
+	}
+	close(p.stop)
+	<-p.done
+	if err := os.Remove(p.file); err != nil {
+		p.log.Warnf("error removing progress file: %s", err)
+	}
+}
@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProgress(t *testing.T) {
+	testCases := []struct {
+		line    string
+		ok      bool
+		percent float64
+		message string
+	}{
+		{line: `##progress 42 "placing rack row 3"`, ok: true, percent: 42, message: "placing rack row 3"},
+		{line: "  ##progress 12.5\tsolving the model  \n", ok: true, percent: 12.5, message: "solving the model"},
+		{line: `##progress 7`, ok: true, percent: 7},
+		{line: `##progress 150 "done"`, ok: true, percent: 100, message: "done"},
+		{line: `##progress -3`, ok: true, percent: 0},
+		{line: `##progress "half way"`},
+		{line: `##progress NaN`},
+		{line: `##progress`},
+		{line: `##progress42`},
+		{line: `progress 42`},
+	}
+	for _, tc := range testCases {
+		progress, ok := ParseProgress(tc.line)
+		assert.Equal(t, tc.ok, ok, tc.line)
+		assert.Equal(t, tc.percent, progress.Percent, tc.line)
+		assert.Equal(t, tc.message, progress.Message, tc.line)
+		assert.Equal(t, tc.ok, !progress.Time.IsZero(), tc.line)
+	}
+}
+
+func TestProgressReporter_Write(t *testing.T) {
+	var reported []Progress
+	p := newProgressReporter(func(progress Progress) {
+		reported = append(reported, progress)
+	}, logger.NewTestLogger())
+
+	// the lines are split across the writes, an overlong line is skipped
+	for _, chunk := range []string{"output\n##prog", "ress 5 \"a\"\n", strings.Repeat("x", maxProgressLine+1), " ##progress 6\n", "##progress 7"} {
+		n, err := p.Write([]byte(chunk))
+		assert.NoError(t, err)
+		assert.Equal(t, len(chunk), n)
+	}
+	if assert.Len(t, reported, 1) {
+		assert.Equal(t, 5.0, reported[0].Percent)
+		assert.Equal(t, "a", reported[0].Message)
+	}
+}
+
+func TestPython_Progress(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		reported []Progress
+	)
+	ctx := WithProgress(context.Background(), func(progress Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, progress)
+	})
+
+	// the progress is read from stdout and from the progress file
+	result := testPythonContext(t, ctx, []string{"progress"}, 5*time.Second)
+	assert.Equal(t, 0, result.ExitCode)
+	assert.Contains(t, result.ScriptOutput, "solving")
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.Len(t, reported, 2) {
+		assert.Equal(t, Progress{Percent: 10, Message: "reading input", Time: reported[0].Time}, reported[0])
+		assert.Equal(t, Progress{Percent: 60, Message: "placing rack row 3", Time: reported[1].Time}, reported[1])
+	}
+}
@@ -0,0 +1,40 @@
+package runner
+
+import (
+	"context"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+)
+
+// DefaultInterpreter is used by the Python runner unless another interpreter is given
+const DefaultInterpreter = "python3"
+
+var _ Runner = (*Python)(nil)
+
+// Python runs a Python script with the given interpreter, e.g. the python of a virtualenv.
+// Tracebacks found in stderr are parsed into Result.Traceback. The output of the script is unbuffered,
+// so that its progress lines arrive as they are printed.
+type Python struct {
+	command *Command
+}
+
+// NewPython creates a runner of the script at scriptPath.
+// If the path is not absolute, the interpreter will attempt to run the script relatively to workDir.
+func NewPython(interpreter string, scriptPath string, timeout time.Duration, log *logger.Logger, opts ...Option) *Python {
+	if interpreter == "" {
+		interpreter = DefaultInterpreter
+	}
+	// the interpreter is never empty, so there is no error
+	opts = append([]Option{withEnv("PYTHONUNBUFFERED=1")}, opts...)
+	command, _ := NewCommand(interpreter, scriptPath, DefaultArgs, timeout, log, opts...)
+	return &Python{
+		command: command,
+	}
+}
+
+func (p *Python) Run(ctx context.Context, workDir string, params []string, inputs ...string) *Result {
+	result := p.command.Run(ctx, workDir, params, inputs...)
+	result.Traceback = ParseTraceback(result.ScriptError)
+	return result
+}
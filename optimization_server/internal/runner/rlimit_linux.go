@@ -0,0 +1,104 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// limitsEnv passes the limits to the binary re-executed as the limits wrapper of a script
+const limitsEnv = "OPTIMIZATION_SCRIPT_LIMITS"
+
+// wrapperExitCode is reported when the wrapper can't apply the limits or execute the script
+const wrapperExitCode = 127
+
+// init turns the binary into the limits wrapper when it has been started by limitedCommand:
+// it applies the limits to itself and replaces itself with the script, so the limits are in place
+// before the script runs and every process it forks inherits them
+func init() {
+	spec, ok := os.LookupEnv(limitsEnv)
+	if !ok || len(os.Args) < 2 {
+		return
+	}
+	if err := os.Unsetenv(limitsEnv); err != nil {
+		fmt.Fprintf(os.Stderr, "error applying script limits: %s\n", err)
+		os.Exit(wrapperExitCode)
+	}
+	if err := applyLimits(spec); err != nil {
+		fmt.Fprintf(os.Stderr, "error applying script limits: %s\n", err)
+		os.Exit(wrapperExitCode)
+	}
+	err := syscall.Exec(os.Args[1], os.Args[1:], os.Environ())
+	fmt.Fprintf(os.Stderr, "error executing '%s': %s\n", os.Args[1], err)
+	os.Exit(wrapperExitCode)
+}
+
+// limitedCommand creates the command of the executable. If a resource limit is set the command
+// runs the current binary as the limits wrapper of the executable, see init.
+func limitedCommand(name string, args []string, limits Limits) (*exec.Cmd, error) {
+	rlimits := rlimitsOf(limits)
+	if len(rlimits) == 0 {
+		return exec.Command(name, args...), nil
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("error starting the limits wrapper: %w", err)
+	}
+	// the wrapper doesn't search the PATH, a path with a separator is resolved in the working directory as usual
+	path := name
+	if !strings.Contains(name, "/") {
+		if path, err = exec.LookPath(name); err != nil {
+			return nil, err
+		}
+	}
+
+	spec := make([]string, 0, len(rlimits))
+	for _, l := range rlimits {
+		spec = append(spec, fmt.Sprintf("%d:%d:%d", l.resource, l.value, l.hard))
+	}
+	cmd := exec.Command(self, append([]string{path}, args...)...)
+	cmd.Env = append(os.Environ(), limitsEnv+"="+strings.Join(spec, ","))
+	return cmd, nil
+}
+
+type rlimit struct {
+	resource int
+	value    uint64
+	hard     uint64
+}
+
+// rlimitsOf lists the limits which are set
+func rlimitsOf(limits Limits) []rlimit {
+	cpu := uint64(cpuLimit(limits).Seconds())
+	all := []rlimit{
+		{syscall.RLIMIT_AS, limits.AddressSpace, limits.AddressSpace},
+		// the hard limit is one second above, so the script gets SIGXCPU before SIGKILL
+		{syscall.RLIMIT_CPU, cpu, cpu + 1},
+		{syscall.RLIMIT_FSIZE, limits.FileSize, limits.FileSize},
+		{syscall.RLIMIT_NOFILE, limits.OpenFiles, limits.OpenFiles},
+	}
+	rlimits := make([]rlimit, 0, len(all))
+	for _, l := range all {
+		if l.value > 0 {
+			rlimits = append(rlimits, l)
+		}
+	}
+	return rlimits
+}
+
+// applyLimits sets the limits of the spec made by limitedCommand on the current process
+func applyLimits(spec string) error {
+	for _, item := range strings.Split(spec, ",") {
+		var resource int
+		var limit syscall.Rlimit
+		if _, err := fmt.Sscanf(item, "%d:%d:%d", &resource, &limit.Cur, &limit.Max); err != nil {
+			return fmt.Errorf("invalid limit '%s': %w", item, err)
+		}
+		if err := syscall.Setrlimit(resource, &limit); err != nil {
+			return fmt.Errorf("error setting limit %d: %w", resource, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package runner
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errLimitsUnsupported = errors.New("script resource limits are supported on linux only")
+
+func limitedCommand(name string, args []string, limits Limits) (*exec.Cmd, error) {
+	if limits.AddressSpace > 0 || limits.CPUTime > 0 || limits.FileSize > 0 || limits.OpenFiles > 0 {
+		return nil, errLimitsUnsupported
+	}
+	return exec.Command(name, args...), nil
+}
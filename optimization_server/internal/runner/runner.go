@@ -0,0 +1,374 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+)
+
+const defaultKillGrace = 5 * time.Second
+
+// Runner runs the optimization script over the input files in workDir.
+// The workDir is used as a working directory for the script, params are the parameter flags of the run.
+// The script is killed once ctx is done.
+type Runner interface {
+	Run(ctx context.Context, workDir string, params []string, inputs ...string) *Result
+}
+
+// Termination tells why the script was stopped before it finished on its own
+type Termination string
+
+const (
+	TerminationNone Termination = ""
+	// TerminationTimeout - the script ran longer than the timeout and its process group was killed
+	TerminationTimeout Termination = "timeout"
+	// TerminationCPULimit - the script used more CPU time than Limits.CPUTime
+	TerminationCPULimit Termination = "cpu_limit"
+	// TerminationFileSizeLimit - the script tried to write a file bigger than Limits.FileSize
+	TerminationFileSizeLimit Termination = "file_size_limit"
+	// TerminationCanceled - the run was canceled and the process group of the script was killed
+	TerminationCanceled Termination = "canceled"
+)
+
+// Limits are the resource limits applied to the script process. Zero values disable a limit.
+type Limits struct {
+	// AddressSpace is the maximum size of the process virtual memory in bytes
+	AddressSpace uint64
+	// CPUTime is the maximum CPU time of the process, rounded up to seconds
+	CPUTime time.Duration
+	// FileSize is the maximum size of a file the process may write in bytes
+	FileSize uint64
+	// OpenFiles is the maximum number of open file descriptors
+	OpenFiles uint64
+	// MaxOutput caps the captured stdout and stderr in bytes each, the rest of the output is discarded
+	MaxOutput int64
+}
+
+// process starts the script processes, the same way for every runner
+type process struct {
+	timeout   time.Duration
+	killGrace time.Duration
+	limits    Limits
+	// env is added to the environment of the script
+	env []string
+	log *logger.Logger
+}
+
+// Option configures a runner
+type Option func(*process)
+
+// WithLimits applies the resource limits to every script run
+func WithLimits(limits Limits) Option {
+	return func(p *process) {
+		p.limits = limits
+	}
+}
+
+// WithKillGrace sets the time between SIGTERM and SIGKILL sent to the script process group on timeout or cancellation
+func WithKillGrace(grace time.Duration) Option {
+	return func(p *process) {
+		p.killGrace = grace
+	}
+}
+
+// withEnv adds the variables to the environment of the script
+func withEnv(vars ...string) Option {
+	return func(p *process) {
+		p.env = append(p.env, vars...)
+	}
+}
+
+// Result is reported by every runner
+type Result struct {
+	ExitCode    int
+	ShellOutput string
+	// ScriptOutput is the stdout of the script
+	ScriptOutput string
+	// ScriptError is the stderr of the script
+	ScriptError   string
+	ExecutionTime time.Duration
+	// Traceback is the last Python traceback found in stderr, set by the Python runner only
+	Traceback *Traceback
+	// Termination is set if the script was killed for a timeout or for exceeding a limit
+	Termination Termination
+	// OutputTruncated is set if stdout or stderr exceeded Limits.MaxOutput
+	OutputTruncated bool
+}
+
+func newProcess(timeout time.Duration, log *logger.Logger, opts []Option) *process {
+	p := &process{
+		timeout:   timeout,
+		killGrace: defaultKillGrace,
+		log:       log,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// run executes the command in workDir.
+//
+// The command is started in its own process group. On timeout or once ctx is done the group receives SIGTERM
+// and SIGKILL after the grace period. Processes forked by the command are killed when it exits.
+// If ctx carries a ProgressFunc the progress lines of stdout and of the progress file are reported as they arrive.
+func (p *process) run(ctx context.Context, workDir string, name string, args []string) *Result {
+	if err := ctx.Err(); err != nil {
+		canceled := failedResult(err)
+		canceled.Termination = TerminationCanceled
+		return canceled
+	}
+	result := Result{}
+
+	// the limits are applied before the script is executed, so the processes it forks are limited as well
+	cmd, err := limitedCommand(name, args, p.limits)
+	if err != nil {
+		p.log.Errorf("error applying script limits: %s", err)
+		return failedResult(err)
+	}
+	cmd.Dir = workDir
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	// The write ends are passed to the script as is, so Wait doesn't block on the output
+	// of the forked processes which are still running
+	stdout, err := newOutput(p.limits.MaxOutput)
+	if err != nil {
+		return failedResult(err)
+	}
+	stderr, err := newOutput(p.limits.MaxOutput)
+	if err != nil {
+		stdout.close(p.log)
+		return failedResult(err)
+	}
+	outputs := []*output{stdout, stderr}
+	cmd.Stdout = stdout.pw
+	cmd.Stderr = stderr.pw
+
+	env := p.env
+	if fn := progressFunc(ctx); fn != nil {
+		progress := newProgressReporter(fn, p.log)
+		stdout.tap = progress
+		if file, err := progress.watch(); err != nil {
+			p.log.Warnf("error creating progress file, the progress is read from stdout only: %s", err)
+		} else {
+			env = append(env, ProgressFileEnv+"="+file)
+		}
+		defer progress.close()
+	}
+	if len(env) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, env...)
+	}
+
+	start := time.Now()
+	err = cmd.Start()
+	for _, out := range outputs {
+		out.closeWriter(p.log)
+	}
+	if err != nil {
+		for _, out := range outputs {
+			out.close(p.log)
+		}
+		return failedResult(err)
+	}
+	pgid := cmd.Process.Pid
+
+	for _, out := range outputs {
+		go out.read(p.log)
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	timer := time.NewTimer(p.timeout)
+	select {
+	case err = <-exited:
+	case <-timer.C:
+		result.Termination = TerminationTimeout
+		err = p.terminate(pgid, exited)
+	case <-ctx.Done():
+		result.Termination = TerminationCanceled
+		err = p.terminate(pgid, exited)
+	}
+	timer.Stop()
+	result.ExecutionTime = time.Since(start)
+
+	// kill the processes forked by the script, they would keep the output pipe open
+	killGroup(pgid, syscall.SIGKILL)
+	p.closeOutputs(outputs)
+
+	p.log.Debugf("executed %q %s -> stdout: %q, stderr: %q", cmd.Path, cmd.Args, stdout.buf.String(), stderr.buf.String())
+	result.ScriptOutput = stdout.buf.String()
+	result.ScriptError = stderr.buf.String()
+	result.OutputTruncated = stdout.buf.truncated || stderr.buf.truncated
+	result.ExitCode = cmd.ProcessState.ExitCode()
+	if result.Termination == TerminationNone {
+		result.Termination = limitTermination(cmd.ProcessState, p.limits)
+	}
+
+	if err != nil {
+		result.ShellOutput = err.Error()
+		if result.Termination != TerminationNone {
+			result.ShellOutput = fmt.Sprintf("%s: %s", result.Termination, err)
+		}
+	}
+
+	return &result
+}
+
+// terminate sends SIGTERM to the process group and SIGKILL if the script doesn't exit within the grace period
+func (p *process) terminate(pgid int, exited <-chan error) error {
+	killGroup(pgid, syscall.SIGTERM)
+
+	grace := time.NewTimer(p.killGrace)
+	defer grace.Stop()
+	select {
+	case err := <-exited:
+		return err
+	case <-grace.C:
+		p.log.Warnf("script process group %d ignored SIGTERM for %s, sending SIGKILL", pgid, p.killGrace)
+		killGroup(pgid, syscall.SIGKILL)
+		return <-exited
+	}
+}
+
+// closeOutputs waits for the outputs to be read. Processes which have left the group may hold
+// the pipes open, so the reading is cut off after the grace period.
+func (p *process) closeOutputs(outputs []*output) {
+	deadline := time.NewTimer(p.killGrace)
+	defer deadline.Stop()
+	expired := false
+	for _, out := range outputs {
+		if !expired {
+			select {
+			case <-out.copied:
+			case <-deadline.C:
+				expired = true
+				p.log.Warnf("script output is still open after the script exited, closing it")
+			}
+		}
+		out.close(p.log)
+		<-out.copied
+	}
+}
+
+func killGroup(pgid int, sig syscall.Signal) {
+	// the group may be gone already
+	_ = syscall.Kill(-pgid, sig)
+}
+
+// limitTermination reports the limit which made the kernel kill the script
+func limitTermination(state *os.ProcessState, limits Limits) Termination {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return TerminationNone
+	}
+	switch status.Signal() {
+	case syscall.SIGXCPU:
+		return TerminationCPULimit
+	case syscall.SIGXFSZ:
+		return TerminationFileSizeLimit
+	case syscall.SIGKILL:
+		// SIGKILL is sent when the hard CPU limit is reached and SIGXCPU was ignored,
+		// any other kill, e.g. by the OOM killer, comes before the CPU time reaches the limit
+		if limits.CPUTime > 0 && state.UserTime()+state.SystemTime() >= cpuLimit(limits) {
+			return TerminationCPULimit
+		}
+	}
+	return TerminationNone
+}
+
+// cpuLimit is Limits.CPUTime rounded up to seconds, the precision of the kernel limit
+func cpuLimit(limits Limits) time.Duration {
+	return time.Duration(math.Ceil(limits.CPUTime.Seconds())) * time.Second
+}
+
+func failedResult(err error) *Result {
+	return &Result{
+		ExitCode:    -1,
+		ShellOutput: err.Error(),
+	}
+}
+
+// output captures a stream of the script through a pipe
+type output struct {
+	pr     *os.File
+	pw     *os.File
+	buf    *cappedBuffer
+	copied chan struct{}
+	// tap receives the whole stream as it is read, e.g. to scan it for the progress lines
+	tap io.Writer
+}
+
+func newOutput(max int64) (*output, error) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	return &output{
+		pr:     pr,
+		pw:     pw,
+		buf:    &cappedBuffer{max: max},
+		copied: make(chan struct{}),
+	}, nil
+}
+
+func (o *output) read(log *logger.Logger) {
+	defer close(o.copied)
+	var w io.Writer = o.buf
+	if o.tap != nil {
+		w = io.MultiWriter(o.buf, o.tap)
+	}
+	if _, err := io.Copy(w, o.pr); err != nil {
+		log.Debugf("script output reading stopped: %s", err)
+	}
+}
+
+// closeWriter closes the write end of the pipe which is inherited by the script
+func (o *output) closeWriter(log *logger.Logger) {
+	if err := o.pw.Close(); err != nil {
+		log.Warnf("error closing output pipe: %s", err)
+	}
+}
+
+// close closes the read end of the pipe, a running read returns right away
+func (o *output) close(log *logger.Logger) {
+	if err := o.pr.Close(); err != nil {
+		log.Debugf("error closing output pipe: %s", err)
+	}
+}
+
+// cappedBuffer keeps the first max bytes written to it, zero max means no limit
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	max       int64
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if b.max > 0 {
+		left := b.max - int64(b.buf.Len())
+		if int64(len(p)) > left {
+			p = p[:left]
+			b.truncated = true
+		}
+	}
+	b.buf.Write(p)
+	return n, nil
+}
+
+func (b *cappedBuffer) String() string {
+	return b.buf.String()
+}
@@ -0,0 +1,203 @@
+package runner
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPython(t *testing.T) {
+	testCases := []struct {
+		name      string
+		timeout   time.Duration
+		req       []string
+		scriptDir string
+		result    Result
+	}{
+		{
+			name:    "normal run",
+			timeout: 5000 * time.Millisecond,
+			req:     []string{},
+			result: Result{
+				ExitCode:      0,
+				ShellOutput:   "",
+				ScriptOutput:  "",
+				ExecutionTime: 0,
+			},
+		},
+		{
+			name:    "error in script",
+			timeout: 5000 * time.Millisecond,
+			req:     []string{"error"},
+			result: Result{
+				ExitCode:      14,
+				ShellOutput:   "smth",
+				ScriptOutput:  "",
+				ExecutionTime: 0,
+			},
+		},
+		{
+			name:    "successful run",
+			timeout: 5000 * time.Millisecond,
+			req:     []string{"success"},
+			result: Result{
+				ExitCode:      0,
+				ShellOutput:   "",
+				ScriptOutput:  "",
+				ExecutionTime: 0,
+			},
+		},
+		{
+			name:    "print message to std output",
+			timeout: 5000 * time.Millisecond,
+			req:     []string{"print", "message"},
+			result: Result{
+				ExitCode:      0,
+				ShellOutput:   "",
+				ScriptOutput:  "message",
+				ExecutionTime: 0,
+			},
+		},
+		{
+			name:    "test execution time",
+			timeout: 5000 * time.Millisecond,
+			req:     []string{"sleep", "100"},
+			result: Result{
+				ExitCode:      0,
+				ShellOutput:   "",
+				ScriptOutput:  "",
+				ExecutionTime: 10 * time.Millisecond,
+			},
+		},
+		{
+			name:    "test timeout",
+			timeout: 5 * time.Millisecond,
+			req:     []string{"sleep", "100"},
+			result: Result{
+				ExitCode:      -1,
+				ShellOutput:   "smth",
+				ScriptOutput:  "",
+				ExecutionTime: 1 * time.Millisecond,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := testPython(t, tc.req, tc.timeout)
+			assert.Equal(t, tc.result.ExitCode, result.ExitCode)
+			assert.Equal(t, true, tc.result.ExecutionTime <= result.ExecutionTime)
+			assert.Equal(t, true, (len(tc.result.ScriptOutput) > 0) == (len(result.ScriptOutput) > 0), "Script output mismatch")
+			assert.Equal(t, true, (len(tc.result.ShellOutput) > 0) == (len(result.ShellOutput) > 0), "Shell output mismatch")
+		})
+	}
+}
+
+func testPython(t *testing.T, req []string, timeout time.Duration, opts ...Option) *Result {
+	return testPythonContext(t, context.Background(), req, timeout, opts...)
+}
+
+func testPythonContext(t *testing.T, ctx context.Context, req []string, timeout time.Duration, opts ...Option) *Result {
+
+	scriptPath, err := filepath.Abs("mock_scripts/main.py")
+	assert.NoError(t, err)
+
+	resp := NewPython("", scriptPath, timeout, logger.NewTestLogger(), opts...).Run(ctx, "", nil, req...)
+	return resp
+}
+
+// assertProcessGone checks that the process printed by the "fork" mock has been killed
+func assertProcessGone(t *testing.T, output string) {
+	pid, err := strconv.Atoi(strings.TrimSpace(output))
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return syscall.Kill(pid, 0) == syscall.ESRCH
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestPython_KillsProcessGroup(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		result := testPython(t, []string{"fork", "10000"}, 500*time.Millisecond)
+		assert.Equal(t, TerminationTimeout, result.Termination)
+		assert.Equal(t, -1, result.ExitCode)
+		assert.Less(t, int64(result.ExecutionTime), int64(5*time.Second))
+		assertProcessGone(t, result.ScriptOutput)
+	})
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		result := testPythonContext(t, ctx, []string{"fork", "10000"}, time.Minute)
+		assert.Equal(t, TerminationCanceled, result.Termination)
+		assert.Equal(t, -1, result.ExitCode)
+		assert.Less(t, int64(result.ExecutionTime), int64(5*time.Second))
+		assertProcessGone(t, result.ScriptOutput)
+
+		// a canceled run doesn't start the script
+		result = testPythonContext(t, ctx, []string{"success"}, time.Minute)
+		assert.Equal(t, TerminationCanceled, result.Termination)
+		assert.Empty(t, result.ScriptOutput)
+	})
+	t.Run("exit", func(t *testing.T) {
+		result := testPython(t, []string{"fork", "10"}, 5*time.Second)
+		assert.Equal(t, TerminationNone, result.Termination)
+		assert.Equal(t, 0, result.ExitCode)
+		assertProcessGone(t, result.ScriptOutput)
+	})
+}
+
+func TestPython_KillGrace(t *testing.T) {
+	start := time.Now()
+	result := testPython(t, []string{"ignore_term", "10000"}, 300*time.Millisecond, WithKillGrace(200*time.Millisecond))
+	assert.Equal(t, TerminationTimeout, result.Termination)
+	assert.Contains(t, result.ShellOutput, "killed")
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+func TestPython_Stderr(t *testing.T) {
+	result := testPython(t, []string{"raise", "bad input"}, 5*time.Second)
+	assert.Equal(t, 1, result.ExitCode)
+	assert.Empty(t, result.ScriptOutput)
+	assert.Contains(t, result.ScriptError, "warning\n")
+	if assert.NotNil(t, result.Traceback) {
+		assert.Equal(t, "ValueError", result.Traceback.Type)
+		assert.Equal(t, "bad input", result.Traceback.Message)
+		assert.NotEmpty(t, result.Traceback.Frames)
+	}
+
+	result = testPython(t, []string{"print", "message"}, 5*time.Second)
+	assert.Equal(t, "message\n", result.ScriptOutput)
+	assert.Empty(t, result.ScriptError)
+	assert.Nil(t, result.Traceback)
+}
+
+func TestPython_OutputLimit(t *testing.T) {
+	result := testPython(t, []string{"flood", "100000"}, 5*time.Second, WithLimits(Limits{MaxOutput: 1000}))
+	assert.Equal(t, 0, result.ExitCode)
+	assert.Len(t, result.ScriptOutput, 1000)
+	assert.True(t, result.OutputTruncated)
+}
+
+func TestPython_CPULimit(t *testing.T) {
+	result := testPython(t, []string{"burn"}, 10*time.Second, WithLimits(Limits{CPUTime: time.Second}))
+	assert.Equal(t, TerminationCPULimit, result.Termination)
+	assert.Equal(t, -1, result.ExitCode)
+	assert.NotEmpty(t, result.ShellOutput)
+}
+
+func TestPython_LimitsForkedProcesses(t *testing.T) {
+	result := testPython(t, []string{"child_limit"}, 10*time.Second, WithLimits(Limits{OpenFiles: 64}))
+	assert.Equal(t, 0, result.ExitCode)
+	assert.Equal(t, "64\n", result.ScriptOutput)
+}
+
+func TestPython_KillIsNotCPULimit(t *testing.T) {
+	result := testPython(t, []string{"kill_self"}, 10*time.Second, WithLimits(Limits{CPUTime: 10 * time.Second}))
+	assert.Equal(t, TerminationNone, result.Termination)
+	assert.Equal(t, -1, result.ExitCode)
+}
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const tracebackHeader = "Traceback (most recent call last):"
+
+var (
+	framePattern     = regexp.MustCompile(`^  File "(.+)", line (\d+)(?:, in (.+))?$`)
+	exceptionPattern = regexp.MustCompile(`^([A-Za-z_][\w.]*)(?::\s?(.*))?$`)
+)
+
+// Frame is a single entry of a Python traceback
+type Frame struct {
+	File     string
+	Line     int
+	Function string
+	Code     string
+}
+
+// Traceback is a Python exception reported in stderr
+type Traceback struct {
+	// Type is the exception class, e.g. ValueError or module.CustomError
+	Type    string
+	Message string
+	// Frames are ordered from the outermost call to the one which raised the exception
+	Frames []Frame
+}
+
+// ParseTraceback returns the last traceback found in the stderr of a Python script, nil if there is none.
+// For chained exceptions the last one, which terminated the script, is returned.
+func ParseTraceback(stderr string) *Traceback {
+	lines := strings.Split(strings.ReplaceAll(stderr, "\r\n", "\n"), "\n")
+
+	start := -1
+	for i, line := range lines {
+		if strings.TrimSpace(line) == tracebackHeader {
+			start = i
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	tb := &Traceback{Frames: make([]Frame, 0)}
+	i := start + 1
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if m := framePattern.FindStringSubmatch(line); m != nil {
+			lineNo, _ := strconv.Atoi(m[2])
+			tb.Frames = append(tb.Frames, Frame{File: m[1], Line: lineNo, Function: m[3]})
+			continue
+		}
+		if strings.HasPrefix(line, "    ") && len(tb.Frames) > 0 {
+			// the source line of the frame and the caret markers of newer Python versions
+			frame := &tb.Frames[len(tb.Frames)-1]
+			if frame.Code == "" {
+				frame.Code = strings.TrimSpace(line)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "  ") {
+			// "[Previous line repeated N more times]" and similar notes
+			continue
+		}
+		break
+	}
+
+	// the exception line, the message may continue on the following lines
+	if i >= len(lines) {
+		return tb
+	}
+	m := exceptionPattern.FindStringSubmatch(strings.TrimSpace(lines[i]))
+	if m == nil {
+		tb.Message = strings.TrimSpace(lines[i])
+		return tb
+	}
+	tb.Type = m[1]
+	message := []string{m[2]}
+	for _, line := range lines[i+1:] {
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		message = append(message, line)
+	}
+	tb.Message = strings.TrimSpace(strings.Join(message, "\n"))
+	return tb
+}
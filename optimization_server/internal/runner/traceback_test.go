@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceback(t *testing.T) {
+	testCases := []struct {
+		name     string
+		stderr   string
+		expected *Traceback
+	}{
+		{
+			name:     "no traceback",
+			stderr:   "warning: something\n",
+			expected: nil,
+		},
+		{
+			name: "simple",
+			stderr: `Traceback (most recent call last):
+  File "main.py", line 60, in <module>
+    main(sys.argv[1:])
+  File "main.py", line 44, in main
+    data1 = readFile(inputfile[0])
+IndexError: list index out of range
+`,
+			expected: &Traceback{
+				Type:    "IndexError",
+				Message: "list index out of range",
+				Frames: []Frame{
+					{File: "main.py", Line: 60, Function: "<module>", Code: "main(sys.argv[1:])"},
+					{File: "main.py", Line: 44, Function: "main", Code: "data1 = readFile(inputfile[0])"},
+				},
+			},
+		},
+		{
+			name: "chained with carets and multiline message",
+			stderr: `Traceback (most recent call last):
+  File "main.py", line 3, in <module>
+    int("x")
+ValueError: invalid literal for int() with base 10: 'x'
+
+During handling of the above exception, another exception occurred:
+
+Traceback (most recent call last):
+  File "/opt/solver/model.py", line 12, in solve
+    raise solver.InfeasibleError("no solution\nrack 3")
+    ^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^
+solver.InfeasibleError: no solution
+rack 3
+`,
+			expected: &Traceback{
+				Type:    "solver.InfeasibleError",
+				Message: "no solution\nrack 3",
+				Frames: []Frame{
+					{File: "/opt/solver/model.py", Line: 12, Function: "solve", Code: `raise solver.InfeasibleError("no solution\nrack 3")`},
+				},
+			},
+		},
+		{
+			name: "exception without message",
+			stderr: "Traceback (most recent call last):\r\n" +
+				"  File \"main.py\", line 1, in <module>\r\n" +
+				"    raise KeyboardInterrupt\r\n" +
+				"KeyboardInterrupt\r\n",
+			expected: &Traceback{
+				Type:   "KeyboardInterrupt",
+				Frames: []Frame{{File: "main.py", Line: 1, Function: "<module>", Code: "raise KeyboardInterrupt"}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ParseTraceback(tc.stderr))
+		})
+	}
+}
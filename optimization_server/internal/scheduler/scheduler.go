@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	ErrQueueFull = errors.New("scheduler queue is full")
+	ErrStopped   = errors.New("scheduler is stopped")
+)
+
+var (
+	queueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "optimization_queue_depth",
+		Help: "Number of optimization runs waiting for a free worker",
+	})
+	busyWorkers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "optimization_busy_workers",
+		Help: "Number of workers currently running an optimization",
+	})
+)
+
+type task struct {
+	fn   func()
+	done chan struct{}
+}
+
+// Scheduler runs submitted tasks on a fixed number of workers.
+// Tasks which can't be picked up right away wait in a bounded queue.
+type Scheduler struct {
+	tasks chan task
+	wg    sync.WaitGroup
+
+	mu      sync.RWMutex
+	stopped bool
+}
+
+// New starts a scheduler with the given number of workers and queue depth.
+// With zero queue depth a task is accepted only if a worker is idle.
+func New(workers int, depth int) *Scheduler {
+	s := &Scheduler{
+		tasks: make(chan task, depth),
+	}
+	for i := 0; i < workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+	return s
+}
+
+// Submit queues fn for execution and returns a channel which is closed once fn has returned.
+// It never blocks: if the queue is full ErrQueueFull is returned.
+func (s *Scheduler) Submit(fn func()) (<-chan struct{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.stopped {
+		return nil, ErrStopped
+	}
+
+	t := task{
+		fn:   fn,
+		done: make(chan struct{}),
+	}
+	queueDepth.Inc()
+	select {
+	case s.tasks <- t:
+		return t.done, nil
+	default:
+		queueDepth.Dec()
+		return nil, ErrQueueFull
+	}
+}
+
+// Stop rejects new tasks, lets the workers drain the queue and waits for them to exit
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	s.stopped = true
+	close(s.tasks)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *Scheduler) work() {
+	defer s.wg.Done()
+	for t := range s.tasks {
+		queueDepth.Dec()
+		busyWorkers.Inc()
+		t.fn()
+		busyWorkers.Dec()
+		close(t.done)
+	}
+}
@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler_RunsTasks(t *testing.T) {
+	s := New(2, 4)
+	defer s.Stop()
+
+	var counter int32
+	dones := make([]<-chan struct{}, 0, 4)
+	for i := 0; i < 4; i++ {
+		done, err := s.Submit(func() { atomic.AddInt32(&counter, 1) })
+		assert.NoError(t, err)
+		dones = append(dones, done)
+	}
+	for _, done := range dones {
+		<-done
+	}
+	assert.Equal(t, int32(4), atomic.LoadInt32(&counter))
+}
+
+func TestScheduler_QueueFull(t *testing.T) {
+	s := New(1, 1)
+	defer s.Stop()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	_, err := s.Submit(func() {
+		close(started)
+		<-release
+	})
+	assert.NoError(t, err)
+	<-started
+
+	// the worker is busy, the first task waits in the queue and the second one is rejected
+	_, err = s.Submit(func() {})
+	assert.NoError(t, err)
+	_, err = s.Submit(func() {})
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	close(release)
+}
+
+func TestScheduler_Stopped(t *testing.T) {
+	s := New(1, 1)
+	s.Stop()
+	s.Stop()
+
+	_, err := s.Submit(func() {})
+	assert.ErrorIs(t, err, ErrStopped)
+}
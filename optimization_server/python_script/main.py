@@ -24,7 +24,7 @@ def main(argv):
     inputfile = []
     outputfile = 'def_output.csv'
     try:
-        opts, args = getopt.gnu_getopt(argv,"hi:o:",["ofile="])
+        opts, args = getopt.gnu_getopt(argv,"hi:o:",["ofile=","seed="])
     except getopt.GetoptError:
         print('test.py -o <outputfile>')
         sys.exit(2)
@@ -35,6 +35,8 @@ def main(argv):
             sys.exit()
         elif opt in ("-o", "--ofile"):
             outputfile = arg
+        elif opt == "--seed":
+            random.seed(int(arg))
 
     inputfile = args
     print('Input file is', inputfile)
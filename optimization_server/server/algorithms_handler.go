@@ -0,0 +1,110 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/optimizer"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
+)
+
+type AlgorithmsHandler struct {
+	algorithms *optimizer.Registry
+	log        *logger.Logger
+}
+
+func NewAlgorithmsHandler(algorithms *optimizer.Registry, log *logger.Logger) *AlgorithmsHandler {
+	return &AlgorithmsHandler{
+		algorithms: algorithms,
+		log:        log,
+	}
+}
+
+// Algorithms
+// @Summary List the algorithms
+// @Description List the algorithms available for the optimization runs and the default one
+// @ID algorithms-handler
+// @Accept plain
+// @Produce  json
+// @Success 200 {object} models.AlgorithmsResponse
+// @Router /v1/algorithms [get]
+func (h *AlgorithmsHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+	list := h.algorithms.List()
+	resp := models.AlgorithmsResponse{
+		Default:    h.algorithms.Default().ID(),
+		Algorithms: make([]models.Algorithm, 0, len(list)),
+	}
+	for _, a := range list {
+		resp.Algorithms = append(resp.Algorithms, newAlgorithm(a))
+	}
+	writeResponse(writer, resp, http.StatusOK, h.log)
+}
+
+func newAlgorithm(a *optimizer.Algorithm) models.Algorithm {
+	outputs := models.Outputs{
+		Dir:      a.Outputs.Dir,
+		Manifest: a.Outputs.Manifest,
+	}
+	for _, f := range a.Outputs.Files {
+		outputs.Files = append(outputs.Files, models.OutputFile{Pattern: f.Pattern, Required: f.Required})
+	}
+	params := make([]models.Parameter, 0, len(a.Parameters))
+	for _, p := range a.Parameters {
+		flag := p.Flag
+		if flag == "" {
+			flag = "--" + p.Name
+		}
+		params = append(params, models.Parameter{
+			Name:        p.Name,
+			Type:        p.Type,
+			Description: p.Description,
+			Required:    p.Required,
+			Default:     p.Default,
+			Min:         p.Min,
+			Max:         p.Max,
+			Enum:        p.Enum,
+			Flag:        flag,
+		})
+	}
+	inputs := make([]models.InputSlot, 0, len(a.Inputs))
+	for _, slot := range a.Inputs {
+		inputs = append(inputs, models.InputSlot{
+			Name:       slot.Name,
+			Required:   slot.Required,
+			Extensions: slot.Extensions,
+			Flag:       slot.Flag,
+			CSV:        newCSVSchema(slot.CSV),
+			EqualRows:  slot.EqualRows,
+		})
+	}
+	return models.Algorithm{
+		ID:          a.ID(),
+		Name:        a.Name,
+		Version:     a.Version,
+		Description: a.Description,
+		Runner:      a.RunnerType,
+		Timeout:     a.Timeout.Milliseconds(),
+		Outputs:     outputs,
+		Parameters:  params,
+		Inputs:      inputs,
+	}
+}
+
+func newCSVSchema(schema *tabular.Schema) *models.CSVSchema {
+	if schema == nil {
+		return nil
+	}
+	csv := &models.CSVSchema{MinRows: schema.MinRows, MaxRows: schema.MaxRows}
+	if schema.Delimiter != 0 {
+		csv.Delimiter = string(schema.Delimiter)
+	}
+	for _, c := range schema.Columns {
+		columnType := c.Type
+		if columnType == "" {
+			columnType = tabular.TypeString
+		}
+		csv.Columns = append(csv.Columns, models.CSVColumn{Name: c.Name, Type: columnType, Required: c.Required})
+	}
+	return csv
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/optimizer"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlgorithmsHandler(t *testing.T) {
+	registry, err := optimizer.NewRegistry("rack-greedy",
+		&optimizer.Algorithm{
+			Name:       "rack-milp",
+			Version:    "2.0",
+			RunnerType: "command",
+			Timeout:    time.Minute,
+			Outputs:    optimizer.OutputContract{Dir: "output", Manifest: "manifest.json"},
+			Parameters: optimizer.ParameterSchema{
+				{Name: "seed", Type: optimizer.TypeInteger, Default: 42},
+				{Name: "gap", Type: optimizer.TypeNumber, Required: true, Flag: "-g"},
+			},
+		},
+		&optimizer.Algorithm{
+			Name:        "rack-greedy",
+			Version:     "1.2",
+			Description: "greedy placement",
+			RunnerType:  "python",
+			Timeout:     5 * time.Second,
+			Outputs:     optimizer.DefaultOutputContract,
+			Inputs: optimizer.InputSchema{
+				{Name: "racks", Required: true, Extensions: []string{".csv"}, EqualRows: true, CSV: &tabular.Schema{
+					Delimiter: ';', Columns: []tabular.Column{{Name: "id", Type: tabular.TypeInteger, Required: true}, {Name: "name"}}, MaxRows: 100}},
+				{Name: "devices", Flag: "--devices"},
+			},
+		},
+	)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequestWithContext(context.Background(), "GET", "/api/v1/algorithms", nil)
+	assert.NoError(t, err)
+	r := httptest.NewRecorder()
+	NewAlgorithmsHandler(registry, logger.NewTestLogger()).ServeHTTP(r, req)
+
+	assert.Equal(t, http.StatusOK, r.Code)
+	assert.JSONEq(t, `{"default":"rack-greedy@1.2","algorithms":[
+		{"id":"rack-greedy@1.2","name":"rack-greedy","version":"1.2","description":"greedy placement","runner":"python","timeout":5000,
+		 "outputs":{"files":[{"pattern":"def_output.csv","required":true}]},
+		 "inputs":[{"name":"racks","required":true,"extensions":[".csv"],"equalRows":true,"csv":{"delimiter":";",
+		   "columns":[{"name":"id","type":"integer","required":true},{"name":"name","type":"string"}],"maxRows":100}},{"name":"devices","flag":"--devices"}]},
+		{"id":"rack-milp@2.0","name":"rack-milp","version":"2.0","runner":"command","timeout":60000,
+		 "outputs":{"dir":"output","manifest":"manifest.json"},
+		 "parameters":[{"name":"seed","type":"integer","default":42,"flag":"--seed"},{"name":"gap","type":"number","required":true,"flag":"-g"}]}]}`, r.Body.String())
+}
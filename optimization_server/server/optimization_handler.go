@@ -3,10 +3,14 @@ package server
 import (
 	"encoding/json"
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/models"
 	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/optimizer"
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/runner"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
 	"github.com/cxrdevelop/optimization_engine/pkg/logger"
 )
 
@@ -18,16 +22,31 @@ const (
 	ErrMsgDownload     = "failed to download files"
 	ErrMsgScript       = "script error"
 	ErrMsgUpload       = "failed to upload the result"
+	ErrMsgBusy         = "too many optimization requests"
+	ErrMsgArchive      = "invalid input archive"
+	ErrMsgTimeout      = "script timeout"
+	ErrMsgLimit        = "script exceeded a resource limit"
+	ErrMsgOutput       = "invalid script output"
+	ErrMsgAlgorithm    = "unknown algorithm"
+	ErrMsgData         = "invalid input data"
+	ErrMsgCanceled     = "optimization canceled"
+	// ErrMsgHandlerTimeout is the plain text body of the requests which took longer than the handler timeout
+	ErrMsgHandlerTimeout = "request timeout"
 )
 
+// statusClientClosedRequest is reported when the caller has gone away before the run finished, nobody reads it
+const statusClientClosedRequest = 499
+
 type OptimizationHandler struct {
 	optimizer optimizer.Optimizer
+	progress  *runProgress
 	log       *logger.Logger
 }
 
-func NewOptimizationHandler(optimizer optimizer.Optimizer, log *logger.Logger) *OptimizationHandler {
+func NewOptimizationHandler(optimizer optimizer.Optimizer, progress *runProgress, log *logger.Logger) *OptimizationHandler {
 	return &OptimizationHandler{
 		optimizer: optimizer,
+		progress:  progress,
 		log:       log,
 	}
 }
@@ -52,7 +71,10 @@ func (h *OptimizationHandler) ServeHTTP(writer http.ResponseWriter, r *http.Requ
 	}
 	defer r.Body.Close()
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	decoder := json.NewDecoder(r.Body)
+	// the numbers of the parameters are kept as is, e.g. large integer seeds
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
 		h.log.Errorf("failed to parse json body, err: %s", err)
 		writeResponse(writer, models.NewErrorResponse(ErrMsgJsonParse), http.StatusBadRequest, h.log)
 		return
@@ -64,28 +86,135 @@ func (h *OptimizationHandler) ServeHTTP(writer http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	res, err := h.optimizer.Execute(req.Filename)
+	// the format has been checked by Validate
+	format, _ := compressor.ParseFormat(req.Format)
+	if req.Format == "" {
+		format = compressor.TarGz
+	}
 
-	switch {
-	case err == nil:
-		writeResponse(writer, models.NewOptimizationResponse(
+	// the run is canceled and the script killed when the caller disconnects
+	ctx := r.Context()
+	if req.RunID != "" {
+		// the progress of the script is kept for the progress queries while the run lasts
+		ctx = runner.WithProgress(ctx, func(progress runner.Progress) {
+			// a canceled run may still report while its script is being stopped
+			if r.Context().Err() == nil {
+				h.progress.set(req.RunID, progress)
+			}
+		})
+		defer h.progress.remove(req.RunID)
+	}
+	res, err := h.optimizer.Execute(ctx, optimizer.Request{Filename: req.Filename, Format: format, Algorithm: req.Algorithm, Parameters: req.Parameters})
+	if err == nil {
+		resp := models.NewOptimizationResponse(
 			res.Location,
 			res.Filename,
 			res.ETag,
-			int64(res.ExecutionTime.Milliseconds())), http.StatusOK, h.log)
+			int64(res.ExecutionTime.Milliseconds()))
+		resp.Algorithm = res.Algorithm
+		resp.Summary = newResultSummary(res.Summary)
+		resp.Parameters = res.Parameters
+		writeResponse(writer, resp, http.StatusOK, h.log)
+		return
+	}
+
+	code := optimizer.ErrorCode(err)
+	var (
+		busyErr   *optimizer.BusyError
+		scriptErr *optimizer.ScriptError
+		dataErr   *optimizer.DataError
+	)
+	switch {
+	case errors.As(err, &busyErr):
+		retryAfter := int(math.Ceil(busyErr.RetryAfter.Seconds()))
+		writer.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		writeResponse(writer, models.NewCodedErrorResponse(code, ErrMsgBusy), http.StatusTooManyRequests, h.log)
+
+	case errors.Is(err, optimizer.ErrCanceled):
+		h.log.Infof("optimization of '%s' canceled by the caller", req.Filename)
+		writeResponse(writer, models.NewCodedErrorResponse(code, ErrMsgCanceled), statusClientClosedRequest, h.log)
+
+	case errors.Is(err, optimizer.ErrInvalidArchive):
+		writeResponse(writer, models.NewCodedErrorResponse(code, ErrMsgArchive), http.StatusBadRequest, h.log)
+
+	case errors.Is(err, optimizer.ErrUnknownAlgorithm):
+		writeResponse(writer, models.NewCodedErrorResponse(code, ErrMsgAlgorithm), http.StatusBadRequest, h.log)
+
+	case errors.Is(err, optimizer.ErrInvalidParameters), errors.Is(err, optimizer.ErrInvalidInputs):
+		// the error lists the rejected parameters or input files
+		writeResponse(writer, models.NewCodedErrorResponse(code, err.Error()), http.StatusBadRequest, h.log)
+
+	case errors.As(err, &dataErr):
+		writeResponse(writer, models.NewViolationsErrorResponse(code, ErrMsgData, newViolations(dataErr)), http.StatusBadRequest, h.log)
 
 	case errors.Is(err, optimizer.ErrDownload):
-		writeResponse(writer, models.NewErrorResponse(ErrMsgDownload), http.StatusInternalServerError, h.log)
+		writeResponse(writer, models.NewCodedErrorResponse(code, ErrMsgDownload), http.StatusInternalServerError, h.log)
+
+	case errors.As(err, &scriptErr):
+		text := scriptErr.Message
+		if text == "" {
+			text = ErrMsgScript
+		}
+		writeResponse(writer, models.NewScriptErrorResponse(code, text, newScriptFailure(scriptErr)), http.StatusInternalServerError, h.log)
 
 	case errors.Is(err, optimizer.ErrOptimize):
-		writeResponse(writer, models.NewErrorResponse(ErrMsgScript), http.StatusInternalServerError, h.log)
+		writeResponse(writer, models.NewCodedErrorResponse(code, ErrMsgScript), http.StatusInternalServerError, h.log)
+
+	case errors.Is(err, optimizer.ErrTimeout):
+		writeResponse(writer, models.NewCodedErrorResponse(code, ErrMsgTimeout), http.StatusInternalServerError, h.log)
+
+	case errors.Is(err, optimizer.ErrLimit):
+		writeResponse(writer, models.NewCodedErrorResponse(code, ErrMsgLimit), http.StatusInternalServerError, h.log)
+
+	case errors.Is(err, optimizer.ErrMissingOutput):
+		// the error lists the missing outputs
+		writeResponse(writer, models.NewCodedErrorResponse(code, err.Error()), http.StatusInternalServerError, h.log)
+
+	case errors.Is(err, optimizer.ErrInvalidOutput):
+		writeResponse(writer, models.NewCodedErrorResponse(code, ErrMsgOutput), http.StatusInternalServerError, h.log)
 
 	case errors.Is(err, optimizer.ErrUpload):
-		writeResponse(writer, models.NewErrorResponse(ErrMsgUpload), http.StatusInternalServerError, h.log)
+		writeResponse(writer, models.NewCodedErrorResponse(code, ErrMsgUpload), http.StatusInternalServerError, h.log)
 
 	case errors.Is(err, optimizer.ErrEnvCreate):
 		fallthrough
 	default:
-		writeResponse(writer, models.NewErrorResponse(ErrMsgInternal), http.StatusInternalServerError, h.log)
+		writeResponse(writer, models.NewCodedErrorResponse(code, ErrMsgInternal), http.StatusInternalServerError, h.log)
+	}
+}
+
+func newScriptFailure(err *optimizer.ScriptError) models.ScriptFailure {
+	failure := models.ScriptFailure{
+		ExitCode: err.ExitCode,
+		Stderr:   err.Stderr,
+	}
+	if tb := err.Traceback; tb != nil {
+		frames := make([]models.Frame, 0, len(tb.Frames))
+		for _, f := range tb.Frames {
+			frames = append(frames, models.Frame{File: f.File, Line: f.Line, Function: f.Function, Code: f.Code})
+		}
+		failure.Exception = &models.Exception{Type: tb.Type, Message: tb.Message, Frames: frames}
+	}
+	return failure
+}
+
+func newViolations(err *optimizer.DataError) []models.Violation {
+	violations := make([]models.Violation, 0, len(err.Violations))
+	for _, v := range err.Violations {
+		violations = append(violations, models.Violation{File: v.File, Line: v.Line, Column: v.Column, Message: v.Message})
+	}
+	return violations
+}
+
+func newResultSummary(summary *optimizer.Summary) *models.ResultSummary {
+	if summary == nil {
+		return nil
+	}
+	return &models.ResultSummary{
+		Objective:  summary.Objective,
+		Status:     string(summary.Status),
+		Iterations: summary.Iterations,
+		Warnings:   summary.Warnings,
+		KPIs:       summary.KPIs,
 	}
 }
@@ -3,13 +3,18 @@ package server
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/optimizer"
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/runner"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
 	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -54,10 +59,10 @@ func TestOptimizationHandler(t *testing.T) {
 			name:           "pseudo error mock, internal error",
 			inputJson:      `{"filename":"1"}`,
 			expectedStatus: http.StatusInternalServerError,
-			outputJson:     fmt.Sprintf(`{"text":"%s"}`, ErrMsgInternal),
+			outputJson:     fmt.Sprintf(`{"text":"%s","code":"env_create"}`, ErrMsgInternal),
 			optimizer: func() optimizer.Optimizer {
 				opt := optimizer.NewMockOptimizer()
-				opt.On("Execute", "1").Return(&optimizer.Result{}, optimizer.ErrEnvCreate)
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz}).Return(&optimizer.Result{}, optimizer.ErrEnvCreate)
 				return opt
 			}(),
 		},
@@ -65,10 +70,10 @@ func TestOptimizationHandler(t *testing.T) {
 			name:           "pseudo error mock, download error",
 			inputJson:      `{"filename":"1"}`,
 			expectedStatus: http.StatusInternalServerError,
-			outputJson:     fmt.Sprintf(`{"text":"%s"}`, ErrMsgDownload),
+			outputJson:     fmt.Sprintf(`{"text":"%s","code":"download"}`, ErrMsgDownload),
 			optimizer: func() optimizer.Optimizer {
 				opt := optimizer.NewMockOptimizer()
-				opt.On("Execute", "1").Return(&optimizer.Result{}, optimizer.ErrDownload)
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz}).Return(&optimizer.Result{}, optimizer.ErrDownload)
 				return opt
 			}(),
 		},
@@ -76,10 +81,10 @@ func TestOptimizationHandler(t *testing.T) {
 			name:           "pseudo error mock, script error",
 			inputJson:      `{"filename":"1"}`,
 			expectedStatus: http.StatusInternalServerError,
-			outputJson:     fmt.Sprintf(`{"text":"%s"}`, ErrMsgScript),
+			outputJson:     fmt.Sprintf(`{"text":"%s","code":"optimize"}`, ErrMsgScript),
 			optimizer: func() optimizer.Optimizer {
 				opt := optimizer.NewMockOptimizer()
-				opt.On("Execute", "1").Return(&optimizer.Result{}, optimizer.ErrOptimize)
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz}).Return(&optimizer.Result{}, optimizer.ErrOptimize)
 				return opt
 			}(),
 		},
@@ -87,10 +92,112 @@ func TestOptimizationHandler(t *testing.T) {
 			name:           "pseudo error mock, upload error",
 			inputJson:      `{"filename":"1"}`,
 			expectedStatus: http.StatusInternalServerError,
-			outputJson:     fmt.Sprintf(`{"text":"%s"}`, ErrMsgUpload),
+			outputJson:     fmt.Sprintf(`{"text":"%s","code":"upload"}`, ErrMsgUpload),
 			optimizer: func() optimizer.Optimizer {
 				opt := optimizer.NewMockOptimizer()
-				opt.On("Execute", "1").Return(&optimizer.Result{}, optimizer.ErrUpload)
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz}).Return(&optimizer.Result{}, optimizer.ErrUpload)
+				return opt
+			}(),
+		},
+		{
+			name:           "pseudo error mock, invalid archive",
+			inputJson:      `{"filename":"1"}`,
+			expectedStatus: http.StatusBadRequest,
+			outputJson:     fmt.Sprintf(`{"text":"%s","code":"invalid_archive"}`, ErrMsgArchive),
+			optimizer: func() optimizer.Optimizer {
+				opt := optimizer.NewMockOptimizer()
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz}).Return(&optimizer.Result{}, optimizer.ErrInvalidArchive)
+				return opt
+			}(),
+		},
+		{
+			name:           "script error from the exit code table",
+			inputJson:      `{"filename":"1"}`,
+			expectedStatus: http.StatusInternalServerError,
+			outputJson:     `{"text":"input files have different lengths","code":"input_length_mismatch","script":{"exitCode":126}}`,
+			optimizer: func() optimizer.Optimizer {
+				opt := optimizer.NewMockOptimizer()
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz}).Return(&optimizer.Result{}, &optimizer.ScriptError{
+					ExitCode: 126,
+					Code:     "input_length_mismatch",
+					Message:  "input files have different lengths",
+				})
+				return opt
+			}(),
+		},
+		{
+			name:           "script error with traceback",
+			inputJson:      `{"filename":"1"}`,
+			expectedStatus: http.StatusInternalServerError,
+			outputJson: fmt.Sprintf(`{"text":"%s","code":"optimize","script":{"exitCode":1,"stderr":"Traceback...",`+
+				`"exception":{"type":"ValueError","message":"bad","frames":[{"file":"main.py","line":3,"function":"main","code":"f()"}]}}}`, ErrMsgScript),
+			optimizer: func() optimizer.Optimizer {
+				opt := optimizer.NewMockOptimizer()
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz}).Return(&optimizer.Result{}, &optimizer.ScriptError{
+					ExitCode: 1,
+					Stderr:   "Traceback...",
+					Traceback: &runner.Traceback{
+						Type:    "ValueError",
+						Message: "bad",
+						Frames:  []runner.Frame{{File: "main.py", Line: 3, Function: "main", Code: "f()"}},
+					},
+				})
+				return opt
+			}(),
+		},
+		{
+			name:           "missing output",
+			inputJson:      `{"filename":"1"}`,
+			expectedStatus: http.StatusInternalServerError,
+			outputJson:     `{"text":"required script output is missing: def_output.csv","code":"missing_output"}`,
+			optimizer: func() optimizer.Optimizer {
+				opt := optimizer.NewMockOptimizer()
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz}).Return(&optimizer.Result{},
+					&optimizer.MissingOutputError{Missing: []string{"def_output.csv"}})
+				return opt
+			}(),
+		},
+		{
+			name:           "pseudo error mock, timeout",
+			inputJson:      `{"filename":"1"}`,
+			expectedStatus: http.StatusInternalServerError,
+			outputJson:     fmt.Sprintf(`{"text":"%s","code":"timeout"}`, ErrMsgTimeout),
+			optimizer: func() optimizer.Optimizer {
+				opt := optimizer.NewMockOptimizer()
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz}).Return(&optimizer.Result{}, optimizer.ErrTimeout)
+				return opt
+			}(),
+		},
+		{
+			name:           "pseudo error mock, limit",
+			inputJson:      `{"filename":"1"}`,
+			expectedStatus: http.StatusInternalServerError,
+			outputJson:     fmt.Sprintf(`{"text":"%s","code":"limit"}`, ErrMsgLimit),
+			optimizer: func() optimizer.Optimizer {
+				opt := optimizer.NewMockOptimizer()
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz}).Return(&optimizer.Result{}, optimizer.ErrLimit)
+				return opt
+			}(),
+		},
+		{
+			name:           "canceled",
+			inputJson:      `{"filename":"1"}`,
+			expectedStatus: statusClientClosedRequest,
+			outputJson:     fmt.Sprintf(`{"text":"%s","code":"canceled"}`, ErrMsgCanceled),
+			optimizer: func() optimizer.Optimizer {
+				opt := optimizer.NewMockOptimizer()
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz}).Return(&optimizer.Result{}, optimizer.ErrCanceled)
+				return opt
+			}(),
+		},
+		{
+			name:           "queue is full",
+			inputJson:      `{"filename":"1"}`,
+			expectedStatus: http.StatusTooManyRequests,
+			outputJson:     fmt.Sprintf(`{"text":"%s","code":"busy"}`, ErrMsgBusy),
+			optimizer: func() optimizer.Optimizer {
+				opt := optimizer.NewMockOptimizer()
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz}).Return(&optimizer.Result{}, &optimizer.BusyError{RetryAfter: 1500 * time.Millisecond})
 				return opt
 			}(),
 		},
@@ -101,7 +208,7 @@ func TestOptimizationHandler(t *testing.T) {
 			outputJson:     `{"filename":"1","location":"1","etag":"1","executionTime":0}`,
 			optimizer: func() optimizer.Optimizer {
 				opt := optimizer.NewMockOptimizer()
-				opt.On("Execute", "1").Return(&optimizer.Result{
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz}).Return(&optimizer.Result{
 					Filename:      "1",
 					Location:      "1",
 					ETag:          "1",
@@ -110,6 +217,134 @@ func TestOptimizationHandler(t *testing.T) {
 				return opt
 			}(),
 		},
+		{
+			name:           "zip result",
+			inputJson:      `{"filename":"1","format":"zip"}`,
+			expectedStatus: http.StatusOK,
+			outputJson:     `{"filename":"1.zip","location":"1","etag":"1","executionTime":0}`,
+			optimizer: func() optimizer.Optimizer {
+				opt := optimizer.NewMockOptimizer()
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.Zip}).Return(&optimizer.Result{
+					Filename: "1.zip",
+					Location: "1",
+					ETag:     "1",
+				}, nil)
+				return opt
+			}(),
+		},
+		{
+			name:           "result summary",
+			inputJson:      `{"filename":"1"}`,
+			expectedStatus: http.StatusOK,
+			outputJson:     `{"filename":"1","location":"1","etag":"1","executionTime":0,"summary":{"objective":12.5,"status":"optimal","iterations":120,"warnings":["slow"],"kpis":{"racks":14}}}`,
+			optimizer: func() optimizer.Optimizer {
+				objective, iterations := 12.5, int64(120)
+				opt := optimizer.NewMockOptimizer()
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz}).Return(&optimizer.Result{
+					Filename: "1",
+					Location: "1",
+					ETag:     "1",
+					Summary: &optimizer.Summary{
+						Objective:  &objective,
+						Status:     optimizer.StatusOptimal,
+						Iterations: &iterations,
+						Warnings:   []string{"slow"},
+						KPIs:       map[string]float64{"racks": 14},
+					},
+				}, nil)
+				return opt
+			}(),
+		},
+		{
+			name:           "selected algorithm",
+			inputJson:      `{"filename":"1","algorithm":"rack-milp@2.0"}`,
+			expectedStatus: http.StatusOK,
+			outputJson:     `{"algorithm":"rack-milp@2.0","filename":"1","location":"1","etag":"1","executionTime":0}`,
+			optimizer: func() optimizer.Optimizer {
+				opt := optimizer.NewMockOptimizer()
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz, Algorithm: "rack-milp@2.0"}).Return(&optimizer.Result{
+					Algorithm: "rack-milp@2.0",
+					Filename:  "1",
+					Location:  "1",
+					ETag:      "1",
+				}, nil)
+				return opt
+			}(),
+		},
+		{
+			name:           "unknown algorithm",
+			inputJson:      `{"filename":"1","algorithm":"rack-tabu"}`,
+			expectedStatus: http.StatusBadRequest,
+			outputJson:     fmt.Sprintf(`{"text":"%s","code":"unknown_algorithm"}`, ErrMsgAlgorithm),
+			optimizer: func() optimizer.Optimizer {
+				opt := optimizer.NewMockOptimizer()
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz, Algorithm: "rack-tabu"}).Return(&optimizer.Result{}, fmt.Errorf("%w 'rack-tabu'", optimizer.ErrUnknownAlgorithm))
+				return opt
+			}(),
+		},
+		{
+			name:           "parameters",
+			inputJson:      `{"filename":"1","parameters":{"seed":9007199254740993,"strategy":"greedy"}}`,
+			expectedStatus: http.StatusOK,
+			outputJson:     `{"filename":"1","location":"1","etag":"1","executionTime":0,"parameters":{"seed":9007199254740993,"strategy":"greedy","verbose":false}}`,
+			optimizer: func() optimizer.Optimizer {
+				opt := optimizer.NewMockOptimizer()
+				params := map[string]interface{}{"seed": json.Number("9007199254740993"), "strategy": "greedy"}
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz, Parameters: params}).Return(&optimizer.Result{
+					Filename:   "1",
+					Location:   "1",
+					ETag:       "1",
+					Parameters: map[string]interface{}{"seed": json.Number("9007199254740993"), "strategy": "greedy", "verbose": false},
+				}, nil)
+				return opt
+			}(),
+		},
+		{
+			name:           "invalid parameters",
+			inputJson:      `{"filename":"1","parameters":{"seed":"x"}}`,
+			expectedStatus: http.StatusBadRequest,
+			outputJson:     `{"text":"invalid parameters: seed: must be an integer","code":"invalid_parameters"}`,
+			optimizer: func() optimizer.Optimizer {
+				opt := optimizer.NewMockOptimizer()
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz, Parameters: map[string]interface{}{"seed": "x"}}).
+					Return(&optimizer.Result{}, &optimizer.ParametersError{Errors: map[string]string{"seed": "must be an integer"}})
+				return opt
+			}(),
+		},
+		{
+			name:           "invalid inputs",
+			inputJson:      `{"filename":"1"}`,
+			expectedStatus: http.StatusBadRequest,
+			outputJson:     `{"text":"invalid input files: devices: required; notes.txt: unexpected file","code":"invalid_inputs"}`,
+			optimizer: func() optimizer.Optimizer {
+				opt := optimizer.NewMockOptimizer()
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz}).
+					Return(&optimizer.Result{}, &optimizer.InputsError{Errors: map[string]string{"devices": "required", "notes.txt": "unexpected file"}})
+				return opt
+			}(),
+		},
+		{
+			name:           "invalid data",
+			inputJson:      `{"filename":"1"}`,
+			expectedStatus: http.StatusBadRequest,
+			outputJson:     `{"text":"invalid input data","code":"invalid_data","violations":[{"file":"racks/r.csv","line":3,"column":"id","message":"'x' is not an integer"},{"file":"devices.csv","message":"has 1 rows, the racks input has 2"}]}`,
+			optimizer: func() optimizer.Optimizer {
+				opt := optimizer.NewMockOptimizer()
+				opt.On("Execute", optimizer.Request{Filename: "1", Format: compressor.TarGz}).
+					Return(&optimizer.Result{}, &optimizer.DataError{Violations: []tabular.Violation{
+						{File: "racks/r.csv", Line: 3, Column: "id", Message: "'x' is not an integer"},
+						{File: "devices.csv", Message: "has 1 rows, the racks input has 2"},
+					}})
+				return opt
+			}(),
+		},
+		{
+			name:           "unknown format",
+			inputJson:      `{"filename":"1","format":"rar"}`,
+			expectedStatus: http.StatusBadRequest,
+			outputJson:     fmt.Sprintf(`{"text":"%s"}`, ErMsgJsonValidate),
+			optimizer:      nil,
+		},
 		/*
 			{
 				name:           "pseudo error mock, no error",
@@ -122,7 +357,7 @@ func TestOptimizationHandler(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			test := func(req *http.Request, err error) {
-				optimize := NewOptimizationHandler(tc.optimizer, logger.NewTestLogger())
+				optimize := NewOptimizationHandler(tc.optimizer, newRunProgress(), logger.NewTestLogger())
 				handle := func(w http.ResponseWriter, r *http.Request) {
 					optimize.ServeHTTP(w, r)
 				}
@@ -133,6 +368,9 @@ func TestOptimizationHandler(t *testing.T) {
 				handler.ServeHTTP(r, req)
 				assert.Equal(t, r.Code, tc.expectedStatus)
 				assert.Equal(t, r.Body.String(), tc.outputJson)
+				if tc.expectedStatus == http.StatusTooManyRequests {
+					assert.Equal(t, "2", r.Header().Get("Retry-After"))
+				}
 			}
 
 			if len(tc.inputJson) == 0 {
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/runner"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/gorilla/mux"
+)
+
+const ErrMsgNoProgress = "no progress reported for the run"
+
+// runProgress keeps the latest progress of the running optimizations by their run id
+type runProgress struct {
+	mu   sync.RWMutex
+	runs map[string]runner.Progress
+}
+
+func newRunProgress() *runProgress {
+	return &runProgress{runs: make(map[string]runner.Progress)}
+}
+
+func (p *runProgress) set(id string, progress runner.Progress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.runs[id] = progress
+}
+
+func (p *runProgress) get(id string) (runner.Progress, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	progress, ok := p.runs[id]
+	return progress, ok
+}
+
+func (p *runProgress) remove(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.runs, id)
+}
+
+type ProgressHandler struct {
+	progress *runProgress
+	log      *logger.Logger
+}
+
+func NewProgressHandler(progress *runProgress, log *logger.Logger) *ProgressHandler {
+	return &ProgressHandler{
+		progress: progress,
+		log:      log,
+	}
+}
+
+// Progress
+// @Summary Get the progress of a running optimization
+// @Description Report the latest progress line of the script of the run started with the run id
+// @ID progress-handler
+// @Produce  json
+// @Param   id path string true "run id of the optimization request"
+// @Success 200 {object} models.ProgressResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /v1/runs/{id}/progress [get]
+func (h *ProgressHandler) ServeHTTP(writer http.ResponseWriter, r *http.Request) {
+	progress, ok := h.progress.get(mux.Vars(r)["id"])
+	if !ok {
+		writeResponse(writer, models.NewErrorResponse(ErrMsgNoProgress), http.StatusNotFound, h.log)
+		return
+	}
+	resp := models.ProgressResponse{
+		Percent:   progress.Percent,
+		Message:   progress.Message,
+		UpdatedAt: progress.Time,
+	}
+	writeResponse(writer, resp, http.StatusOK, h.log)
+}
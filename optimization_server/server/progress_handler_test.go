@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/models"
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/optimizer"
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/runner"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// optimizerFunc adapts a function to the Optimizer interface
+type optimizerFunc func(ctx context.Context, req optimizer.Request) (*optimizer.Result, error)
+
+func (f optimizerFunc) Execute(ctx context.Context, req optimizer.Request) (*optimizer.Result, error) {
+	return f(ctx, req)
+}
+
+func TestProgressHandler(t *testing.T) {
+	// the script reports its progress and waits for the done file
+	workDir := t.TempDir()
+	script, err := runner.NewCommand("sh", "", []string{"-c", `echo '##progress 50 "half way"'; while [ ! -f done ]; do sleep 0.05; done`},
+		5*time.Second, logger.NewTestLogger())
+	assert.NoError(t, err)
+	opt := optimizerFunc(func(ctx context.Context, req optimizer.Request) (*optimizer.Result, error) {
+		script.Run(ctx, workDir, nil)
+		return &optimizer.Result{Filename: "opt_result_1.tar.gz"}, nil
+	})
+
+	progress := newRunProgress()
+	r := mux.NewRouter()
+	r.Handle("/api/v1/optimize", NewOptimizationHandler(opt, progress, logger.NewTestLogger()))
+	r.Handle("/api/v1/runs/{id}/progress", NewProgressHandler(progress, logger.NewTestLogger()))
+	get := func(id string) *httptest.ResponseRecorder {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/api/v1/runs/"+id+"/progress", nil)
+		assert.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusNotFound, get("job-1").Code)
+	optimized := make(chan int)
+	go func() {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "/api/v1/optimize", strings.NewReader(`{"filename":"1","runId":"job-1"}`))
+		assert.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		optimized <- recorder.Code
+	}()
+
+	var resp models.ProgressResponse
+	assert.Eventually(t, func() bool {
+		return get("job-1").Code == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+	recorder := get("job-1")
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+	assert.Equal(t, 50.0, resp.Percent)
+	assert.Equal(t, "half way", resp.Message)
+	assert.False(t, resp.UpdatedAt.IsZero())
+	assert.Equal(t, http.StatusNotFound, get("job-2").Code)
+
+	// the progress is dropped once the run has finished
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(workDir, "done"), nil, os.ModePerm))
+	assert.Equal(t, http.StatusOK, <-optimized)
+	recorder = get("job-1")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.JSONEq(t, `{"text":"`+ErrMsgNoProgress+`"}`, recorder.Body.String())
+}
@@ -9,13 +9,17 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/cxrdevelop/optimization_engine/optimization_server/config"
 	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/optimizer"
-	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/python"
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/runner"
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/scheduler"
+	"github.com/cxrdevelop/optimization_engine/pkg/compressor"
 	"github.com/cxrdevelop/optimization_engine/pkg/logger"
 	"github.com/cxrdevelop/optimization_engine/pkg/metrics"
 	"github.com/cxrdevelop/optimization_engine/pkg/storage"
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
@@ -24,15 +28,27 @@ import (
 const (
 	gracefulShutdownTimeoutMs = 5000
 	defaultScriptTimeoutMs    = 5000
+	defaultConcurrency        = 8
+	defaultKillGrace          = 5 * time.Second
+	// defaultHandlerTimeout bounds the requests which are answered right away
+	defaultHandlerTimeout = 30 * time.Second
+	// the script section of the config is registered under this name if no algorithms are listed
+	defaultAlgorithmName    = "default"
+	defaultAlgorithmVersion = "1"
 )
 
 type Server struct {
 	config        *config.Config
-	wrapper       *python.Wrapper
+	algorithms    *optimizer.Registry
 	optimizer     optimizer.Optimizer
+	scheduler     *scheduler.Scheduler
 	storage       storage.Storage
+	progress      *runProgress
 	logger        *logger.Logger
 	signalChannel chan os.Signal
+
+	// handlerTimeout bounds every handler but the optimize one
+	handlerTimeout time.Duration
 }
 
 func New(config *config.Config) *Server {
@@ -44,13 +60,7 @@ func (s *Server) Start() {
 
 	s.SetDefaults()
 
-	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%s", s.config.Application.Port),
-		WriteTimeout: time.Second * 30,
-		ReadTimeout:  time.Second * 30,
-		IdleTimeout:  time.Second * 60,
-		Handler:      s.SetupRoutes(),
-	}
+	srv := s.newHTTPServer()
 	s.logger.Infof("optimization server is running at port %s", s.config.Application.Port)
 
 	go func() {
@@ -71,10 +81,25 @@ func (s *Server) Start() {
 	if err := srv.Shutdown(ctx); err != nil {
 		s.logger.Errorf("http server shutdown failed: %s", err)
 	}
+	if s.scheduler != nil {
+		s.scheduler.Stop()
+	}
 
 	s.logger.Warn("optimization server shutting down")
 }
 
+// newHTTPServer creates the http server of the routes. It has no write timeout: an optimize request
+// lasts as long as its run, which waits in the queue and is bounded by the timeout of the algorithm.
+// The other handlers are bounded by the handler timeout instead.
+func (s *Server) newHTTPServer() *http.Server {
+	return &http.Server{
+		Addr:        fmt.Sprintf(":%s", s.config.Application.Port),
+		ReadTimeout: time.Second * 30,
+		IdleTimeout: time.Second * 60,
+		Handler:     s.SetupRoutes(),
+	}
+}
+
 func (s *Server) SetDefaults() {
 	if s.logger == nil {
 		level, err := logrus.ParseLevel(s.config.Application.LogLevel)
@@ -84,17 +109,26 @@ func (s *Server) SetDefaults() {
 		s.logger = logger.New(s.config.Application.LogPath, "optimization_service", level)
 	}
 
-	if s.wrapper == nil {
+	if s.algorithms == nil {
 		execTimeout := s.config.Script.Timeout
 		if execTimeout <= 0 {
 			execTimeout = defaultScriptTimeoutMs * time.Millisecond
 			s.logger.Errorf("incorrect timeout value %s, switching to default value of %s", s.config.Script.Timeout, execTimeout)
 		}
-		scriptPath, err := filepath.Abs(s.config.Script.Path)
-		if err != nil {
-			s.logger.Errorf("error creating absolute path for script: %s", err)
+		killGrace := s.config.Script.KillGrace
+		if killGrace <= 0 {
+			killGrace = defaultKillGrace
+			s.logger.Errorf("incorrect kill grace value %s, switching to default value of %s", s.config.Script.KillGrace, killGrace)
+		}
+		limits := runner.Limits{
+			AddressSpace: s.config.Script.Limits.AddressSpace,
+			CPUTime:      s.config.Script.Limits.CPUTime,
+			FileSize:     s.config.Script.Limits.FileSize,
+			OpenFiles:    s.config.Script.Limits.OpenFiles,
+			MaxOutput:    s.config.Script.MaxOutput,
 		}
-		s.wrapper = python.NewWrapper(scriptPath, execTimeout, s.logger)
+		opts := []runner.Option{runner.WithLimits(limits), runner.WithKillGrace(killGrace)}
+		s.algorithms = s.newRegistry(execTimeout, opts)
 	}
 
 	if s.storage == nil {
@@ -107,9 +141,238 @@ func (s *Server) SetDefaults() {
 			s.storage = storage.NewFSStorage(s.config.Storage.Bucket, s.logger)
 		}
 	}
+	if s.scheduler == nil {
+		concurrency := s.config.Script.Concurrency
+		if concurrency <= 0 {
+			concurrency = defaultConcurrency
+			s.logger.Errorf("incorrect concurrency value %d, switching to default value of %d", s.config.Script.Concurrency, concurrency)
+		}
+		queueDepth := s.config.Script.QueueDepth
+		if queueDepth < 0 {
+			queueDepth = 0
+			s.logger.Errorf("incorrect queue depth value %d, switching to %d", s.config.Script.QueueDepth, queueDepth)
+		}
+		s.scheduler = scheduler.New(concurrency, queueDepth)
+	}
+	if s.handlerTimeout <= 0 {
+		s.handlerTimeout = defaultHandlerTimeout
+	}
+	if s.progress == nil {
+		s.progress = newRunProgress()
+	}
 	if s.optimizer == nil {
-		s.optimizer = optimizer.NewRackOptimizer(s.wrapper, s.storage, ".", "tmp_prefix", s.logger)
+		limits := compressor.Limits{
+			MaxEntries:   s.config.Archive.MaxEntries,
+			MaxFileSize:  s.config.Archive.MaxFileSize,
+			MaxTotalSize: s.config.Archive.MaxTotalSize,
+		}
+		rack := optimizer.NewRackOptimizer(s.algorithms, s.storage, ".", "tmp_prefix", limits, s.logger)
+		s.optimizer = optimizer.NewScheduledOptimizer(rack, s.scheduler, s.config.Script.RetryAfter)
+	}
+}
+
+// newRegistry creates the algorithms listed in the config, the script section is the only algorithm if none are listed
+func (s *Server) newRegistry(timeout time.Duration, opts []runner.Option) *optimizer.Registry {
+	legacy := config.Algorithm{Name: defaultAlgorithmName, Version: defaultAlgorithmVersion}
+	algorithms := s.config.Algorithms.List
+	if len(algorithms) == 0 {
+		algorithms = []config.Algorithm{legacy}
+	}
+	list := make([]*optimizer.Algorithm, 0, len(algorithms))
+	for _, a := range algorithms {
+		list = append(list, s.newAlgorithm(a, timeout, opts))
+	}
+
+	registry, err := optimizer.NewRegistry(s.config.Algorithms.Default, list...)
+	if err != nil && s.config.Algorithms.Default != "" {
+		s.logger.Errorf("incorrect default algorithm: %s, switching to %s", err, list[0].ID())
+		registry, err = optimizer.NewRegistry("", list...)
+	}
+	if err != nil {
+		s.logger.Errorf("incorrect algorithms: %s, switching to the script section", err)
+		registry, _ = optimizer.NewRegistry("", s.newAlgorithm(legacy, timeout, opts))
+	}
+	return registry
+}
+
+// newAlgorithm creates the algorithm, the settings it doesn't set are taken from the script and output sections
+func (s *Server) newAlgorithm(a config.Algorithm, timeout time.Duration, opts []runner.Option) *optimizer.Algorithm {
+	script := s.config.Script
+	if a.Path == "" {
+		a.Path = script.Path
+	}
+	if a.Runner == "" {
+		a.Runner = script.Runner
+	}
+	if a.Interpreter == "" {
+		a.Interpreter = script.Interpreter
+	}
+	if a.Command == "" {
+		a.Command = script.Command
+	}
+	if len(a.Args) == 0 {
+		a.Args = script.Args
+	}
+	if a.Timeout <= 0 {
+		a.Timeout = timeout
+	}
+	if len(a.ExitCodes) == 0 {
+		a.ExitCodes = script.ExitCodes
+	}
+	if len(a.Parameters) == 0 {
+		a.Parameters = script.Parameters
+	}
+	if len(a.Inputs) == 0 {
+		a.Inputs = script.Inputs
+	}
+	output := s.config.Output
+	if a.Output != nil {
+		output = *a.Output
+	}
+
+	scriptPath, err := filepath.Abs(a.Path)
+	if err != nil {
+		s.logger.Errorf("error creating absolute path for script: %s", err)
+	}
+	scriptRunner, runnerType := s.newRunner(a, scriptPath, opts)
+	params := parameterSchema(a.Parameters)
+	if err := params.Check(); err != nil {
+		s.logger.Errorf("incorrect parameters of algorithm %s@%s: %s, switching to no parameters", a.Name, a.Version, err)
+		params = nil
+	}
+	inputs := inputSchema(a.Inputs)
+	if err := inputs.Check(); err != nil {
+		s.logger.Errorf("incorrect inputs of algorithm %s@%s: %s, switching to no input slots", a.Name, a.Version, err)
+		inputs = nil
 	}
+	return &optimizer.Algorithm{
+		Name:        a.Name,
+		Version:     a.Version,
+		Description: a.Description,
+		Runner:      scriptRunner,
+		RunnerType:  runnerType,
+		Timeout:     a.Timeout,
+		ExitCodes:   exitCodes(a.ExitCodes),
+		Outputs:     outputContract(output),
+		Parameters:  params,
+		Inputs:      inputs,
+	}
+}
+
+// newRunner creates the script runner selected for the algorithm, the python3 runner is the default
+func (s *Server) newRunner(a config.Algorithm, scriptPath string, opts []runner.Option) (runner.Runner, string) {
+	switch strings.ToLower(a.Runner) {
+	case config.RunnerCommand:
+		command, err := runner.NewCommand(a.Command, scriptPath, a.Args, a.Timeout, s.logger, opts...)
+		if err == nil {
+			return command, config.RunnerCommand
+		}
+		s.logger.Errorf("incorrect script command: %s, switching to default runner %s", err, config.RunnerPython)
+	case config.RunnerPython:
+	default:
+		s.logger.Errorf("incorrect script runner '%s', switching to default runner %s", a.Runner, config.RunnerPython)
+	}
+	return runner.NewPython(a.Interpreter, scriptPath, a.Timeout, s.logger, opts...), config.RunnerPython
+}
+
+// exitCodes converts the exit code table of the config, the codes of the sample script are used if it's empty
+func exitCodes(codes []config.ExitCode) map[int]optimizer.ExitCode {
+	if len(codes) == 0 {
+		return optimizer.DefaultExitCodes
+	}
+	table := make(map[int]optimizer.ExitCode, len(codes))
+	for _, c := range codes {
+		table[c.ExitCode] = optimizer.ExitCode{Code: c.Code, Message: c.Message}
+	}
+	return table
+}
+
+// parameterSchema converts the parameters of the config
+func parameterSchema(params []config.Parameter) optimizer.ParameterSchema {
+	schema := make(optimizer.ParameterSchema, 0, len(params))
+	for _, p := range params {
+		schema = append(schema, optimizer.Parameter{
+			Name:        p.Name,
+			Type:        p.Type,
+			Description: p.Description,
+			Required:    p.Required,
+			Default:     stringKeys(p.Default),
+			Min:         p.Min,
+			Max:         p.Max,
+			Enum:        p.Enum,
+			Flag:        p.Flag,
+		})
+	}
+	return schema
+}
+
+// inputSchema converts the input slots of the config
+func inputSchema(slots []config.InputSlot) optimizer.InputSchema {
+	schema := make(optimizer.InputSchema, 0, len(slots))
+	for _, slot := range slots {
+		schema = append(schema, optimizer.InputSlot{
+			Name:       slot.Name,
+			Required:   slot.Required,
+			Extensions: slot.Extensions,
+			Flag:       slot.Flag,
+			CSV:        csvSchema(slot.CSV),
+			EqualRows:  slot.EqualRows,
+		})
+	}
+	return schema
+}
+
+// csvSchema converts the CSV schema of an input slot, nil if it isn't set.
+// A delimiter which isn't a single character fails the check of the schema.
+func csvSchema(c *config.CSVSchema) *tabular.Schema {
+	if c == nil {
+		return nil
+	}
+	schema := &tabular.Schema{MinRows: c.MinRows, MaxRows: c.MaxRows}
+	if c.Delimiter != "" {
+		delimiter := []rune(c.Delimiter)
+		schema.Delimiter = delimiter[0]
+		if len(delimiter) != 1 {
+			schema.Delimiter = utf8.RuneError
+		}
+	}
+	for _, column := range c.Columns {
+		schema.Columns = append(schema.Columns, tabular.Column{Name: column.Name, Type: column.Type, Required: column.Required})
+	}
+	return schema
+}
+
+// stringKeys converts the maps decoded from YAML into the maps decoded from JSON
+func stringKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = stringKeys(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = stringKeys(item)
+		}
+	}
+	return value
+}
+
+// outputContract converts the output section of the config, the default contract is used if it's empty
+func outputContract(output config.Output) optimizer.OutputContract {
+	if output.Dir == "" && len(output.Files) == 0 && output.Manifest == "" {
+		return optimizer.DefaultOutputContract
+	}
+	contract := optimizer.OutputContract{
+		Dir:      output.Dir,
+		Files:    make([]optimizer.OutputFile, 0, len(output.Files)),
+		Manifest: output.Manifest,
+	}
+	for _, f := range output.Files {
+		contract.Files = append(contract.Files, optimizer.OutputFile{Pattern: f.Pattern, Required: f.Required})
+	}
+	return contract
 }
 
 func (s *Server) SetupRoutes() *mux.Router {
@@ -121,14 +384,29 @@ func (s *Server) SetupRoutes() *mux.Router {
 	apiPrefix := r.PathPrefix("/api/v1").Subrouter()
 
 	wrappedHealthHandler := handlers.LoggingHandler(s.logger.Writer(),
-		NewHealthHandler(s.logger),
+		s.bounded(NewHealthHandler(s.logger)),
 	)
 	apiPrefix.Handle("/health", wrappedHealthHandler).Methods("GET")
 
 	wrappedOptimizationHandler := handlers.LoggingHandler(s.logger.Writer(),
-		NewOptimizationHandler(s.optimizer, s.logger),
+		NewOptimizationHandler(s.optimizer, s.progress, s.logger),
 	)
 	apiPrefix.Handle("/optimize", wrappedOptimizationHandler).Methods("GET", "POST")
 
+	wrappedProgressHandler := handlers.LoggingHandler(s.logger.Writer(),
+		s.bounded(NewProgressHandler(s.progress, s.logger)),
+	)
+	apiPrefix.Handle("/runs/{id}/progress", wrappedProgressHandler).Methods("GET")
+
+	wrappedAlgorithmsHandler := handlers.LoggingHandler(s.logger.Writer(),
+		s.bounded(NewAlgorithmsHandler(s.algorithms, s.logger)),
+	)
+	apiPrefix.Handle("/algorithms", wrappedAlgorithmsHandler).Methods("GET")
+
 	return r
 }
+
+// bounded responds with 503 if the handler doesn't finish within the handler timeout
+func (s *Server) bounded(handler http.Handler) http.Handler {
+	return http.TimeoutHandler(handler, s.handlerTimeout, ErrMsgHandlerTimeout)
+}
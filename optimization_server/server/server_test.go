@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cxrdevelop/optimization_engine/optimization_server/config"
+	"github.com/cxrdevelop/optimization_engine/optimization_server/internal/optimizer"
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+	"github.com/cxrdevelop/optimization_engine/pkg/tabular"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_NewRegistry(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Script.Path = "main.py"
+	cfg.Script.Runner = config.RunnerPython
+	cfg.Output.Files = []config.OutputFile{{Pattern: "*.csv"}}
+	s := &Server{config: cfg, logger: logger.NewTestLogger()}
+
+	t.Run("script section", func(t *testing.T) {
+		registry := s.newRegistry(time.Second, nil)
+		a := registry.Default()
+		assert.Equal(t, "default@1", a.ID())
+		assert.Equal(t, config.RunnerPython, a.RunnerType)
+		assert.Equal(t, time.Second, a.Timeout)
+		assert.Equal(t, optimizer.DefaultExitCodes, a.ExitCodes)
+		assert.Equal(t, optimizer.OutputContract{Files: []optimizer.OutputFile{{Pattern: "*.csv"}}}, a.Outputs)
+	})
+
+	cfg.Algorithms.List = []config.Algorithm{
+		{Name: "rack-greedy", Version: "1.2", Parameters: []config.Parameter{{Name: "weights", Type: "object", Default: map[interface{}]interface{}{"cost": 1}}}},
+		{Name: "rack-milp", Version: "2.0", Runner: config.RunnerCommand, Command: "milp", Timeout: time.Minute,
+			ExitCodes: []config.ExitCode{{ExitCode: 3, Code: "no_solution"}}, Output: &config.Output{Dir: "output"},
+			Inputs: []config.InputSlot{{Name: "racks", Required: true, EqualRows: true, CSV: &config.CSVSchema{
+				Delimiter: ";", Columns: []config.CSVColumn{{Name: "id", Type: "integer"}}, MinRows: 1}}}},
+	}
+
+	t.Run("algorithms", func(t *testing.T) {
+		cfg.Algorithms.Default = "rack-milp"
+		registry := s.newRegistry(time.Second, nil)
+		a := registry.Default()
+		assert.Equal(t, "rack-milp@2.0", a.ID())
+		assert.Equal(t, config.RunnerCommand, a.RunnerType)
+		assert.Equal(t, time.Minute, a.Timeout)
+		assert.Equal(t, map[int]optimizer.ExitCode{3: {Code: "no_solution"}}, a.ExitCodes)
+		assert.Equal(t, optimizer.OutputContract{Dir: "output", Files: []optimizer.OutputFile{}}, a.Outputs)
+
+		greedy, err := registry.Get("rack-greedy@1.2")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Second, greedy.Timeout)
+		assert.Equal(t, config.RunnerPython, greedy.RunnerType)
+		assert.Equal(t, map[string]interface{}{"cost": 1}, greedy.Parameters[0].Default)
+		assert.Empty(t, a.Parameters)
+		assert.Equal(t, optimizer.InputSchema{{Name: "racks", Required: true, EqualRows: true, CSV: &tabular.Schema{
+			Delimiter: ';', Columns: []tabular.Column{{Name: "id", Type: "integer"}}, MinRows: 1}}}, a.Inputs)
+		assert.Empty(t, greedy.Inputs)
+	})
+
+	t.Run("csv delimiter", func(t *testing.T) {
+		assert.Equal(t, '\t', csvSchema(&config.CSVSchema{Delimiter: "\t"}).Delimiter)
+		assert.Error(t, csvSchema(&config.CSVSchema{Delimiter: ";;"}).Check())
+		assert.Nil(t, csvSchema(nil))
+	})
+
+	t.Run("unknown default", func(t *testing.T) {
+		cfg.Algorithms.Default = "rack-tabu"
+		registry := s.newRegistry(time.Second, nil)
+		assert.Equal(t, "rack-greedy@1.2", registry.Default().ID())
+	})
+}
+
+func TestServer_LongRun(t *testing.T) {
+	// the run lasts longer than the timeout of the other handlers
+	opt := optimizerFunc(func(ctx context.Context, req optimizer.Request) (*optimizer.Result, error) {
+		time.Sleep(300 * time.Millisecond)
+		return &optimizer.Result{Filename: "opt_result_1.tar.gz"}, nil
+	})
+	s := &Server{config: &config.Config{}, optimizer: opt, progress: newRunProgress(),
+		handlerTimeout: 100 * time.Millisecond, logger: logger.NewTestLogger()}
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config = s.newHTTPServer()
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/v1/optimize", "application/json", strings.NewReader(`{"filename":"input_files_1.tar.gz"}`))
+	if assert.NoError(t, err) {
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	assert.Zero(t, srv.Config.WriteTimeout)
+}
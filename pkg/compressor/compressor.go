@@ -1,44 +1,156 @@
 package compressor
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
-	"os/exec"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 var (
 	ErrEmptyPath       = fmt.Errorf("path can't be empty")
 	ErrEmptyFolderPath = fmt.Errorf("path to folder can't be empty")
 	ErrNoFiles         = fmt.Errorf("no files were provided")
+
+	// ErrFormat is returned when the archive can't be read
+	ErrFormat = errors.New("invalid archive format")
+	// ErrUnsafePath is returned for entries which are absolute or point outside of the target folder
+	ErrUnsafePath = errors.New("archive entry path escapes the target folder")
+	// ErrUnsupportedEntry is returned for symlinks, hard links, devices and other non-regular entries
+	ErrUnsupportedEntry = errors.New("archive entry is not a regular file")
+	// ErrTooManyEntries is returned when the archive has more entries than Limits.MaxEntries
+	ErrTooManyEntries = errors.New("archive has too many entries")
+	// ErrFileTooLarge is returned when an entry is bigger than Limits.MaxFileSize
+	ErrFileTooLarge = errors.New("archive entry is too large")
+	// ErrArchiveTooLarge is returned when the uncompressed archive is bigger than Limits.MaxTotalSize
+	ErrArchiveTooLarge = errors.New("uncompressed archive is too large")
 )
 
-// Compress function compresses input files using system tar and gzip.
+// EntryError reports the archive entry which caused the failure
+type EntryError struct {
+	Name string
+	Err  error
+}
+
+func (e *EntryError) Error() string {
+	return fmt.Sprintf("archive entry '%s': %s", e.Name, e.Err)
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// IsInvalidArchive reports whether the archive was rejected because of its content
+// rather than because of an I/O failure
+func IsInvalidArchive(err error) bool {
+	for _, target := range []error{ErrFormat, ErrUnknownFormat, ErrUnsafePath, ErrUnsupportedEntry, ErrTooManyEntries, ErrFileTooLarge, ErrArchiveTooLarge} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Limits protect against decompression bombs. Zero values disable the corresponding limit.
+type Limits struct {
+	MaxEntries   int
+	MaxFileSize  int64
+	MaxTotalSize int64
+}
+
+// DefaultLimits are applied by Decompress and Extract unless WithLimits is given
+var DefaultLimits = Limits{
+	MaxEntries:   1000,
+	MaxFileSize:  512 << 20, // 512 MB
+	MaxTotalSize: 2 << 30,   // 2 GB
+}
+
+type options struct {
+	limits Limits
+}
+
+// Option configures Decompress and Extract
+type Option func(*options)
+
+// WithLimits overrides DefaultLimits
+func WithLimits(limits Limits) Option {
+	return func(o *options) {
+		o.limits = limits
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{limits: DefaultLimits}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Compress function compresses input files into a gzip compressed tar archive.
 // path sets the archive name without '.tar.gz' suffix.
 // workDir sets the working directory.
 // filenames set the names to be compressed. Can't be empty.
 // Function returns the name of the created archive by adding 'tar.gz' suffix to the provided path and an error message.
 func Compress(ctx context.Context, path string, workDir string, filenames ...string) (archPath string, err error) {
+	return CompressFormat(ctx, TarGz, path, workDir, filenames...)
+}
+
+// CompressFormat works like Compress but creates an archive in the given format.
+// The archive name is the provided path with the format extension appended.
+func CompressFormat(ctx context.Context, format Format, path string, workDir string, filenames ...string) (archPath string, err error) {
 	if path == "" {
 		return "", ErrEmptyPath
 	}
 	if len(filenames) == 0 {
 		return "", ErrNoFiles
 	}
-	archPath = fmt.Sprintf("%s.tar.gz", path)
+	archPath = path + format.Ext()
+
+	entries := make([]Entry, 0, len(filenames))
+	for _, filename := range filenames {
+		if !isLocalPath(filename) {
+			return "", &EntryError{Name: filename, Err: ErrUnsafePath}
+		}
+		entry, err := FileEntry(workDir, filename)
+		if err != nil {
+			return "", fmt.Errorf("error compressing files: '%w'", err)
+		}
+		entries = append(entries, entry)
+	}
 
-	params := []string{"-C", workDir, "-czf", archPath}
-	params = append(params, filenames...)
-	cmd := exec.CommandContext(ctx, "tar", params...)
-	if _, err := cmd.Output(); err != nil {
+	file, err := os.Create(archPath)
+	if err != nil {
+		return "", fmt.Errorf("error compressing files: '%w'", err)
+	}
+	err = Stream(ctx, file, format, entries...)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		if rerr := os.Remove(archPath); rerr != nil {
+			err = fmt.Errorf("%w, error removing partial archive: %s", err, rerr)
+		}
 		return "", fmt.Errorf("error compressing files: '%w'", err)
 	}
 	return archPath, nil
 }
 
-// Decompress function decompresses the archive into the provided directory using system tar and gzip.
+// Decompress function decompresses the archive into the provided directory.
+// The archive format is detected from its content.
 // path sets the archive name.
-// folder sets folder in which the archive will be decompressed. Can't be empty.
-func Decompress(ctx context.Context, path string, folder string) error {
+// folder sets folder in which the archive will be decompressed. Can't be empty and must exist.
+func Decompress(ctx context.Context, path string, folder string, opts ...Option) error {
 	if path == "" {
 		return ErrEmptyPath
 	}
@@ -46,10 +158,292 @@ func Decompress(ctx context.Context, path string, folder string) error {
 		return ErrEmptyFolderPath
 	}
 
-	params := []string{"-xzf", path, "-C", folder}
-	cmd := exec.CommandContext(ctx, "tar", params...)
-	if out, err := cmd.Output(); err != nil {
-		return fmt.Errorf("error decompressing files: '%w', output: '%s'", err, out)
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error decompressing files: '%w'", err)
+	}
+	defer file.Close()
+
+	return Extract(ctx, file, folder, opts...)
+}
+
+// Extract reads an archive from r and writes its files into folder. The archive format is detected from its content.
+// Only regular files and directories are accepted, every entry must stay inside the folder.
+// Zip archives are spilled to a temporary file unless r is an *os.File.
+func Extract(ctx context.Context, r io.Reader, folder string, opts ...Option) error {
+	if folder == "" {
+		return ErrEmptyFolderPath
+	}
+	o := newOptions(opts)
+
+	root, err := filepath.Abs(folder)
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(root); err != nil {
+		return fmt.Errorf("error decompressing files: '%w'", err)
+	} else if !fi.IsDir() {
+		return fmt.Errorf("error decompressing files: '%s' is not a directory", folder)
+	}
+
+	return (&extractor{root: root, limits: o.limits}).extract(ctx, r)
+}
+
+// WalkFunc is called by Walk with the name and the content of a file of the archive
+type WalkFunc func(name string, r io.Reader) error
+
+// Walk reads an archive from r and calls fn for every regular file in the archive order without writing
+// the files to disk. The limits and the entry checks of Extract apply, an error returned by fn stops the walk
+// and is returned as is. Zip archives are spilled to a temporary file unless r is an *os.File.
+func Walk(ctx context.Context, r io.Reader, fn WalkFunc, opts ...Option) error {
+	o := newOptions(opts)
+	return (&extractor{root: string(filepath.Separator), limits: o.limits, visit: fn}).extract(ctx, r)
+}
+
+func (x *extractor) extract(ctx context.Context, r io.Reader) error {
+	format, br, err := Detect(r)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case TarGz:
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrFormat, err)
+		}
+		defer gr.Close()
+		return x.tar(ctx, gr)
+	case TarZst:
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrFormat, err)
+		}
+		defer zr.Close()
+		return x.tar(ctx, zr)
+	case Tar:
+		return x.tar(ctx, br)
+	case Zip:
+		if file, ok := r.(*os.File); ok {
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			return x.zipFile(ctx, file)
+		}
+		return x.zipStream(ctx, br)
+	}
+	return ErrUnknownFormat
+}
+
+// extractor writes archive entries into root and keeps track of the limits.
+// If visit is set the files are passed to it instead and nothing is written.
+type extractor struct {
+	root    string
+	limits  Limits
+	visit   WalkFunc
+	entries int
+	total   int64
+}
+
+func (x *extractor) tar(ctx context.Context, r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrFormat, err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeDir:
+			err = x.dir(header.Name)
+		case tar.TypeReg, tar.TypeRegA:
+			err = x.file(header.Name, header.Size, tr)
+		default:
+			err = x.reject(header.Name, ErrUnsupportedEntry)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (x *extractor) zipFile(ctx context.Context, file *os.File) error {
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(file, fi.Size())
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrFormat, err)
+	}
+
+	for _, f := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := x.zipEntry(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zipStream copies a zip archive into a temporary file, zip needs random access to its central directory
+func (x *extractor) zipStream(ctx context.Context, r io.Reader) error {
+	tmp, err := ioutil.TempFile("", "extract_*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	src := r
+	if x.limits.MaxTotalSize > 0 {
+		src = io.LimitReader(r, x.limits.MaxTotalSize+1)
+	}
+	n, err := io.Copy(tmp, src)
+	if err != nil {
+		return err
+	}
+	if x.limits.MaxTotalSize > 0 && n > x.limits.MaxTotalSize {
+		return ErrArchiveTooLarge
+	}
+	return x.zipFile(ctx, tmp)
+}
+
+func (x *extractor) zipEntry(f *zip.File) error {
+	mode := f.Mode()
+	switch {
+	case mode.IsDir():
+		return x.dir(f.Name)
+	case mode.IsRegular():
+		if f.UncompressedSize64 > uint64(math.MaxInt64) {
+			return x.reject(f.Name, ErrFileTooLarge)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return &EntryError{Name: f.Name, Err: fmt.Errorf("%w: %s", ErrFormat, err)}
+		}
+		defer rc.Close()
+		return x.file(f.Name, int64(f.UncompressedSize64), rc)
+	}
+	return x.reject(f.Name, ErrUnsupportedEntry)
+}
+
+func (x *extractor) reject(name string, err error) error {
+	return &EntryError{Name: name, Err: err}
+}
+
+func (x *extractor) count(name string) (string, error) {
+	x.entries++
+	if x.limits.MaxEntries > 0 && x.entries > x.limits.MaxEntries {
+		return "", x.reject(name, ErrTooManyEntries)
+	}
+	target, err := entryPath(x.root, name)
+	if err != nil {
+		return "", x.reject(name, err)
+	}
+	return target, nil
+}
+
+func (x *extractor) dir(name string) error {
+	target, err := x.count(name)
+	if err != nil || x.visit != nil {
+		return err
+	}
+	if err := os.MkdirAll(target, os.ModePerm); err != nil {
+		return x.reject(name, err)
+	}
+	return nil
+}
+
+// file writes at most size bytes of the entry, the declared size is checked against the limits before writing
+func (x *extractor) file(name string, size int64, r io.Reader) error {
+	target, err := x.count(name)
+	if err != nil {
+		return err
+	}
+	if x.limits.MaxFileSize > 0 && size > x.limits.MaxFileSize {
+		return x.reject(name, ErrFileTooLarge)
+	}
+	if x.limits.MaxTotalSize > 0 && x.total+size > x.limits.MaxTotalSize {
+		return x.reject(name, ErrArchiveTooLarge)
+	}
+	if x.visit != nil {
+		counter := &countingReader{r: io.LimitReader(r, size)}
+		rel, err := filepath.Rel(x.root, target)
+		if err != nil {
+			return x.reject(name, err)
+		}
+		err = x.visit(filepath.ToSlash(rel), counter)
+		x.total += counter.n
+		return err
+	}
+	written, err := writeFile(target, r, size)
+	x.total += written
+	if err != nil {
+		return x.reject(name, err)
 	}
 	return nil
 }
+
+// entryPath resolves the entry name inside root and rejects names which escape it
+func entryPath(root string, name string) (string, error) {
+	if !isLocalPath(name) {
+		return "", ErrUnsafePath
+	}
+	target := filepath.Join(root, filepath.FromSlash(name))
+	if target != root && !strings.HasPrefix(target, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator)) {
+		return "", ErrUnsafePath
+	}
+	return target, nil
+}
+
+// isLocalPath reports whether name is a relative path which does not climb up the tree
+func isLocalPath(name string) bool {
+	if name == "" || filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) || filepath.VolumeName(name) != "" {
+		return false
+	}
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+func writeFile(target string, r io.Reader, size int64) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return 0, err
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(file, io.LimitReader(r, size))
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return written, err
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -1,9 +1,18 @@
 package compressor
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
+	"io"
+	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -92,3 +101,274 @@ func compress(t *testing.T) string {
 	assert.Equal(t, name, path.Join(tmpFolder, "arch.tar.gz"))
 	return name
 }
+
+func TestStream_Decompress(t *testing.T) {
+	assert.NoError(t, os.Mkdir(tmpFolder, os.ModePerm))
+	defer func() { assert.NoError(t, os.RemoveAll(tmpFolder)) }()
+
+	entry1, err := FileEntry("test_files", "file1.csv")
+	assert.NoError(t, err)
+	entry2, err := FileEntry("test_files", "file2.csv")
+	assert.NoError(t, err)
+
+	name := archPath + TarGz.Ext()
+	file, err := os.Create(name)
+	assert.NoError(t, err)
+	r := Reader(context.Background(), TarGz, entry1, entry2)
+	_, err = io.Copy(file, r)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	assert.NoError(t, file.Close())
+
+	decFolder := path.Join(tmpFolder, decompressFolder)
+	assert.NoError(t, os.MkdirAll(decFolder, os.ModePerm))
+	assert.NoError(t, Decompress(context.Background(), name, decFolder))
+
+	expected, err := ioutil.ReadFile("test_files/file1.csv")
+	assert.NoError(t, err)
+	actual, err := ioutil.ReadFile(path.Join(decFolder, "file1.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestStream_NoFiles(t *testing.T) {
+	err := Stream(context.Background(), ioutil.Discard, TarGz)
+	assert.Equal(t, ErrNoFiles, err)
+}
+
+func TestFileEntry_NotFound(t *testing.T) {
+	_, err := FileEntry("test_files", "missing.csv")
+	assert.Error(t, err)
+}
+
+type testEntry struct {
+	name     string
+	typeflag byte
+	body     string
+}
+
+func buildArchive(t *testing.T, entries ...testEntry) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Typeflag: e.typeflag, Mode: 0644, Size: int64(len(e.body))}
+		if e.typeflag == tar.TypeSymlink {
+			header.Linkname = "/etc/passwd"
+			header.Size = 0
+		}
+		if e.typeflag == tar.TypeDir {
+			header.Size = 0
+		}
+		assert.NoError(t, tw.WriteHeader(header))
+		if header.Size > 0 {
+			_, err := tw.Write([]byte(e.body))
+			assert.NoError(t, err)
+		}
+	}
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gw.Close())
+	return buf
+}
+
+func TestExtract(t *testing.T) {
+	testCases := []struct {
+		name    string
+		entries []testEntry
+		limits  Limits
+		err     error
+		files   []string
+	}{
+		{
+			name:    "regular files and directories",
+			entries: []testEntry{{"dir", tar.TypeDir, ""}, {"dir/a.csv", tar.TypeReg, "a"}, {"b.csv", tar.TypeReg, "b"}},
+			limits:  DefaultLimits,
+			files:   []string{"dir/a.csv", "b.csv"},
+		},
+		{
+			name:    "parent directory",
+			entries: []testEntry{{"../evil.csv", tar.TypeReg, "x"}},
+			limits:  DefaultLimits,
+			err:     ErrUnsafePath,
+		},
+		{
+			name:    "nested parent directory",
+			entries: []testEntry{{"dir/../../evil.csv", tar.TypeReg, "x"}},
+			limits:  DefaultLimits,
+			err:     ErrUnsafePath,
+		},
+		{
+			name:    "absolute path",
+			entries: []testEntry{{"/tmp/evil.csv", tar.TypeReg, "x"}},
+			limits:  DefaultLimits,
+			err:     ErrUnsafePath,
+		},
+		{
+			name:    "symlink",
+			entries: []testEntry{{"link", tar.TypeSymlink, ""}},
+			limits:  DefaultLimits,
+			err:     ErrUnsupportedEntry,
+		},
+		{
+			name:    "too many entries",
+			entries: []testEntry{{"a", tar.TypeReg, "a"}, {"b", tar.TypeReg, "b"}},
+			limits:  Limits{MaxEntries: 1},
+			err:     ErrTooManyEntries,
+		},
+		{
+			name:    "file too large",
+			entries: []testEntry{{"a", tar.TypeReg, "12345"}},
+			limits:  Limits{MaxFileSize: 4},
+			err:     ErrFileTooLarge,
+		},
+		{
+			name:    "archive too large",
+			entries: []testEntry{{"a", tar.TypeReg, "123"}, {"b", tar.TypeReg, "456"}},
+			limits:  Limits{MaxTotalSize: 5},
+			err:     ErrArchiveTooLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			folder := t.TempDir()
+			err := Extract(context.Background(), buildArchive(t, tc.entries...), folder, WithLimits(tc.limits))
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				assert.True(t, IsInvalidArchive(err))
+				var entryErr *EntryError
+				assert.True(t, errors.As(err, &entryErr))
+				return
+			}
+			assert.NoError(t, err)
+			for _, f := range tc.files {
+				assert.FileExists(t, filepath.Join(folder, f))
+			}
+		})
+	}
+}
+
+func TestExtract_InvalidFormat(t *testing.T) {
+	err := Extract(context.Background(), strings.NewReader("not an archive"), t.TempDir())
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+	assert.True(t, IsInvalidArchive(err))
+
+	err = Extract(context.Background(), bytes.NewReader([]byte{0x1f, 0x8b, 0, 0}), t.TempDir())
+	assert.ErrorIs(t, err, ErrFormat)
+}
+
+func TestCompress_UnsafePath(t *testing.T) {
+	_, err := Compress(context.Background(), archPath, "test_files", "../compressor.go")
+	assert.ErrorIs(t, err, ErrUnsafePath)
+}
+
+func TestFormats_RoundTrip(t *testing.T) {
+	expected, err := ioutil.ReadFile("test_files/file2.csv")
+	assert.NoError(t, err)
+
+	for _, format := range Formats {
+		t.Run(string(format), func(t *testing.T) {
+			dir := t.TempDir()
+			name, err := CompressFormat(context.Background(), format, filepath.Join(dir, "arch"), "test_files", "file1.csv", "file2.csv")
+			assert.NoError(t, err)
+			assert.Equal(t, filepath.Join(dir, "arch"+format.Ext()), name)
+
+			file, err := os.Open(name)
+			assert.NoError(t, err)
+			detected, _, err := Detect(file)
+			assert.NoError(t, err)
+			assert.Equal(t, format, detected)
+			assert.NoError(t, file.Close())
+
+			// extract from the file and from a plain stream
+			for _, extract := range []func(folder string) error{
+				func(folder string) error { return Decompress(context.Background(), name, folder) },
+				func(folder string) error {
+					data, err := ioutil.ReadFile(name)
+					assert.NoError(t, err)
+					return Extract(context.Background(), bytes.NewReader(data), folder)
+				},
+			} {
+				folder := t.TempDir()
+				assert.NoError(t, extract(folder))
+				actual, err := ioutil.ReadFile(filepath.Join(folder, "file2.csv"))
+				assert.NoError(t, err)
+				assert.Equal(t, expected, actual)
+			}
+		})
+	}
+}
+
+func TestExtract_ZipUnsafe(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	_, err := zw.Create("../evil.csv")
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+
+	err = Extract(context.Background(), buf, t.TempDir())
+	assert.ErrorIs(t, err, ErrUnsafePath)
+
+	buf = &bytes.Buffer{}
+	zw = zip.NewWriter(buf)
+	header := &zip.FileHeader{Name: "link"}
+	header.SetMode(os.ModeSymlink | 0777)
+	w, err := zw.CreateHeader(header)
+	assert.NoError(t, err)
+	_, err = w.Write([]byte("/etc/passwd"))
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+
+	err = Extract(context.Background(), buf, t.TempDir())
+	assert.ErrorIs(t, err, ErrUnsupportedEntry)
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, expected := range map[string]Format{"zip": Zip, ".tar.gz": TarGz, "TGZ": TarGz, "tar.zst": TarZst, "tar": Tar} {
+		format, err := ParseFormat(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, format)
+	}
+	_, err := ParseFormat("rar")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	format, ok := FormatFromName("input.TAR.ZST")
+	assert.True(t, ok)
+	assert.Equal(t, TarZst, format)
+	_, ok = FormatFromName("file.csv")
+	assert.False(t, ok)
+}
+
+func TestWalk(t *testing.T) {
+	archive := buildArchive(t, testEntry{"dir", tar.TypeDir, ""}, testEntry{"dir/a.csv", tar.TypeReg, "aaa"}, testEntry{"./b.csv", tar.TypeReg, "b"})
+
+	files := map[string]string{}
+	err := Walk(context.Background(), archive, func(name string, r io.Reader) error {
+		data, err := ioutil.ReadAll(r)
+		files[name] = string(data)
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"dir/a.csv": "aaa", "b.csv": "b"}, files)
+
+	stop := errors.New("stop")
+	calls := 0
+	err = Walk(context.Background(), buildArchive(t, testEntry{"a", tar.TypeReg, "a"}, testEntry{"b", tar.TypeReg, "b"}), func(name string, r io.Reader) error {
+		calls++
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, calls)
+
+	err = Walk(context.Background(), buildArchive(t, testEntry{"../evil.csv", tar.TypeReg, "x"}), func(name string, r io.Reader) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrUnsafePath)
+
+	// the bytes the callback reads count towards the total size
+	err = Walk(context.Background(), buildArchive(t, testEntry{"a", tar.TypeReg, "123"}, testEntry{"b", tar.TypeReg, "456"}), func(name string, r io.Reader) error {
+		_, err := io.Copy(ioutil.Discard, r)
+		return err
+	}, WithLimits(Limits{MaxTotalSize: 5}))
+	assert.ErrorIs(t, err, ErrArchiveTooLarge)
+}
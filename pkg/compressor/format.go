@@ -0,0 +1,110 @@
+package compressor
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is an archive format supported by the package
+type Format string
+
+const (
+	TarGz  Format = "tar.gz"
+	TarZst Format = "tar.zst"
+	Tar    Format = "tar"
+	Zip    Format = "zip"
+)
+
+// ErrUnknownFormat is returned for format names and archives which are not supported
+var ErrUnknownFormat = errors.New("unknown archive format")
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	zipMagic  = []byte("PK\x03\x04")
+	// zipEmptyMagic starts the end of central directory record of an archive without entries
+	zipEmptyMagic = []byte("PK\x05\x06")
+	tarMagic      = []byte("ustar")
+)
+
+const (
+	tarMagicOffset = 257
+	sniffLen       = tarMagicOffset + 5
+)
+
+// Formats lists the supported formats, the longest extensions go first
+var Formats = []Format{TarGz, TarZst, Tar, Zip}
+
+// Ext returns the file extension including the leading dot
+func (f Format) Ext() string {
+	return "." + string(f)
+}
+
+// ContentType returns the media type of the archive
+func (f Format) ContentType() string {
+	switch f {
+	case TarGz:
+		return "application/gzip"
+	case TarZst:
+		return "application/zstd"
+	case Tar:
+		return "application/x-tar"
+	case Zip:
+		return "application/zip"
+	}
+	return "application/octet-stream"
+}
+
+// ParseFormat returns the format with the given name. The name may be given with a leading dot.
+func ParseFormat(name string) (Format, error) {
+	name = strings.TrimPrefix(strings.ToLower(name), ".")
+	switch name {
+	case "tgz":
+		return TarGz, nil
+	case "tzst":
+		return TarZst, nil
+	}
+	for _, f := range Formats {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("%w: '%s'", ErrUnknownFormat, name)
+}
+
+// FormatFromName returns the format matching the extension of filename
+func FormatFromName(filename string) (Format, bool) {
+	lower := strings.ToLower(filename)
+	for _, f := range Formats {
+		if strings.HasSuffix(lower, f.Ext()) {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// Detect sniffs the archive format from the magic bytes at the beginning of r.
+// The returned reader yields the whole stream including the sniffed bytes.
+func Detect(r io.Reader) (Format, io.Reader, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	header, err := br.Peek(sniffLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", br, err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return TarGz, br, nil
+	case bytes.HasPrefix(header, zstdMagic):
+		return TarZst, br, nil
+	case bytes.HasPrefix(header, zipMagic), bytes.HasPrefix(header, zipEmptyMagic):
+		return Zip, br, nil
+	case len(header) >= sniffLen && bytes.Equal(header[tarMagicOffset:sniffLen], tarMagic):
+		return Tar, br, nil
+	}
+	return "", br, ErrUnknownFormat
+}
@@ -0,0 +1,148 @@
+package compressor
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Entry describes a file added to a streamed archive.
+// Size must match the number of bytes produced by the reader returned from Open.
+type Entry struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	Open    func() (io.ReadCloser, error)
+}
+
+// FileEntry creates an archive entry for the file workDir/name
+func FileEntry(workDir string, name string) (Entry, error) {
+	path := filepath.Join(workDir, name)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	if !fi.Mode().IsRegular() {
+		return Entry{}, fmt.Errorf("%s is not a regular file", path)
+	}
+	return Entry{
+		Name:    filepath.ToSlash(name),
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+		Open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+	}, nil
+}
+
+// Stream writes an archive of the entries in the given format into w
+func Stream(ctx context.Context, w io.Writer, format Format, entries ...Entry) error {
+	if len(entries) == 0 {
+		return ErrNoFiles
+	}
+
+	switch format {
+	case TarGz:
+		gw := gzip.NewWriter(w)
+		if err := writeTar(ctx, gw, entries); err != nil {
+			return err
+		}
+		return gw.Close()
+	case TarZst:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		if err := writeTar(ctx, zw, entries); err != nil {
+			zw.Close()
+			return err
+		}
+		return zw.Close()
+	case Tar:
+		return writeTar(ctx, w, entries)
+	case Zip:
+		return writeZip(ctx, w, entries)
+	}
+	return fmt.Errorf("%w: '%s'", ErrUnknownFormat, format)
+}
+
+// Reader returns the archive of the entries as a stream. The archive is produced in the background
+// while the stream is read, the caller must close the reader to release it.
+func Reader(ctx context.Context, format Format, entries ...Entry) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(Stream(ctx, pw, format, entries...))
+	}()
+	return pr
+}
+
+func writeTar(ctx context.Context, w io.Writer, entries []Entry) error {
+	tw := tar.NewWriter(w)
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     entry.Name,
+			Size:     entry.Size,
+			Mode:     0644,
+			ModTime:  modTime(entry),
+		}); err != nil {
+			return fmt.Errorf("error compressing '%s': '%w'", entry.Name, err)
+		}
+		if err := copyEntry(tw, entry); err != nil {
+			return fmt.Errorf("error compressing '%s': '%w'", entry.Name, err)
+		}
+	}
+	return tw.Close()
+}
+
+func writeZip(ctx context.Context, w io.Writer, entries []Entry) error {
+	zw := zip.NewWriter(w)
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		header := &zip.FileHeader{
+			Name:     entry.Name,
+			Method:   zip.Deflate,
+			Modified: modTime(entry),
+		}
+		header.SetMode(0644)
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("error compressing '%s': '%w'", entry.Name, err)
+		}
+		if err := copyEntry(fw, entry); err != nil {
+			return fmt.Errorf("error compressing '%s': '%w'", entry.Name, err)
+		}
+	}
+	return zw.Close()
+}
+
+func copyEntry(w io.Writer, entry Entry) error {
+	r, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	_, err = io.Copy(w, r)
+	return err
+}
+
+func modTime(entry Entry) time.Time {
+	if entry.ModTime.IsZero() {
+		return time.Now()
+	}
+	return entry.ModTime
+}
@@ -1,14 +1,20 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/cxrdevelop/optimization_engine/pkg/logger"
 )
 
+const fsTempPattern = ".put_*"
+
 type FSStorage struct {
 	bucket string
 	log    *logger.Logger
@@ -30,105 +36,186 @@ func NewFSStorage(bucket string, log *logger.Logger) *FSStorage {
 	}
 }
 
-// DownloadFiles function takes S3 bucket name and a remote filename.
-// It creates a new file with the same name in the workDir. If download fails the file will be empty.
-func (s *FSStorage) DownloadFiles(dir string, paths ...string) error {
-	for _, path := range paths {
-		if err := s.download(dir, path, path); err != nil {
-			return err
-		}
+// Get opens the file stored under the key. The returned reader is an *os.File.
+func (s *FSStorage) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	if err := validateKey(key); err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, ObjectInfo{}, err
 	}
-	return nil
-}
 
-// UploadFiles function takes dir name and a local filename.
-// It takes a file from the workDir and uploads it to the bucket with the same name.
-func (s *FSStorage) UploadFiles(dir string, paths ...string) ([]UploadResult, error) {
-	res := make([]UploadResult, 0, len(paths))
-	for _, path := range paths {
-		if r, err := s.upload(dir, path, path); err != nil {
-			return nil, err
-		} else {
-			res = append(res, *r)
+	path := s.path(key)
+	s.log.Debugf("Opening file '%s'", path)
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = ErrNotFound
 		}
+		return nil, ObjectInfo{}, fmt.Errorf("unable to download '%s' from bucket '%s' with '%w'", key, s.bucket, err)
 	}
-	return res, nil
-}
 
-func (s *FSStorage) download(dir string, remoteFilename string, localFilename string) error {
-	if localFilename == "" {
-		localFilename = remoteFilename
+	fi, err := file.Stat()
+	if err != nil {
+		closeWithLog(file, s.log)
+		return nil, ObjectInfo{}, fmt.Errorf("unable to stat '%s' in bucket '%s' with '%w'", key, s.bucket, err)
 	}
-	src := getFilePath(s.bucket, remoteFilename)
-	dst := getFilePath(dir, localFilename)
-	s.log.Debugf("Downloading file '%s' to '%s", src, dst)
-
-	if err := s.copyFile(src, dst); err != nil {
-		return fmt.Errorf("unable to download '%s' from bucket '%s' with '%w'", remoteFilename, s.bucket, err)
+	if !fi.Mode().IsRegular() {
+		closeWithLog(file, s.log)
+		return nil, ObjectInfo{}, fmt.Errorf("%s is not a regular file", path)
 	}
 
-	return nil
+	return file, ObjectInfo{
+		Key:          key,
+		Size:         fi.Size(),
+		LastModified: fi.ModTime(),
+	}, nil
 }
 
-func (s *FSStorage) upload(dir string, localFilename string, remoteFilename string) (*UploadResult, error) {
-	if remoteFilename == "" {
-		remoteFilename = localFilename
+// Put writes r into a temporary file inside the bucket and renames it to the key once everything is written.
+// Readers never observe a partially written object.
+func (s *FSStorage) Put(ctx context.Context, key string, r io.Reader, opts PutOptions) (UploadResult, error) {
+	if err := validateKey(key); err != nil {
+		return UploadResult{}, err
 	}
-	path := getFilePath(dir, localFilename)
-	s.log.Debugf("Open file '%s'", path)
 
-	file, err := os.Open(path)
-	if err != nil {
-		s.log.Debugf("failed to open file '%s', error: '%s", path, err)
-		return nil, fmt.Errorf("failed to open file '%s', error: '%w", path, err)
+	path := s.path(key)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return UploadResult{}, fmt.Errorf("unable to create directory '%s' in local bucket '%s', error: '%w'", dir, s.bucket, err)
 	}
-	defer s.closeFile(file)
 
-	s.log.Debugf("Uploading file '%s' to the bucket...", remoteFilename)
+	tmp, err := ioutil.TempFile(dir, fsTempPattern)
+	if err != nil {
+		return UploadResult{}, fmt.Errorf("unable to create a temporary file in local bucket '%s', error: '%w'", s.bucket, err)
+	}
+	defer func() {
+		if err := os.Remove(tmp.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.log.Warnf("error removing temporary file '%s': %s", tmp.Name(), err)
+		}
+	}()
 
-	tmp := getFilePath(s.bucket, remoteFilename)
-	if err := s.copyFile(path, tmp); err != nil {
-		return nil, fmt.Errorf("unable to upload local file '%s' to local bucket '%s', remote filename: '%s', error: '%w'", localFilename, s.bucket, remoteFilename, err)
+	s.log.Debugf("Uploading '%s' to the bucket...", key)
+	if _, err := io.Copy(tmp, &contextReader{ctx: ctx, r: r}); err != nil {
+		closeWithLog(tmp, s.log)
+		return UploadResult{}, fmt.Errorf("unable to upload '%s' to local bucket '%s', error: '%w'", key, s.bucket, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return UploadResult{}, fmt.Errorf("unable to upload '%s' to local bucket '%s', error: '%w'", key, s.bucket, err)
 	}
-	location, err := filepath.Abs(tmp)
+	if opts.IfNotExists {
+		// unlike a rename, a link fails if the key exists, the temporary file is removed by the deferred call
+		if err := os.Link(tmp.Name(), path); err != nil {
+			if errors.Is(err, os.ErrExist) {
+				err = ErrExists
+			}
+			return UploadResult{}, fmt.Errorf("unable to upload '%s' to local bucket '%s', error: '%w'", key, s.bucket, err)
+		}
+	} else if err := os.Rename(tmp.Name(), path); err != nil {
+		return UploadResult{}, fmt.Errorf("unable to upload '%s' to local bucket '%s', error: '%w'", key, s.bucket, err)
+	}
+
+	location, err := filepath.Abs(path)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get absolute path for filename: '%s', error: '%w'", tmp, err)
+		return UploadResult{}, fmt.Errorf("unable to get absolute path for filename: '%s', error: '%w'", path, err)
 	}
 
-	return &UploadResult{
-		Filename: remoteFilename,
+	return UploadResult{
+		Filename: key,
 		Location: location,
 		ETag:     "",
 	}, nil
 }
 
-func (c *FSStorage) closeFile(file *os.File) {
-	if err := file.Close(); err != nil {
-		c.log.Warnf("error closing file: %s", err)
-	}
-}
+// List walks the bucket folder and returns the files whose keys start with prefix
+func (s *FSStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	objects := make([]ObjectInfo, 0)
+	err := filepath.Walk(s.bucket, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		if ok, _ := filepath.Match(fsTempPattern, fi.Name()); ok {
+			return nil
+		}
 
-func (s *FSStorage) copyFile(src, dst string) error {
-	sourceFileStat, err := os.Stat(src)
+		rel, err := filepath.Rel(s.bucket, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		objects = append(objects, ObjectInfo{
+			Key:          key,
+			Size:         fi.Size(),
+			LastModified: fi.ModTime(),
+		})
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("unable to list local bucket '%s' with '%w'", s.bucket, err)
 	}
+	return objects, nil
+}
 
-	if !sourceFileStat.Mode().IsRegular() {
-		return fmt.Errorf("%s is not a regular file", src)
+// Stat returns the info of the file stored under the key
+func (s *FSStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	if err := validateKey(key); err != nil {
+		return ObjectInfo{}, err
 	}
 
-	source, err := os.Open(src)
+	fi, err := os.Stat(s.path(key))
 	if err != nil {
-		return err
+		if errors.Is(err, os.ErrNotExist) {
+			err = ErrNotFound
+		}
+		return ObjectInfo{}, fmt.Errorf("unable to stat '%s' in bucket '%s' with '%w'", key, s.bucket, err)
+	}
+	if !fi.Mode().IsRegular() {
+		return ObjectInfo{}, fmt.Errorf("unable to stat '%s' in bucket '%s' with '%w'", key, s.bucket, ErrNotFound)
 	}
-	defer s.closeFile(source)
+	return ObjectInfo{
+		Key:          key,
+		Size:         fi.Size(),
+		LastModified: fi.ModTime(),
+	}, nil
+}
 
-	destination, err := os.Create(dst)
-	if err != nil {
+// Delete removes the file stored under the key
+func (s *FSStorage) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
 		return err
 	}
-	defer s.closeFile(destination)
-	_, err = io.Copy(destination, source)
-	return err
+
+	if err := os.Remove(s.path(key)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = ErrNotFound
+		}
+		return fmt.Errorf("unable to delete '%s' from bucket '%s' with '%w'", key, s.bucket, err)
+	}
+	return nil
+}
+
+// DownloadFiles function takes a local dir name and remote filenames.
+// It creates a new file with the same name in the dir. The download stops at the first file which fails,
+// its error is returned and no partial file is left for it.
+func (s *FSStorage) DownloadFiles(dir string, paths ...string) error {
+	return downloadFiles(context.Background(), s, s.log, dir, paths...)
+}
+
+// UploadFiles function takes dir name and a local filename.
+// It takes a file from the workDir and uploads it to the bucket with the same name.
+func (s *FSStorage) UploadFiles(dir string, paths ...string) ([]UploadResult, error) {
+	return uploadFiles(context.Background(), s, s.log, dir, paths...)
+}
+
+func (s *FSStorage) path(key string) string {
+	return filepath.Join(s.bucket, filepath.FromSlash(key))
 }
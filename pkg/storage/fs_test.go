@@ -1,9 +1,13 @@
 package storage
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/cxrdevelop/optimization_engine/pkg/logger"
@@ -118,12 +122,124 @@ func TestFSStorage_DownloadFails(t *testing.T) {
 	assert.NotPanics(t, func() { mock = NewFSStorage(bucket, logger.NewTestLogger()) })
 	err := mock.DownloadFiles("", "1")
 	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrNotFound)
 
 	assert.NotPanics(t, func() { mock = NewFSStorage(bucket, logger.NewTestLogger()) })
 	err = mock.DownloadFiles("", "")
 	assert.Error(t, err)
 }
 
+func TestFSStorage_PutGet(t *testing.T) {
+	createTestBucket(t)
+	defer removeTestBucket(t)
+
+	fs := NewFSStorage(bucket, logger.NewTestLogger())
+	res, err := fs.Put(context.Background(), "nested/key.txt", strings.NewReader("payload"), PutOptions{ContentType: "text/plain"})
+	assert.NoError(t, err)
+	assert.Equal(t, "nested/key.txt", res.Filename)
+	assert.True(t, filepath.IsAbs(res.Location))
+
+	body, info, err := fs.Get(context.Background(), "nested/key.txt")
+	assert.NoError(t, err)
+	defer body.Close()
+	assert.Equal(t, int64(len("payload")), info.Size)
+	assert.Equal(t, "nested/key.txt", info.Key)
+	assert.Implements(t, (*io.Seeker)(nil), body)
+
+	data, err := ioutil.ReadAll(body)
+	assert.NoError(t, err)
+	assert.Equal(t, "payload", string(data))
+
+	// no temporary files are left in the bucket
+	entries, err := ioutil.ReadDir(filepath.Join(bucket, "nested"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestFSStorage_PutIfNotExists(t *testing.T) {
+	createTestBucket(t)
+	defer removeTestBucket(t)
+
+	fs := NewFSStorage(bucket, logger.NewTestLogger())
+	_, err := fs.Put(context.Background(), "manifest.json", strings.NewReader("first"), PutOptions{IfNotExists: true})
+	assert.NoError(t, err)
+	_, err = fs.Put(context.Background(), "manifest.json", strings.NewReader("second"), PutOptions{IfNotExists: true})
+	assert.ErrorIs(t, err, ErrExists)
+
+	data, err := ioutil.ReadFile(filepath.Join(bucket, "manifest.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, "first", string(data))
+	entries, err := ioutil.ReadDir(bucket)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files are left in the bucket")
+}
+
+func TestFSStorage_GetNotFound(t *testing.T) {
+	createTestBucket(t)
+	defer removeTestBucket(t)
+
+	fs := NewFSStorage(bucket, logger.NewTestLogger())
+	_, _, err := fs.Get(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFSStorage_InvalidKey(t *testing.T) {
+	createTestBucket(t)
+	defer removeTestBucket(t)
+
+	fs := NewFSStorage(bucket, logger.NewTestLogger())
+	for _, key := range []string{"", "/etc/passwd", "../outside", "a/../../b", "a//b"} {
+		_, _, err := fs.Get(context.Background(), key)
+		assert.ErrorIs(t, err, ErrInvalidKey, key)
+		_, err = fs.Put(context.Background(), key, strings.NewReader(""), PutOptions{})
+		assert.ErrorIs(t, err, ErrInvalidKey, key)
+	}
+}
+
+func TestFSStorage_PutCancelled(t *testing.T) {
+	createTestBucket(t)
+	defer removeTestBucket(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	fs := NewFSStorage(bucket, logger.NewTestLogger())
+	_, err := fs.Put(ctx, "key", strings.NewReader("payload"), PutOptions{})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoFileExists(t, filepath.Join(bucket, "key"))
+}
+
+func TestFSStorage_ListStatDelete(t *testing.T) {
+	createTestBucket(t)
+	defer removeTestBucket(t)
+
+	fs := NewFSStorage(bucket, logger.NewTestLogger())
+	for _, key := range []string{"opt_result_2.tar.gz", "input_files_1.tar.gz", "opt_result_1.tar.gz", "datasets/opt_result_3"} {
+		_, err := fs.Put(context.Background(), key, strings.NewReader(key), PutOptions{})
+		assert.NoError(t, err)
+	}
+
+	objects, err := fs.List(context.Background(), "opt_result_")
+	assert.NoError(t, err)
+	assert.Len(t, objects, 2)
+	assert.Equal(t, "opt_result_1.tar.gz", objects[0].Key)
+	assert.Equal(t, "opt_result_2.tar.gz", objects[1].Key)
+	assert.Equal(t, int64(len("opt_result_1.tar.gz")), objects[0].Size)
+
+	objects, err = fs.List(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Len(t, objects, 4)
+
+	info, err := fs.Stat(context.Background(), "input_files_1.tar.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len("input_files_1.tar.gz")), info.Size)
+
+	assert.NoError(t, fs.Delete(context.Background(), "input_files_1.tar.gz"))
+	_, err = fs.Stat(context.Background(), "input_files_1.tar.gz")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, fs.Delete(context.Background(), "input_files_1.tar.gz"), ErrNotFound)
+}
+
 func removeFile(t *testing.T, path string) {
 	err := os.Remove(path)
 	assert.NoError(t, err)
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/cxrdevelop/optimization_engine/pkg/logger"
+)
+
+// downloadFiles copies the objects into dir, each file gets the name of its key.
+// It stops at the first object which fails, the partial file of that object is removed.
+func downloadFiles(ctx context.Context, s Storage, log *logger.Logger, dir string, keys ...string) error {
+	for _, key := range keys {
+		if err := downloadFile(ctx, s, log, dir, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func downloadFile(ctx context.Context, s Storage, log *logger.Logger, dir string, key string) error {
+	body, _, err := s.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer closeWithLog(body, log)
+
+	dst := getFilePath(dir, key)
+	log.Debugf("Downloading '%s' to '%s'", key, dst)
+	file, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create file '%s', error: '%w'", dst, err)
+	}
+
+	_, err = io.Copy(file, body)
+	closeWithLog(file, log)
+	if err != nil {
+		if rerr := os.Remove(dst); rerr != nil {
+			log.Warnf("error removing partial file '%s': %s", dst, rerr)
+		}
+		return fmt.Errorf("unable to download '%s', error: '%w'", key, err)
+	}
+	return nil
+}
+
+// uploadFiles puts the files from dir into the bucket, each object gets the name of its file
+func uploadFiles(ctx context.Context, s Storage, log *logger.Logger, dir string, paths ...string) ([]UploadResult, error) {
+	res := make([]UploadResult, 0, len(paths))
+	for _, p := range paths {
+		r, err := uploadFile(ctx, s, log, dir, p)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+func uploadFile(ctx context.Context, s Storage, log *logger.Logger, dir string, filename string) (UploadResult, error) {
+	src := getFilePath(dir, filename)
+	log.Debugf("Uploading '%s' to the bucket", src)
+	file, err := os.Open(src)
+	if err != nil {
+		return UploadResult{}, fmt.Errorf("failed to open file '%s', error: '%w'", src, err)
+	}
+	defer closeWithLog(file, log)
+
+	return s.Put(ctx, filename, file, PutOptions{})
+}
+
+// validateKey rejects keys which can't be safely mapped to a path inside the bucket
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("%w: '%s'", ErrInvalidKey, key)
+	}
+	return nil
+}
+
+func closeWithLog(c io.Closer, log *logger.Logger) {
+	if err := c.Close(); err != nil {
+		log.Warnf("error closing file: %s", err)
+	}
+}
+
+func getFilePath(dir, fileName string) string {
+	return fmt.Sprintf("%s/%s", dir, fileName)
+}
+
+// contextReader fails reads once the context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -1,10 +1,16 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
@@ -13,16 +19,15 @@ import (
 )
 
 type S3Storage struct {
-	svc        s3iface.S3API
-	downloader *s3manager.Downloader
-	uploader   *s3manager.Uploader
-	bucket     string
-	log        *logger.Logger
+	svc      s3iface.S3API
+	uploader *s3manager.Uploader
+	bucket   string
+	log      *logger.Logger
 }
 
 var _ Storage = (*S3Storage)(nil)
 
-// New S3Client creates s3 service and downloader or panics on error
+// New S3Client creates s3 service and uploader or panics on error
 func NewS3Storage(region string, bucket string, log *logger.Logger) *S3Storage {
 	// verify aws auth and panic on error
 	if err := verifyEnv("AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"); err != nil {
@@ -38,110 +43,255 @@ func NewS3Storage(region string, bucket string, log *logger.Logger) *S3Storage {
 	rawClient := s3.New(awsSession)
 
 	return &S3Storage{
-		svc:        rawClient,
-		downloader: s3manager.NewDownloaderWithClient(rawClient),
-		uploader:   s3manager.NewUploaderWithClient(rawClient),
-		bucket:     bucket,
-		log:        log,
+		svc:      rawClient,
+		uploader: s3manager.NewUploaderWithClient(rawClient),
+		bucket:   bucket,
+		log:      log,
 	}
 }
 
-// DownloadFiles function takes S3 bucket name and a remote filename.
-// It creates a new file with the same name in the dir. If download fails the file will be empty.
-func (s *S3Storage) DownloadFiles(dir string, paths ...string) error {
-	for _, path := range paths {
-		if err := s.download(dir, path, ""); err != nil {
-			return err
-		}
+// Get requests the object from s3. The returned reader implements io.Seeker, the size is known from the
+// response so seeking doesn't request anything. A read at another position than the open response body
+// closes it and requests the remaining byte range.
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	if err := validateKey(key); err != nil {
+		return nil, ObjectInfo{}, err
 	}
-	return nil
-}
 
-// UploadFiles function takes local dir name and a local file name.
-// It takes a file from the dir and uploads it to the bucket with the same name.
-func (s *S3Storage) UploadFiles(dir string, paths ...string) ([]UploadResult, error) {
-	res := make([]UploadResult, 0, len(paths))
-	for _, path := range paths {
-		if r, err := s.upload(dir, path, ""); err != nil {
-			return nil, err
-		} else {
-			res = append(res, *r)
+	s.log.Debugf("Downloading file '%s' from s3...", key)
+	out, err := s.svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		if isNotFound(err) {
+			err = ErrNotFound
 		}
+		return nil, ObjectInfo{}, fmt.Errorf("unable to download '%s' from bucket '%s' with '%w'", key, s.bucket, err)
+	}
+
+	info := ObjectInfo{
+		Key:          key,
+		Size:         aws.Int64Value(out.ContentLength),
+		LastModified: aws.TimeValue(out.LastModified),
+		ETag:         aws.StringValue(out.ETag),
+		ContentType:  aws.StringValue(out.ContentType),
 	}
-	return res, nil
+	return &s3Reader{
+		ctx:     ctx,
+		storage: s,
+		key:     key,
+		size:    info.Size,
+		body:    out.Body,
+	}, info, nil
 }
 
-func (s *S3Storage) download(dir string, remoteFilename string, localFilename string) error {
-	if localFilename == "" {
-		localFilename = remoteFilename
+// Put streams r to s3, large objects are sent as a multipart upload
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, opts PutOptions) (UploadResult, error) {
+	if err := validateKey(key); err != nil {
+		return UploadResult{}, err
 	}
-	path := getFilePath(dir, localFilename)
-	s.log.Debugf("Creating tmp file '%s'...", path)
-	file, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("failed to create tmp file '%s', error: `%w`", path, err)
+
+	input := &s3manager.UploadInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+		Body:   r,
 	}
-	defer func() {
-		if err := file.Close(); err != nil {
-			s.log.Warnf("error closing file: %s", err)
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+
+	var options []func(*s3manager.Uploader)
+	if opts.IfNotExists {
+		// the small objects are sent with a single PutObject which carries the condition
+		options = append(options, s3manager.WithUploaderRequestOptions(request.WithSetRequestHeaders(map[string]string{"If-None-Match": "*"})))
+	}
+
+	s.log.Debugf("Uploading file '%s' to s3...", key)
+	out, err := s.uploader.UploadWithContext(ctx, input, options...)
+	if err != nil {
+		if opts.IfNotExists && isConditionFailed(err) {
+			err = ErrExists
 		}
-	}()
+		return UploadResult{}, fmt.Errorf("unable to upload '%s' to bucket '%s', error: '%w'", key, s.bucket, err)
+	}
+	etag := aws.StringValue(out.ETag)
+	s.log.Debugf("Upload successful, location: %s, ETag: %s", out.Location, etag)
 
-	s.log.Debugf("Downloading file '%s' from s3...", remoteFilename)
+	return UploadResult{
+		Filename: key,
+		Location: out.Location,
+		ETag:     etag,
+	}, nil
+}
 
-	fileSize, err := s.downloader.Download(file, &s3.GetObjectInput{
+// List returns all objects with the given key prefix, pages of the s3 listing are fetched sequentially
+func (s *S3Storage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	objects := make([]ObjectInfo, 0)
+	err := s.svc.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
 		Bucket: &s.bucket,
-		Key:    &remoteFilename,
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          aws.StringValue(obj.Key),
+				Size:         aws.Int64Value(obj.Size),
+				LastModified: aws.TimeValue(obj.LastModified),
+				ETag:         aws.StringValue(obj.ETag),
+			})
+		}
+		return true
 	})
-	s.log.Debugf("Downloaded file size: '%d'", fileSize)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list bucket '%s' with '%w'", s.bucket, err)
+	}
+	return objects, nil
+}
+
+// Stat requests the object metadata with a HEAD request
+func (s *S3Storage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	if err := validateKey(key); err != nil {
+		return ObjectInfo{}, err
+	}
 
+	out, err := s.svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
 	if err != nil {
-		return fmt.Errorf("unable to download '%s' from bucket '%s' with '%w'", localFilename, s.bucket, err)
+		if isNotFound(err) {
+			err = ErrNotFound
+		}
+		return ObjectInfo{}, fmt.Errorf("unable to stat '%s' in bucket '%s' with '%w'", key, s.bucket, err)
 	}
+	return ObjectInfo{
+		Key:          key,
+		Size:         aws.Int64Value(out.ContentLength),
+		LastModified: aws.TimeValue(out.LastModified),
+		ETag:         aws.StringValue(out.ETag),
+		ContentType:  aws.StringValue(out.ContentType),
+	}, nil
+}
 
+// Delete removes the object. S3 does not report missing keys on delete, so ErrNotFound is never returned.
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	if _, err := s.svc.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	}); err != nil {
+		return fmt.Errorf("unable to delete '%s' from bucket '%s' with '%w'", key, s.bucket, err)
+	}
 	return nil
 }
 
-func (s *S3Storage) upload(dir string, localFilename string, remoteFilename string) (*UploadResult, error) {
-	if remoteFilename == "" {
-		remoteFilename = localFilename
-	}
-	path := getFilePath(dir, localFilename)
-	s.log.Debugf("Opening a file '%s'...", path)
+// DownloadFiles function takes a local dir name and remote filenames.
+// It creates a new file with the same name in the dir. The download stops at the first file which fails,
+// its error is returned and no partial file is left for it.
+func (s *S3Storage) DownloadFiles(dir string, paths ...string) error {
+	return downloadFiles(context.Background(), s, s.log, dir, paths...)
+}
 
-	file, err := os.Open(path)
-	if err != nil {
-		s.log.Fatalf("failed to create file '%s'", path)
-		return nil, err
+// UploadFiles function takes local dir name and a local file name.
+// It takes a file from the dir and uploads it to the bucket with the same name.
+func (s *S3Storage) UploadFiles(dir string, paths ...string) ([]UploadResult, error) {
+	return uploadFiles(context.Background(), s, s.log, dir, paths...)
+}
+
+// s3Reader reads an s3 object and supports seeking by requesting byte ranges
+type s3Reader struct {
+	ctx     context.Context
+	storage *S3Storage
+	key     string
+	size    int64
+	// offset is the position of the next read
+	offset int64
+	body   io.ReadCloser
+	// bodyOffset is the position of body, it's reopened at offset before a read if they differ
+	bodyOffset int64
+}
+
+func (r *s3Reader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
 	}
-	defer func() {
-		if err := file.Close(); err != nil {
-			s.log.Warnf("error closing file: %s", err)
+	if r.body != nil && r.bodyOffset != r.offset {
+		closeWithLog(r.body, r.storage.log)
+		r.body = nil
+	}
+	if r.body == nil {
+		out, err := r.storage.svc.GetObjectWithContext(r.ctx, &s3.GetObjectInput{
+			Bucket: &r.storage.bucket,
+			Key:    &r.key,
+			Range:  aws.String(fmt.Sprintf("bytes=%d-", r.offset)),
+		})
+		if err != nil {
+			return 0, err
 		}
-	}()
+		r.body = out.Body
+		r.bodyOffset = r.offset
+	}
 
-	s.log.Debugf("Uploading file '%s' to s3...", remoteFilename)
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	r.bodyOffset = r.offset
+	return n, err
+}
 
-	out, err := s.uploader.Upload(&s3manager.UploadInput{
-		Bucket: &s.bucket,
-		Key:    &remoteFilename,
-		Body:   file,
-	})
+func (r *s3Reader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.offset + offset
+	case io.SeekEnd:
+		abs = r.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.offset = abs
+	return abs, nil
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("unable to upload local file '%s' to bucket '%s', remote filename: '%s', error: '%w'", localFilename, s.bucket, remoteFilename, err)
+func (r *s3Reader) Close() error {
+	if r.body == nil {
+		return nil
 	}
-	s.log.Debugf("Upload successful, location: %s, ETag: %s", out.Location, *out.ETag)
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
 
-	return &UploadResult{
-		Filename: remoteFilename,
-		Location: out.Location,
-		ETag:     *out.ETag,
-	}, nil
+func isNotFound(err error) bool {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		switch aerr.Code() {
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return true
+		}
+	}
+	return false
 }
 
-func getFilePath(dir, fileName string) string {
-	return fmt.Sprintf("%s/%s", dir, fileName)
+// isConditionFailed reports whether a conditional write was rejected, s3 answers a concurrent conditional write
+// of the same key with a conflict
+func isConditionFailed(err error) bool {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		switch aerr.Code() {
+		case "PreconditionFailed", "ConditionalRequestConflict":
+			return true
+		}
+	}
+	var rerr awserr.RequestFailure
+	return errors.As(err, &rerr) && rerr.StatusCode() == http.StatusPreconditionFailed
 }
 
 func verifyEnv(keys ...string) error {
@@ -1,9 +1,24 @@
 package storage
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/cxrdevelop/optimization_engine/pkg/logger"
 	"github.com/stretchr/testify/assert"
 )
@@ -14,3 +29,93 @@ func TestS3Storage_Creation(t *testing.T) {
 	assert.NoError(t, os.Setenv("AWS_SECRET_ACCESS_KEY", "2"))
 	assert.NotPanics(t, func() { NewS3Storage("region", "dir", logger.NewTestLogger()) })
 }
+
+// getObjectAPI serves GetObject from content and records the requested ranges
+type getObjectAPI struct {
+	s3iface.S3API
+	content string
+	ranges  []string
+}
+
+func (a *getObjectAPI) GetObjectWithContext(ctx aws.Context, in *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	a.ranges = append(a.ranges, aws.StringValue(in.Range))
+	body := a.content
+	if in.Range != nil {
+		var start int
+		if _, err := fmt.Sscanf(*in.Range, "bytes=%d-", &start); err != nil {
+			return nil, err
+		}
+		body = body[start:]
+	}
+	return &s3.GetObjectOutput{
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		ContentLength: aws.Int64(int64(len(body))),
+		LastModified:  aws.Time(time.Now()),
+	}, nil
+}
+
+func TestS3Storage_GetServeContent(t *testing.T) {
+	testCases := []struct {
+		name        string
+		rangeHeader string
+		body        string
+		ranges      []string
+	}{
+		{name: "whole object", body: "0123456789", ranges: []string{""}},
+		{name: "range", rangeHeader: "bytes=4-6", body: "456", ranges: []string{"", "bytes=4-"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api := &getObjectAPI{content: "0123456789"}
+			s := &S3Storage{svc: api, bucket: "bucket", log: logger.NewTestLogger()}
+			body, info, err := s.Get(context.Background(), "opt_result_1.tar.gz")
+			assert.NoError(t, err)
+			defer body.Close()
+
+			// the size is taken from the response, seeking to the end and back doesn't request the object again
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.rangeHeader != "" {
+				req.Header.Set("Range", tc.rangeHeader)
+			}
+			recorder := httptest.NewRecorder()
+			http.ServeContent(recorder, req, "opt_result_1.tar.gz", info.LastModified, body.(io.ReadSeeker))
+			assert.Equal(t, tc.body, recorder.Body.String())
+			assert.Equal(t, tc.ranges, api.ranges)
+		})
+	}
+}
+
+func TestS3Storage_PutIfNotExists(t *testing.T) {
+	stored := map[string]bool{}
+	var conditions []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(ioutil.Discard, r.Body)
+		conditions = append(conditions, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == "*" && stored[r.URL.Path] {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			fmt.Fprint(w, `<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`)
+			return
+		}
+		stored[r.URL.Path] = true
+		w.Header().Set("ETag", `"etag"`)
+	}))
+	defer srv.Close()
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:           aws.String("us-east-1"),
+		Endpoint:         aws.String(srv.URL),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+	})
+	assert.NoError(t, err)
+	client := s3.New(sess)
+	s3Storage := &S3Storage{svc: client, uploader: s3manager.NewUploaderWithClient(client), bucket: "bucket", log: logger.NewTestLogger()}
+
+	_, err = s3Storage.Put(context.Background(), "manifest.json", strings.NewReader("{}"), PutOptions{IfNotExists: true})
+	assert.NoError(t, err)
+	_, err = s3Storage.Put(context.Background(), "manifest.json", strings.NewReader("{}"), PutOptions{IfNotExists: true})
+	assert.ErrorIs(t, err, ErrExists)
+	_, err = s3Storage.Put(context.Background(), "manifest.json", strings.NewReader("{}"), PutOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"*", "*", ""}, conditions)
+}
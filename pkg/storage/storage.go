@@ -1,12 +1,57 @@
 package storage
 
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the requested object does not exist in the bucket
+	ErrNotFound = errors.New("object not found")
+	// ErrInvalidKey is returned for keys which are empty or point outside of the bucket
+	ErrInvalidKey = errors.New("invalid object key")
+	// ErrExists is returned by a Put with IfNotExists when the object exists already
+	ErrExists = errors.New("object already exists")
+)
+
 type UploadResult struct {
 	Filename string
 	Location string
 	ETag     string
 }
 
+// ObjectInfo describes an object stored in the bucket
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	ETag         string
+	ContentType  string
+}
+
+// PutOptions holds optional object attributes for Put
+type PutOptions struct {
+	ContentType string
+	// IfNotExists makes the Put fail with ErrExists instead of replacing an existing object,
+	// the check and the write are atomic. It is meant for small objects, e.g. manifests.
+	IfNotExists bool
+}
+
 type Storage interface {
+	// Get opens the object for reading. The caller must close the returned reader.
+	// Readers returned by the provided backends also implement io.Seeker.
+	Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
+	// Put stores everything read from r under the key
+	Put(ctx context.Context, key string, r io.Reader, opts PutOptions) (UploadResult, error)
+	// List returns the objects whose keys start with prefix sorted by key
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// Stat returns the object info without reading its content
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// Delete removes the object, ErrNotFound is returned if the backend can tell it does not exist
+	Delete(ctx context.Context, key string) error
+
 	DownloadFiles(dir string, paths ...string) error
 	UploadFiles(dir string, paths ...string) ([]UploadResult, error)
 }
@@ -0,0 +1,173 @@
+package tabular
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// OriginalsDir is the directory of the input archive keeping the uploaded files which have been converted to CSV
+const OriginalsDir = ".originals"
+
+// Formats of the files which are converted to CSV
+const (
+	FormatTSV  = "tsv"
+	FormatJSON = "json"
+	FormatXLSX = "xlsx"
+)
+
+// ErrInvalidFile is returned when the content of a file can't be converted
+var ErrInvalidFile = errors.New("invalid file")
+
+// ConvertFormat returns the format of the file to convert to CSV by its extension, empty if the file is kept as is
+func ConvertFormat(name string) string {
+	switch ext := strings.ToLower(strings.TrimPrefix(path.Ext(name), ".")); ext {
+	case FormatTSV, FormatJSON, FormatXLSX:
+		return ext
+	}
+	return ""
+}
+
+// CSVName returns the name of the converted file, the extension is replaced with .csv
+func CSVName(name string) string {
+	return strings.TrimSuffix(name, path.Ext(name)) + ".csv"
+}
+
+// ConvertTSV writes the tab separated file as CSV
+func ConvertTSV(r io.Reader, w io.Writer) error {
+	reader, err := NewReader(r, '\t')
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	for {
+		record, _, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ConvertJSON writes the JSON array of objects as CSV. The header starts with the given columns
+// followed by the other keys in the order they first appear. Nested objects and arrays are written as JSON,
+// nulls as empty values.
+func ConvertJSON(r io.Reader, w io.Writer, columns []string) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return fmt.Errorf("%w: an array of objects is expected", ErrInvalidFile)
+	}
+
+	header := append([]string(nil), columns...)
+	index := make(map[string]int, len(columns))
+	for i, c := range columns {
+		index[c] = i
+	}
+	rows := make([]map[string]string, 0)
+	for decoder.More() {
+		var object map[string]json.RawMessage
+		keys, err := objectKeys(decoder, &object)
+		if err != nil {
+			return fmt.Errorf("%w: item %d: %s", ErrInvalidFile, len(rows)+1, err)
+		}
+		row := make(map[string]string, len(object))
+		for _, key := range keys {
+			if _, ok := index[key]; !ok {
+				index[key] = len(header)
+				header = append(header, key)
+			}
+			row[key] = jsonValue(object[key])
+		}
+		rows = append(rows, row)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidFile, err)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(header))
+	for _, row := range rows {
+		for i, column := range header {
+			record[i] = row[column]
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// objectKeys decodes the next object of the decoder and returns its keys in the order of the document
+func objectKeys(decoder *json.Decoder, object *map[string]json.RawMessage) ([]string, error) {
+	var raw json.RawMessage
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, object); err != nil || *object == nil {
+		return nil, errors.New("an object is expected")
+	}
+	keys := make([]string, 0, len(*object))
+	inner := json.NewDecoder(strings.NewReader(string(raw)))
+	// skip the opening brace, then read the key and skip the value of every member
+	if _, err := inner.Token(); err != nil {
+		return nil, err
+	}
+	for inner.More() {
+		key, err := inner.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key.(string))
+		var value json.RawMessage
+		if err := inner.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// jsonValue returns the CSV value of a JSON value
+func jsonValue(raw json.RawMessage) string {
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return string(raw)
+	}
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	}
+	// nested objects and arrays are kept as compact JSON
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return string(raw)
+	}
+	return compact.String()
+}
@@ -0,0 +1,121 @@
+package tabular
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newXLSX builds a workbook of the given sheet parts with the shared strings
+func newXLSX(t *testing.T, shared string, sheets map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	write := func(name string, content string) {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	var workbook, rels strings.Builder
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8"?><workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(`<?xml version="1.0" encoding="UTF-8"?><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i, name := range []string{"Racks", "Devices"} {
+		data, ok := sheets[name]
+		if !ok {
+			continue
+		}
+		id := string(rune('1' + i))
+		workbook.WriteString(`<sheet name="` + name + `" sheetId="` + id + `" r:id="rId` + id + `"/>`)
+		rels.WriteString(`<Relationship Id="rId` + id + `" Type="worksheet" Target="worksheets/sheet` + id + `.xml"/>`)
+		write("xl/worksheets/sheet"+id+".xml", `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`+data+`</sheetData></worksheet>`)
+	}
+	workbook.WriteString(`</sheets></workbook>`)
+	rels.WriteString(`</Relationships>`)
+	write("xl/workbook.xml", workbook.String())
+	write("xl/_rels/workbook.xml.rels", rels.String())
+	if shared != "" {
+		write("xl/sharedStrings.xml", `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`+shared+`</sst>`)
+	}
+	assert.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestConvertXLSX(t *testing.T) {
+	data := newXLSX(t, `<si><t>id</t></si><si><t>name</t></si><si><r><t>rack </t></r><r><t>one</t></r></si>`, map[string]string{
+		"Racks": `<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="inlineStr"><is><t>active</t></is></c></row>` +
+			`<row r="2"><c r="A2"><v>1</v></c><c r="B2" t="s"><v>2</v></c><c r="C2" t="b"><v>1</v></c></row>` +
+			`<row r="3"></row>` +
+			`<row r="4"><c r="A4"><v>2.5</v></c><c r="C4" t="b"><v>0</v></c></row>` +
+			`<row r="5"><c r="A5"><v>3</v></c></row>`,
+		"Devices": `<row r="1"><c r="B1" t="inlineStr"><is><t>device</t></is></c></row><row r="2"><c r="B2" t="str"><v>d, "1"</v></c></row>`,
+	})
+
+	var out bytes.Buffer
+	assert.NoError(t, ConvertXLSX(bytes.NewReader(data), int64(len(data)), "", &out))
+	assert.Equal(t, "id,name,active\n1,rack one,true\n2.5,,false\n3,,\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, ConvertXLSX(bytes.NewReader(data), int64(len(data)), "Devices", &out))
+	assert.Equal(t, ",device\n,\"d, \"\"1\"\"\"\n", out.String())
+
+	err := ConvertXLSX(bytes.NewReader(data), int64(len(data)), "Cables", &out)
+	assert.ErrorIs(t, err, ErrInvalidFile)
+	assert.Contains(t, err.Error(), "sheet 'Cables' not found, the workbook has: Racks, Devices")
+
+	bad := newXLSX(t, "", map[string]string{"Racks": `<row r="1"><c r="A1" t="s"><v>7</v></c></row>`})
+	assert.ErrorIs(t, ConvertXLSX(bytes.NewReader(bad), int64(len(bad)), "", &out), ErrInvalidFile)
+	assert.ErrorIs(t, ConvertXLSX(strings.NewReader("id,name"), 7, "", &out), ErrInvalidFile)
+}
+
+func TestConvertJSON(t *testing.T) {
+	var out bytes.Buffer
+	input := `[{"name": "r1", "id": 1, "tags": ["a", "b"], "weight": 1.50},
+		{"id": 2, "active": true, "name": null, "size": {"u": 2}}]`
+	assert.NoError(t, ConvertJSON(strings.NewReader(input), &out, []string{"id", "weight"}))
+	assert.Equal(t, "id,weight,name,tags,active,size\n1,1.50,r1,\"[\"\"a\"\",\"\"b\"\"]\",,\n2,,,,true,\"{\"\"u\"\":2}\"\n", out.String())
+
+	for _, input := range []string{`{"id": 1}`, `[{"id": 1}, 2]`, `[{"id": 1}`, ``} {
+		assert.ErrorIs(t, ConvertJSON(strings.NewReader(input), &out, nil), ErrInvalidFile, input)
+	}
+}
+
+func TestConvertTSV(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, ConvertTSV(strings.NewReader("id\tname\r\n1\ta, b\r\n"), &out))
+	assert.Equal(t, "id,name\n1,\"a, b\"\n", out.String())
+	assert.ErrorIs(t, ConvertTSV(strings.NewReader("id\tname\n1\t\"a\n"), &out), ErrSyntax)
+}
+
+func TestConvertFormat(t *testing.T) {
+	assert.Equal(t, FormatXLSX, ConvertFormat("racks/Inventory.XLSX"))
+	assert.Equal(t, FormatJSON, ConvertFormat("devices.json"))
+	assert.Equal(t, "", ConvertFormat("racks.csv"))
+	assert.Equal(t, "racks/inventory.csv", CSVName("racks/inventory.xlsx"))
+}
+
+func TestExportJSON(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, ExportJSON(strings.NewReader("id,name,weight\n007,\"r, 1\",1.5\n2,r2\n"), &out))
+	assert.Equal(t, `[{"id":"007","name":"r, 1","weight":1.5},{"id":2,"name":"r2","weight":""}]`+"\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, ExportJSON(strings.NewReader(""), &out))
+	assert.Equal(t, "[]\n", out.String())
+	assert.ErrorIs(t, ExportJSON(strings.NewReader("id\n\"1\n"), &out), ErrSyntax)
+}
+
+func TestExportXLSX(t *testing.T) {
+	const data = "id,name,weight\n007,<r & 1>,1.5\n2, r2 ,-3e2\n"
+	var out bytes.Buffer
+	assert.NoError(t, ExportXLSX(strings.NewReader(data), &out, "Result"))
+
+	var csv bytes.Buffer
+	assert.NoError(t, ConvertXLSX(bytes.NewReader(out.Bytes()), int64(out.Len()), "Result", &csv))
+	// the writer quotes the values with leading spaces
+	assert.Equal(t, strings.Replace(data, " r2 ", `" r2 "`, 1), csv.String())
+	assert.Contains(t, out.String(), "[Content_Types].xml")
+}
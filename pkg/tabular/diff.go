@@ -0,0 +1,318 @@
+package tabular
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Kinds of a row change
+const (
+	RowAdded   = "added"
+	RowRemoved = "removed"
+	RowChanged = "changed"
+)
+
+// Index is a CSV file with a header loaded to be compared with another one.
+// The rows are identified by the values of the key columns or by their number if there is no key.
+type Index struct {
+	header []string
+	key    []string
+	// keys keeps the order of the rows, rows maps a key to the values
+	keys []string
+	rows map[string][]string
+	sums map[string]*columnSum
+}
+
+// Diff is the difference between two files
+type Diff struct {
+	// AddedColumns and RemovedColumns are the columns only the second or only the first file has
+	AddedColumns   []string
+	RemovedColumns []string
+	RowsA, RowsB   int
+	Added          int
+	Removed        int
+	Changed        int
+	// Columns reports the numeric columns both files have
+	Columns []ColumnDelta
+	// Rows lists the changes, at most the limit given to Diff
+	Rows      []RowDiff
+	Truncated bool
+}
+
+// ColumnDelta is the change of a numeric column
+type ColumnDelta struct {
+	Column string
+	// Changed is the number of rows whose value of the column has changed
+	Changed    int
+	SumA, SumB float64
+	Delta      float64
+}
+
+// RowDiff is an added, removed or changed row. Added and removed rows carry their values,
+// changed rows the changed cells of the columns both files have.
+type RowDiff struct {
+	Kind   string
+	Key    string
+	Header []string
+	Values []string
+	Cells  []CellDiff
+}
+
+// CellDiff is a changed value, Delta is set when both values are numbers
+type CellDiff struct {
+	Column string
+	A, B   string
+	Delta  *float64
+}
+
+// columnSum is the sum of a column, numeric is cleared by the first value which isn't a number
+type columnSum struct {
+	sum     float64
+	values  int
+	numeric bool
+}
+
+// number reports whether the column has numbers and nothing else
+func (s *columnSum) number() bool {
+	return s != nil && s.numeric && s.values > 0
+}
+
+func (s *columnSum) add(value string) {
+	value = strings.TrimSpace(value)
+	if !s.numeric || value == "" {
+		return
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		s.numeric = false
+		return
+	}
+	s.sum += n
+	s.values++
+}
+
+// NewIndex reads the file into memory. Every key column must be in the header and every key must be unique.
+func NewIndex(r io.Reader, key []string) (*Index, error) {
+	reader, err := NewReader(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	header, err := readHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+	positions, err := keyPositions(header, key)
+	if err != nil {
+		return nil, err
+	}
+	ix := &Index{header: header, key: key, rows: make(map[string][]string), sums: newSums(header)}
+	for {
+		record, line, err := reader.Read()
+		if err == io.EOF {
+			return ix, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		k := rowKey(record, positions, len(ix.keys)+1)
+		if _, ok := ix.rows[k]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key '%s'", line, k)
+		}
+		ix.keys = append(ix.keys, k)
+		ix.rows[k] = record
+		addSums(ix.sums, header, record)
+	}
+}
+
+// Len returns the number of rows without the header
+func (ix *Index) Len() int {
+	return len(ix.keys)
+}
+
+// Diff compares the file read from r with the indexed one and reports at most limit row changes
+func (ix *Index) Diff(r io.Reader, limit int) (*Diff, error) {
+	reader, err := NewReader(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	header, err := readHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+	positions, err := keyPositions(header, ix.key)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &Diff{RowsA: len(ix.keys), Rows: make([]RowDiff, 0)}
+	columnsA := columnIndex(ix.header)
+	columnsB := columnIndex(header)
+	for _, c := range header {
+		if _, ok := columnsA[c]; !ok {
+			diff.AddedColumns = append(diff.AddedColumns, c)
+		}
+	}
+	for _, c := range ix.header {
+		if _, ok := columnsB[c]; !ok {
+			diff.RemovedColumns = append(diff.RemovedColumns, c)
+		}
+	}
+	sums := newSums(header)
+	changed := make(map[string]int)
+	seen := make(map[string]struct{}, len(ix.keys))
+	for {
+		record, line, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		diff.RowsB++
+		addSums(sums, header, record)
+		k := rowKey(record, positions, diff.RowsB)
+		if _, ok := seen[k]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key '%s'", line, k)
+		}
+		seen[k] = struct{}{}
+
+		old, ok := ix.rows[k]
+		if !ok {
+			diff.Added++
+			diff.add(RowDiff{Kind: RowAdded, Key: k, Header: header, Values: record}, limit)
+			continue
+		}
+		var cells []CellDiff
+		for i, c := range ix.header {
+			j, ok := columnsB[c]
+			if !ok {
+				continue
+			}
+			a, b := value(old, i), value(record, j)
+			if a == b {
+				continue
+			}
+			changed[c]++
+			cells = append(cells, CellDiff{Column: c, A: a, B: b, Delta: delta(a, b)})
+		}
+		if len(cells) > 0 {
+			diff.Changed++
+			diff.add(RowDiff{Kind: RowChanged, Key: k, Cells: cells}, limit)
+		}
+	}
+	for _, k := range ix.keys {
+		if _, ok := seen[k]; !ok {
+			diff.Removed++
+			diff.add(RowDiff{Kind: RowRemoved, Key: k, Header: ix.header, Values: ix.rows[k]}, limit)
+		}
+	}
+
+	for _, c := range ix.header {
+		a, b := ix.sums[c], sums[c]
+		if !a.number() || !b.number() {
+			continue
+		}
+		diff.Columns = append(diff.Columns, ColumnDelta{Column: c, Changed: changed[c], SumA: a.sum, SumB: b.sum, Delta: b.sum - a.sum})
+	}
+	return diff, nil
+}
+
+func (d *Diff) add(row RowDiff, limit int) {
+	if len(d.Rows) >= limit {
+		d.Truncated = true
+		return
+	}
+	d.Rows = append(d.Rows, row)
+}
+
+// keyPositions returns the header positions of the key columns
+func keyPositions(header []string, key []string) ([]int, error) {
+	columns := columnIndex(header)
+	positions := make([]int, len(key))
+	for i, k := range key {
+		pos, ok := columns[k]
+		if !ok {
+			return nil, fmt.Errorf("key column '%s' missing in the header", k)
+		}
+		positions[i] = pos
+	}
+	return positions, nil
+}
+
+// rowKey writes the key values of the row as a CSV record, so a value with the delimiter in it is quoted
+// and the keys of different values can't be the same. The row number is the key if there are no key columns.
+func rowKey(record []string, positions []int, row int) string {
+	if len(positions) == 0 {
+		return strconv.Itoa(row)
+	}
+	values := make([]string, len(positions))
+	for i, pos := range positions {
+		values[i] = value(record, pos)
+	}
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	// the builder doesn't fail
+	_ = w.Write(values)
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// readHeader returns the header with the surrounding spaces of the names trimmed, nil for an empty file
+func readHeader(reader *Reader) ([]string, error) {
+	header, _, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	return header, err
+}
+
+// columnIndex maps the column names to their positions, the first one wins for duplicates
+func columnIndex(header []string) map[string]int {
+	index := make(map[string]int, len(header))
+	for i, c := range header {
+		if _, ok := index[c]; !ok {
+			index[c] = i
+		}
+	}
+	return index
+}
+
+func newSums(header []string) map[string]*columnSum {
+	sums := make(map[string]*columnSum, len(header))
+	for _, c := range header {
+		sums[c] = &columnSum{numeric: true}
+	}
+	return sums
+}
+
+func addSums(sums map[string]*columnSum, header []string, record []string) {
+	for i, c := range header {
+		sums[c].add(value(record, i))
+	}
+}
+
+func value(record []string, i int) string {
+	if i < len(record) {
+		return record[i]
+	}
+	return ""
+}
+
+func delta(a, b string) *float64 {
+	x, err := strconv.ParseFloat(strings.TrimSpace(a), 64)
+	if err != nil {
+		return nil
+	}
+	y, err := strconv.ParseFloat(strings.TrimSpace(b), 64)
+	if err != nil {
+		return nil
+	}
+	d := y - x
+	return &d
+}
@@ -0,0 +1,87 @@
+package tabular
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func TestIndex_Diff(t *testing.T) {
+	const a = "id,rack,cost,note\n1,r1,10,x\n2,r1,5,y\n3,r2,1.5,z\n"
+	const b = "id, rack ,cost,slot\n1,r1,10,4\n3,r3,2,5\n4,r2,7,6\n"
+
+	ix, err := NewIndex(strings.NewReader(a), []string{"id"})
+	assert.NoError(t, err)
+	diff, err := ix.Diff(strings.NewReader(b), 10)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"slot"}, diff.AddedColumns)
+	assert.Equal(t, []string{"note"}, diff.RemovedColumns)
+	assert.Equal(t, 3, diff.RowsA)
+	assert.Equal(t, 3, diff.RowsB)
+	assert.Equal(t, 1, diff.Added)
+	assert.Equal(t, 1, diff.Removed)
+	assert.Equal(t, 1, diff.Changed)
+	assert.Equal(t, []ColumnDelta{
+		{Column: "id", SumA: 6, SumB: 8, Delta: 2},
+		{Column: "cost", Changed: 1, SumA: 16.5, SumB: 19, Delta: 2.5},
+	}, diff.Columns)
+	assert.Equal(t, []RowDiff{
+		{Kind: RowChanged, Key: "3", Cells: []CellDiff{{Column: "rack", A: "r2", B: "r3"}, {Column: "cost", A: "1.5", B: "2", Delta: float(0.5)}}},
+		{Kind: RowAdded, Key: "4", Header: []string{"id", "rack", "cost", "slot"}, Values: []string{"4", "r2", "7", "6"}},
+		{Kind: RowRemoved, Key: "2", Header: []string{"id", "rack", "cost", "note"}, Values: []string{"2", "r1", "5", "y"}},
+	}, diff.Rows)
+	assert.False(t, diff.Truncated)
+
+	diff, err = ix.Diff(strings.NewReader(b), 1)
+	assert.NoError(t, err)
+	assert.Len(t, diff.Rows, 1)
+	assert.True(t, diff.Truncated)
+}
+
+func TestIndex_DiffByRowNumber(t *testing.T) {
+	ix, err := NewIndex(strings.NewReader("value\n1\n2\n"), nil)
+	assert.NoError(t, err)
+	diff, err := ix.Diff(strings.NewReader("value\n1\n3\n4\n"), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, diff.Added)
+	assert.Equal(t, 1, diff.Changed)
+	assert.Equal(t, "2", diff.Rows[0].Key)
+	assert.Equal(t, "3", diff.Rows[1].Key)
+}
+
+func TestIndex_DiffCompositeKey(t *testing.T) {
+	// the key values hold the delimiters, the keys of the rows differ still
+	const a = "rack,slot,cost\nr1|a,b,1\nr1,a|b,2\n\"r2,x\",y,3\n"
+	const b = "rack,slot,cost\nr1|a,b,1\nr1,a|b,5\nr2,\"x,y\",3\n"
+
+	ix, err := NewIndex(strings.NewReader(a), []string{"rack", "slot"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, ix.Len())
+	diff, err := ix.Diff(strings.NewReader(b), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, diff.Added)
+	assert.Equal(t, 1, diff.Removed)
+	assert.Equal(t, 1, diff.Changed)
+	assert.Equal(t, []string{`r1,a|b`, `r2,"x,y"`, `"r2,x",y`}, []string{diff.Rows[0].Key, diff.Rows[1].Key, diff.Rows[2].Key})
+}
+
+func TestIndex_Errors(t *testing.T) {
+	_, err := NewIndex(strings.NewReader("id\n1\n1\n"), []string{"id"})
+	assert.EqualError(t, err, "line 3: duplicate key '1'")
+
+	_, err = NewIndex(strings.NewReader("id\n1\n"), []string{"rack"})
+	assert.EqualError(t, err, "key column 'rack' missing in the header")
+
+	ix, err := NewIndex(strings.NewReader("id\n1\n"), []string{"id"})
+	assert.NoError(t, err)
+	_, err = ix.Diff(strings.NewReader("rack\nr1\n"), 10)
+	assert.Error(t, err)
+	_, err = ix.Diff(strings.NewReader("id\n\"1\n"), 10)
+	assert.ErrorIs(t, err, ErrSyntax)
+}
@@ -0,0 +1,178 @@
+package tabular
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// jsonNumber matches the values exported as numbers, values with leading zeros such as ids stay strings
+var jsonNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// xlsxParts are the static parts of an exported workbook of a single sheet
+var xlsxParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{xlsxWorkbookRels, xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// XLSXContentType is the media type of the exported workbooks
+const XLSXContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// ExportJSON writes the CSV file with a header as a JSON array of objects keyed by the header.
+// Values which are JSON numbers are written as numbers, other values as strings.
+func ExportJSON(r io.Reader, w io.Writer) error {
+	reader, err := NewReader(r, ',')
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(w)
+	if _, err := out.WriteString("["); err != nil {
+		return err
+	}
+	header, _, err := reader.Read()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	for rows := 0; err == nil; rows++ {
+		record, _, rerr := reader.Read()
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+		if rows > 0 {
+			out.WriteString(",")
+		}
+		if err := writeJSONObject(out, header, record); err != nil {
+			return err
+		}
+	}
+	if _, err := out.WriteString("]\n"); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+func writeJSONObject(out *bufio.Writer, header []string, record []string) error {
+	out.WriteString("{")
+	for i, column := range header {
+		if i > 0 {
+			out.WriteString(",")
+		}
+		key, err := json.Marshal(column)
+		if err != nil {
+			return err
+		}
+		out.Write(key)
+		out.WriteString(":")
+		value := ""
+		if i < len(record) {
+			value = record[i]
+		}
+		if jsonNumber.MatchString(value) {
+			out.WriteString(value)
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		out.Write(encoded)
+	}
+	_, err := out.WriteString("}")
+	return err
+}
+
+// ExportXLSX writes the CSV file as a workbook of a single sheet with the given name.
+// Values which are numbers are written as numeric cells, other values as strings.
+func ExportXLSX(r io.Reader, w io.Writer, sheet string) error {
+	reader, err := NewReader(r, ',')
+	if err != nil {
+		return err
+	}
+	archive := zip.NewWriter(w)
+	for _, part := range xlsxParts {
+		if err := writeZipPart(archive, part.name, part.content); err != nil {
+			return err
+		}
+	}
+	var name strings.Builder
+	if err := xml.EscapeText(&name, []byte(sheet)); err != nil {
+		return err
+	}
+	workbook := xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="` + name.String() + `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	if err := writeZipPart(archive, xlsxWorkbook, workbook); err != nil {
+		return err
+	}
+
+	part, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(part)
+	out.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for row := 1; ; row++ {
+		record, _, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := writeXLSXRow(out, row, record); err != nil {
+			return err
+		}
+	}
+	out.WriteString(`</sheetData></worksheet>`)
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func writeXLSXRow(out *bufio.Writer, row int, record []string) error {
+	out.WriteString(`<row r="` + strconv.Itoa(row) + `">`)
+	for _, value := range record {
+		if jsonNumber.MatchString(value) {
+			out.WriteString(`<c><v>` + value + `</v></c>`)
+			continue
+		}
+		out.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		if err := xml.EscapeText(out, []byte(value)); err != nil {
+			return err
+		}
+		out.WriteString(`</t></is></c>`)
+	}
+	_, err := out.WriteString(`</row>`)
+	return err
+}
+
+func writeZipPart(archive *zip.Writer, name string, content string) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, content)
+	return err
+}
@@ -0,0 +1,47 @@
+package tabular
+
+import "fmt"
+
+// Input is the file of an input slot checked against the CSV schema of the slot
+type Input struct {
+	// Slot is the name of the input slot
+	Slot string
+	// File is the name of the file reported in the violations
+	File   string
+	Schema Schema
+	// EqualRows requires the file to have as many rows as the other inputs with EqualRows set
+	EqualRows bool
+}
+
+// ValidateInputs checks the inputs in their order. validate reads the file of an input and returns its report,
+// e.g. with Schema.Validate or Schema.NormalizeFile. The valid inputs with EqualRows must have the same number of rows.
+// The returned report has the violations of every input, its Rows is not set.
+func ValidateInputs(inputs []Input, validate func(Input) (*Report, error)) (*Report, error) {
+	all := &Report{}
+	var (
+		rowsSlot string
+		rows     int
+	)
+	for _, input := range inputs {
+		report, err := validate(input)
+		if err != nil {
+			return nil, err
+		}
+		all.Violations = append(all.Violations, report.Violations...)
+		all.Truncated = all.Truncated || report.Truncated
+		if !input.EqualRows || !report.Valid() {
+			continue
+		}
+		if rowsSlot == "" {
+			rowsSlot, rows = input.Slot, report.Rows
+			continue
+		}
+		if report.Rows != rows {
+			all.Violations = append(all.Violations, Violation{
+				File:    input.File,
+				Message: fmt.Sprintf("has %d rows, the %s input has %d", report.Rows, rowsSlot, rows),
+			})
+		}
+	}
+	return all, nil
+}
@@ -0,0 +1,44 @@
+package tabular
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateInputs(t *testing.T) {
+	content := map[string]string{
+		"racks.csv":   "id\n1\n2\n",
+		"devices.csv": "id\n1\n2\n3\n",
+		"bad.csv":     "id\nx\n",
+		"other.csv":   "id\n1\n",
+	}
+	validate := func(input Input) (*Report, error) {
+		return input.Schema.Validate(input.File, strings.NewReader(content[input.File]), nil)
+	}
+	schema := Schema{Columns: []Column{{Name: "id", Type: TypeInteger}}}
+
+	report, err := ValidateInputs([]Input{
+		{Slot: "bad", File: "bad.csv", Schema: schema, EqualRows: true},
+		{Slot: "racks", File: "racks.csv", Schema: schema, EqualRows: true},
+		{Slot: "other", File: "other.csv", Schema: schema},
+		{Slot: "devices", File: "devices.csv", Schema: schema, EqualRows: true},
+	}, validate)
+	assert.NoError(t, err)
+	assert.Equal(t, []Violation{
+		{File: "bad.csv", Line: 2, Column: "id", Message: "'x' is not an integer"},
+		{File: "devices.csv", Message: "has 3 rows, the racks input has 2"},
+	}, report.Violations)
+
+	report, err = ValidateInputs([]Input{{Slot: "racks", File: "racks.csv", Schema: schema, EqualRows: true}}, validate)
+	assert.NoError(t, err)
+	assert.True(t, report.Valid())
+
+	readErr := errors.New("read error")
+	_, err = ValidateInputs([]Input{{Slot: "racks", File: "racks.csv"}}, func(Input) (*Report, error) {
+		return nil, readErr
+	})
+	assert.ErrorIs(t, err, readErr)
+}
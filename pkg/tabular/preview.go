@@ -0,0 +1,88 @@
+package tabular
+
+import (
+	"io"
+	"strings"
+)
+
+// Filter keeps the rows whose value of the column equals the value, surrounding spaces are ignored
+type Filter struct {
+	Column string
+	Value  string
+}
+
+// Page is a window of the rows of a file
+type Page struct {
+	Header []string
+	// Rows is the number of rows without the header which match the filters
+	Rows    int
+	Records [][]string
+}
+
+// ReadPage reads the CSV file with a header and returns at most limit of the rows matching the filters
+// starting from offset. The file is streamed, only the returned rows are kept in memory.
+// A filter on a column missing in the header matches no rows.
+func ReadPage(r io.Reader, offset int, limit int, filters ...Filter) (*Page, error) {
+	reader, err := NewReader(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	page := &Page{Records: make([][]string, 0)}
+	header, _, err := reader.Read()
+	if err == io.EOF {
+		return page, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	page.Header = header
+
+	positions, ok := filterPositions(header, filters)
+	for {
+		record, _, err := reader.Read()
+		if err == io.EOF {
+			return page, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !ok || !matches(record, positions, filters) {
+			continue
+		}
+		if page.Rows >= offset && len(page.Records) < limit {
+			page.Records = append(page.Records, record)
+		}
+		page.Rows++
+	}
+}
+
+// filterPositions returns the header positions of the filter columns, false if a column is missing
+func filterPositions(header []string, filters []Filter) ([]int, bool) {
+	positions := make([]int, len(filters))
+	for i, f := range filters {
+		positions[i] = -1
+		for j, column := range header {
+			if strings.TrimSpace(column) == f.Column {
+				positions[i] = j
+				break
+			}
+		}
+		if positions[i] < 0 {
+			return nil, false
+		}
+	}
+	return positions, true
+}
+
+func matches(record []string, positions []int, filters []Filter) bool {
+	for i, f := range filters {
+		value := ""
+		if positions[i] < len(record) {
+			value = record[positions[i]]
+		}
+		if strings.TrimSpace(value) != f.Value {
+			return false
+		}
+	}
+	return true
+}
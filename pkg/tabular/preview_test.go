@@ -0,0 +1,82 @@
+package tabular
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadPage(t *testing.T) {
+	const data = "id;status\n1;ok\n2;failed\n3; ok \n4;ok\n"
+
+	testCases := []struct {
+		name    string
+		data    string
+		offset  int
+		limit   int
+		filters []Filter
+		rows    int
+		records [][]string
+	}{
+		{
+			name:    "first page",
+			data:    data,
+			limit:   2,
+			rows:    4,
+			records: [][]string{{"1", "ok"}, {"2", "failed"}},
+		},
+		{
+			name:    "offset",
+			data:    data,
+			offset:  3,
+			limit:   2,
+			rows:    4,
+			records: [][]string{{"4", "ok"}},
+		},
+		{
+			name:    "offset past the end",
+			data:    data,
+			offset:  10,
+			limit:   2,
+			rows:    4,
+			records: [][]string{},
+		},
+		{
+			name:    "filter",
+			data:    data,
+			offset:  1,
+			limit:   10,
+			filters: []Filter{{Column: "status", Value: "ok"}},
+			rows:    3,
+			records: [][]string{{"3", " ok "}, {"4", "ok"}},
+		},
+		{
+			name:    "filter on a missing column",
+			data:    data,
+			limit:   10,
+			filters: []Filter{{Column: "state", Value: "ok"}},
+			records: [][]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			page, err := ReadPage(strings.NewReader(tc.data), tc.offset, tc.limit, tc.filters...)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"id", "status"}, page.Header)
+			assert.Equal(t, tc.rows, page.Rows)
+			assert.Equal(t, tc.records, page.Records)
+		})
+	}
+}
+
+func TestReadPage_Invalid(t *testing.T) {
+	page, err := ReadPage(strings.NewReader(""), 0, 10)
+	assert.NoError(t, err)
+	assert.Nil(t, page.Header)
+	assert.Zero(t, page.Rows)
+
+	_, err = ReadPage(strings.NewReader("id\n\"1\n"), 0, 10)
+	assert.ErrorIs(t, err, ErrSyntax)
+}
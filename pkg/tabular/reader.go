@@ -0,0 +1,187 @@
+package tabular
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrSyntax is returned for malformed quoted fields
+var ErrSyntax = errors.New("malformed csv")
+
+// utf8BOM is written by spreadsheet software at the start of UTF-8 files
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// sniffLen is the size of the start of the file the delimiter is detected from
+const sniffLen = 64 << 10
+
+// SyntaxError reports the line of a malformed record
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s: line %d: %s", ErrSyntax, e.Line, e.Msg)
+}
+
+func (e *SyntaxError) Is(target error) bool {
+	return target == ErrSyntax
+}
+
+// Reader reads the records of a delimited text file. It skips the UTF-8 byte order mark,
+// accepts LF, CRLF and CR line endings and reports the line every record starts at.
+// Blank lines are skipped.
+type Reader struct {
+	r     *bufio.Reader
+	comma rune
+	line  int
+}
+
+// NewReader returns a reader of the records separated by comma.
+// If comma is zero the delimiter is detected from the first line, ',' or ';'.
+func NewReader(r io.Reader, comma rune) (*Reader, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	if prefix, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		if _, err := br.Discard(len(utf8BOM)); err != nil {
+			return nil, err
+		}
+	}
+	if comma == 0 {
+		head, err := br.Peek(sniffLen)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return nil, err
+		}
+		comma = Detect(head)
+	}
+	return &Reader{r: br, comma: comma, line: 1}, nil
+}
+
+// Detect returns the delimiter of the first line of data, ';' if it has more semicolons than commas
+// outside of the quoted fields, ',' otherwise
+func Detect(data []byte) rune {
+	commas, semicolons := 0, 0
+	quoted := false
+	for _, b := range data {
+		switch {
+		case b == '"':
+			quoted = !quoted
+		case quoted:
+		case b == ',':
+			commas++
+		case b == ';':
+			semicolons++
+		case b == '\n' || b == '\r':
+			if semicolons > commas {
+				return ';'
+			}
+			return ','
+		}
+	}
+	if semicolons > commas {
+		return ';'
+	}
+	return ','
+}
+
+// Comma returns the delimiter of the records
+func (r *Reader) Comma() rune {
+	return r.comma
+}
+
+// Read returns the next record and the line it starts at, io.EOF after the last record
+func (r *Reader) Read() ([]string, int, error) {
+	// skip blank lines
+	for {
+		c, _, err := r.r.ReadRune()
+		if err != nil {
+			return nil, 0, err
+		}
+		if c == '\n' || c == '\r' {
+			r.newLine(c)
+			continue
+		}
+		if err := r.r.UnreadRune(); err != nil {
+			return nil, 0, err
+		}
+		break
+	}
+
+	start := r.line
+	var (
+		record []string
+		field  strings.Builder
+		// quoted is set inside of a quoted field, closed after its closing quote
+		quoted, closed bool
+	)
+	for {
+		c, size, err := r.r.ReadRune()
+		if err == io.EOF {
+			if quoted {
+				return nil, start, &SyntaxError{Line: start, Msg: "unterminated quoted field"}
+			}
+			return append(record, field.String()), start, nil
+		}
+		if err != nil {
+			return nil, start, err
+		}
+		if c == utf8.RuneError && size == 1 {
+			return nil, start, &SyntaxError{Line: r.line, Msg: "invalid UTF-8, the file must be UTF-8 encoded"}
+		}
+		switch {
+		case quoted:
+			if c == '"' {
+				next, _, err := r.r.ReadRune()
+				if err == nil && next == '"' {
+					field.WriteRune('"')
+					continue
+				}
+				if err == nil {
+					if err := r.r.UnreadRune(); err != nil {
+						return nil, start, err
+					}
+				}
+				quoted, closed = false, true
+				continue
+			}
+			if c == '\n' || c == '\r' {
+				r.newLine(c)
+				// line endings of quoted fields are normalized to LF
+				field.WriteRune('\n')
+				continue
+			}
+			field.WriteRune(c)
+		case c == r.comma:
+			record = append(record, field.String())
+			field.Reset()
+			closed = false
+		case c == '\n' || c == '\r':
+			r.newLine(c)
+			return append(record, field.String()), start, nil
+		case closed:
+			return nil, start, &SyntaxError{Line: r.line, Msg: "unexpected character after a quoted field"}
+		case c == '"':
+			if field.Len() > 0 {
+				return nil, start, &SyntaxError{Line: r.line, Msg: "bare quote in a non-quoted field"}
+			}
+			quoted = true
+		default:
+			field.WriteRune(c)
+		}
+	}
+}
+
+// newLine counts a line ending, CRLF is a single one
+func (r *Reader) newLine(c rune) {
+	r.line++
+	if c != '\r' {
+		return
+	}
+	if next, err := r.r.Peek(1); err == nil && next[0] == '\n' {
+		_, _ = r.r.Discard(1)
+	}
+}
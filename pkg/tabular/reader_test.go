@@ -0,0 +1,72 @@
+package tabular
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRecord struct {
+	line   int
+	fields []string
+}
+
+func readAll(t *testing.T, data string, comma rune) ([]testRecord, error) {
+	reader, err := NewReader(strings.NewReader(data), comma)
+	assert.NoError(t, err)
+	var records []testRecord
+	for {
+		record, line, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, testRecord{line: line, fields: record})
+	}
+}
+
+func TestReader_Read(t *testing.T) {
+	records, err := readAll(t, "\xef\xbb\xbfid,name\r\n1,\"a, \"\"b\"\"\"\r\n\r\n2,\"multi\r\nline\"\n3,c", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []testRecord{
+		{1, []string{"id", "name"}},
+		{2, []string{"1", `a, "b"`}},
+		{4, []string{"2", "multi\nline"}},
+		{6, []string{"3", "c"}},
+	}, records)
+
+	records, err = readAll(t, "id;value\r1;2,5\r", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []testRecord{{1, []string{"id", "value"}}, {2, []string{"1", "2,5"}}}, records)
+
+	records, err = readAll(t, "a\tb\n1\t2\n", '\t')
+	assert.NoError(t, err)
+	assert.Equal(t, []testRecord{{1, []string{"a", "b"}}, {2, []string{"1", "2"}}}, records)
+}
+
+func TestReader_Errors(t *testing.T) {
+	for data, line := range map[string]int{
+		"a,b\n1,\"2\n":     2,
+		"a,b\n1,2\"x\"\n":  2,
+		"a,b\n1,\"2\"x\n":  2,
+		"a,b\n\n1,\xff\n":  3,
+		"a,b\n\"1\n\n2\"x": 4,
+	} {
+		_, err := readAll(t, data, 0)
+		assert.ErrorIs(t, err, ErrSyntax, data)
+		var syntaxErr *SyntaxError
+		if assert.ErrorAs(t, err, &syntaxErr, data) {
+			assert.Equal(t, line, syntaxErr.Line, data)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, ',', Detect([]byte("a,b;c\n1;2;3;4")))
+	assert.Equal(t, ';', Detect([]byte("a;b;\"c,d,e\"\n1,2,3")))
+	assert.Equal(t, ',', Detect(nil))
+}
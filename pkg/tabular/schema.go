@@ -0,0 +1,259 @@
+package tabular
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Column types
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+)
+
+// MaxViolations caps the violations reported for a single file
+const MaxViolations = 100
+
+// Column describes a column of the header
+type Column struct {
+	Name string
+	// Type of the values: string (default), integer, number or boolean
+	Type string
+	// Required columns must have a value in every row
+	Required bool
+}
+
+// Schema describes a CSV file. The columns are looked up in the header by name,
+// the file may have other columns as well.
+type Schema struct {
+	// Delimiter of the fields, detected from the header if zero
+	Delimiter rune
+	Columns   []Column
+	// MinRows and MaxRows limit the number of rows without the header, zero disables a limit
+	MinRows int
+	MaxRows int
+}
+
+// Violation is a problem found in a file. Line is zero for the problems of the whole file,
+// Column is empty for the problems of a whole row.
+type Violation struct {
+	File    string
+	Line    int
+	Column  string
+	Message string
+}
+
+func (v Violation) String() string {
+	location := v.File
+	if v.Line > 0 {
+		location += ":" + strconv.Itoa(v.Line)
+	}
+	if v.Column != "" {
+		location += ": column '" + v.Column + "'"
+	}
+	return location + ": " + v.Message
+}
+
+// Report is the outcome of the validation of a file
+type Report struct {
+	// Rows is the number of rows without the header
+	Rows       int
+	Violations []Violation
+	// Truncated is set when the violations have been capped at MaxViolations
+	Truncated bool
+}
+
+// Valid reports whether the file has no violations
+func (r *Report) Valid() bool {
+	return len(r.Violations) == 0
+}
+
+func (r *Report) add(v Violation) {
+	if len(r.Violations) >= MaxViolations {
+		r.Truncated = true
+		return
+	}
+	r.Violations = append(r.Violations, v)
+}
+
+// Check verifies the schema itself
+func (s Schema) Check() error {
+	if s.MinRows < 0 || s.MaxRows < 0 || (s.MaxRows > 0 && s.MinRows > s.MaxRows) {
+		return fmt.Errorf("invalid row limits %d..%d", s.MinRows, s.MaxRows)
+	}
+	switch s.Delimiter {
+	case 0, ',', ';', '\t', '|':
+	default:
+		return fmt.Errorf("unsupported delimiter '%c'", s.Delimiter)
+	}
+	seen := make(map[string]struct{}, len(s.Columns))
+	for _, c := range s.Columns {
+		if c.Name == "" {
+			return errors.New("empty column name")
+		}
+		if _, ok := seen[c.Name]; ok {
+			return fmt.Errorf("duplicate column '%s'", c.Name)
+		}
+		seen[c.Name] = struct{}{}
+		switch c.Type {
+		case "", TypeString, TypeInteger, TypeNumber, TypeBoolean:
+		default:
+			return fmt.Errorf("column '%s': unknown type '%s'", c.Name, c.Type)
+		}
+	}
+	return nil
+}
+
+// Validate reads the file from r and checks it against the schema. If w is not nil
+// the file is written into it normalized: UTF-8 without the byte order mark, comma separated, LF line endings.
+// The returned error is set only if the file could not be read or written, problems of the content are violations.
+func (s Schema) Validate(name string, r io.Reader, w io.Writer) (*Report, error) {
+	report := &Report{}
+	reader, err := NewReader(r, s.Delimiter)
+	if err != nil {
+		return nil, err
+	}
+	var writer *csv.Writer
+	if w != nil {
+		writer = csv.NewWriter(w)
+	}
+
+	header, _, err := reader.Read()
+	if err == io.EOF {
+		report.add(Violation{File: name, Message: "empty file, a header is expected"})
+		return report, nil
+	}
+	if err != nil {
+		return syntaxViolation(report, name, err)
+	}
+	index := make(map[string]int, len(header))
+	for i, column := range header {
+		index[strings.TrimSpace(column)] = i
+	}
+	positions := make([]int, len(s.Columns))
+	for i, c := range s.Columns {
+		pos, ok := index[c.Name]
+		if !ok {
+			report.add(Violation{File: name, Line: 1, Column: c.Name, Message: "missing in the header"})
+			pos = -1
+		}
+		positions[i] = pos
+	}
+	if writer != nil {
+		if err := writer.Write(header); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		record, line, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return syntaxViolation(report, name, err)
+		}
+		report.Rows++
+		if len(record) != len(header) {
+			report.add(Violation{File: name, Line: line, Message: fmt.Sprintf("has %d fields, the header has %d", len(record), len(header))})
+		}
+		for i, c := range s.Columns {
+			pos := positions[i]
+			if pos < 0 {
+				continue
+			}
+			value := ""
+			if pos < len(record) {
+				value = strings.TrimSpace(record[pos])
+			}
+			if msg := c.check(value); msg != "" {
+				report.add(Violation{File: name, Line: line, Column: c.Name, Message: msg})
+			}
+		}
+		if writer != nil {
+			if err := writer.Write(record); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if s.MinRows > 0 && report.Rows < s.MinRows {
+		report.add(Violation{File: name, Message: fmt.Sprintf("has %d rows, at least %d expected", report.Rows, s.MinRows)})
+	}
+	if s.MaxRows > 0 && report.Rows > s.MaxRows {
+		report.add(Violation{File: name, Message: fmt.Sprintf("has %d rows, at most %d allowed", report.Rows, s.MaxRows)})
+	}
+	if writer != nil {
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// NormalizeFile validates the file and replaces it with the normalized one if it is valid
+func (s Schema) NormalizeFile(dir string, name string) (*Report, error) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	out, err := ioutil.TempFile(filepath.Dir(path), ".normalize_*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(out.Name())
+
+	report, err := s.Validate(name, in, out)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || !report.Valid() {
+		return report, err
+	}
+	return report, os.Rename(out.Name(), path)
+}
+
+func syntaxViolation(report *Report, name string, err error) (*Report, error) {
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return nil, err
+	}
+	report.add(Violation{File: name, Line: syntaxErr.Line, Message: syntaxErr.Msg})
+	return report, nil
+}
+
+// check returns the reason the value doesn't match the column, empty if it does
+func (c Column) check(value string) string {
+	if value == "" {
+		if c.Required {
+			return "value is required"
+		}
+		return ""
+	}
+	switch c.Type {
+	case TypeInteger:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Sprintf("'%s' is not an integer", value)
+		}
+	case TypeNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Sprintf("'%s' is not a number", value)
+		}
+	case TypeBoolean:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Sprintf("'%s' is not a boolean", value)
+		}
+	}
+	return ""
+}
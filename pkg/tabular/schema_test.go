@@ -0,0 +1,107 @@
+package tabular
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSchema = Schema{
+	Columns: []Column{
+		{Name: "id", Type: TypeInteger, Required: true},
+		{Name: "weight", Type: TypeNumber},
+		{Name: "active", Type: TypeBoolean},
+	},
+	MinRows: 1,
+	MaxRows: 3,
+}
+
+func TestSchema_Validate(t *testing.T) {
+	var out bytes.Buffer
+	report, err := testSchema.Validate("racks.csv", strings.NewReader("\xef\xbb\xbfname;id;weight;active\r\n\"a;b\";1;2.5;true\r\nc;2;;\r\n"), &out)
+	assert.NoError(t, err)
+	assert.True(t, report.Valid(), report.Violations)
+	assert.Equal(t, 2, report.Rows)
+	assert.Equal(t, "name,id,weight,active\na;b,1,2.5,true\nc,2,,\n", out.String())
+
+	report, err = testSchema.Validate("racks.csv", strings.NewReader("id,active,extra\n1,yes,x\n,true\nx,false,y\n1,true,x\n"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.Rows)
+	assert.Equal(t, []Violation{
+		{File: "racks.csv", Line: 1, Column: "weight", Message: "missing in the header"},
+		{File: "racks.csv", Line: 2, Column: "active", Message: "'yes' is not a boolean"},
+		{File: "racks.csv", Line: 3, Message: "has 2 fields, the header has 3"},
+		{File: "racks.csv", Line: 3, Column: "id", Message: "value is required"},
+		{File: "racks.csv", Line: 4, Column: "id", Message: "'x' is not an integer"},
+		{File: "racks.csv", Message: "has 4 rows, at most 3 allowed"},
+	}, report.Violations)
+	assert.Equal(t, "racks.csv:4: column 'id': 'x' is not an integer", report.Violations[4].String())
+
+	idSchema := Schema{Columns: testSchema.Columns[:1], MinRows: 1, MaxRows: 3}
+	for data, violation := range map[string]Violation{
+		"":                    {File: "f.csv", Message: "empty file, a header is expected"},
+		"id\n":                {File: "f.csv", Message: "has 0 rows, at least 1 expected"},
+		"id\n1\n\"2\n":        {File: "f.csv", Line: 3, Message: "unterminated quoted field"},
+		"id\n1\n2\n3\n4\n5\n": {File: "f.csv", Message: "has 5 rows, at most 3 allowed"},
+	} {
+		report, err := idSchema.Validate("f.csv", strings.NewReader(data), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []Violation{violation}, report.Violations, data)
+	}
+}
+
+func TestSchema_Validate_Truncated(t *testing.T) {
+	data := "id\n" + strings.Repeat("x\n", MaxViolations+10)
+	report, err := Schema{Columns: []Column{{Name: "id", Type: TypeInteger}}}.Validate("f.csv", strings.NewReader(data), nil)
+	assert.NoError(t, err)
+	assert.Len(t, report.Violations, MaxViolations)
+	assert.True(t, report.Truncated)
+}
+
+func TestSchema_NormalizeFile(t *testing.T) {
+	schema := Schema{Columns: testSchema.Columns[:2]}
+	dir := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "racks"), os.ModePerm))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "racks", "r.csv"), []byte("id;weight\r\n1;2\r\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bad.csv"), []byte("id;weight\r\nx;2\r\n"), 0644))
+
+	report, err := schema.NormalizeFile(dir, "racks/r.csv")
+	assert.NoError(t, err)
+	assert.True(t, report.Valid())
+	data, err := ioutil.ReadFile(filepath.Join(dir, "racks", "r.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "id,weight\n1,2\n", string(data))
+
+	report, err = schema.NormalizeFile(dir, "bad.csv")
+	assert.NoError(t, err)
+	assert.False(t, report.Valid())
+	data, err = ioutil.ReadFile(filepath.Join(dir, "bad.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "id;weight\r\nx;2\r\n", string(data))
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	_, err = schema.NormalizeFile(dir, "missing.csv")
+	assert.Error(t, err)
+}
+
+func TestSchema_Check(t *testing.T) {
+	assert.NoError(t, testSchema.Check())
+	assert.NoError(t, Schema{}.Check())
+	for _, s := range []Schema{
+		{MinRows: 5, MaxRows: 2},
+		{MaxRows: -1},
+		{Delimiter: 'x'},
+		{Columns: []Column{{Name: ""}}},
+		{Columns: []Column{{Name: "a"}, {Name: "a"}}},
+		{Columns: []Column{{Name: "a", Type: "date"}}},
+	} {
+		assert.Error(t, s.Check(), s)
+	}
+}
@@ -0,0 +1,255 @@
+package tabular
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	xlsxWorkbook      = "xl/workbook.xml"
+	xlsxWorkbookRels  = "xl/_rels/workbook.xml.rels"
+	xlsxSharedStrings = "xl/sharedStrings.xml"
+	// maxXLSXPartSize caps the uncompressed size of a part of the workbook
+	maxXLSXPartSize = 512 << 20
+)
+
+type xlsxWorkbookXML struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelsXML struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText is a plain or a rich text, the text of every run is concatenated
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxRow struct {
+	Cells []struct {
+		Ref    string   `xml:"r,attr"`
+		Type   string   `xml:"t,attr"`
+		Value  string   `xml:"v"`
+		Inline xlsxText `xml:"is"`
+	} `xml:"c"`
+}
+
+// ConvertXLSX writes the sheet of the workbook as CSV, the first sheet if the name is empty.
+// The first row is the header, shorter rows are padded to its length and empty rows are skipped.
+// Shared and inline strings are resolved, booleans are written as true and false, numbers as stored.
+func ConvertXLSX(r io.ReaderAt, size int64, sheet string, w io.Writer) error {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("%w: not an xlsx workbook", ErrInvalidFile)
+	}
+	parts := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		parts[f.Name] = f
+	}
+
+	sheetPart, err := xlsxSheetPart(parts, sheet)
+	if err != nil {
+		return err
+	}
+	var shared []string
+	if part, ok := parts[xlsxSharedStrings]; ok {
+		var sst struct {
+			Items []xlsxText `xml:"si"`
+		}
+		if err := decodePart(part, &sst); err != nil {
+			return err
+		}
+		shared = make([]string, 0, len(sst.Items))
+		for _, item := range sst.Items {
+			shared = append(shared, item.String())
+		}
+	}
+
+	file, err := sheetPart.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidFile, err)
+	}
+	defer file.Close()
+	decoder := xml.NewDecoder(io.LimitReader(file, maxXLSXPartSize))
+	writer := csv.NewWriter(w)
+	width := -1
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidFile, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+		var row xlsxRow
+		if err := decoder.DecodeElement(&row, &start); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidFile, err)
+		}
+		record, err := xlsxRecord(row, shared)
+		if err != nil {
+			return err
+		}
+		if len(record) == 0 {
+			continue
+		}
+		if width < 0 {
+			width = len(record)
+		}
+		for len(record) < width {
+			record = append(record, "")
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// xlsxSheetPart finds the part of the named sheet through the relationships of the workbook
+func xlsxSheetPart(parts map[string]*zip.File, sheet string) (*zip.File, error) {
+	workbookPart, ok := parts[xlsxWorkbook]
+	if !ok {
+		return nil, fmt.Errorf("%w: not an xlsx workbook", ErrInvalidFile)
+	}
+	var workbook xlsxWorkbookXML
+	if err := decodePart(workbookPart, &workbook); err != nil {
+		return nil, err
+	}
+	rid := ""
+	names := make([]string, 0, len(workbook.Sheets))
+	for _, s := range workbook.Sheets {
+		names = append(names, s.Name)
+		if rid == "" && (sheet == "" || s.Name == sheet) {
+			rid = s.RID
+		}
+	}
+	if rid == "" {
+		return nil, fmt.Errorf("%w: sheet '%s' not found, the workbook has: %s", ErrInvalidFile, sheet, strings.Join(names, ", "))
+	}
+
+	var rels xlsxRelsXML
+	if relsPart, ok := parts[xlsxWorkbookRels]; ok {
+		if err := decodePart(relsPart, &rels); err != nil {
+			return nil, err
+		}
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID != rid {
+			continue
+		}
+		target := path.Join("xl", rel.Target)
+		if strings.HasPrefix(rel.Target, "/") {
+			target = strings.TrimPrefix(rel.Target, "/")
+		}
+		if part, ok := parts[target]; ok {
+			return part, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: the part of sheet '%s' not found", ErrInvalidFile, sheet)
+}
+
+// xlsxRecord returns the values of the row in the column order, the columns are taken from the cell references
+func xlsxRecord(row xlsxRow, shared []string) ([]string, error) {
+	record := make([]string, 0, len(row.Cells))
+	empty := true
+	for _, c := range row.Cells {
+		column := len(record)
+		if c.Ref != "" {
+			var err error
+			if column, err = xlsxColumn(c.Ref); err != nil {
+				return nil, err
+			}
+		}
+		value := c.Value
+		switch c.Type {
+		case "s":
+			i, err := strconv.Atoi(strings.TrimSpace(c.Value))
+			if err != nil || i < 0 || i >= len(shared) {
+				return nil, fmt.Errorf("%w: cell %s: invalid shared string", ErrInvalidFile, c.Ref)
+			}
+			value = shared[i]
+		case "inlineStr":
+			value = c.Inline.String()
+		case "b":
+			value = strconv.FormatBool(strings.TrimSpace(c.Value) == "1")
+		}
+		for len(record) < column {
+			record = append(record, "")
+		}
+		if column < len(record) {
+			record[column] = value
+		} else {
+			record = append(record, value)
+		}
+		empty = empty && value == ""
+	}
+	if empty {
+		return nil, nil
+	}
+	return record, nil
+}
+
+// xlsxColumn returns the zero based column of a cell reference, e.g. 2 for C7
+func xlsxColumn(ref string) (int, error) {
+	column := 0
+	letters := 0
+	for _, c := range strings.ToUpper(ref) {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		column = column*26 + int(c-'A'+1)
+		letters++
+	}
+	if letters == 0 || letters > 3 {
+		return 0, fmt.Errorf("%w: invalid cell reference '%s'", ErrInvalidFile, ref)
+	}
+	return column - 1, nil
+}
+
+func decodePart(part *zip.File, v interface{}) error {
+	file, err := part.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidFile, err)
+	}
+	defer file.Close()
+	if err := xml.NewDecoder(io.LimitReader(file, maxXLSXPartSize)).Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("%w: %s: %s", ErrInvalidFile, part.Name, err)
+	}
+	return nil
+}
@@ -7,6 +7,9 @@ export default class FilesUploadComponent extends Component {
         super(props);
 
         this.onFileChange = this.onFileChange.bind(this);
+        this.onAlgorithmChange = this.onAlgorithmChange.bind(this);
+        this.onParametersChange = this.onParametersChange.bind(this);
+        this.onSheetChange = this.onSheetChange.bind(this);
         this.onSubmit = this.onSubmit.bind(this);
 
         this.state = {
@@ -14,13 +17,39 @@ export default class FilesUploadComponent extends Component {
             isReady: false,
             fileCollection: '',
             optimizationData: [],        
+            algorithms: [],
+            algorithm: '',
+            parameters: '',
+            sheet: '',
         }
     }
 
+    componentDidMount() {
+        axios.get("/api/v1/algorithms")
+        .then((response) => {
+            this.setState({algorithms: response.data.algorithms, algorithm: response.data.default})
+        })
+        .catch(() => {
+            this.setState({algorithms: []})
+        })
+    }
+
     onFileChange(e) {
         this.setState({ fileCollection: e.target.files })
     }
 
+    onAlgorithmChange(e) {
+        this.setState({ algorithm: e.target.value })
+    }
+
+    onParametersChange(e) {
+        this.setState({ parameters: e.target.value })
+    }
+
+    onSheetChange(e) {
+        this.setState({ sheet: e.target.value })
+    }
+
     onSubmit(e) {
         e.preventDefault()
 
@@ -28,6 +57,15 @@ export default class FilesUploadComponent extends Component {
         for (const key of Object.keys(this.state.fileCollection)) {
             formData.append('file', this.state.fileCollection[key])
         }
+        if (this.state.algorithm) {
+            formData.append('algorithm', this.state.algorithm)
+        }
+        if (this.state.parameters.trim()) {
+            formData.append('parameters', this.state.parameters)
+        }
+        if (this.state.sheet.trim()) {
+            formData.append('sheet', this.state.sheet.trim())
+        }
         this.setState({isReady: false})
         axios.post("/api/v1/upload", formData, {})
         .then((response) => {
@@ -44,8 +82,21 @@ export default class FilesUploadComponent extends Component {
                 <div className="row" style={ {margin: '20px'}}>
                     <form onSubmit={this.onSubmit} >
                         <div className="form-group" style={ {padding: '10px'}}>
-                            <input type="file" name="fileCollection" onChange={this.onFileChange} multiple />
+                            <input type="file" name="fileCollection" accept=".csv,.tsv,.json,.xlsx,.zip,.gz,.tgz,.zst,.tar" onChange={this.onFileChange} multiple />
                         </div>    
+                        { this.state.algorithms.length > 0 &&
+                            <div className="form-group" style={ {padding: '10px'}}>
+                                <select className="form-control" value={this.state.algorithm} onChange={this.onAlgorithmChange}>
+                                    { this.state.algorithms.map((a) => <option key={a.id} value={a.id}>{a.id}{a.description ? " - " + a.description : ""}</option>) }
+                                </select>
+                            </div>
+                        }
+                        <div className="form-group" style={ {padding: '10px'}}>
+                            <input type="text" className="form-control" placeholder='Parameters, e.g. {"seed": 42}' value={this.state.parameters} onChange={this.onParametersChange} />
+                        </div>
+                        <div className="form-group" style={ {padding: '10px'}}>
+                            <input type="text" className="form-control" placeholder='Sheet of the xlsx files, the first one by default' value={this.state.sheet} onChange={this.onSheetChange} />
+                        </div>
                         <div className="form-group" style={ {padding: '10px'}}>
                                 <button className="btn btn-primary" type="submit">Start optimization</button>
                         </div> 
@@ -63,6 +114,11 @@ export default class FilesUploadComponent extends Component {
             <div>
                 <div>Error: {this.state.error.message}</div>
                 <div>Script response: {this.state.error.response.data.text}</div>
+                { this.state.error.response.data.violations &&
+                    <ul>
+                        { this.state.error.response.data.violations.map((v, i) =>
+                            <li key={i}>{v.file}{v.line ? ":" + v.line : ""}{v.column ? " (" + v.column + ")" : ""}: {v.message}</li>) }
+                    </ul> }
             </div>            
             ) 
     }
@@ -80,7 +136,10 @@ export default class FilesUploadComponent extends Component {
             <div>
                 <div>
                     <ul>
-                        <li>Filename: {this.state.optimizationData.filename}</li>
+                        <li>Algorithm: {this.state.optimizationData.algorithm}</li>
+                    </ul>
+                    <ul>
+                        <li>Filename: {this.state.optimizationData.filename}{this.state.optimizationData.cached ? " (cached result)" : ""}</li>
                     </ul>
                     <ul>
                         <li>Location: {this.state.optimizationData.location}</li>
@@ -88,11 +147,42 @@ export default class FilesUploadComponent extends Component {
                     <ul>
                         <li>ETag: {this.state.optimizationData.etag}</li>
                     </ul>
+                    <ul>
+                        <li><a href={"/api/v1/results/" + this.state.optimizationData.filename}>Download archive</a></li>
+                        <li><a href={"/api/v1/results/" + this.state.optimizationData.filename + "/output"}>Download def_output.csv</a></li>
+                        <li><a href={"/api/v1/results/" + this.state.optimizationData.filename + "?format=json"}>Download as JSON</a></li>
+                        <li><a href={"/api/v1/results/" + this.state.optimizationData.filename + "?format=xlsx"}>Download as Excel</a></li>
+                    </ul>
+                    { this.showSummary() }
                 </div>
             </div>
             )
     }
 
+    showSummary() {
+        const summary = this.state.optimizationData.summary
+        if (!summary) {
+            return null
+        }
+        const kpis = summary.kpis || {}
+        const warnings = summary.warnings || []
+        return (
+            <div>
+                <ul>
+                    { summary.status && <li>Status: {summary.status}</li> }
+                    { summary.objective !== undefined && <li>Objective: {summary.objective}</li> }
+                    { summary.iterations !== undefined && <li>Iterations: {summary.iterations}</li> }
+                </ul>
+                <ul>
+                    { Object.keys(kpis).sort().map((name) => <li key={name}>{name}: {kpis[name]}</li>) }
+                </ul>
+                <ul>
+                    { warnings.map((warning, i) => <li key={i}>Warning: {warning}</li>) }
+                </ul>
+            </div>
+            )
+    }
+
     showResult() {
         if (this.state.error) {
             if (this.state.error.response.data.text) {